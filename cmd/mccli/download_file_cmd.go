@@ -19,6 +19,11 @@ var downloadFileCommand = cli.Command{
 			Name:  "project, proj, p",
 			Usage: "The project to download the file from",
 		},
+		cli.IntFlag{
+			Name:  "parallel, n",
+			Value: 3,
+			Usage: "Number of simultaneous ranged requests to download the file with, defaults to 3",
+		},
 	},
 	Action: downloadFileCLI,
 }
@@ -31,9 +36,10 @@ func downloadFileCLI(c *cli.Context) {
 
 	path := filepath.Clean(c.Args()[0])
 	project := c.String("project")
+	numThreads := getNumThreads(c)
 	client := mc.NewClientAPI()
 
-	if err := client.DownloadFile(project, path); err != nil {
+	if err := client.DownloadFile(project, path, numThreads); err != nil {
 		fmt.Println("File download failed:", err)
 		os.Exit(1)
 	}
@@ -109,7 +109,7 @@ func (s *projectStatusCmd) displayStatusUploads(projectID string) {
 func (s *projectStatusCmd) getUploads(projectID string) ([]mcstoreapi.UploadEntry, error) {
 	config.Set("apikey", "test")
 	r, body, errs := s.client.Get(mcstoreapi.Url("/upload/test")).End()
-	if err := mcstoreapi.ToError(r, errs); err != nil {
+	if err := mcstoreapi.ResponseToError(r, body, errs); err != nil {
 		return nil, err
 	}
 
@@ -88,7 +88,7 @@ func (c *ClientAPI) DownloadProject(projectName string, numThreads int) error {
 		return err
 	}
 
-	projectDownloader := newProjectDownloader(projectDB, c)
+	projectDownloader := newProjectDownloader(projectDB, c, numThreads)
 	projectDownloader.downloadProject()
 	return nil
 }
@@ -97,7 +97,7 @@ func (c *ClientAPI) DownloadDirectory(projectName string, path string, recursive
 	return nil
 }
 
-func (c *ClientAPI) DownloadFile(projectName string, path string) error {
+func (c *ClientAPI) DownloadFile(projectName string, path string, numThreads int) error {
 	projectDB, err := ProjectOpener.OpenProjectDB(projectName)
 	if err != nil {
 		return err
@@ -114,7 +114,7 @@ func (c *ClientAPI) DownloadFile(projectName string, path string) error {
 		return ErrInvalidProjectFilePath
 	}
 
-	downloader := newDownloader(projectDB, c)
+	downloader := newDownloader(projectDB, c, numThreads)
 	return downloader.downloadFile(normalizedPath)
 }
 
@@ -19,12 +19,14 @@ type downloader struct {
 	file       *File
 	serverFile *mcstoreapi.ServerFile
 	c          *ClientAPI
+	numThreads int
 }
 
-func newDownloader(projectDB ProjectDB, clientAPI *ClientAPI) *downloader {
+func newDownloader(projectDB ProjectDB, clientAPI *ClientAPI, numThreads int) *downloader {
 	return &downloader{
-		projectDB: projectDB,
-		c:         clientAPI,
+		projectDB:  projectDB,
+		c:          clientAPI,
+		numThreads: numThreads,
 	}
 }
 
@@ -54,7 +56,7 @@ func (d *downloader) downloadFile(path string) error {
 
 func (d *downloader) downloadNewFile(path string) error {
 	project := d.projectDB.Project()
-	if err := d.c.serverAPI.DownloadFile(project.ProjectID, d.serverFile.ID, path); err != nil {
+	if err := d.c.serverAPI.DownloadFile(project.ProjectID, d.serverFile, path, d.numThreads); err != nil {
 		fmt.Println("serverAPI.DownloadFile error", err)
 		return err
 	}
@@ -121,9 +123,9 @@ type projectDownloader struct {
 	files      []fentry
 }
 
-func newProjectDownloader(projectDB ProjectDB, clientAPI *ClientAPI) *projectDownloader {
+func newProjectDownloader(projectDB ProjectDB, clientAPI *ClientAPI, numThreads int) *projectDownloader {
 	return &projectDownloader{
-		downloader: newDownloader(projectDB, clientAPI),
+		downloader: newDownloader(projectDB, clientAPI, numThreads),
 		files:      []fentry{},
 	}
 }
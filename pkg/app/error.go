@@ -18,20 +18,30 @@ var (
 	// ErrNoAccess Access to item not allowed
 	ErrNoAccess = errors.New("no access")
 
+	// ErrForbidden Credentials are valid but don't allow the request
+	ErrForbidden = errors.New("forbidden")
+
 	// ErrInternal Internal fatal error
 	ErrInternal = errors.New("internal error")
 
 	// ErrCreate Create of object failed
 	ErrCreate = errors.New("unable to create")
 
+	// ErrRateLimited Too many requests from a client
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// ErrUnavailable Service is shutting down or otherwise unable to take the request
+	ErrUnavailable = errors.New("service unavailable")
+
 	// ErrUnclassified error is not classified
 	ErrUnclassified = errors.New("unclassified error")
 )
 
 // Error holds the error code and additional messages.
 type Error struct {
-	Err     error  // Error code
-	Message string // Message related to error
+	Err     error                  // Error code
+	Message string                 // Message related to error
+	Details map[string]interface{} // Additional information for clients
 }
 
 // Implement error interface.
@@ -51,15 +61,33 @@ func newError(err error, msg string) *Error {
 	}
 }
 
+// WithDetail adds a detail to the error and returns the error.
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// A causer is an error that wraps an underlying error code.
+type causer interface {
+	Cause() error
+}
+
 // Is returns true if the particular error code in an Error
 // is equal to the expected error. This is useful comparing
-// without having to cast and unpack.
+// without having to cast and unpack. Errors with a Cause
+// method are compared using their cause.
 func Is(err error, what error) bool {
-	if e, ok := err.(*Error); ok {
+	switch e := err.(type) {
+	case *Error:
 		return e.Err == what
+	case causer:
+		return e.Cause() == what
+	default:
+		return err == what
 	}
-
-	return err == what
 }
 
 // Errorf takes and error, a message string and a set of arguments and produces
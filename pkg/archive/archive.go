@@ -0,0 +1,213 @@
+// Package archive streams files into zip or tar.gz archives. Archives are
+// written directly to an io.Writer so they can be sent to a client without
+// creating temporary files.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+)
+
+// Format is the type of archive to create.
+type Format string
+
+const (
+	// Zip creates a zip archive. ZIP64 records are written automatically
+	// when an archive or an entry is larger than 4GB.
+	Zip Format = "zip"
+
+	// TarGz creates a gzip compressed tar archive.
+	TarGz Format = "tar.gz"
+)
+
+// ManifestName is the name of the entry listing the files that could not be
+// added to an archive. It is only written if files were skipped.
+const ManifestName = "SKIPPED_FILES.txt"
+
+// ParseFormat converts a format name to a Format. An empty name is a Zip.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "zip":
+		return Zip, nil
+	case "tar.gz", "tgz":
+		return TarGz, nil
+	default:
+		return "", app.Errorf(app.ErrInvalid, "unknown archive format %s", name)
+	}
+}
+
+// ContentType returns the mime type for the format.
+func (f Format) ContentType() string {
+	if f == TarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// Extension returns the file extension for the format.
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Skipped is a file that wasn't added to an archive.
+type Skipped struct {
+	Name   string
+	Reason string
+}
+
+// A Writer writes files into an archive. Files that can't be read are
+// recorded in a manifest rather than failing the whole archive.
+type Writer struct {
+	format  Format
+	zw      *zip.Writer
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	names   map[string]bool
+	skipped []Skipped
+}
+
+// NewWriter creates a new Writer that writes an archive in format to w.
+func NewWriter(w io.Writer, format Format) *Writer {
+	aw := &Writer{
+		format: format,
+		names:  make(map[string]bool),
+	}
+
+	if format == TarGz {
+		aw.gz = gzip.NewWriter(w)
+		aw.tw = tar.NewWriter(aw.gz)
+	} else {
+		aw.zw = zip.NewWriter(w)
+	}
+
+	return aw
+}
+
+// AddFile adds the file at filePath to the archive as name. If the file can't
+// be opened it is recorded as skipped and AddFile returns nil. An error is only
+// returned when writing to the archive fails, in which case the archive is
+// unusable.
+func (w *Writer) AddFile(name, filePath string, mtime time.Time) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		w.Skip(name, "file could not be read")
+		return nil
+	}
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err != nil || !finfo.Mode().IsRegular() {
+		w.Skip(name, "file could not be read")
+		return nil
+	}
+
+	return w.addEntry(w.uniqueName(name), f, finfo.Size(), mtime)
+}
+
+// Skip records a file that wasn't added to the archive.
+func (w *Writer) Skip(name, reason string) {
+	w.skipped = append(w.skipped, Skipped{Name: name, Reason: reason})
+}
+
+// Skipped returns the files that weren't added to the archive.
+func (w *Writer) Skipped() []Skipped {
+	return w.skipped
+}
+
+// Close writes the manifest of skipped files, if there are any, and finishes
+// the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if len(w.skipped) != 0 {
+		if err := w.writeManifest(); err != nil {
+			return err
+		}
+	}
+
+	if w.format == TarGz {
+		if err := w.tw.Close(); err != nil {
+			return err
+		}
+		return w.gz.Close()
+	}
+	return w.zw.Close()
+}
+
+// addZipEntry copies r into the zip archive as name.
+func (w *Writer) addZipEntry(name string, r io.Reader, mtime time.Time) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	header.SetModTime(mtime)
+
+	entry, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, r)
+	return err
+}
+
+// addTarEntry copies size bytes from r into the tar archive as name.
+func (w *Writer) addTarEntry(name string, r io.Reader, size int64, mtime time.Time) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  mtime,
+		Typeflag: tar.TypeReg,
+	}
+
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(w.tw, r, size)
+	return err
+}
+
+// writeManifest adds an entry listing all the skipped files.
+func (w *Writer) writeManifest() error {
+	var manifest string
+	for _, s := range w.skipped {
+		manifest += fmt.Sprintf("%s: %s\n", s.Name, s.Reason)
+	}
+
+	return w.addString(w.uniqueName(ManifestName), manifest)
+}
+
+// addString adds an entry holding contents.
+func (w *Writer) addString(name, contents string) error {
+	return w.addEntry(name, strings.NewReader(contents), int64(len(contents)), time.Now())
+}
+
+// addEntry copies size bytes from r into the archive as name.
+func (w *Writer) addEntry(name string, r io.Reader, size int64, mtime time.Time) error {
+	if w.format == TarGz {
+		return w.addTarEntry(name, r, size, mtime)
+	}
+	return w.addZipEntry(name, r, mtime)
+}
+
+// uniqueName cleans name and makes sure it doesn't match an existing entry.
+// Duplicate names have a counter added before the extension.
+func (w *Writer) uniqueName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	unique := name
+	ext := path.Ext(name)
+	for i := 1; w.names[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	w.names[unique] = true
+	return unique
+}
@@ -0,0 +1,145 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// zipContents reads a zip archive and returns a map of entry names to contents.
+func zipContents(data []byte) map[string]string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	Expect(err).To(BeNil())
+	contents := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		Expect(err).To(BeNil())
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(b)
+	}
+	return contents
+}
+
+// tarGzContents reads a tar.gz archive and returns a map of entry names to contents.
+func tarGzContents(data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	Expect(err).To(BeNil())
+	tr := tar.NewReader(gz)
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).To(BeNil())
+		b, _ := ioutil.ReadAll(tr)
+		contents[header.Name] = string(b)
+	}
+	return contents
+}
+
+var _ = Describe("Archive", func() {
+	var (
+		dir   string
+		file1 string
+		file2 string
+		now   = time.Now()
+	)
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "archive")
+		file1 = filepath.Join(dir, "file1")
+		file2 = filepath.Join(dir, "file2")
+		ioutil.WriteFile(file1, []byte("hello"), 0644)
+		ioutil.WriteFile(file2, []byte("world"), 0644)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("ParseFormat Method Tests", func() {
+		It("Should default to zip", func() {
+			f, err := ParseFormat("")
+			Expect(err).To(BeNil())
+			Expect(f).To(Equal(Zip))
+		})
+
+		It("Should accept tgz as tar.gz", func() {
+			f, err := ParseFormat("tgz")
+			Expect(err).To(BeNil())
+			Expect(f).To(Equal(TarGz))
+		})
+
+		It("Should reject unknown formats", func() {
+			_, err := ParseFormat("rar")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Zip Archive Tests", func() {
+		It("Should write files and a manifest of skipped files", func() {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, Zip)
+			Expect(w.AddFile("proj/a/one.txt", file1, now)).To(BeNil())
+			Expect(w.AddFile("proj/b/two.txt", file2, now)).To(BeNil())
+			Expect(w.AddFile("proj/missing.txt", filepath.Join(dir, "nofile"), now)).To(BeNil())
+			w.Skip("abc-123", "no access")
+			Expect(w.Close()).To(BeNil())
+
+			contents := zipContents(buf.Bytes())
+			Expect(contents).To(HaveLen(3))
+			Expect(contents["proj/a/one.txt"]).To(Equal("hello"))
+			Expect(contents["proj/b/two.txt"]).To(Equal("world"))
+			Expect(contents[ManifestName]).To(ContainSubstring("proj/missing.txt"))
+			Expect(contents[ManifestName]).To(ContainSubstring("abc-123: no access"))
+		})
+
+		It("Should not write a manifest when nothing was skipped", func() {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, Zip)
+			Expect(w.AddFile("one.txt", file1, now)).To(BeNil())
+			Expect(w.Close()).To(BeNil())
+			Expect(zipContents(buf.Bytes())).NotTo(HaveKey(ManifestName))
+		})
+
+		It("Should rename duplicates and strip parent directory references", func() {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, Zip)
+			Expect(w.AddFile("one.txt", file1, now)).To(BeNil())
+			Expect(w.AddFile("one.txt", file2, now)).To(BeNil())
+			Expect(w.AddFile("../../etc/passwd", file1, now)).To(BeNil())
+			Expect(w.Close()).To(BeNil())
+
+			contents := zipContents(buf.Bytes())
+			Expect(contents["one.txt"]).To(Equal("hello"))
+			Expect(contents["one (1).txt"]).To(Equal("world"))
+			Expect(contents).To(HaveKey("etc/passwd"))
+		})
+	})
+
+	Describe("Tar.gz Archive Tests", func() {
+		It("Should write files and a manifest of skipped files", func() {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, TarGz)
+			Expect(w.AddFile("proj/a/one.txt", file1, now)).To(BeNil())
+			Expect(w.AddFile("proj/missing.txt", filepath.Join(dir, "nofile"), now)).To(BeNil())
+			Expect(w.Close()).To(BeNil())
+
+			contents := tarGzContents(buf.Bytes())
+			Expect(contents).To(HaveLen(2))
+			Expect(contents["proj/a/one.txt"]).To(Equal("hello"))
+			Expect(contents[ManifestName]).To(ContainSubstring("proj/missing.txt"))
+		})
+	})
+})
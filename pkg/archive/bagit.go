@@ -0,0 +1,247 @@
+package archive
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// BagItVersion is the version of the BagIt specification (RFC 8493) that
+// bags are written in.
+const BagItVersion = "1.0"
+
+// A BagInfoField is a line in a bag's bag-info.txt. Labels can repeat.
+type BagInfoField struct {
+	Label string
+	Value string
+}
+
+// A Bag writes a BagIt bag into a zip or tar.gz archive. Everything is under
+// a top level directory with the bag's name, and the files added are the
+// payload under its data directory. The tag files are written by Close, after
+// the payload, so that a bag can be streamed without knowing its contents up
+// front.
+type Bag struct {
+	w         *Writer
+	name      string
+	info      []BagInfoField
+	md5       []string
+	sha256    []string
+	size      int64
+	count     int
+	tagMD5    []string
+	tagSHA256 []string
+	skipped   []Skipped
+}
+
+// NewBag creates a Bag named name that writes an archive in format to w. The
+// info fields are written to bag-info.txt along with the bagging date and the
+// payload size.
+func NewBag(w io.Writer, format Format, name string, info []BagInfoField) *Bag {
+	return &Bag{
+		w:    NewWriter(w, format),
+		name: name,
+		info: info,
+	}
+}
+
+// AddFile adds the file at filePath to the payload as name. When checksum,
+// the MD5 computed when the file was stored, is given the file is checked
+// against it before it is written, and a file that has changed since then is
+// skipped rather than put in the bag. The manifests are computed from what is
+// written. Files that can't be read are skipped as with Writer.
+func (b *Bag) AddFile(name, filePath, checksum string, mtime time.Time) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		b.Skip(name, "file could not be read")
+		return nil
+	}
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err != nil || !finfo.Mode().IsRegular() {
+		b.Skip(name, "file could not be read")
+		return nil
+	}
+
+	if checksum != "" {
+		switch sum, err := md5Sum(f); {
+		case err != nil:
+			b.Skip(name, "file could not be read")
+			return nil
+		case !strings.EqualFold(sum, checksum):
+			b.Skip(name, "checksum mismatch")
+			return nil
+		}
+	}
+
+	entry := b.w.uniqueName(path.Join(b.name, "data", name))
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	r := io.TeeReader(f, io.MultiWriter(md5Hash, sha256Hash))
+	if err := b.w.addEntry(entry, r, finfo.Size(), mtime); err != nil {
+		return err
+	}
+
+	payloadPath := strings.TrimPrefix(entry, b.name+"/")
+	b.md5 = append(b.md5, manifestLine(hex.EncodeToString(md5Hash.Sum(nil)), payloadPath))
+	b.sha256 = append(b.sha256, manifestLine(hex.EncodeToString(sha256Hash.Sum(nil)), payloadPath))
+	b.size += finfo.Size()
+	b.count++
+	return nil
+}
+
+// md5Sum computes the MD5 of f and rewinds it so it can be read again.
+func md5Sum(f *os.File) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Skip records a file that wasn't added to the bag. Skipped files are listed
+// in a tag file rather than the payload, so that the payload only holds the
+// files that were asked for.
+func (b *Bag) Skip(name, reason string) {
+	b.skipped = append(b.skipped, Skipped{Name: name, Reason: reason})
+}
+
+// Skipped returns the files that weren't added to the bag.
+func (b *Bag) Skipped() []Skipped {
+	return b.skipped
+}
+
+// Close writes the tag files and finishes the archive. It does not close the
+// underlying writer.
+func (b *Bag) Close() error {
+	tags := []struct {
+		name     string
+		contents string
+	}{
+		{"bagit.txt", fmt.Sprintf("BagIt-Version: %s\nTag-File-Character-Encoding: UTF-8\n", BagItVersion)},
+		{"bag-info.txt", b.bagInfo()},
+		{"manifest-md5.txt", strings.Join(b.md5, "")},
+		{"manifest-sha256.txt", strings.Join(b.sha256, "")},
+	}
+
+	if len(b.skipped) != 0 {
+		var list string
+		for _, s := range b.skipped {
+			list += fmt.Sprintf("%s: %s\n", s.Name, s.Reason)
+		}
+		tags = append(tags, struct {
+			name     string
+			contents string
+		}{ManifestName, list})
+	}
+
+	for _, tag := range tags {
+		if err := b.addTag(tag.name, tag.contents); err != nil {
+			return err
+		}
+	}
+
+	if err := b.w.addString(path.Join(b.name, "tagmanifest-md5.txt"), strings.Join(b.tagMD5, "")); err != nil {
+		return err
+	}
+	if err := b.w.addString(path.Join(b.name, "tagmanifest-sha256.txt"), strings.Join(b.tagSHA256, "")); err != nil {
+		return err
+	}
+	return b.w.Close()
+}
+
+// addTag writes a tag file and adds it to the tag manifests.
+func (b *Bag) addTag(name, contents string) error {
+	if err := b.w.addString(path.Join(b.name, name), contents); err != nil {
+		return err
+	}
+
+	b.tagMD5 = append(b.tagMD5, manifestLine(hashString(md5.New(), contents), name))
+	b.tagSHA256 = append(b.tagSHA256, manifestLine(hashString(sha256.New(), contents), name))
+	return nil
+}
+
+// bagInfo returns the contents of bag-info.txt. Values that span lines are
+// continued on indented lines.
+func (b *Bag) bagInfo() string {
+	fields := append(append([]BagInfoField{}, b.info...),
+		BagInfoField{"Bagging-Date", time.Now().Format("2006-01-02")},
+		BagInfoField{"Bag-Size", bagSize(b.size)},
+		BagInfoField{"Payload-Oxum", fmt.Sprintf("%d.%d", b.size, b.count)},
+	)
+
+	var info string
+	for _, field := range fields {
+		value := strings.TrimSpace(strings.Replace(field.Value, "\r", "", -1))
+		if value == "" {
+			continue
+		}
+		info += fmt.Sprintf("%s: %s\n", field.Label, strings.Replace(value, "\n", "\n  ", -1))
+	}
+	return info
+}
+
+// DatasetBagInfo returns the bag-info.txt fields that describe a dataset.
+func DatasetBagInfo(dataset *schema.Dataset) []BagInfoField {
+	info := []BagInfoField{
+		{"External-Identifier", dataset.ID},
+		{"Title", dataset.Title},
+		{"External-Description", dataset.Description},
+	}
+
+	for _, author := range dataset.Authors {
+		info = append(info, BagInfoField{"Author", author})
+	}
+
+	info = append(info,
+		BagInfoField{"Keywords", strings.Join(dataset.Keywords, ", ")},
+		BagInfoField{"License", dataset.License},
+		BagInfoField{"Contact-Email", dataset.Owner},
+	)
+
+	if dataset.Published {
+		info = append(info, BagInfoField{"Date-Published", dataset.PublishedDate.Format("2006-01-02")})
+	}
+	return info
+}
+
+// manifestLine returns a manifest line for a path. Line breaks and percent
+// signs in the path are percent encoded as RFC 8493 requires.
+func manifestLine(checksum, filePath string) string {
+	encoded := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(filePath)
+	return fmt.Sprintf("%s  %s\n", checksum, encoded)
+}
+
+// hashString returns the hex encoded hash of s.
+func hashString(h hash.Hash, s string) string {
+	io.WriteString(h, s)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bagSize returns a size in the human readable form used for Bag-Size.
+func bagSize(size int64) string {
+	units := []string{"bytes", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
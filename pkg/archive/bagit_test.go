@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// validateBag checks every line of a bag's payload and tag manifests against
+// the contents of the bag, and returns the number of payload files.
+func validateBag(contents map[string]string, name string) int {
+	Expect(contents[name+"/bagit.txt"]).To(Equal("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"))
+
+	check := func(manifest string, hash func(string) string) int {
+		lines := strings.Split(strings.TrimSuffix(contents[name+"/"+manifest], "\n"), "\n")
+		for _, line := range lines {
+			parts := strings.SplitN(line, "  ", 2)
+			Expect(parts).To(HaveLen(2))
+			entry, ok := contents[name+"/"+parts[1]]
+			Expect(ok).To(BeTrue(), parts[1])
+			Expect(hash(entry)).To(Equal(parts[0]), parts[1])
+		}
+		return len(lines)
+	}
+
+	md5Hash := func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) }
+	sha256Hash := func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) }
+
+	files := check("manifest-md5.txt", md5Hash)
+	Expect(check("manifest-sha256.txt", sha256Hash)).To(Equal(files))
+	check("tagmanifest-md5.txt", md5Hash)
+	check("tagmanifest-sha256.txt", sha256Hash)
+	return files
+}
+
+var _ = Describe("Bag", func() {
+	var (
+		dir     string
+		file1   string
+		file2   string
+		now     = time.Now()
+		dataset = &schema.Dataset{
+			ID:            "ds1",
+			Title:         "Tensile tests",
+			Description:   "Line one\nLine two",
+			Authors:       []string{"A. Author", "B. Author"},
+			Keywords:      []string{"steel", "tensile"},
+			License:       "CC-BY-4.0",
+			Owner:         "test@mc.org",
+			Published:     true,
+			PublishedDate: time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC),
+		}
+	)
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "bag")
+		file1 = filepath.Join(dir, "file1")
+		file2 = filepath.Join(dir, "file2")
+		ioutil.WriteFile(file1, []byte("hello"), 0644)
+		ioutil.WriteFile(file2, []byte("world!"), 0644)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should write a valid tar.gz bag with the payload under data", func() {
+		var buf bytes.Buffer
+		bag := NewBag(&buf, TarGz, "dataset-ds1", DatasetBagInfo(dataset))
+		Expect(bag.AddFile("proj/a/one.txt", file1, "5d41402abc4b2a76b9719d911017c592", now)).To(BeNil())
+		Expect(bag.AddFile("proj/two.txt", file2, "", now)).To(BeNil())
+		Expect(bag.Close()).To(BeNil())
+
+		contents := tarGzContents(buf.Bytes())
+		Expect(contents["dataset-ds1/data/proj/a/one.txt"]).To(Equal("hello"))
+		Expect(contents["dataset-ds1/data/proj/two.txt"]).To(Equal("world!"))
+		Expect(contents).NotTo(HaveKey("dataset-ds1/" + ManifestName))
+		Expect(validateBag(contents, "dataset-ds1")).To(Equal(2))
+
+		info := contents["dataset-ds1/bag-info.txt"]
+		Expect(info).To(ContainSubstring("External-Identifier: ds1\n"))
+		Expect(info).To(ContainSubstring("Title: Tensile tests\n"))
+		Expect(info).To(ContainSubstring("External-Description: Line one\n  Line two\n"))
+		Expect(info).To(ContainSubstring("Author: A. Author\nAuthor: B. Author\n"))
+		Expect(info).To(ContainSubstring("Keywords: steel, tensile\n"))
+		Expect(info).To(ContainSubstring("Date-Published: 2015-06-01\n"))
+		Expect(info).To(ContainSubstring("Payload-Oxum: 11.2\n"))
+		Expect(info).To(ContainSubstring("Bag-Size: 11 bytes\n"))
+	})
+
+	It("Should skip a file that doesn't match its stored checksum", func() {
+		var buf bytes.Buffer
+		bag := NewBag(&buf, Zip, "bag", nil)
+		Expect(bag.AddFile("one.txt", file1, "0123456789abcdef0123456789abcdef", now)).To(BeNil())
+		Expect(bag.AddFile("two.txt", file2, "", now)).To(BeNil())
+		Expect(bag.Close()).To(BeNil())
+
+		contents := zipContents(buf.Bytes())
+		Expect(contents).NotTo(HaveKey("bag/data/one.txt"))
+		Expect(contents["bag/"+ManifestName]).To(Equal("one.txt: checksum mismatch\n"))
+		Expect(validateBag(contents, "bag")).To(Equal(1))
+	})
+
+	It("Should list skipped files in a tag file inside the bag", func() {
+		var buf bytes.Buffer
+		bag := NewBag(&buf, Zip, "bag", nil)
+		Expect(bag.AddFile("one.txt", file1, "", now)).To(BeNil())
+		Expect(bag.AddFile("missing.txt", filepath.Join(dir, "nofile"), "", now)).To(BeNil())
+		Expect(bag.Close()).To(BeNil())
+
+		contents := zipContents(buf.Bytes())
+		Expect(contents).NotTo(HaveKey(ManifestName))
+		Expect(contents["bag/"+ManifestName]).To(ContainSubstring("missing.txt"))
+		Expect(contents["bag/tagmanifest-md5.txt"]).To(ContainSubstring(ManifestName))
+		Expect(bag.Skipped()).To(HaveLen(1))
+		Expect(validateBag(contents, "bag")).To(Equal(1))
+	})
+
+	It("Should percent encode line breaks and percent signs in manifest paths", func() {
+		Expect(manifestLine("abc", "data/100%\nfile")).To(Equal("abc  data/100%25%0Afile\n"))
+	})
+})
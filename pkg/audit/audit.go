@@ -0,0 +1,115 @@
+// Package audit records who did what to the data in materials commons. Events
+// are written to the audit_events table and can be exported as CSV.
+package audit
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Actions that are recorded.
+const (
+	Download         = "download"
+	DownloadArchive  = "download_archive"
+	Upload           = "upload"
+	CreateUpload     = "create_upload"
+	DeleteUpload     = "delete_upload"
+	CreateProject    = "create_project"
+	Share            = "share"
+	CreateToken      = "create_token"
+	RevokeToken      = "revoke_token"
+	CreateDataset    = "create_dataset"
+	PublishDataset   = "publish_dataset"
+	UnpublishDataset = "unpublish_dataset"
+	Trash            = "trash"
+	RestoreTrash     = "restore_trash"
+	PurgeTrash       = "purge_trash"
+)
+
+// Results of an action.
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Anonymous is the actor for requests made without credentials.
+const Anonymous = "anonymous"
+
+// A Recorder records audit events.
+type Recorder interface {
+	Record(event schema.AuditEvent)
+}
+
+// dbRecorder records audit events in the database.
+type dbRecorder struct {
+	events dai.AuditEvents
+	files  dai.Files
+}
+
+// NewRecorder creates a Recorder that writes events using events. Events
+// for a file that don't name a project are given the file's project.
+func NewRecorder(events dai.AuditEvents, files dai.Files) Recorder {
+	return &dbRecorder{
+		events: events,
+		files:  files,
+	}
+}
+
+// Record writes an event to the audit log. Auditing never fails the action
+// being audited, so errors are logged rather than returned.
+func (r *dbRecorder) Record(event schema.AuditEvent) {
+	if event.ProjectID == "" && event.FileID != "" {
+		if project, err := r.files.GetProject(event.FileID); err == nil {
+			event.ProjectID = project.ID
+		}
+	}
+
+	if err := r.events.Insert(&event); err != nil {
+		app.Log.Error("Unable to record audit event", "actor", event.Actor, "action", event.Action,
+			"projectid", event.ProjectID, "fileid", event.FileID, "error", err)
+	}
+}
+
+// ResultOf returns Success if err is nil, otherwise it returns Failure.
+func ResultOf(err error) string {
+	if err != nil {
+		return Failure
+	}
+	return Success
+}
+
+// csvHeader is the first row of a CSV export.
+var csvHeader = []string{"time", "actor", "action", "project_id", "datafile_id", "host", "result", "detail"}
+
+// WriteCSV writes events as CSV with a header row. Times are written in RFC 3339
+// format in UTC.
+func WriteCSV(w io.Writer, events []schema.AuditEvent) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		row := []string{
+			event.Birthtime.UTC().Format(time.RFC3339),
+			event.Actor,
+			event.Action,
+			event.ProjectID,
+			event.FileID,
+			event.Host,
+			event.Result,
+			event.Detail,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/dai/mocks"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// savedEvents is an AuditEvents that keeps the events inserted into it.
+type savedEvents struct {
+	events []schema.AuditEvent
+}
+
+func (s *savedEvents) Insert(event *schema.AuditEvent) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *savedEvents) Query(query dai.AuditQuery) ([]schema.AuditEvent, error) {
+	return s.events, nil
+}
+
+var _ = Describe("Audit", func() {
+	Describe("Record Method Tests", func() {
+		var (
+			events   *savedEvents
+			mfiles   *mocks.Files
+			recorder Recorder
+		)
+
+		BeforeEach(func() {
+			events = &savedEvents{}
+			mfiles = mocks.NewMFiles()
+			recorder = NewRecorder(events, mfiles)
+		})
+
+		It("Should fill in the project for a file event", func() {
+			mfiles.On("GetProject", "file1").Return(&schema.Project{ID: "proj1"}, nil)
+			event := schema.NewAuditEvent("user1", Download, "10.0.0.1")
+			event.FileID = "file1"
+			recorder.Record(event)
+			Expect(events.events).To(HaveLen(1))
+			Expect(events.events[0].ProjectID).To(Equal("proj1"))
+			Expect(events.events[0].Actor).To(Equal("user1"))
+		})
+
+		It("Should record the event when the project can't be found", func() {
+			var nilProject *schema.Project
+			mfiles.On("GetProject", "file1").Return(nilProject, app.ErrNotFound)
+			event := schema.NewAuditEvent(Anonymous, Download, "10.0.0.1")
+			event.FileID = "file1"
+			recorder.Record(event)
+			Expect(events.events).To(HaveLen(1))
+			Expect(events.events[0].ProjectID).To(Equal(""))
+		})
+
+		It("Should not look up the project when it is given", func() {
+			event := schema.NewAuditEvent("user1", Share, "10.0.0.1")
+			event.ProjectID = "proj1"
+			event.FileID = "file1"
+			recorder.Record(event)
+			Expect(events.events[0].ProjectID).To(Equal("proj1"))
+			mfiles.AssertNotCalled(GinkgoT(), "GetProject", "file1")
+		})
+	})
+
+	Describe("WriteCSV Method Tests", func() {
+		It("Should write a header and a row for each event", func() {
+			event := schema.NewAuditEvent("user1", Upload, "10.0.0.1")
+			event.Birthtime = time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+			event.ProjectID = "proj1"
+			event.FileID = "file1"
+			event.Result = Success
+			event.Detail = `name "with" quotes, and comma`
+
+			var buf bytes.Buffer
+			Expect(WriteCSV(&buf, []schema.AuditEvent{event})).To(BeNil())
+			Expect(buf.String()).To(Equal("time,actor,action,project_id,datafile_id,host,result,detail\n" +
+				`2015-06-01T12:00:00Z,user1,upload,proj1,file1,10.0.0.1,success,"name ""with"" quotes, and comma"` + "\n"))
+		})
+	})
+})
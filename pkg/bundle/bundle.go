@@ -0,0 +1,132 @@
+// Package bundle exports a project to a portable bundle and imports bundles
+// into another instance. A bundle is a gzipped tar file with these entries:
+//
+//	manifest.json      what the bundle holds, written first
+//	project.json       the project
+//	directories.json   the directories, each after its parent
+//	files.json         the files, including earlier versions
+//	records/TABLE.json the sample, process, note and tag records
+//	blobs/CHECKSUM     the file contents, one blob per checksum
+//
+// IDs in a bundle are the IDs in the instance it came from. On import every
+// entry gets a new ID, owners are mapped to users of the new instance, and
+// blobs that the new instance already has are shared rather than copied.
+package bundle
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Format identifies a bundle, and Version is the version of the format this
+// package writes and reads.
+const (
+	Format  = "mcstore-bundle"
+	Version = 1
+)
+
+// The names of the entries in a bundle.
+const (
+	manifestEntry    = "manifest.json"
+	projectEntry     = "project.json"
+	directoriesEntry = "directories.json"
+	filesEntry       = "files.json"
+	recordsPrefix    = "records/"
+	blobsPrefix      = "blobs/"
+)
+
+// A Manifest describes the contents of a bundle.
+type Manifest struct {
+	Format      string         `json:"format"`
+	Version     int            `json:"version"`
+	Created     time.Time      `json:"created"`
+	Project     Project        `json:"project"`
+	Directories int            `json:"directories"`
+	Files       int            `json:"files"`
+	Blobs       int            `json:"blobs"`
+	Records     map[string]int `json:"records,omitempty"`
+}
+
+// A Project is the project a bundle was exported from.
+type Project struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Owner       string    `json:"owner"`
+	Birthtime   time.Time `json:"birthtime"`
+	MTime       time.Time `json:"mtime"`
+}
+
+// A Directory is a directory in the project. Name is the full path, starting
+// with the project name. The top level directory has no Parent.
+type Directory struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Parent    string    `json:"parent"`
+	Birthtime time.Time `json:"birthtime"`
+	MTime     time.Time `json:"mtime"`
+	ATime     time.Time `json:"atime"`
+}
+
+// A File is a version of a file in the project. Parent is the previous version.
+// The contents are in the blob for Checksum.
+type File struct {
+	ID          string           `json:"id"`
+	DirectoryID string           `json:"directory_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Owner       string           `json:"owner"`
+	Checksum    string           `json:"checksum"`
+	Size        int64            `json:"size"`
+	MediaType   schema.MediaType `json:"mediatype"`
+	Parent      string           `json:"parent"`
+	Current     bool             `json:"current"`
+	Birthtime   time.Time        `json:"birthtime"`
+	MTime       time.Time        `json:"mtime"`
+
+	// usesID is the id the blob is stored under when exporting.
+	usesID string
+}
+
+// versionOrder sorts files so that each file comes after its parent, keeping
+// the order of files otherwise.
+func versionOrder(files []File) []File {
+	byID := make(map[string]int, len(files))
+	for i, file := range files {
+		byID[file.ID] = i
+	}
+
+	ordered := make([]File, 0, len(files))
+	added := make([]bool, len(files))
+	var add func(i int)
+	add = func(i int) {
+		if added[i] {
+			return
+		}
+		added[i] = true
+		if parent, ok := byID[files[i].Parent]; ok {
+			add(parent)
+		}
+		ordered = append(ordered, files[i])
+	}
+
+	for i := range files {
+		add(i)
+	}
+	return ordered
+}
+
+// newID creates a random (version 4) UUID, the same form as RethinkDB ids.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("unable to generate id: %s", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
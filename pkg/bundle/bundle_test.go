@@ -0,0 +1,263 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRecords exports a fixed set of tables and keeps what is imported.
+type fakeRecords struct {
+	tables   []Table
+	imported []Table
+}
+
+func (f *fakeRecords) Export(projectID string, itemIDs []string) ([]Table, error) {
+	return f.tables, nil
+}
+
+func (f *fakeRecords) Import(table Table) error {
+	f.imported = append(f.imported, table)
+	return nil
+}
+
+var _ = Describe("Bundle", func() {
+	var (
+		src, dst         *dai.MemStore
+		srcRoot, dstRoot string
+		project          *schema.Project
+		savedMCDIR       string
+	)
+
+	checksum := func(data string) string {
+		sum := md5.Sum([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+
+	// addFile adds a file and its blob to the source store.
+	addFile := func(name, data, dirID, parent string) *schema.File {
+		f := schema.NewFile(name, "alice@mc.org")
+		f.Checksum = checksum(data)
+		f.Size = int64(len(data))
+		f.Uploaded = f.Size
+		f.Parent = parent
+		file, err := src.Files().Insert(&f, dirID, project.ID)
+		Expect(err).To(BeNil())
+
+		dir := app.MCDir.FileDirFromPath(srcRoot, file.ID)
+		Expect(os.MkdirAll(dir, 0700)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(dir, file.ID), []byte(data), 0600)).To(BeNil())
+		return file
+	}
+
+	export := func(records Records) (*bytes.Buffer, *Manifest) {
+		config.Set("MCDIR", srcRoot)
+		var buf bytes.Buffer
+		manifest, err := NewExporter(src, records).Export(project.ID, &buf)
+		Expect(err).To(BeNil())
+		return &buf, manifest
+	}
+
+	importInto := func(r io.Reader, records Records, options ImportOptions) (*ImportResult, error) {
+		config.Set("MCDIR", dstRoot)
+		return NewImporter(dst, records).Import(r, options)
+	}
+
+	BeforeEach(func() {
+		var err error
+		savedMCDIR = config.GetString("MCDIR")
+		srcRoot, err = ioutil.TempDir("", "bundle-src")
+		Expect(err).To(BeNil())
+		dstRoot, err = ioutil.TempDir("", "bundle-dst")
+		Expect(err).To(BeNil())
+
+		src = dai.NewMemStore()
+		dst = dai.NewMemStore()
+		p := schema.NewProject("proj", "alice@mc.org")
+		project, err = src.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		config.Set("MCDIR", savedMCDIR)
+		os.RemoveAll(srcRoot)
+		os.RemoveAll(dstRoot)
+	})
+
+	It("Should import an exported project with new ids and owners", func() {
+		d := schema.NewDirectory("proj/data", "alice@mc.org", project.ID, project.DataDir)
+		dir, err := src.Dirs().Insert(&d)
+		Expect(err).To(BeNil())
+
+		v1 := addFile("a.txt", "first", project.DataDir, "")
+		Expect(src.Files().UpdateFields(v1.ID, map[string]interface{}{schema.FileFields.Current(): false})).To(BeNil())
+		v2 := addFile("a.txt", "second", project.DataDir, v1.ID)
+		addFile("b.txt", "second", dir.ID, "")
+		f := schema.NewFile("partial.txt", "alice@mc.org")
+		_, err = src.Files().Insert(&f, dir.ID, project.ID)
+		Expect(err).To(BeNil())
+
+		buf, manifest := export(nil)
+		Expect(manifest.Directories).To(Equal(2))
+		Expect(manifest.Files).To(Equal(3))
+		Expect(manifest.Blobs).To(Equal(2))
+
+		result, err := importInto(buf, nil, ImportOptions{Name: "copy", Owners: map[string]string{"alice@mc.org": "bob@mc.org"}})
+		Expect(err).To(BeNil())
+		Expect(result.Directories).To(Equal(1))
+		Expect(result.Files).To(Equal(3))
+		Expect(result.Blobs).To(Equal(2))
+		Expect(result.Shared).To(Equal(1))
+
+		newProject := result.Project
+		Expect(newProject.ID).NotTo(Equal(project.ID))
+		Expect(newProject.Name).To(Equal("copy"))
+		Expect(newProject.Owner).To(Equal("bob@mc.org"))
+
+		newDir, err := dst.Dirs().ByPath("copy/data", newProject.ID)
+		Expect(err).To(BeNil())
+		Expect(newDir.Owner).To(Equal("bob@mc.org"))
+		Expect(newDir.Parent).To(Equal(newProject.DataDir))
+
+		current, err := dst.Files().ByPath("a.txt", newProject.DataDir)
+		Expect(err).To(BeNil())
+		Expect(current.ID).NotTo(Equal(v2.ID))
+		Expect(current.Checksum).To(Equal(v2.Checksum))
+		Expect(current.Parent).NotTo(BeEmpty())
+		previous, err := dst.Files().ByID(current.Parent)
+		Expect(err).To(BeNil())
+		Expect(previous.Checksum).To(Equal(v1.Checksum))
+		Expect(previous.Current).To(BeFalse())
+
+		data, err := ioutil.ReadFile(app.MCDir.FilePath(current.FileID()))
+		Expect(err).To(BeNil())
+		Expect(string(data)).To(Equal("second"))
+	})
+
+	It("Should share blobs the instance already has", func() {
+		addFile("a.txt", "data", project.DataDir, "")
+		buf, _ := export(nil)
+
+		p := schema.NewProject("other", "bob@mc.org")
+		other, err := dst.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+		f := schema.NewFile("same.txt", "bob@mc.org")
+		f.Checksum = checksum("data")
+		existing, err := dst.Files().Insert(&f, other.DataDir, other.ID)
+		Expect(err).To(BeNil())
+
+		result, err := importInto(buf, nil, ImportOptions{})
+		Expect(err).To(BeNil())
+		Expect(result.Blobs).To(Equal(0))
+		Expect(result.Shared).To(Equal(1))
+
+		file, err := dst.Files().ByPath("a.txt", result.Project.DataDir)
+		Expect(err).To(BeNil())
+		Expect(file.UsesID).To(Equal(existing.ID))
+	})
+
+	It("Should not import over an existing project", func() {
+		buf, _ := export(nil)
+		p := schema.NewProject("proj", "alice@mc.org")
+		_, err := dst.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+
+		_, err = importInto(buf, nil, ImportOptions{})
+		Expect(app.Is(err, app.ErrExists)).To(BeTrue())
+	})
+
+	It("Should refuse to export a file whose blob is missing", func() {
+		file := addFile("a.txt", "data", project.DataDir, "")
+		Expect(os.Remove(filepath.Join(app.MCDir.FileDirFromPath(srcRoot, file.ID), file.ID))).To(BeNil())
+
+		config.Set("MCDIR", srcRoot)
+		_, err := NewExporter(src, nil).Export(project.ID, ioutil.Discard)
+		Expect(app.Is(err, app.ErrNotFound)).To(BeTrue())
+	})
+
+	It("Should reject a blob that doesn't match its checksum", func() {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		Expect(writeJSON(tw, manifestEntry, Manifest{Format: Format, Version: Version})).To(BeNil())
+		sum := checksum("data")
+		Expect(tw.WriteHeader(&tar.Header{Name: blobsPrefix + sum, Mode: 0600, Size: 3})).To(BeNil())
+		_, err := tw.Write([]byte("bad"))
+		Expect(err).To(BeNil())
+		Expect(tw.Close()).To(BeNil())
+		Expect(gz.Close()).To(BeNil())
+
+		_, err = importInto(&buf, nil, ImportOptions{})
+		Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+	})
+
+	It("Should remap the ids and owners in records", func() {
+		file := addFile("a.txt", "data", project.DataDir, "")
+		records := &fakeRecords{
+			tables: []Table{
+				{Name: "samples", Docs: []map[string]interface{}{{"id": "s1", "name": "sample", "owner": "alice@mc.org"}}},
+				{Name: "sample2datafile", Docs: []map[string]interface{}{{"id": "j1", "sample_id": "s1", "datafile_id": file.ID}}},
+				{Name: "tag2item", Docs: []map[string]interface{}{{"id": "t1", "tag_id": "test", "item_id": project.ID}}},
+				{Name: "tags", Docs: []map[string]interface{}{{"id": "test", "owner": "alice@mc.org"}}},
+			},
+		}
+		buf, manifest := export(records)
+		Expect(manifest.Records).To(HaveKeyWithValue("samples", 1))
+
+		imported := &fakeRecords{}
+		result, err := importInto(buf, imported, ImportOptions{Owner: "bob@mc.org"})
+		Expect(err).To(BeNil())
+		Expect(imported.imported).To(HaveLen(4))
+
+		newFile, err := dst.Files().ByPath("a.txt", result.Project.DataDir)
+		Expect(err).To(BeNil())
+		tag := imported.imported[0]
+		Expect(tag.Name).To(Equal("tags"))
+		Expect(tag.Docs[0]["id"]).To(Equal("test"))
+		sample := imported.imported[1].Docs[0]
+		Expect(sample["id"]).NotTo(Equal("s1"))
+		Expect(sample["owner"]).To(Equal("bob@mc.org"))
+		join := imported.imported[2].Docs[0]
+		Expect(join["sample_id"]).To(Equal(sample["id"]))
+		Expect(join["datafile_id"]).To(Equal(newFile.ID))
+		tagJoin := imported.imported[3].Docs[0]
+		Expect(tagJoin["tag_id"]).To(Equal("test"))
+		Expect(tagJoin["item_id"]).To(Equal(result.Project.ID))
+	})
+
+	It("Should refuse records the store can't hold", func() {
+		records := &fakeRecords{
+			tables: []Table{{Name: "notes", Docs: []map[string]interface{}{{"id": "n1"}}}},
+		}
+		buf, _ := export(records)
+
+		_, err := importInto(buf, nil, ImportOptions{})
+		Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+		_, err = dst.Projects().ByName(project.Name, project.Owner)
+		Expect(app.Is(err, app.ErrNotFound)).To(BeTrue())
+	})
+
+	It("Should skip records the store can't hold when asked to", func() {
+		records := &fakeRecords{
+			tables: []Table{{Name: "notes", Docs: []map[string]interface{}{{"id": "n1"}}}},
+		}
+		buf, _ := export(records)
+
+		result, err := importInto(buf, nil, ImportOptions{SkipRecords: true})
+		Expect(err).To(BeNil())
+		Expect(result.Skipped).To(Equal(1))
+	})
+})
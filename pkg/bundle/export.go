@@ -0,0 +1,259 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// An Exporter writes projects to bundles.
+type Exporter struct {
+	store   dai.Store
+	records Records
+}
+
+// NewExporter creates an Exporter for the projects in store. If records is
+// nil the bundles only hold the directories, files and blobs.
+func NewExporter(store dai.Store, records Records) *Exporter {
+	return &Exporter{
+		store:   store,
+		records: records,
+	}
+}
+
+// blob is a stored file to write to the bundle.
+type blob struct {
+	checksum string
+	path     string
+	size     int64
+}
+
+// Export writes the project as a bundle to w. Files that are still being
+// uploaded are left out. It fails if the blob for a file is missing, since the
+// bundle would be incomplete.
+func (e *Exporter) Export(projectID string, w io.Writer) (*Manifest, error) {
+	project, err := e.store.Projects().ByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, files, err := e.tree(project)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := blobsFor(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []Table
+	if e.records != nil {
+		itemIDs := make([]string, 0, len(dirs)+len(files))
+		for _, dir := range dirs {
+			itemIDs = append(itemIDs, dir.ID)
+		}
+		for _, file := range files {
+			itemIDs = append(itemIDs, file.ID)
+		}
+		if tables, err = e.records.Export(project.ID, itemIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := &Manifest{
+		Format:  Format,
+		Version: Version,
+		Created: time.Now(),
+		Project: Project{
+			ID:          project.ID,
+			Name:        project.Name,
+			Description: project.Description,
+			Owner:       project.Owner,
+			Birthtime:   project.Birthtime,
+			MTime:       project.MTime,
+		},
+		Directories: len(dirs),
+		Files:       len(files),
+		Blobs:       len(blobs),
+	}
+	if len(tables) != 0 {
+		manifest.Records = make(map[string]int)
+		for _, table := range tables {
+			manifest.Records[table.Name] = len(table.Docs)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	entries := []struct {
+		name  string
+		value interface{}
+	}{
+		{manifestEntry, manifest},
+		{projectEntry, manifest.Project},
+		{directoriesEntry, dirs},
+		{filesEntry, files},
+	}
+	for _, table := range tables {
+		entries = append(entries, struct {
+			name  string
+			value interface{}
+		}{recordsPrefix + table.Name + ".json", table.Docs})
+	}
+
+	for _, entry := range entries {
+		if err := writeJSON(tw, entry.name, entry.value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range blobs {
+		if err := writeBlob(tw, b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// tree walks the project's directories from the top, returning each directory
+// after its parent along with the files in them.
+func (e *Exporter) tree(project *schema.Project) ([]Directory, []File, error) {
+	var (
+		dirs  []Directory
+		files []File
+	)
+
+	top, err := e.store.Dirs().ByID(project.DataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queue := []schema.Directory{*top}
+	for len(queue) != 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		d := Directory{
+			ID:        dir.ID,
+			Name:      dir.Name,
+			Owner:     dir.Owner,
+			Birthtime: dir.Birthtime,
+			MTime:     dir.MTime,
+			ATime:     dir.ATime,
+		}
+		if dir.ID != top.ID {
+			d.Parent = dir.Parent
+		}
+		dirs = append(dirs, d)
+
+		dirFiles, err := e.store.Dirs().Files(dir.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, file := range dirFiles {
+			if file.Checksum == "" {
+				continue
+			}
+			files = append(files, File{
+				ID:          file.ID,
+				DirectoryID: dir.ID,
+				Name:        file.Name,
+				Description: file.Description,
+				Owner:       file.Owner,
+				Checksum:    file.Checksum,
+				Size:        file.Size,
+				MediaType:   file.MediaType,
+				Parent:      file.Parent,
+				Current:     file.Current,
+				Birthtime:   file.Birthtime,
+				MTime:       file.MTime,
+				usesID:      file.FileID(),
+			})
+		}
+
+		children, err := e.store.Dirs().Children(dir.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		queue = append(queue, children...)
+	}
+
+	return dirs, versionOrder(files), nil
+}
+
+// blobsFor returns one blob for each checksum in files.
+func blobsFor(files []File) ([]blob, error) {
+	seen := make(map[string]bool)
+	var blobs []blob
+	for _, file := range files {
+		if seen[file.Checksum] {
+			continue
+		}
+		seen[file.Checksum] = true
+
+		path := app.MCDir.FilePath(file.usesID)
+		finfo, err := os.Stat(path)
+		if err != nil {
+			return nil, app.Errorf(app.ErrNotFound, "blob for file %s (%s) is missing, run mcstore fsck", file.ID, file.Name)
+		}
+		blobs = append(blobs, blob{checksum: file.Checksum, path: path, size: finfo.Size()})
+	}
+	return blobs, nil
+}
+
+// writeJSON writes value as a JSON entry named name.
+func writeJSON(tw *tar.Writer, name string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
+
+// writeBlob copies a blob into the bundle.
+func writeBlob(tw *tar.Writer, b blob) error {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr := &tar.Header{
+		Name:    blobsPrefix + b.checksum,
+		Mode:    0600,
+		Size:    b.size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, b.size)
+	return err
+}
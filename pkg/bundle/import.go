@@ -0,0 +1,387 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// ImportOptions control how a bundle is imported.
+type ImportOptions struct {
+	// Name is the name of the new project. It defaults to the name of the
+	// exported project.
+	Name string
+
+	// Owners maps the owners in the bundle to users of this instance.
+	Owners map[string]string
+
+	// Owner is used for owners that aren't in Owners. When it is empty
+	// those owners are kept.
+	Owner string
+
+	// SkipRecords imports a bundle that has records into a store that has no
+	// Records, leaving the records out. Without it the import fails.
+	SkipRecords bool
+}
+
+// owner returns the user on this instance for an owner in the bundle.
+func (o ImportOptions) owner(owner string) string {
+	switch {
+	case o.Owners[owner] != "":
+		return o.Owners[owner]
+	case o.Owner != "":
+		return o.Owner
+	default:
+		return owner
+	}
+}
+
+// ImportResult describes what an import created.
+type ImportResult struct {
+	Project     *schema.Project
+	Directories int
+	Files       int
+	Blobs       int            // Blobs copied into MCDIR.
+	Shared      int            // Files that share a blob already in MCDIR.
+	Records     map[string]int // Records imported by table.
+	Skipped     int            // Records left out with SkipRecords.
+}
+
+// An Importer loads bundles into a store.
+type Importer struct {
+	store   dai.Store
+	records Records
+}
+
+// NewImporter creates an Importer for store. If records is nil, bundles with
+// records can only be imported with SkipRecords.
+func NewImporter(store dai.Store, records Records) *Importer {
+	return &Importer{
+		store:   store,
+		records: records,
+	}
+}
+
+// contents is a bundle read into memory, with its blobs staged under MCDIR.
+type contents struct {
+	manifest *Manifest
+	project  *Project
+	dirs     []Directory
+	files    []File
+	tables   map[string][]map[string]interface{}
+	blobs    map[string]string // Staged blob path by checksum.
+}
+
+// Import reads a bundle from r and creates it as a new project. Every entry
+// gets a new id. Blobs are checked against their checksum before anything is
+// created, and blobs this instance already has are shared. An error part way
+// through creating the project leaves what was already created in place.
+func (i *Importer) Import(r io.Reader, options ImportOptions) (*ImportResult, error) {
+	staging, err := ioutil.TempDir(app.MCDir.Path(), ".import")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	c, err := read(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.tables) != 0 && i.records == nil && !options.SkipRecords {
+		return nil, app.Errorf(app.ErrInvalid,
+			"bundle has sample, process, note and tag records, which this store can't hold")
+	}
+
+	name := options.Name
+	if name == "" {
+		name = c.project.Name
+	}
+	owner := options.owner(c.project.Owner)
+	if _, err := i.store.Projects().ByName(name, owner); err == nil {
+		return nil, app.Errorf(app.ErrExists, "%s already has a project named %s", owner, name)
+	} else if !app.Is(err, app.ErrNotFound) {
+		return nil, err
+	}
+
+	project := schema.NewProject(name, owner)
+	project.Description = c.project.Description
+	project.Birthtime = c.project.Birthtime
+	project.MTime = c.project.MTime
+	newProject, err := i.store.Projects().Insert(&project)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Project: newProject}
+	ids := map[string]string{c.project.ID: newProject.ID}
+
+	for _, dir := range c.dirs {
+		if dir.Parent == "" {
+			ids[dir.ID] = newProject.DataDir
+			continue
+		}
+
+		parent, ok := ids[dir.Parent]
+		if !ok {
+			return result, app.Errorf(app.ErrInvalid, "directory %s comes before its parent", dir.Name)
+		}
+
+		d := schema.NewDirectory(name+strings.TrimPrefix(dir.Name, c.project.Name), options.owner(dir.Owner),
+			newProject.ID, parent)
+		d.Birthtime, d.MTime, d.ATime = dir.Birthtime, dir.MTime, dir.ATime
+		newDir, err := i.store.Dirs().Insert(&d)
+		if err != nil {
+			return result, err
+		}
+		ids[dir.ID] = newDir.ID
+		result.Directories++
+	}
+
+	for _, file := range versionOrder(c.files) {
+		newID, err := i.importFile(file, c.blobs[file.Checksum], ids, newProject.ID, options, result)
+		if err != nil {
+			return result, app.Errorf(err, "file %s", file.Name)
+		}
+		ids[file.ID] = newID
+	}
+
+	if err := i.importRecords(c.tables, ids, options, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// importFile creates a file in its new directory. If a blob with its checksum
+// is already stored, the file uses it. Otherwise the staged blob is moved into
+// MCDIR under the file's new id.
+func (i *Importer) importFile(file File, staged string, ids map[string]string, projectID string,
+	options ImportOptions, result *ImportResult) (string, error) {
+	dirID, ok := ids[file.DirectoryID]
+	if !ok {
+		return "", app.Errorf(app.ErrInvalid, "unknown directory %s", file.DirectoryID)
+	}
+
+	f := schema.NewFile(file.Name, options.owner(file.Owner))
+	f.Description = file.Description
+	f.Checksum = file.Checksum
+	f.Size = file.Size
+	f.Uploaded = file.Size
+	f.MediaType = file.MediaType
+	f.Parent = ids[file.Parent]
+	f.Current = file.Current
+	f.Birthtime, f.MTime, f.ATime = file.Birthtime, file.MTime, file.MTime
+
+	switch match, err := i.store.Files().ByChecksum(file.Checksum); {
+	case err == nil:
+		f.UsesID = match.ID
+	case !app.Is(err, app.ErrNotFound):
+		return "", err
+	}
+
+	newFile, err := i.store.Files().Insert(&f, dirID, projectID)
+	if err != nil {
+		return "", err
+	}
+	result.Files++
+
+	if newFile.UsesID != "" {
+		result.Shared++
+		return newFile.ID, nil
+	}
+
+	dir := app.MCDir.FileDir(newFile.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if err := os.Rename(staged, filepath.Join(dir, newFile.ID)); err != nil {
+		return "", err
+	}
+	result.Blobs++
+	return newFile.ID, nil
+}
+
+// importRecords remaps and imports the records, in the order of recordTables.
+func (i *Importer) importRecords(docs map[string][]map[string]interface{}, ids map[string]string,
+	options ImportOptions, result *ImportResult) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	if i.records == nil {
+		for _, tableDocs := range docs {
+			result.Skipped += len(tableDocs)
+		}
+		return nil
+	}
+
+	var tables []Table
+	for _, name := range recordTables {
+		if len(docs[name]) != 0 {
+			tables = append(tables, Table{Name: name, Docs: docs[name]})
+		}
+	}
+
+	m := &remapper{ids: ids, owner: options.owner}
+	m.remap(tables)
+
+	result.Records = make(map[string]int)
+	for _, table := range tables {
+		if err := i.records.Import(table); err != nil {
+			return app.Errorf(err, "records for %s", table.Name)
+		}
+		result.Records[table.Name] = len(table.Docs)
+	}
+	return nil
+}
+
+// read reads and checks a bundle. Blobs are staged in the staging directory,
+// and must match their checksum. Every file must have its blob.
+func read(r io.Reader, staging string) (*contents, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, app.Errorf(app.ErrInvalid, "not a bundle: %s", err)
+	}
+	defer gz.Close()
+
+	c := &contents{
+		tables: make(map[string][]map[string]interface{}),
+		blobs:  make(map[string]string),
+	}
+	known := make(map[string]bool)
+	for _, name := range recordTables {
+		known[name] = true
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return c, c.check()
+		case err != nil:
+			return nil, app.Errorf(app.ErrInvalid, "bad bundle: %s", err)
+		case c.manifest == nil && hdr.Name != manifestEntry:
+			return nil, app.Errorf(app.ErrInvalid, "bundle doesn't start with %s", manifestEntry)
+		}
+
+		switch name := hdr.Name; {
+		case name == manifestEntry:
+			err = readManifest(tr, &c.manifest)
+		case name == projectEntry:
+			err = readJSON(tr, name, &c.project)
+		case name == directoriesEntry:
+			err = readJSON(tr, name, &c.dirs)
+		case name == filesEntry:
+			err = readJSON(tr, name, &c.files)
+		case strings.HasPrefix(name, recordsPrefix):
+			table := strings.TrimSuffix(strings.TrimPrefix(name, recordsPrefix), ".json")
+			if !known[table] {
+				return nil, app.Errorf(app.ErrInvalid, "bundle has records for unknown table %s", table)
+			}
+			var docs []map[string]interface{}
+			err = readJSON(tr, name, &docs)
+			c.tables[table] = docs
+		case strings.HasPrefix(name, blobsPrefix):
+			checksum := strings.TrimPrefix(name, blobsPrefix)
+			c.blobs[checksum], err = stageBlob(tr, staging, checksum)
+		default:
+			err = app.Errorf(app.ErrInvalid, "unknown entry %s", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// check makes sure the bundle had all its entries and that every file has a
+// blob.
+func (c *contents) check() error {
+	switch {
+	case c.manifest == nil:
+		return app.Errorf(app.ErrInvalid, "bundle is empty")
+	case c.project == nil:
+		return app.Errorf(app.ErrInvalid, "bundle has no %s", projectEntry)
+	case len(c.dirs) == 0:
+		return app.Errorf(app.ErrInvalid, "bundle has no directories")
+	}
+
+	for _, file := range c.files {
+		if c.blobs[file.Checksum] == "" {
+			return app.Errorf(app.ErrInvalid, "bundle has no blob for file %s", file.Name)
+		}
+	}
+
+	// Directories are created in order, each needing its parent.
+	sort.Stable(byParentFirst(c.dirs))
+	return nil
+}
+
+// byParentFirst moves the top level directory to the front. Exported bundles
+// already list every other directory after its parent.
+type byParentFirst []Directory
+
+func (d byParentFirst) Len() int           { return len(d) }
+func (d byParentFirst) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byParentFirst) Less(i, j int) bool { return d[i].Parent == "" && d[j].Parent != "" }
+
+// readManifest reads the manifest and checks that it is a bundle this package
+// can read.
+func readManifest(r io.Reader, manifest **Manifest) error {
+	if err := readJSON(r, manifestEntry, manifest); err != nil {
+		return err
+	}
+
+	switch m := *manifest; {
+	case m.Format != Format:
+		return app.Errorf(app.ErrInvalid, "not a bundle: format is %q", m.Format)
+	case m.Version > Version:
+		return app.Errorf(app.ErrInvalid, "bundle version %d is newer than %d", m.Version, Version)
+	}
+	return nil
+}
+
+// readJSON decodes the JSON entry name from r into value.
+func readJSON(r io.Reader, name string, value interface{}) error {
+	if err := json.NewDecoder(r).Decode(value); err != nil {
+		return app.Errorf(app.ErrInvalid, "bad %s: %s", name, err)
+	}
+	return nil
+}
+
+// stageBlob copies a blob to the staging directory and checks its checksum.
+func stageBlob(r io.Reader, staging, checksum string) (string, error) {
+	if checksum == "" || strings.ContainsAny(checksum, "/\\.") {
+		return "", app.Errorf(app.ErrInvalid, "bad blob name %q", checksum)
+	}
+
+	path := filepath.Join(staging, checksum)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(io.MultiWriter(f, hasher), r); err != nil {
+		return "", err
+	}
+
+	if hex.EncodeToString(hasher.Sum(nil)) != checksum {
+		return "", app.Errorf(app.ErrInvalid, "blob %s doesn't match its checksum", checksum)
+	}
+	return path, nil
+}
@@ -0,0 +1,137 @@
+package bundle
+
+import (
+	"strings"
+)
+
+// A Table is the records from one table, kept as the raw documents.
+type Table struct {
+	Name string
+	Docs []map[string]interface{}
+}
+
+// Records reads and writes the records of a project that mcstore doesn't
+// model itself, such as samples, processes, notes and tags. Only RethinkDB
+// has these tables, so the other stores can't export or import them.
+type Records interface {
+	// Export returns the records that belong to the project, or refer to one
+	// of the items, in the order they should be imported.
+	Export(projectID string, itemIDs []string) ([]Table, error)
+
+	// Import adds the docs to a table. The docs already have their new ids.
+	// Docs of a table in globalTables that are already present are left as
+	// they are.
+	Import(table Table) error
+}
+
+// recordTables are the tables of records that are exported, in the order
+// they are imported. Records come before the join tables that refer to them.
+var recordTables = []string{
+	"tags",
+	"samples",
+	"processes",
+	"properties",
+	"setupproperties",
+	"notes",
+	"project2sample",
+	"project2process",
+	"sample2datafile",
+	"sample2propertyset",
+	"propertyset2property",
+	"process2setup",
+	"note2item",
+	"tag2item",
+}
+
+// globalTables are tables of records every project shares. Their docs keep
+// their ids on import.
+var globalTables = map[string]bool{
+	"tags": true,
+}
+
+// globalIDFields are id fields that refer to things every project shares, so
+// they keep their value on import.
+var globalIDFields = map[string]bool{
+	"tag_id": true,
+}
+
+// remapper rewrites the ids and owners in records for a new instance.
+type remapper struct {
+	ids   map[string]string
+	owner func(string) string
+}
+
+// remap gives every doc a new id, except the docs of globalTables, then
+// rewrites the references in each doc. A string that is the old id of
+// anything in the bundle becomes the new id. Other values of fields ending in
+// _id, such as property set ids, are ids the bundle has no entry for; they get
+// new ids so that importing the same bundle twice doesn't join the copies.
+// Owners are mapped with the owner function.
+func (m *remapper) remap(tables []Table) {
+	for _, table := range tables {
+		for _, doc := range table.Docs {
+			id, ok := doc["id"].(string)
+			switch {
+			case !ok || id == "":
+			case globalTables[table.Name]:
+				m.ids[id] = id
+			default:
+				m.ids[id] = newID()
+			}
+		}
+	}
+
+	for _, table := range tables {
+		for i, doc := range table.Docs {
+			table.Docs[i] = m.remapDoc(doc)
+		}
+	}
+}
+
+// remapDoc rewrites the fields of a doc.
+func (m *remapper) remapDoc(doc map[string]interface{}) map[string]interface{} {
+	for key, value := range doc {
+		s, ok := value.(string)
+		switch {
+		case !ok:
+			doc[key] = m.remapValue(value)
+		case key == "id":
+			if s == "" {
+				delete(doc, key)
+			} else {
+				doc[key] = m.ids[s]
+			}
+		case key == "owner" || key == "who":
+			doc[key] = m.owner(s)
+		case m.ids[s] != "":
+			doc[key] = m.ids[s]
+		case strings.HasSuffix(key, "_id") && !globalIDFields[key] && s != "":
+			m.ids[s] = newID()
+			doc[key] = m.ids[s]
+		}
+	}
+	return doc
+}
+
+// remapValue rewrites the ids in nested values.
+func (m *remapper) remapValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if id := m.ids[v]; id != "" {
+			return id
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = m.remapValue(v[i])
+		}
+	case map[string]interface{}:
+		// Pseudo types, such as times, are left alone.
+		if _, pseudo := v["$reql_type$"]; pseudo {
+			return v
+		}
+		for key := range v {
+			v[key] = m.remapValue(v[key])
+		}
+	}
+	return value
+}
@@ -0,0 +1,151 @@
+package bundle
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+)
+
+// rRecords implements the Records interface for RethinkDB.
+type rRecords struct {
+	session *r.Session
+}
+
+// NewRRecords creates Records that read and write the RethinkDB tables.
+func NewRRecords(session *r.Session) Records {
+	return rRecords{
+		session: session,
+	}
+}
+
+// runOpts keeps times and binary values as RethinkDB pseudo types, so the
+// docs are written back exactly as they were read.
+var runOpts = r.RunOpts{TimeFormat: "raw", BinaryFormat: "raw"}
+
+// Export follows the join tables from the project to its samples and
+// processes, and from the project, files, samples and processes to their
+// notes and tags. The tags the tag2item rows refer to are exported with
+// them.
+func (rr rRecords) Export(projectID string, itemIDs []string) ([]Table, error) {
+	tables := make(map[string][]map[string]interface{})
+	var err error
+	get := func(table, index string, keys []string) []map[string]interface{} {
+		if err != nil {
+			return nil
+		}
+		var docs []map[string]interface{}
+		docs, err = rr.getAll(table, index, keys)
+		tables[table] = docs
+		return docs
+	}
+
+	project := []string{projectID}
+	sampleIDs := values(get("project2sample", "project_id", project), "sample_id")
+	get("samples", "", sampleIDs)
+	get("sample2datafile", "sample_id", sampleIDs)
+	propertySetIDs := values(get("sample2propertyset", "sample_id", sampleIDs), "property_set_id")
+	propertyIDs := values(get("propertyset2property", "property_set_id", propertySetIDs), "property_id")
+	get("properties", "", propertyIDs)
+
+	processIDs := values(get("project2process", "project_id", project), "process_id")
+	get("processes", "", processIDs)
+	setupIDs := values(get("process2setup", "process_id", processIDs), "setup_id")
+	get("setupproperties", "setup_id", setupIDs)
+
+	items := append(append(append(project, itemIDs...), sampleIDs...), processIDs...)
+	get("notes", "", values(get("note2item", "item_id", items), "note_id"))
+	get("tags", "", values(get("tag2item", "item_id", items), "tag_id"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var exported []Table
+	for _, name := range recordTables {
+		if len(tables[name]) != 0 {
+			exported = append(exported, Table{Name: name, Docs: tables[name]})
+		}
+	}
+	return exported, nil
+}
+
+// Import inserts the docs into the table. Docs of a global table that this
+// instance already has are left out.
+func (rr rRecords) Import(table Table) error {
+	docs := table.Docs
+	if globalTables[table.Name] {
+		existing, err := rr.getAll(table.Name, "", values(docs, "id"))
+		if err != nil {
+			return err
+		}
+		docs = without(docs, values(existing, "id"))
+	}
+
+	if len(docs) == 0 {
+		return nil
+	}
+	rv, err := r.Table(table.Name).Insert(docs).RunWrite(rr.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Errors != 0:
+		return app.Errorf(app.ErrCreate, "%d of %d records failed: %s", rv.Errors, len(docs), rv.FirstError)
+	default:
+		return nil
+	}
+}
+
+// getAll returns the docs in table whose index matches one of the keys. An
+// empty index looks the keys up by primary key.
+func (rr rRecords) getAll(table, index string, keys []string) ([]map[string]interface{}, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	term := r.Table(table).GetAll(args...)
+	if index != "" {
+		term = r.Table(table).GetAllByIndex(index, args...)
+	}
+
+	res, err := term.Run(rr.session, runOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var docs []map[string]interface{}
+	err = res.All(&docs)
+	return docs, err
+}
+
+// values returns the distinct string values of field in docs.
+func values(docs []map[string]interface{}, field string) []string {
+	seen := make(map[string]bool)
+	var vals []string
+	for _, doc := range docs {
+		if s, ok := doc[field].(string); ok && s != "" && !seen[s] {
+			seen[s] = true
+			vals = append(vals, s)
+		}
+	}
+	return vals
+}
+
+// without returns the docs whose id isn't one of ids.
+func without(docs []map[string]interface{}, ids []string) []map[string]interface{} {
+	skip := make(map[string]bool)
+	for _, id := range ids {
+		skip[id] = true
+	}
+	var kept []map[string]interface{}
+	for _, doc := range docs {
+		if id, _ := doc["id"].(string); !skip[id] {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,66 @@
+package dai
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Fixture describes the users and projects to load into a store.
+type Fixture struct {
+	Users []struct {
+		ID       string `json:"id"`
+		Fullname string `json:"fullname"`
+		APIKey   string `json:"apikey"`
+		Admin    bool   `json:"admin"`
+	} `json:"users"`
+
+	Projects []struct {
+		Name    string   `json:"name"`
+		Owner   string   `json:"owner"`
+		Members []string `json:"members"`
+	} `json:"projects"`
+}
+
+// fixtureStore is a Store that users and access entries can be added to.
+type fixtureStore interface {
+	Store
+	AddUser(user schema.User) error
+	AddAccess(access schema.Access) error
+}
+
+// loadFixture adds the users and projects in a JSON Fixture to the store. Each
+// project is created with its top level directory, and its members are given
+// access to it.
+func loadFixture(s fixtureStore, r io.Reader) error {
+	var fixture Fixture
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		return app.Errorf(app.ErrInvalid, "bad fixture: %s", err)
+	}
+
+	for _, u := range fixture.Users {
+		user := schema.NewUser(u.Fullname, u.ID, "", u.APIKey)
+		user.Admin = u.Admin
+		if err := s.AddUser(user); err != nil {
+			return app.Errorf(err, "user %s", u.ID)
+		}
+	}
+
+	for _, p := range fixture.Projects {
+		project := schema.NewProject(p.Name, p.Owner)
+		newProject, err := s.Projects().Insert(&project)
+		if err != nil {
+			return app.Errorf(err, "project %s", p.Name)
+		}
+
+		for _, member := range p.Members {
+			if err := s.AddAccess(schema.NewAccess(newProject.ID, newProject.Name, member)); err != nil {
+				return app.Errorf(err, "access for %s", member)
+			}
+		}
+	}
+
+	return nil
+}
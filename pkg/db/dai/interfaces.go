@@ -1,6 +1,10 @@
 package dai
 
-import "github.com/materials-commons/mcstore/pkg/db/schema"
+import (
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
 
 // Users gives access to users.
 type Users interface {
@@ -13,12 +17,15 @@ type Files interface {
 	ByID(id string) (*schema.File, error)
 	ByChecksum(checksum string) (*schema.File, error)
 	AllByChecksum(checksum string) ([]schema.File, error)
+	UsedBy(fileID string) ([]schema.File, error)
 	ByPath(name, dirID string) (*schema.File, error)
 	Insert(file *schema.File, dirID string, projectID string) (*schema.File, error)
 	Update(file *schema.File) error
 	UpdateFields(fileID string, fields map[string]interface{}) error
 	Delete(fileID, directoryID, projectID string) (*schema.File, error)
+	Move(fileID, fromDirID, toDirID string) error
 	GetProject(fileID string) (*schema.Project, error)
+	Directory(fileID string) (*schema.Directory, error)
 	FileDatasets(fileID string) ([]schema.Dataset, error)
 }
 
@@ -37,6 +44,7 @@ type Uploads interface {
 	Update(upload *schema.Upload) error
 	ForUser(user string) ([]schema.Upload, error)
 	ForProject(projectID string) ([]schema.Upload, error)
+	All() ([]schema.Upload, error)
 	Delete(uploadID string) error
 	DeleteAll() error
 }
@@ -56,6 +64,73 @@ type Dirs interface {
 	ByID(id string) (*schema.Directory, error)
 	ByPath(path, projectID string) (*schema.Directory, error)
 	Files(dirID string) ([]schema.File, error)
+	Children(dirID string) ([]schema.Directory, error)
 	Insert(dir *schema.Directory) (*schema.Directory, error)
+	Update(dir *schema.Directory) error
 	Delete(dirID string) error
 }
+
+// APITokens is an interface describing access to user api tokens.
+type APITokens interface {
+	ByID(id string) (*schema.APIToken, error)
+	ByHash(hash string) (*schema.APIToken, error)
+	ForUser(user string) ([]schema.APIToken, error)
+	Insert(token *schema.APIToken) (*schema.APIToken, error)
+	Revoke(id string) error
+}
+
+// ShareLinks is an interface describing access to signed file share links.
+type ShareLinks interface {
+	ByID(id string) (*schema.ShareLink, error)
+	Insert(link *schema.ShareLink) (*schema.ShareLink, error)
+	IncrementDownloads(id string) error
+}
+
+// Datasets is an interface describing access to datasets.
+type Datasets interface {
+	ByID(id string) (*schema.Dataset, error)
+	Published() ([]schema.Dataset, error)
+	Files(datasetID string) ([]schema.File, error)
+	Insert(dataset *schema.Dataset) (*schema.Dataset, error)
+	AddFiles(datasetID string, fileIDs []string) error
+	UpdateFields(id string, fields map[string]interface{}) error
+	IncrementDownloads(id string) error
+}
+
+// Trash is an interface describing access to the deleted items in project
+// trash. Items are listed newest first.
+type Trash interface {
+	ByID(id string) (*schema.TrashItem, error)
+	ForProject(projectID string) ([]schema.TrashItem, error)
+	DeletedBefore(t time.Time) ([]schema.TrashItem, error)
+	Insert(item *schema.TrashItem) (*schema.TrashItem, error)
+	Delete(id string) error
+}
+
+// AuditEvents is an interface describing access to the audit log.
+type AuditEvents interface {
+	Insert(event *schema.AuditEvent) error
+	Query(query AuditQuery) ([]schema.AuditEvent, error)
+}
+
+// AuditQuery selects the audit events for a project. Actor, Since, Until
+// and Limit are optional and are ignored when they have their zero value.
+type AuditQuery struct {
+	ProjectID string
+	Actor     string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Inventory lists every file and file join entry. It is used by maintenance
+// tools that check the whole database, so it also has the repairs those tools
+// make to the join tables.
+type Inventory interface {
+	Files() ([]schema.File, error)
+	ProjectFiles() ([]schema.Project2DataFile, error)
+	DirFiles() ([]schema.DataDir2DataFile, error)
+	AddProjectFile(projectID, fileID string) error
+	DeleteProjectFile(id string) error
+	DeleteDirFile(id string) error
+}
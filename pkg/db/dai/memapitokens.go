@@ -0,0 +1,87 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memAPITokens implements the APITokens interface for a MemStore.
+type memAPITokens struct {
+	store *MemStore
+}
+
+// ByID looks up a token by its primary key.
+func (t memAPITokens) ByID(id string) (*schema.APIToken, error) {
+	return t.find(func(token *schema.APIToken) bool { return token.ID == id })
+}
+
+// ByHash looks up a token by the hash of its secret.
+func (t memAPITokens) ByHash(hash string) (*schema.APIToken, error) {
+	return t.find(func(token *schema.APIToken) bool { return token.Hash == hash })
+}
+
+// ForUser returns all the tokens owned by user.
+func (t memAPITokens) ForUser(user string) ([]schema.APIToken, error) {
+	t.store.mutex.RLock()
+	defer t.store.mutex.RUnlock()
+
+	var tokens []schema.APIToken
+	for _, token := range t.store.apitokens {
+		if token.Owner == user {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// Insert adds a new token.
+func (t memAPITokens) Insert(token *schema.APIToken) (*schema.APIToken, error) {
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+
+	newToken := *token
+	if newToken.ID == "" {
+		newToken.ID = newID()
+	} else if t.index(newToken.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+	t.store.apitokens = append(t.store.apitokens, newToken)
+	return &newToken, nil
+}
+
+// Revoke marks a token as revoked.
+func (t memAPITokens) Revoke(id string) error {
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+
+	i := t.index(id)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	t.store.apitokens[i].Revoked = true
+	return nil
+}
+
+// find returns the first token that matches.
+func (t memAPITokens) find(match func(token *schema.APIToken) bool) (*schema.APIToken, error) {
+	t.store.mutex.RLock()
+	defer t.store.mutex.RUnlock()
+
+	for i := range t.store.apitokens {
+		if match(&t.store.apitokens[i]) {
+			token := t.store.apitokens[i]
+			return &token, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// index returns the index of the token, or -1. The caller must hold the mutex.
+func (t memAPITokens) index(id string) int {
+	for i := range t.store.apitokens {
+		if t.store.apitokens[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,56 @@
+package dai
+
+import (
+	"sort"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memAuditEvents implements the AuditEvents interface for a MemStore.
+type memAuditEvents struct {
+	store *MemStore
+}
+
+// Insert adds a new audit event.
+func (a memAuditEvents) Insert(event *schema.AuditEvent) error {
+	a.store.mutex.Lock()
+	defer a.store.mutex.Unlock()
+
+	newEvent := *event
+	if newEvent.ID == "" {
+		newEvent.ID = newID()
+	}
+	a.store.auditEvents = append(a.store.auditEvents, newEvent)
+	return nil
+}
+
+// Query returns the audit events for a project matching the query, newest first.
+func (a memAuditEvents) Query(query AuditQuery) ([]schema.AuditEvent, error) {
+	a.store.mutex.RLock()
+	defer a.store.mutex.RUnlock()
+
+	var events []schema.AuditEvent
+	for _, event := range a.store.auditEvents {
+		switch {
+		case event.ProjectID != query.ProjectID:
+		case query.Actor != "" && event.Actor != query.Actor:
+		case !query.Since.IsZero() && event.Birthtime.Before(query.Since):
+		case !query.Until.IsZero() && !event.Birthtime.Before(query.Until):
+		default:
+			events = append(events, event)
+		}
+	}
+
+	sort.Sort(sort.Reverse(auditEventsByTime(events)))
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[:query.Limit]
+	}
+	return events, nil
+}
+
+// auditEventsByTime sorts audit events oldest first.
+type auditEventsByTime []schema.AuditEvent
+
+func (e auditEventsByTime) Len() int           { return len(e) }
+func (e auditEventsByTime) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e auditEventsByTime) Less(i, j int) bool { return e[i].Birthtime.Before(e[j].Birthtime) }
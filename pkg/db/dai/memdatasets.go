@@ -0,0 +1,126 @@
+package dai
+
+import (
+	"sort"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memDatasets implements the Datasets interface for a MemStore.
+type memDatasets struct {
+	store *MemStore
+}
+
+// ByID looks up a dataset by its primary key.
+func (d memDatasets) ByID(id string) (*schema.Dataset, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	if i := d.store.datasetIndex(id); i != -1 {
+		dataset := d.store.datasets[i]
+		return &dataset, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// Published returns the published datasets ordered by id.
+func (d memDatasets) Published() ([]schema.Dataset, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	var datasets []schema.Dataset
+	for _, dataset := range d.store.datasets {
+		if dataset.Published {
+			datasets = append(datasets, dataset)
+		}
+	}
+	sort.Sort(datasetsByID(datasets))
+	return datasets, nil
+}
+
+// datasetsByID sorts datasets by id.
+type datasetsByID []schema.Dataset
+
+func (d datasetsByID) Len() int           { return len(d) }
+func (d datasetsByID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d datasetsByID) Less(i, j int) bool { return d[i].ID < d[j].ID }
+
+// Files returns the files in a dataset.
+func (d memDatasets) Files(datasetID string) ([]schema.File, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	var files []schema.File
+	for _, entry := range d.store.datasetFiles {
+		if entry.DatasetID != datasetID {
+			continue
+		}
+
+		if i := d.store.fileIndex(entry.DataFileID); i != -1 {
+			files = append(files, d.store.files[i])
+		}
+	}
+	return files, nil
+}
+
+// Insert adds a new dataset.
+func (d memDatasets) Insert(dataset *schema.Dataset) (*schema.Dataset, error) {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	newDataset := *dataset
+	if newDataset.ID == "" {
+		newDataset.ID = newID()
+	} else if d.store.datasetIndex(newDataset.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+	d.store.datasets = append(d.store.datasets, newDataset)
+	return &newDataset, nil
+}
+
+// AddFiles adds the given files to a dataset.
+func (d memDatasets) AddFiles(datasetID string, fileIDs []string) error {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	for _, fileID := range fileIDs {
+		d.store.datasetFiles = append(d.store.datasetFiles, schema.Dataset2DataFile{
+			ID:         newID(),
+			DatasetID:  datasetID,
+			DataFileID: fileID,
+		})
+	}
+	return nil
+}
+
+// UpdateFields updates the fields for the given dataset.
+func (d memDatasets) UpdateFields(id string, fields map[string]interface{}) error {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	i := d.store.datasetIndex(id)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+
+	dataset := d.store.datasets[i]
+	if err := setFields(&dataset, fields); err != nil {
+		return err
+	}
+	d.store.datasets[i] = dataset
+	return nil
+}
+
+// IncrementDownloads increments the download count for a dataset.
+func (d memDatasets) IncrementDownloads(id string) error {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	i := d.store.datasetIndex(id)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	d.store.datasets[i].Downloads++
+	return nil
+}
@@ -0,0 +1,127 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memDirs implements the Dirs interface for a MemStore.
+type memDirs struct {
+	store *MemStore
+}
+
+// ByID looks up a directory by the given id.
+func (d memDirs) ByID(id string) (*schema.Directory, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	if i := d.store.dirIndex(id); i != -1 {
+		dir := d.store.dirs[i]
+		return &dir, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// ByPath looks up a directory in a project by its path.
+func (d memDirs) ByPath(path, projectID string) (*schema.Directory, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	for _, dir := range d.store.dirs {
+		if dir.Name == path && dir.Project == projectID {
+			return &dir, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// Files returns the files for the given directory id.
+func (d memDirs) Files(dirID string) ([]schema.File, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+	return d.store.dirFileList(dirID), nil
+}
+
+// Children returns the directories whose parent is dirID.
+func (d memDirs) Children(dirID string) ([]schema.Directory, error) {
+	d.store.mutex.RLock()
+	defer d.store.mutex.RUnlock()
+
+	var dirs []schema.Directory
+	for _, dir := range d.store.dirs {
+		if dir.Parent == dirID {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// Insert creates a new dir.
+func (d memDirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+	return d.insert(dir)
+}
+
+// insert creates a new dir. The caller must hold the mutex.
+func (d memDirs) insert(dir *schema.Directory) (*schema.Directory, error) {
+	newDir := *dir
+	if newDir.ID == "" {
+		newDir.ID = newID()
+	} else if d.store.dirIndex(newDir.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+
+	d.store.dirs = append(d.store.dirs, newDir)
+	d.store.projectDirs = append(d.store.projectDirs, schema.Project2DataDir{
+		ID:        newID(),
+		ProjectID: newDir.Project,
+		DataDirID: newDir.ID,
+	})
+	return &newDir, nil
+}
+
+// Update updates an existing directory.
+func (d memDirs) Update(dir *schema.Directory) error {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	i := d.store.dirIndex(dir.ID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	d.store.dirs[i] = *dir
+	return nil
+}
+
+// Delete will delete the directory from the project and the directory
+// entry. If there are files, you should call the delete for files before
+// deleting the directory.
+func (d memDirs) Delete(dirID string) error {
+	d.store.mutex.Lock()
+	defer d.store.mutex.Unlock()
+
+	i := d.store.dirIndex(dirID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	d.store.dirs = append(d.store.dirs[:i], d.store.dirs[i+1:]...)
+
+	var (
+		kept    []schema.Project2DataDir
+		deleted bool
+	)
+	for _, entry := range d.store.projectDirs {
+		if entry.DataDirID == dirID {
+			deleted = true
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	d.store.projectDirs = kept
+
+	if !deleted {
+		return app.ErrNotFound
+	}
+	return nil
+}
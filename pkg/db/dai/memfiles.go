@@ -0,0 +1,334 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memFiles implements the Files interface for a MemStore.
+type memFiles struct {
+	store *MemStore
+}
+
+// ByID looks up a file by its primary key.
+func (f memFiles) ByID(id string) (*schema.File, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+	return f.byID(id)
+}
+
+// byID looks up a file. The caller must hold the mutex.
+func (f memFiles) byID(id string) (*schema.File, error) {
+	if i := f.store.fileIndex(id); i != -1 {
+		file := f.store.files[i]
+		return &file, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// ByChecksum looks up a file by its checksum. This routine only returns the original
+// root entry, it will not return entries that are duplicates and point at the root.
+func (f memFiles) ByChecksum(checksum string) (*schema.File, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	for _, file := range f.store.files {
+		if file.Checksum == checksum && file.UsesID == "" {
+			return &file, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// AllByChecksum returns all the files with the given checksum, including duplicates.
+func (f memFiles) AllByChecksum(checksum string) ([]schema.File, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	var files []schema.File
+	for _, file := range f.store.files {
+		if file.Checksum == checksum {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// UsedBy returns all the files that point at this file.
+func (f memFiles) UsedBy(fileID string) ([]schema.File, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	var files []schema.File
+	for _, file := range f.store.files {
+		if file.UsesID == fileID {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// ByPath looks up a file by its name in a specific directory. It only returns the
+// current file, not hidden files.
+func (f memFiles) ByPath(name, dirID string) (*schema.File, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	for _, file := range f.store.dirFileList(dirID) {
+		if file.Current && file.Name == name {
+			return &file, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// Insert adds a new file to the system.
+func (f memFiles) Insert(file *schema.File, dirID string, projectID string) (*schema.File, error) {
+	f.store.mutex.Lock()
+	defer f.store.mutex.Unlock()
+
+	newFile := *file
+	if newFile.ID == "" {
+		newFile.ID = newID()
+	} else if f.store.fileIndex(newFile.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+
+	f.store.files = append(f.store.files, newFile)
+	f.store.dirFiles = append(f.store.dirFiles, schema.DataDir2DataFile{
+		ID:         newID(),
+		DataDirID:  dirID,
+		DataFileID: newFile.ID,
+	})
+	f.store.projectFiles = append(f.store.projectFiles, schema.Project2DataFile{
+		ID:         newID(),
+		ProjectID:  projectID,
+		DataFileID: newFile.ID,
+	})
+
+	return &newFile, nil
+}
+
+// Delete will delete the file from the directory and project. See rFiles.Delete
+// for the rules on when the file entry itself is removed.
+func (f memFiles) Delete(fileID, directoryID, projectID string) (*schema.File, error) {
+	f.store.mutex.Lock()
+	defer f.store.mutex.Unlock()
+
+	var firstError error
+
+	projects := 0
+	for _, entry := range f.store.projectFiles {
+		if entry.DataFileID == fileID {
+			projects++
+		}
+	}
+
+	dirs := 0
+	for _, entry := range f.store.dirFiles {
+		if entry.DataFileID == fileID {
+			dirs++
+		}
+	}
+
+	if !f.deleteFromDir(fileID, directoryID) {
+		firstError = app.ErrNotFound
+	}
+
+	file, err := f.byID(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	usedBy := 0
+	for _, other := range f.store.files {
+		if other.UsesID == fileID {
+			usedBy++
+		}
+	}
+
+	switch {
+	case dirs == 1 && projects == 1 && usedBy == 0 && !f.inPublishedDataset(fileID):
+		f.deleteFromProject(fileID, projectID)
+		i := f.store.fileIndex(fileID)
+		f.store.files = append(f.store.files[:i], f.store.files[i+1:]...)
+	case file.Current:
+		// File is referenced by somebody, so just mark it as
+		// not current, since we cannot delete it.
+		file.Current = false
+		f.store.files[f.store.fileIndex(fileID)].Current = false
+	}
+
+	// The previous version of the file becomes the current file.
+	if file.Parent != "" {
+		if i := f.store.fileIndex(file.Parent); i != -1 {
+			f.store.files[i].Current = true
+		}
+	}
+
+	return file, firstError
+}
+
+// Move moves the file from one directory to another.
+func (f memFiles) Move(fileID, fromDirID, toDirID string) error {
+	f.store.mutex.Lock()
+	defer f.store.mutex.Unlock()
+
+	for i, entry := range f.store.dirFiles {
+		if entry.DataFileID == fileID && entry.DataDirID == fromDirID {
+			f.store.dirFiles[i].DataDirID = toDirID
+			return nil
+		}
+	}
+	return app.ErrNotFound
+}
+
+// deleteFromDir removes the file from the directory. It returns false if the
+// file wasn't in the directory. The caller must hold the mutex.
+func (f memFiles) deleteFromDir(fileID, directoryID string) bool {
+	for i, entry := range f.store.dirFiles {
+		if entry.DataFileID == fileID && entry.DataDirID == directoryID {
+			f.store.dirFiles = append(f.store.dirFiles[:i], f.store.dirFiles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// deleteFromProject removes the file from the project. The caller must hold the mutex.
+func (f memFiles) deleteFromProject(fileID, projectID string) {
+	for i, entry := range f.store.projectFiles {
+		if entry.DataFileID == fileID && entry.ProjectID == projectID {
+			f.store.projectFiles = append(f.store.projectFiles[:i], f.store.projectFiles[i+1:]...)
+			return
+		}
+	}
+}
+
+// inPublishedDataset returns true if the file is in a published dataset. The
+// caller must hold the mutex.
+func (f memFiles) inPublishedDataset(fileID string) bool {
+	for _, ds := range f.store.fileDatasetList(fileID) {
+		if ds.Published {
+			return true
+		}
+	}
+	return false
+}
+
+// Update updates an existing datafile.
+func (f memFiles) Update(file *schema.File) error {
+	f.store.mutex.Lock()
+	defer f.store.mutex.Unlock()
+
+	i := f.store.fileIndex(file.ID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	f.store.files[i] = *file
+	return nil
+}
+
+// UpdateFields updates the fields for the given id.
+func (f memFiles) UpdateFields(fileID string, fields map[string]interface{}) error {
+	f.store.mutex.Lock()
+	defer f.store.mutex.Unlock()
+
+	i := f.store.fileIndex(fileID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+
+	file := f.store.files[i]
+	if err := setFields(&file, fields); err != nil {
+		return err
+	}
+	f.store.files[i] = file
+	return nil
+}
+
+// GetProject returns the project the file is in.
+func (f memFiles) GetProject(fileID string) (*schema.Project, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	for _, dirEntry := range f.store.dirFiles {
+		if dirEntry.DataFileID != fileID {
+			continue
+		}
+
+		for _, projEntry := range f.store.projectDirs {
+			if projEntry.DataDirID != dirEntry.DataDirID {
+				continue
+			}
+
+			if i := f.store.projectIndex(projEntry.ProjectID); i != -1 {
+				project := f.store.projects[i]
+				return &project, nil
+			}
+		}
+	}
+
+	return nil, app.ErrNotFound
+}
+
+// Directory returns the directory the file is in.
+func (f memFiles) Directory(fileID string) (*schema.Directory, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	for _, entry := range f.store.dirFiles {
+		if entry.DataFileID != fileID {
+			continue
+		}
+
+		if i := f.store.dirIndex(entry.DataDirID); i != -1 {
+			dir := f.store.dirs[i]
+			return &dir, nil
+		}
+	}
+
+	return nil, app.ErrNotFound
+}
+
+// FileDatasets returns the datasets the file is in.
+func (f memFiles) FileDatasets(fileID string) ([]schema.Dataset, error) {
+	f.store.mutex.RLock()
+	defer f.store.mutex.RUnlock()
+
+	datasets := f.store.fileDatasetList(fileID)
+	if len(datasets) == 0 {
+		return nil, app.ErrNotFound
+	}
+	return datasets, nil
+}
+
+// dirFileList returns the files in a directory. The caller must hold the mutex.
+func (s *MemStore) dirFileList(dirID string) []schema.File {
+	var files []schema.File
+	for _, entry := range s.dirFiles {
+		if entry.DataDirID != dirID {
+			continue
+		}
+
+		if i := s.fileIndex(entry.DataFileID); i != -1 {
+			files = append(files, s.files[i])
+		}
+	}
+	return files
+}
+
+// fileDatasetList returns the datasets a file is in. The caller must hold the mutex.
+func (s *MemStore) fileDatasetList(fileID string) []schema.Dataset {
+	var datasets []schema.Dataset
+	for _, entry := range s.datasetFiles {
+		if entry.DataFileID != fileID {
+			continue
+		}
+
+		if i := s.datasetIndex(entry.DatasetID); i != -1 {
+			datasets = append(datasets, s.datasets[i])
+		}
+	}
+	return datasets
+}
@@ -0,0 +1,74 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memInventory implements the Inventory interface for a MemStore.
+type memInventory struct {
+	store *MemStore
+}
+
+// Files returns every file.
+func (i memInventory) Files() ([]schema.File, error) {
+	i.store.mutex.RLock()
+	defer i.store.mutex.RUnlock()
+	return append([]schema.File(nil), i.store.files...), nil
+}
+
+// ProjectFiles returns every project to file join entry.
+func (i memInventory) ProjectFiles() ([]schema.Project2DataFile, error) {
+	i.store.mutex.RLock()
+	defer i.store.mutex.RUnlock()
+	return append([]schema.Project2DataFile(nil), i.store.projectFiles...), nil
+}
+
+// DirFiles returns every directory to file join entry.
+func (i memInventory) DirFiles() ([]schema.DataDir2DataFile, error) {
+	i.store.mutex.RLock()
+	defer i.store.mutex.RUnlock()
+	return append([]schema.DataDir2DataFile(nil), i.store.dirFiles...), nil
+}
+
+// AddProjectFile adds the file to the project.
+func (i memInventory) AddProjectFile(projectID, fileID string) error {
+	i.store.mutex.Lock()
+	defer i.store.mutex.Unlock()
+
+	entry := schema.Project2DataFile{
+		ID:         newID(),
+		ProjectID:  projectID,
+		DataFileID: fileID,
+	}
+	i.store.projectFiles = append(i.store.projectFiles, entry)
+	return nil
+}
+
+// DeleteProjectFile deletes a project to file join entry.
+func (i memInventory) DeleteProjectFile(id string) error {
+	i.store.mutex.Lock()
+	defer i.store.mutex.Unlock()
+
+	for j, entry := range i.store.projectFiles {
+		if entry.ID == id {
+			i.store.projectFiles = append(i.store.projectFiles[:j], i.store.projectFiles[j+1:]...)
+			return nil
+		}
+	}
+	return app.ErrNotFound
+}
+
+// DeleteDirFile deletes a directory to file join entry.
+func (i memInventory) DeleteDirFile(id string) error {
+	i.store.mutex.Lock()
+	defer i.store.mutex.Unlock()
+
+	for j, entry := range i.store.dirFiles {
+		if entry.ID == id {
+			i.store.dirFiles = append(i.store.dirFiles[:j], i.store.dirFiles[j+1:]...)
+			return nil
+		}
+	}
+	return app.ErrNotFound
+}
@@ -0,0 +1,123 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memProjects implements the Projects interface for a MemStore.
+type memProjects struct {
+	store *MemStore
+}
+
+// ByID looks up a project by the given id.
+func (p memProjects) ByID(id string) (*schema.Project, error) {
+	p.store.mutex.RLock()
+	defer p.store.mutex.RUnlock()
+
+	if i := p.store.projectIndex(id); i != -1 {
+		project := p.store.projects[i]
+		return &project, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// ByName looks up a project by its name and owner.
+func (p memProjects) ByName(name string, owner string) (*schema.Project, error) {
+	p.store.mutex.RLock()
+	defer p.store.mutex.RUnlock()
+
+	for _, project := range p.store.projects {
+		if project.Name == name && project.Owner == owner {
+			return &project, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// ForUser returns the projects owned by user, or all the projects the user
+// has access to when ownedOnly is false.
+func (p memProjects) ForUser(user string, ownedOnly bool) ([]schema.Project, error) {
+	p.store.mutex.RLock()
+	defer p.store.mutex.RUnlock()
+
+	var projects []schema.Project
+	if ownedOnly {
+		for _, project := range p.store.projects {
+			if project.Owner == user {
+				projects = append(projects, project)
+			}
+		}
+		return projects, nil
+	}
+
+	for _, access := range p.store.access {
+		if access.UserID != user {
+			continue
+		}
+
+		if i := p.store.projectIndex(access.ProjectID); i != -1 {
+			projects = append(projects, p.store.projects[i])
+		}
+	}
+	return projects, nil
+}
+
+// Insert creates a new project for the given owner. It also creates the directory associated
+// with the project.
+func (p memProjects) Insert(project *schema.Project) (*schema.Project, error) {
+	if project.DataDir != "" {
+		return nil, app.ErrInvalid
+	}
+
+	p.store.mutex.Lock()
+	defer p.store.mutex.Unlock()
+
+	newProject := *project
+	if newProject.ID == "" {
+		newProject.ID = newID()
+	} else if p.store.projectIndex(newProject.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+
+	accessEntry := schema.NewAccess(newProject.ID, newProject.Name, newProject.Owner)
+	accessEntry.ID = newID()
+	p.store.access = append(p.store.access, accessEntry)
+
+	dir := schema.NewDirectory(project.Name, project.Owner, newProject.ID, "")
+	newDir, err := memDirs{p.store}.insert(&dir)
+	if err != nil {
+		return nil, app.ErrCreate
+	}
+
+	newProject.DataDir = newDir.ID
+	p.store.projects = append(p.store.projects, newProject)
+	return &newProject, nil
+}
+
+// HasDirectory checks if the given directoryID is in the given project.
+func (p memProjects) HasDirectory(projectID, dirID string) bool {
+	p.store.mutex.RLock()
+	defer p.store.mutex.RUnlock()
+
+	for _, entry := range p.store.projectDirs {
+		if entry.ProjectID == projectID && entry.DataDirID == dirID {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessList returns the access list for this project.
+func (p memProjects) AccessList(projectID string) ([]schema.Access, error) {
+	p.store.mutex.RLock()
+	defer p.store.mutex.RUnlock()
+
+	var access []schema.Access
+	for _, entry := range p.store.access {
+		if entry.ProjectID == projectID {
+			access = append(access, entry)
+		}
+	}
+	return access, nil
+}
@@ -0,0 +1,67 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memShareLinks implements the ShareLinks interface for a MemStore.
+type memShareLinks struct {
+	store *MemStore
+}
+
+// ByID looks up a share link by its primary key.
+func (l memShareLinks) ByID(id string) (*schema.ShareLink, error) {
+	l.store.mutex.RLock()
+	defer l.store.mutex.RUnlock()
+
+	if i := l.index(id); i != -1 {
+		link := l.store.sharelinks[i]
+		return &link, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// Insert adds a new share link.
+func (l memShareLinks) Insert(link *schema.ShareLink) (*schema.ShareLink, error) {
+	l.store.mutex.Lock()
+	defer l.store.mutex.Unlock()
+
+	newLink := *link
+	if newLink.ID == "" {
+		newLink.ID = newID()
+	} else if l.index(newLink.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+	l.store.sharelinks = append(l.store.sharelinks, newLink)
+	return &newLink, nil
+}
+
+// IncrementDownloads increments the download count for a link. It returns
+// app.ErrNoAccess if the link has already reached its download limit.
+func (l memShareLinks) IncrementDownloads(id string) error {
+	l.store.mutex.Lock()
+	defer l.store.mutex.Unlock()
+
+	i := l.index(id)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+
+	link := &l.store.sharelinks[i]
+	if link.MaxDownloads != 0 && link.Downloads >= link.MaxDownloads {
+		return app.ErrNoAccess
+	}
+	link.Downloads++
+	return nil
+}
+
+// index returns the index of the link, or -1. The caller must hold the mutex.
+func (l memShareLinks) index(id string) int {
+	for i := range l.store.sharelinks {
+		if l.store.sharelinks[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
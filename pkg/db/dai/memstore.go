@@ -0,0 +1,129 @@
+package dai
+
+import (
+	"io"
+	"sync"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// MemStore is a Store that keeps everything in memory. It has the same
+// semantics as the RethinkDB implementations, so services can run against
+// it in tests and without a database. It is safe for concurrent use.
+type MemStore struct {
+	mutex        sync.RWMutex
+	users        []schema.User
+	files        []schema.File
+	dirs         []schema.Directory
+	projects     []schema.Project
+	access       []schema.Access
+	uploads      []schema.Upload
+	apitokens    []schema.APIToken
+	sharelinks   []schema.ShareLink
+	datasets     []schema.Dataset
+	auditEvents  []schema.AuditEvent
+	trash        []schema.TrashItem
+	projectDirs  []schema.Project2DataDir
+	projectFiles []schema.Project2DataFile
+	dirFiles     []schema.DataDir2DataFile
+	datasetFiles []schema.Dataset2DataFile
+}
+
+// NewMemStore creates a new, empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+func (s *MemStore) Users() Users             { return memUsers{s} }
+func (s *MemStore) Files() Files             { return memFiles{s} }
+func (s *MemStore) Uploads() Uploads         { return memUploads{s} }
+func (s *MemStore) Projects() Projects       { return memProjects{s} }
+func (s *MemStore) Dirs() Dirs               { return memDirs{s} }
+func (s *MemStore) APITokens() APITokens     { return memAPITokens{s} }
+func (s *MemStore) ShareLinks() ShareLinks   { return memShareLinks{s} }
+func (s *MemStore) Datasets() Datasets       { return memDatasets{s} }
+func (s *MemStore) AuditEvents() AuditEvents { return memAuditEvents{s} }
+func (s *MemStore) Trash() Trash             { return memTrash{s} }
+func (s *MemStore) Inventory() Inventory     { return memInventory{s} }
+
+// AddUser adds a user. Users are created outside of mcstore, so there is no
+// Insert in the Users interface. It returns app.ErrExists if there is already
+// a user with the same id.
+func (s *MemStore) AddUser(user schema.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.userIndex(user.ID) != -1 {
+		return app.ErrExists
+	}
+	s.users = append(s.users, user)
+	return nil
+}
+
+// AddAccess gives a user access to a project.
+func (s *MemStore) AddAccess(access schema.Access) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if access.ID == "" {
+		access.ID = newID()
+	}
+	s.access = append(s.access, access)
+	return nil
+}
+
+// Load adds the users and projects in a JSON Fixture to the store.
+func (s *MemStore) Load(r io.Reader) error {
+	return loadFixture(s, r)
+}
+
+// userIndex returns the index of the user, or -1. The caller must hold the mutex.
+func (s *MemStore) userIndex(id string) int {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// fileIndex returns the index of the file, or -1. The caller must hold the mutex.
+func (s *MemStore) fileIndex(id string) int {
+	for i := range s.files {
+		if s.files[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// dirIndex returns the index of the directory, or -1. The caller must hold the mutex.
+func (s *MemStore) dirIndex(id string) int {
+	for i := range s.dirs {
+		if s.dirs[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// projectIndex returns the index of the project, or -1. The caller must hold the mutex.
+func (s *MemStore) projectIndex(id string) int {
+	for i := range s.projects {
+		if s.projects[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// datasetIndex returns the index of the dataset, or -1. The caller must hold the mutex.
+func (s *MemStore) datasetIndex(id string) int {
+	for i := range s.datasets {
+		if s.datasets[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
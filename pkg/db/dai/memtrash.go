@@ -0,0 +1,97 @@
+package dai
+
+import (
+	"sort"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memTrash implements the Trash interface for a MemStore.
+type memTrash struct {
+	store *MemStore
+}
+
+// ByID looks up a trash item.
+func (t memTrash) ByID(id string) (*schema.TrashItem, error) {
+	t.store.mutex.RLock()
+	defer t.store.mutex.RUnlock()
+
+	if i := t.store.trashIndex(id); i != -1 {
+		item := t.store.trash[i]
+		return &item, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// ForProject returns the items in a project's trash, newest first.
+func (t memTrash) ForProject(projectID string) ([]schema.TrashItem, error) {
+	return t.matching(func(item schema.TrashItem) bool { return item.ProjectID == projectID })
+}
+
+// DeletedBefore returns the items in every project's trash that were deleted
+// before t, newest first.
+func (t memTrash) DeletedBefore(before time.Time) ([]schema.TrashItem, error) {
+	return t.matching(func(item schema.TrashItem) bool { return item.Birthtime.Before(before) })
+}
+
+// matching returns the items that match, newest first.
+func (t memTrash) matching(match func(item schema.TrashItem) bool) ([]schema.TrashItem, error) {
+	t.store.mutex.RLock()
+	defer t.store.mutex.RUnlock()
+
+	var items []schema.TrashItem
+	for _, item := range t.store.trash {
+		if match(item) {
+			items = append(items, item)
+		}
+	}
+	sort.Sort(sort.Reverse(trashByTime(items)))
+	return items, nil
+}
+
+// trashByTime sorts trash items oldest first.
+type trashByTime []schema.TrashItem
+
+func (t trashByTime) Len() int           { return len(t) }
+func (t trashByTime) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t trashByTime) Less(i, j int) bool { return t[i].Birthtime.Before(t[j].Birthtime) }
+
+// Insert adds a new trash item.
+func (t memTrash) Insert(item *schema.TrashItem) (*schema.TrashItem, error) {
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+
+	newItem := *item
+	if newItem.ID == "" {
+		newItem.ID = newID()
+	} else if t.store.trashIndex(newItem.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+	t.store.trash = append(t.store.trash, newItem)
+	return &newItem, nil
+}
+
+// Delete removes a trash item. It doesn't touch what the item holds.
+func (t memTrash) Delete(id string) error {
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+
+	i := t.store.trashIndex(id)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	t.store.trash = append(t.store.trash[:i], t.store.trash[i+1:]...)
+	return nil
+}
+
+// trashIndex returns the index of the trash item, or -1. The caller must hold the mutex.
+func (s *MemStore) trashIndex(id string) int {
+	for i := range s.trash {
+		if s.trash[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
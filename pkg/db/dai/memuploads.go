@@ -0,0 +1,143 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memUploads implements the Uploads interface for a MemStore. Like rUploads
+// the block state is kept in BitString, and Blocks is rebuilt from it when an
+// upload is read, so callers never share a BitSet with the store.
+type memUploads struct {
+	store *MemStore
+}
+
+// ByID looks up an upload by its primary key (id).
+func (u memUploads) ByID(id string) (*schema.Upload, error) {
+	u.store.mutex.RLock()
+	defer u.store.mutex.RUnlock()
+
+	if i := u.index(id); i != -1 {
+		return u.get(i), nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// Search attempts to find a matching upload request matching the given
+// parameters.
+func (u memUploads) Search(params UploadSearch) (*schema.Upload, error) {
+	u.store.mutex.RLock()
+	defer u.store.mutex.RUnlock()
+
+	for i, upload := range u.store.uploads {
+		if upload.ProjectID == params.ProjectID && upload.DirectoryID == params.DirectoryID &&
+			upload.File.Name == params.FileName && upload.File.Checksum == params.Checksum {
+			return u.get(i), nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
+
+// Insert adds a new upload.
+func (u memUploads) Insert(upload *schema.Upload) (*schema.Upload, error) {
+	u.store.mutex.Lock()
+	defer u.store.mutex.Unlock()
+
+	newUpload := *upload
+	if newUpload.ID == "" {
+		newUpload.ID = newID()
+	} else if u.index(newUpload.ID) != -1 {
+		return nil, app.ErrCreate
+	}
+
+	if upload.File.Blocks != nil {
+		newUpload.File.BitString = toBitStr(upload.File.Blocks)
+	}
+	newUpload.File.Blocks = nil
+	u.store.uploads = append(u.store.uploads, newUpload)
+	return u.get(len(u.store.uploads) - 1), nil
+}
+
+// Update updates an existing upload entry.
+func (u memUploads) Update(upload *schema.Upload) error {
+	u.store.mutex.Lock()
+	defer u.store.mutex.Unlock()
+
+	i := u.index(upload.ID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+
+	updated := *upload
+	updated.File.Blocks = nil
+	u.store.uploads[i] = updated
+	return nil
+}
+
+// ForUser retrieves all the uploads for the named user.
+func (u memUploads) ForUser(user string) ([]schema.Upload, error) {
+	return u.filter(func(upload *schema.Upload) bool { return upload.Owner == user }), nil
+}
+
+// ForProject returns all uploads for a particular project.
+func (u memUploads) ForProject(projectID string) ([]schema.Upload, error) {
+	return u.filter(func(upload *schema.Upload) bool { return upload.ProjectID == projectID }), nil
+}
+
+// All returns every upload.
+func (u memUploads) All() ([]schema.Upload, error) {
+	return u.filter(func(upload *schema.Upload) bool { return true }), nil
+}
+
+// Delete deletes the given upload id.
+func (u memUploads) Delete(uploadID string) error {
+	u.store.mutex.Lock()
+	defer u.store.mutex.Unlock()
+
+	i := u.index(uploadID)
+	if i == -1 {
+		return app.ErrNotFound
+	}
+	u.store.uploads = append(u.store.uploads[:i], u.store.uploads[i+1:]...)
+	return nil
+}
+
+// DeleteAll deletes all uploads.
+func (u memUploads) DeleteAll() error {
+	u.store.mutex.Lock()
+	defer u.store.mutex.Unlock()
+	u.store.uploads = nil
+	return nil
+}
+
+// filter returns the uploads that match.
+func (u memUploads) filter(match func(upload *schema.Upload) bool) []schema.Upload {
+	u.store.mutex.RLock()
+	defer u.store.mutex.RUnlock()
+
+	var uploads []schema.Upload
+	for i := range u.store.uploads {
+		if match(&u.store.uploads[i]) {
+			uploads = append(uploads, *u.get(i))
+		}
+	}
+	return uploads
+}
+
+// index returns the index of the upload, or -1. The caller must hold the mutex.
+func (u memUploads) index(id string) int {
+	for i := range u.store.uploads {
+		if u.store.uploads[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// get returns a copy of the upload at index i with its Blocks set. The caller
+// must hold the mutex.
+func (u memUploads) get(i int) *schema.Upload {
+	upload := u.store.uploads[i]
+	upload.File.Blocks = toBitSet(upload.File.BitString)
+	return &upload
+}
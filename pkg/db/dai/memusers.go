@@ -0,0 +1,36 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// memUsers implements the Users interface for a MemStore.
+type memUsers struct {
+	store *MemStore
+}
+
+// ByID looks up users by their primary key.
+func (u memUsers) ByID(id string) (*schema.User, error) {
+	u.store.mutex.RLock()
+	defer u.store.mutex.RUnlock()
+
+	if i := u.store.userIndex(id); i != -1 {
+		user := u.store.users[i]
+		return &user, nil
+	}
+	return nil, app.ErrNotFound
+}
+
+// ByAPIKey looks up users by their apikey.
+func (u memUsers) ByAPIKey(apikey string) (*schema.User, error) {
+	u.store.mutex.RLock()
+	defer u.store.mutex.RUnlock()
+
+	for _, user := range u.store.users {
+		if user.APIKey == apikey {
+			return &user, nil
+		}
+	}
+	return nil, app.ErrNotFound
+}
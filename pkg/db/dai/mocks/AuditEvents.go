@@ -0,0 +1,33 @@
+package mocks
+
+import "github.com/materials-commons/testify/mock"
+
+import (
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+type AuditEvents struct {
+	mock.Mock
+}
+
+func NewMAuditEvents() *AuditEvents {
+	return &AuditEvents{}
+}
+
+func (m *AuditEvents) Insert(event *schema.AuditEvent) error {
+	ret := m.Called(event)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+func (m *AuditEvents) Query(query dai.AuditQuery) ([]schema.AuditEvent, error) {
+	ret := m.Called(query)
+
+	r0 := ret.Get(0).([]schema.AuditEvent)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
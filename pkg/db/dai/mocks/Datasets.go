@@ -0,0 +1,73 @@
+package mocks
+
+import "github.com/materials-commons/testify/mock"
+
+import "github.com/materials-commons/mcstore/pkg/db/schema"
+
+type Datasets struct {
+	mock.Mock
+}
+
+func NewMDatasets() *Datasets {
+	return &Datasets{}
+}
+
+func (m *Datasets) ByID(id string) (*schema.Dataset, error) {
+	ret := m.Called(id)
+
+	r0 := ret.Get(0).(*schema.Dataset)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+func (m *Datasets) Published() ([]schema.Dataset, error) {
+	ret := m.Called()
+
+	r0 := ret.Get(0).([]schema.Dataset)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+func (m *Datasets) Files(datasetID string) ([]schema.File, error) {
+	ret := m.Called(datasetID)
+
+	r0 := ret.Get(0).([]schema.File)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+func (m *Datasets) Insert(dataset *schema.Dataset) (*schema.Dataset, error) {
+	ret := m.Called(dataset)
+
+	r0 := ret.Get(0).(*schema.Dataset)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+func (m *Datasets) AddFiles(datasetID string, fileIDs []string) error {
+	ret := m.Called(datasetID, fileIDs)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+func (m *Datasets) UpdateFields(id string, fields map[string]interface{}) error {
+	ret := m.Called(id, fields)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+func (m *Datasets) IncrementDownloads(id string) error {
+	ret := m.Called(id)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
@@ -38,6 +38,13 @@ func (m *Dirs) Files(dirID string) ([]schema.File, error) {
 	return r0, r1
 }
 
+func (m *Dirs) Children(dirID string) ([]schema.Directory, error) {
+	ret := m.Called(dirID)
+	r0 := ret.Get(0).([]schema.Directory)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
 func (m *Dirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
 	ret := m.Called(dir)
 	r0 := ret.Get(0).(*schema.Directory)
@@ -45,6 +52,12 @@ func (m *Dirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
 	return r0, r1
 }
 
+func (m *Dirs) Update(dir *schema.Directory) error {
+	ret := m.Called(dir)
+	r0 := ret.Error(0)
+	return r0
+}
+
 func (m *Dirs) Delete(dirID string) error {
 	ret := m.Called(dirID)
 	r0 := ret.Error(0)
@@ -55,6 +68,7 @@ type dentry struct {
 	dir   *schema.Directory
 	err   error
 	files []schema.File
+	dirs  []schema.Directory
 }
 
 type Dirs2 struct {
@@ -90,11 +104,21 @@ func (m *Dirs2) Files(dirID string) ([]schema.File, error) {
 	return e.files, e.err
 }
 
+func (m *Dirs2) Children(dirID string) ([]schema.Directory, error) {
+	e := m.lookup("Children")
+	return e.dirs, e.err
+}
+
 func (m *Dirs2) Insert(dir *schema.Directory) (*schema.Directory, error) {
 	e := m.lookup("Insert")
 	return e.dir, e.err
 }
 
+func (m *Dirs2) Update(dir *schema.Directory) error {
+	e := m.lookup("Update")
+	return e.err
+}
+
 func (m *Dirs2) Delete(dirID string) error {
 	e := m.lookup("Delete")
 	return e.err
@@ -120,3 +144,8 @@ func (m *Dirs2) SetFiles(files []schema.File) *Dirs2 {
 	m.method[m.currentMethod].files = files
 	return m
 }
+
+func (m *Dirs2) SetDirs(dirs []schema.Directory) *Dirs2 {
+	m.method[m.currentMethod].dirs = dirs
+	return m
+}
@@ -40,6 +40,13 @@ func (m *Files) AllByChecksum(checksum string) ([]schema.File, error) {
 	return r0, r1
 }
 
+func (m *Files) UsedBy(fileID string) ([]schema.File, error) {
+	ret := m.Called(fileID)
+	r0 := ret.Get(0).([]schema.File)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
 func (m *Files) ByPath(name, dirID string) (*schema.File, error) {
 	ret := m.Called(name, dirID)
 	r0 := ret.Get(0).(*schema.File)
@@ -54,6 +61,12 @@ func (m *Files) Insert(file *schema.File, dirID string, projectID string) (*sche
 	return r0, r1
 }
 
+func (m *Files) Move(fileID, fromDirID, toDirID string) error {
+	ret := m.Called(fileID, fromDirID, toDirID)
+	r0 := ret.Error(0)
+	return r0
+}
+
 func (m *Files) Update(file *schema.File) error {
 	ret := m.Called()
 	r0 := ret.Error(0)
@@ -80,11 +93,27 @@ func (m *Files) GetProject(fileID string) (*schema.Project, error) {
 	return r0, r1
 }
 
+func (m *Files) Directory(fileID string) (*schema.Directory, error) {
+	ret := m.Called(fileID)
+	r0 := ret.Get(0).(*schema.Directory)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *Files) FileDatasets(fileID string) ([]schema.Dataset, error) {
+	ret := m.Called(fileID)
+	r0 := ret.Get(0).([]schema.Dataset)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
 type fentry struct {
-	file    *schema.File
-	err     error
-	project *schema.Project
-	files   []schema.File
+	file     *schema.File
+	err      error
+	project  *schema.Project
+	dir      *schema.Directory
+	files    []schema.File
+	datasets []schema.Dataset
 }
 
 type Files2 struct {
@@ -120,6 +149,11 @@ func (m *Files2) AllByChecksum(checksum string) ([]schema.File, error) {
 	return e.files, e.err
 }
 
+func (m *Files2) UsedBy(fileID string) ([]schema.File, error) {
+	e := m.lookup("UsedBy")
+	return e.files, e.err
+}
+
 func (m *Files2) ByPath(name, dirID string) (*schema.File, error) {
 	e := m.lookup("ByPath")
 	return e.file, e.err
@@ -130,6 +164,11 @@ func (m *Files2) Insert(file *schema.File, dirID string, projectID string) (*sch
 	return e.file, e.err
 }
 
+func (m *Files2) Move(fileID, fromDirID, toDirID string) error {
+	e := m.lookup("Move")
+	return e.err
+}
+
 func (m *Files2) Update(file *schema.File) error {
 	e := m.lookup("Update")
 	return e.err
@@ -150,6 +189,16 @@ func (m *Files2) GetProject(fileID string) (*schema.Project, error) {
 	return e.project, e.err
 }
 
+func (m *Files2) Directory(fileID string) (*schema.Directory, error) {
+	e := m.lookup("Directory")
+	return e.dir, e.err
+}
+
+func (m *Files2) FileDatasets(fileID string) ([]schema.Dataset, error) {
+	e := m.lookup("FileDatasets")
+	return e.datasets, e.err
+}
+
 func (m *Files2) On(method string) *Files2 {
 	m.currentMethod = method
 	m.method[method] = &fentry{}
@@ -175,3 +224,13 @@ func (m *Files2) SetProject(project *schema.Project) *Files2 {
 	m.method[m.currentMethod].project = project
 	return m
 }
+
+func (m *Files2) SetDatasets(datasets []schema.Dataset) *Files2 {
+	m.method[m.currentMethod].datasets = datasets
+	return m
+}
+
+func (m *Files2) SetDir(dir *schema.Directory) *Files2 {
+	m.method[m.currentMethod].dir = dir
+	return m
+}
@@ -0,0 +1,51 @@
+package mocks
+
+import "github.com/materials-commons/testify/mock"
+
+import (
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+type Trash struct {
+	mock.Mock
+}
+
+func NewMTrash() *Trash {
+	return &Trash{}
+}
+
+func (m *Trash) ByID(id string) (*schema.TrashItem, error) {
+	ret := m.Called(id)
+	r0 := ret.Get(0).(*schema.TrashItem)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *Trash) ForProject(projectID string) ([]schema.TrashItem, error) {
+	ret := m.Called(projectID)
+	r0 := ret.Get(0).([]schema.TrashItem)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *Trash) DeletedBefore(t time.Time) ([]schema.TrashItem, error) {
+	ret := m.Called(t)
+	r0 := ret.Get(0).([]schema.TrashItem)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *Trash) Insert(item *schema.TrashItem) (*schema.TrashItem, error) {
+	ret := m.Called(item)
+	r0 := ret.Get(0).(*schema.TrashItem)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+func (m *Trash) Delete(id string) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
@@ -64,6 +64,13 @@ func (m *Uploads) ForProject(projectID string) ([]schema.Upload, error) {
 	return r0, r1
 }
 
+func (m *Uploads) All() ([]schema.Upload, error) {
+	ret := m.Called()
+	r0 := ret.Get(0).([]schema.Upload)
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
 func (m *Uploads) Delete(uploadID string) error {
 	ret := m.Called(uploadID)
 
@@ -71,3 +78,11 @@ func (m *Uploads) Delete(uploadID string) error {
 
 	return r0
 }
+
+func (m *Uploads) DeleteAll() error {
+	ret := m.Called()
+
+	r0 := ret.Error(0)
+
+	return r0
+}
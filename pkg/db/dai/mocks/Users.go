@@ -20,3 +20,12 @@ func (m *Users) ByAPIKey(apikey string) (*schema.User, error) {
 
 	return r0, r1
 }
+
+func (m *Users) ByID(id string) (*schema.User, error) {
+	ret := m.Called(id)
+
+	r0 := ret.Get(0).(*schema.User)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
@@ -0,0 +1,66 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rAPITokens implements the APITokens interface for RethinkDB.
+type rAPITokens struct {
+	session *r.Session
+}
+
+// NewRAPITokens creates a new instance of rAPITokens.
+func NewRAPITokens(session *r.Session) rAPITokens {
+	return rAPITokens{
+		session: session,
+	}
+}
+
+// ByID looks up a token by its primary key.
+func (t rAPITokens) ByID(id string) (*schema.APIToken, error) {
+	var token schema.APIToken
+	if err := model.APITokens.Qs(t.session).ByID(id, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ByHash looks up a token by the hash of its secret.
+func (t rAPITokens) ByHash(hash string) (*schema.APIToken, error) {
+	var token schema.APIToken
+	rql := model.APITokens.T().GetAllByIndex("hash", hash)
+	if err := model.APITokens.Qs(t.session).Row(rql, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ForUser returns all the tokens owned by user.
+func (t rAPITokens) ForUser(user string) ([]schema.APIToken, error) {
+	var tokens []schema.APIToken
+	rql := model.APITokens.T().GetAllByIndex("owner", user)
+	if err := model.APITokens.Qs(t.session).Rows(rql, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Insert adds a new token.
+func (t rAPITokens) Insert(token *schema.APIToken) (*schema.APIToken, error) {
+	var newToken schema.APIToken
+	if err := model.APITokens.Qs(t.session).Insert(token, &newToken); err != nil {
+		return nil, err
+	}
+	return &newToken, nil
+}
+
+// Revoke marks a token as revoked. Revoked tokens are kept so
+// that they show up when a user lists their tokens.
+func (t rAPITokens) Revoke(id string) error {
+	fields := map[string]interface{}{
+		"revoked": true,
+	}
+	return model.APITokens.Qs(t.session).Update(id, fields)
+}
@@ -0,0 +1,60 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rAuditEvents implements the AuditEvents interface for RethinkDB.
+type rAuditEvents struct {
+	session *r.Session
+}
+
+// NewRAuditEvents creates a new instance of rAuditEvents.
+func NewRAuditEvents(session *r.Session) rAuditEvents {
+	return rAuditEvents{
+		session: session,
+	}
+}
+
+// Insert adds a new audit event.
+func (a rAuditEvents) Insert(event *schema.AuditEvent) error {
+	rv, err := model.AuditEvents.T().Insert(event).RunWrite(a.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Errors != 0:
+		return app.ErrCreate
+	default:
+		return nil
+	}
+}
+
+// Query returns the audit events for a project matching the query, newest first.
+func (a rAuditEvents) Query(query AuditQuery) ([]schema.AuditEvent, error) {
+	rql := model.AuditEvents.T().GetAllByIndex("project_id", query.ProjectID)
+	if query.Actor != "" {
+		rql = rql.Filter(r.Row.Field("actor").Eq(query.Actor))
+	}
+
+	if !query.Since.IsZero() {
+		rql = rql.Filter(r.Row.Field("birthtime").Ge(query.Since))
+	}
+
+	if !query.Until.IsZero() {
+		rql = rql.Filter(r.Row.Field("birthtime").Lt(query.Until))
+	}
+
+	rql = rql.OrderBy(r.Desc("birthtime"))
+	if query.Limit > 0 {
+		rql = rql.Limit(query.Limit)
+	}
+
+	var events []schema.AuditEvent
+	if err := model.AuditEvents.Qs(a.session).Rows(rql, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
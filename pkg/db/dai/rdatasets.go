@@ -0,0 +1,101 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rDatasets implements the Datasets interface for RethinkDB.
+type rDatasets struct {
+	session *r.Session
+}
+
+// NewRDatasets creates a new instance of rDatasets.
+func NewRDatasets(session *r.Session) rDatasets {
+	return rDatasets{
+		session: session,
+	}
+}
+
+// ByID looks up a dataset by its primary key.
+func (d rDatasets) ByID(id string) (*schema.Dataset, error) {
+	var dataset schema.Dataset
+	if err := model.Datasets.Qs(d.session).ByID(id, &dataset); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// Published returns the published datasets ordered by id.
+func (d rDatasets) Published() ([]schema.Dataset, error) {
+	var datasets []schema.Dataset
+	rql := model.Datasets.T().Filter(r.Row.Field("published").Eq(true)).OrderBy("id")
+	if err := model.Datasets.Qs(d.session).Rows(rql, &datasets); err != nil && err != app.ErrNotFound {
+		return nil, err
+	}
+	return datasets, nil
+}
+
+// Files returns the files in a dataset.
+func (d rDatasets) Files(datasetID string) ([]schema.File, error) {
+	var files []schema.File
+	rql := r.Table("dataset2datafile").GetAllByIndex("dataset_id", datasetID).
+		EqJoin("datafile_id", r.Table("datafiles")).Zip()
+	if err := model.Files.Qs(d.session).Rows(rql, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Insert adds a new dataset.
+func (d rDatasets) Insert(dataset *schema.Dataset) (*schema.Dataset, error) {
+	var newDataset schema.Dataset
+	if err := model.Datasets.Qs(d.session).Insert(dataset, &newDataset); err != nil {
+		return nil, err
+	}
+	return &newDataset, nil
+}
+
+// AddFiles adds the given files to a dataset.
+func (d rDatasets) AddFiles(datasetID string, fileIDs []string) error {
+	entries := make([]schema.Dataset2DataFile, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		entries = append(entries, schema.Dataset2DataFile{
+			DatasetID:  datasetID,
+			DataFileID: fileID,
+		})
+	}
+
+	rv, err := model.DatasetFiles.T().Insert(entries).RunWrite(d.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Errors != 0:
+		return app.ErrCreate
+	default:
+		return nil
+	}
+}
+
+// UpdateFields updates the fields for the given dataset.
+func (d rDatasets) UpdateFields(id string, fields map[string]interface{}) error {
+	return model.Datasets.Qs(d.session).Update(id, fields)
+}
+
+// IncrementDownloads atomically increments the download count for a dataset.
+func (d rDatasets) IncrementDownloads(id string) error {
+	rql := model.Datasets.T().Get(id).Update(map[string]interface{}{
+		"downloads": r.Row.Field("downloads").Default(0).Add(1),
+	})
+	rv, err := rql.RunWrite(d.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Skipped != 0:
+		return app.ErrNotFound
+	default:
+		return nil
+	}
+}
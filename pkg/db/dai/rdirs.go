@@ -49,6 +49,16 @@ func (d rDirs) Files(dirID string) ([]schema.File, error) {
 	return files, nil
 }
 
+// Children returns the directories whose parent is dirID.
+func (d rDirs) Children(dirID string) ([]schema.Directory, error) {
+	var dirs []schema.Directory
+	rql := model.Dirs.T().GetAllByIndex("parent", dirID)
+	if err := model.Dirs.Qs(d.session).Rows(rql, &dirs); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
 // Insert creates a new dir.
 func (d rDirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
 	var newDir schema.Directory
@@ -66,6 +76,11 @@ func (d rDirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
 	return &newDir, nil
 }
 
+// Update updates an existing directory.
+func (d rDirs) Update(dir *schema.Directory) error {
+	return model.Dirs.Qs(d.session).Update(dir.ID, dir)
+}
+
 // Delete will delete the directory from the project and the directory
 // entry. If there are files, you should call the delete for files before
 // deleting the directory.
@@ -39,8 +39,9 @@ func (f rFiles) ByChecksum(checksum string) (*schema.File, error) {
 	return &file, nil
 }
 
+// AllByChecksum returns all the files with the given checksum, including duplicates.
 func (f rFiles) AllByChecksum(checksum string) ([]schema.File, error) {
-	rql := model.Files.T().GetAllByIndex("checksum")
+	rql := model.Files.T().GetAllByIndex("checksum", checksum)
 	var files []schema.File
 	if err := model.Files.Qs(f.session).Rows(rql, &files); err != nil {
 		return nil, err
@@ -105,10 +106,13 @@ func (f rFiles) updateDependencies(fileID, dirID, projectID string) error {
 // if it isn't referred to by other files (via usesid). If the file is no longer
 // in a project and directory, but it is referenced by a usesid then it will remain
 // in place as a disconnected file. In this case its current flag will be set to false.
-// If you are deleting a file that has a parentid, then the parent will be set to
+// Files in a published dataset are also never removed, so that published datasets
+// don't change. If you are deleting a file that has a parentid, then the parent will be set to
 // current. This method will attempt to clean up as much as possible even in the face
 // of errors. If there are any errors it will return the first error. It is the calling
-// routines duty to figure out what steps could not be performed.
+// routines duty to figure out what steps could not be performed. Delete can't be
+// undone, files that users delete go into the project's trash instead (see the
+// trash package), and are only deleted from there when the trash is purged.
 func (f rFiles) Delete(fileID, directoryID, projectID string) (*schema.File, error) {
 	var firstError error // Keep the first error around
 
@@ -137,7 +141,7 @@ func (f rFiles) Delete(fileID, directoryID, projectID string) (*schema.File, err
 		return nil, err
 	}
 
-	filesUsedBy, err := f.getUsedBy(fileID)
+	filesUsedBy, err := f.UsedBy(fileID)
 
 	//
 	// TODO: Support a file existing in multiple projects.
@@ -150,7 +154,7 @@ func (f rFiles) Delete(fileID, directoryID, projectID string) (*schema.File, err
 	// the file from the project, but not completely delete
 	// the file.
 	switch {
-	case len(dirs) == 1 && len(projects) == 1 && len(filesUsedBy) == 0:
+	case len(dirs) == 1 && len(projects) == 1 && len(filesUsedBy) == 0 && !f.inPublishedDataset(fileID):
 		f.deleteFromProject(fileID, projectID)
 		model.Files.Qs(f.session).Delete(fileID)
 	case file.Current:
@@ -172,6 +176,17 @@ func (f rFiles) Delete(fileID, directoryID, projectID string) (*schema.File, err
 	return file, firstError
 }
 
+// inPublishedDataset returns true if the file is in a published dataset.
+func (f rFiles) inPublishedDataset(fileID string) bool {
+	datasets, _ := f.FileDatasets(fileID)
+	for _, ds := range datasets {
+		if ds.Published {
+			return true
+		}
+	}
+	return false
+}
+
 // getProjects returns a list of all the projects containing this fileID.
 func (f rFiles) getProjects(fileID string) ([]schema.Project2DataFile, error) {
 	rql := model.ProjectFiles.T().GetAllByIndex("datafile_id", fileID)
@@ -192,8 +207,8 @@ func (f rFiles) getDirs(fileID string) ([]schema.DataDir2DataFile, error) {
 	return dirs, nil
 }
 
-// getUsedBy returns all the files that point at this file.
-func (f rFiles) getUsedBy(fileID string) ([]schema.File, error) {
+// UsedBy returns all the files that point at this file.
+func (f rFiles) UsedBy(fileID string) ([]schema.File, error) {
 	rql := model.Files.T().GetAllByIndex("usesid", fileID)
 	var files []schema.File
 	if err := model.Files.Qs(f.session).Rows(rql, &files); err != nil {
@@ -236,6 +251,24 @@ func (f rFiles) deleteFromProject(fileID, projectID string) error {
 	}
 }
 
+// Move moves the file from one directory to another.
+func (f rFiles) Move(fileID, fromDirID, toDirID string) error {
+	rql := model.DirFiles.T().GetAllByIndex("datafile_id", fileID).
+		Filter(r.Row.Field("datadir_id").Eq(fromDirID)).
+		Update(map[string]interface{}{"datadir_id": toDirID})
+	rv, err := rql.RunWrite(f.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Errors != 0:
+		return app.ErrNotFound
+	case rv.Replaced == 0:
+		return app.ErrNotFound
+	default:
+		return nil
+	}
+}
+
 // Update updates an existing datafile.
 func (f rFiles) Update(file *schema.File) error {
 	if err := model.Files.Qs(f.session).Update(file.ID, file); err != nil {
@@ -270,6 +303,17 @@ func (f rFiles) GetProject(fileID string) (*schema.Project, error) {
 	return &projects[0], nil
 }
 
+// Directory returns the directory the file is in.
+func (f rFiles) Directory(fileID string) (*schema.Directory, error) {
+	rql := model.DirFiles.T().GetAllByIndex("datafile_id", fileID).
+		EqJoin("datadir_id", r.Table("datadirs")).Zip()
+	var dir schema.Directory
+	if err := model.Dirs.Qs(f.session).Row(rql, &dir); err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
 func (f rFiles) FileDatasets(fileID string) ([]schema.Dataset, error) {
 	rql := r.Table("dataset2datafile").
 		GetAllByIndex("datafile_id", fileID).
@@ -0,0 +1,69 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rInventory implements the Inventory interface for RethinkDB.
+type rInventory struct {
+	session *r.Session
+}
+
+// NewRInventory creates a new instance of rInventory.
+func NewRInventory(session *r.Session) rInventory {
+	return rInventory{
+		session: session,
+	}
+}
+
+// Files returns every file.
+func (i rInventory) Files() ([]schema.File, error) {
+	var files []schema.File
+	err := model.Files.Qs(i.session).Rows(model.Files.T(), &files)
+	return files, noRowsOK(err)
+}
+
+// ProjectFiles returns every project to file join entry.
+func (i rInventory) ProjectFiles() ([]schema.Project2DataFile, error) {
+	var entries []schema.Project2DataFile
+	err := model.ProjectFiles.Qs(i.session).Rows(model.ProjectFiles.T(), &entries)
+	return entries, noRowsOK(err)
+}
+
+// DirFiles returns every directory to file join entry.
+func (i rInventory) DirFiles() ([]schema.DataDir2DataFile, error) {
+	var entries []schema.DataDir2DataFile
+	err := model.DirFiles.Qs(i.session).Rows(model.DirFiles.T(), &entries)
+	return entries, noRowsOK(err)
+}
+
+// AddProjectFile adds the file to the project.
+func (i rInventory) AddProjectFile(projectID, fileID string) error {
+	entry := schema.Project2DataFile{
+		ProjectID:  projectID,
+		DataFileID: fileID,
+	}
+	return model.ProjectFiles.Qs(i.session).Insert(entry, nil)
+}
+
+// DeleteProjectFile deletes a project to file join entry.
+func (i rInventory) DeleteProjectFile(id string) error {
+	return model.ProjectFiles.Qs(i.session).Delete(id)
+}
+
+// DeleteDirFile deletes a directory to file join entry.
+func (i rInventory) DeleteDirFile(id string) error {
+	return model.DirFiles.Qs(i.session).Delete(id)
+}
+
+// noRowsOK turns the app.ErrNotFound that Rows returns for an empty table
+// into success.
+func noRowsOK(err error) error {
+	if err == app.ErrNotFound {
+		return nil
+	}
+	return err
+}
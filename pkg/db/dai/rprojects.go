@@ -43,7 +43,7 @@ func (p rProjects) ByName(name string, owner string) (*schema.Project, error) {
 	numProjects := len(projects)
 	switch {
 	case numProjects == 0:
-		return nil, nil
+		return nil, app.ErrNotFound
 	case numProjects > 1:
 		app.Log.Critf("Projects table corrupted, there are multiple projects for user '%s' with name '%s'", owner, name)
 		return &projects[0], nil
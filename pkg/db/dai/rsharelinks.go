@@ -0,0 +1,60 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rShareLinks implements the ShareLinks interface for RethinkDB.
+type rShareLinks struct {
+	session *r.Session
+}
+
+// NewRShareLinks creates a new instance of rShareLinks.
+func NewRShareLinks(session *r.Session) rShareLinks {
+	return rShareLinks{
+		session: session,
+	}
+}
+
+// ByID looks up a share link by its primary key.
+func (l rShareLinks) ByID(id string) (*schema.ShareLink, error) {
+	var link schema.ShareLink
+	if err := model.ShareLinks.Qs(l.session).ByID(id, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Insert adds a new share link.
+func (l rShareLinks) Insert(link *schema.ShareLink) (*schema.ShareLink, error) {
+	var newLink schema.ShareLink
+	if err := model.ShareLinks.Qs(l.session).Insert(link, &newLink); err != nil {
+		return nil, err
+	}
+	return &newLink, nil
+}
+
+// IncrementDownloads atomically increments the download count for a link. It
+// returns app.ErrNoAccess if the link has already reached its download limit.
+func (l rShareLinks) IncrementDownloads(id string) error {
+	rql := model.ShareLinks.T().Get(id).Update(func(row r.Term) interface{} {
+		underLimit := row.Field("max_downloads").Eq(0).Or(row.Field("downloads").Lt(row.Field("max_downloads")))
+		return r.Branch(underLimit,
+			map[string]interface{}{"downloads": row.Field("downloads").Add(1)},
+			r.Error("download limit reached"))
+	})
+	rv, err := rql.RunWrite(l.session)
+	switch {
+	case err != nil:
+		return err
+	case rv.Skipped != 0:
+		return app.ErrNotFound
+	case rv.Errors != 0:
+		return app.ErrNoAccess
+	default:
+		return nil
+	}
+}
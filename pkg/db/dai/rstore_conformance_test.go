@@ -0,0 +1,66 @@
+package dai
+
+import (
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db"
+	"github.com/materials-commons/mcstore/pkg/db/migrate"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The conformance run uses its own database on the test server, so that
+// emptying the tables between specs doesn't disturb the mctestdb fixtures.
+const rConformanceDB = "mcstoreconformance"
+
+var _ = Describe("RStore", func() {
+	itBehavesLikeAStore(func() fixtureStore {
+		session := db.RSessionUsingMust("localhost:30815", rConformanceDB)
+		migrator := migrate.New(session, rConformanceDB)
+		pending, err := migrator.Pending()
+		Expect(err).To(BeNil())
+		for _, migration := range pending {
+			Expect(migrator.Apply(migration)).To(BeNil())
+		}
+		emptyRTables(session)
+		return &rFixtureStore{Store: NewRStore(session), session: session}
+	})
+})
+
+// emptyRTables deletes the documents in every table the migrations create.
+func emptyRTables(session *r.Session) {
+	seen := make(map[string]bool)
+	for _, migration := range migrate.Migrations {
+		for _, step := range migration.Steps {
+			if seen[step.Table] {
+				continue
+			}
+			seen[step.Table] = true
+			Expect(r.Table(step.Table).Delete().Exec(session)).To(BeNil())
+		}
+	}
+}
+
+// rFixtureStore adds users and access entries to an rStore. mcstore doesn't
+// create either, so the rStore has no way to.
+type rFixtureStore struct {
+	Store
+	session *r.Session
+}
+
+// AddUser adds a user. It returns app.ErrExists if there is already a user
+// with the same id.
+func (s *rFixtureStore) AddUser(user schema.User) error {
+	resp, err := r.Table("users").Insert(user).RunWrite(s.session)
+	if resp.Errors != 0 {
+		// A duplicate id is the only write error an insert gets.
+		return app.ErrExists
+	}
+	return err
+}
+
+// AddAccess gives a user access to a project.
+func (s *rFixtureStore) AddAccess(access schema.Access) error {
+	return r.Table("access").Insert(access).Exec(s.session)
+}
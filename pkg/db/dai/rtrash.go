@@ -0,0 +1,67 @@
+package dai
+
+import (
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/model"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// rTrash implements the Trash interface for RethinkDB.
+type rTrash struct {
+	session *r.Session
+}
+
+// NewRTrash creates a new instance of rTrash.
+func NewRTrash(session *r.Session) rTrash {
+	return rTrash{
+		session: session,
+	}
+}
+
+// ByID looks up a trash item.
+func (t rTrash) ByID(id string) (*schema.TrashItem, error) {
+	var item schema.TrashItem
+	if err := model.Trash.Qs(t.session).ByID(id, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ForProject returns the items in a project's trash, newest first.
+func (t rTrash) ForProject(projectID string) ([]schema.TrashItem, error) {
+	rql := model.Trash.T().GetAllByIndex("project_id", projectID).OrderBy(r.Desc("birthtime"))
+	return t.rows(rql)
+}
+
+// DeletedBefore returns the items in every project's trash that were deleted
+// before the given time, newest first.
+func (t rTrash) DeletedBefore(before time.Time) ([]schema.TrashItem, error) {
+	rql := model.Trash.T().Filter(r.Row.Field("birthtime").Lt(before)).OrderBy(r.Desc("birthtime"))
+	return t.rows(rql)
+}
+
+// rows runs a query for trash items. No matches is an empty list, not an error.
+func (t rTrash) rows(rql r.Term) ([]schema.TrashItem, error) {
+	var items []schema.TrashItem
+	if err := model.Trash.Qs(t.session).Rows(rql, &items); err != nil && err != app.ErrNotFound {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Insert adds a new trash item.
+func (t rTrash) Insert(item *schema.TrashItem) (*schema.TrashItem, error) {
+	var newItem schema.TrashItem
+	if err := model.Trash.Qs(t.session).Insert(item, &newItem); err != nil {
+		return nil, err
+	}
+	return &newItem, nil
+}
+
+// Delete removes a trash item. It doesn't touch what the item holds.
+func (t rTrash) Delete(id string) error {
+	return model.Trash.Qs(t.session).Delete(id)
+}
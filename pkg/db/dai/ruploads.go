@@ -103,6 +103,18 @@ func (u rUploads) ForProject(projectID string) ([]schema.Upload, error) {
 	return uploads, nil
 }
 
+// All returns every upload.
+func (u rUploads) All() ([]schema.Upload, error) {
+	var uploads []schema.Upload
+	if err := model.Uploads.Qs(u.session).Rows(model.Uploads.T(), &uploads); err != nil {
+		return nil, err
+	}
+	for i := range uploads {
+		uploads[i].File.Blocks = toBitSet(uploads[i].File.BitString)
+	}
+	return uploads, nil
+}
+
 // Delete deletes the given upload id
 func (u rUploads) Delete(uploadID string) error {
 	return model.Uploads.Qs(u.session).Delete(uploadID)
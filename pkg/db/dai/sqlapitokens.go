@@ -0,0 +1,84 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlAPITokens implements the APITokens interface for a SQLStore.
+type sqlAPITokens struct {
+	store *SQLStore
+}
+
+// ByID looks up a token by its primary key.
+func (t sqlAPITokens) ByID(id string) (*schema.APIToken, error) {
+	return tokenByID(t.store.db, id, "")
+}
+
+// ByHash looks up a token by the hash of its secret.
+func (t sqlAPITokens) ByHash(hash string) (*schema.APIToken, error) {
+	var token schema.APIToken
+	if err := getDoc(t.store.db, &token, "select doc from apitokens where hash = ?", hash); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ForUser returns all the tokens owned by user.
+func (t sqlAPITokens) ForUser(user string) ([]schema.APIToken, error) {
+	docs, err := selectDocs(t.store.db, "select doc from apitokens where owner = ?", user)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []schema.APIToken
+	for _, doc := range docs {
+		var token schema.APIToken
+		if err := decodeDoc(doc, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// Insert adds a new token.
+func (t sqlAPITokens) Insert(token *schema.APIToken) (*schema.APIToken, error) {
+	newToken := *token
+	if newToken.ID == "" {
+		newToken.ID = newID()
+	} else if exists, err := rowExists(t.store.db, "apitokens", newToken.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, app.ErrCreate
+	}
+
+	query := "insert into apitokens(id, hash, owner, doc) values(?, ?, ?, ?)"
+	if err := insertDoc(t.store.db, query, &newToken, newToken.ID, newToken.Hash, newToken.Owner); err != nil {
+		return nil, err
+	}
+	return &newToken, nil
+}
+
+// Revoke marks a token as revoked.
+func (t sqlAPITokens) Revoke(id string) error {
+	return t.store.transact(func(tx *sqlx.Tx) error {
+		token, err := tokenByID(tx, id, t.store.forUpdate())
+		if err != nil {
+			return err
+		}
+
+		token.Revoked = true
+		return updateDoc(tx, "update apitokens set doc = ? where id = ?", token, token.ID)
+	})
+}
+
+// tokenByID looks up a token. The suffix is added to the query.
+func tokenByID(q sqlx.Ext, id, suffix string) (*schema.APIToken, error) {
+	var token schema.APIToken
+	if err := getDoc(q, &token, "select doc from apitokens where id = ?"+suffix, id); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
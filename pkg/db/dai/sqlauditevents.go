@@ -0,0 +1,67 @@
+package dai
+
+import (
+	"fmt"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlAuditEvents implements the AuditEvents interface for a SQLStore. The
+// birthtime column holds the event time in nanoseconds since the epoch so
+// that it sorts and compares the same way in every database.
+type sqlAuditEvents struct {
+	store *SQLStore
+}
+
+// Insert adds a new audit event.
+func (a sqlAuditEvents) Insert(event *schema.AuditEvent) error {
+	newEvent := *event
+	if newEvent.ID == "" {
+		newEvent.ID = newID()
+	}
+
+	query := "insert into audit_events(id, project_id, actor, birthtime, doc) values(?, ?, ?, ?, ?)"
+	return insertDoc(a.store.db, query, &newEvent, newEvent.ID, newEvent.ProjectID, newEvent.Actor,
+		newEvent.Birthtime.UnixNano())
+}
+
+// Query returns the audit events for a project matching the query, newest first.
+func (a sqlAuditEvents) Query(query AuditQuery) ([]schema.AuditEvent, error) {
+	sql := "select doc from audit_events where project_id = ?"
+	args := []interface{}{query.ProjectID}
+
+	if query.Actor != "" {
+		sql += " and actor = ?"
+		args = append(args, query.Actor)
+	}
+
+	if !query.Since.IsZero() {
+		sql += " and birthtime >= ?"
+		args = append(args, query.Since.UnixNano())
+	}
+
+	if !query.Until.IsZero() {
+		sql += " and birthtime < ?"
+		args = append(args, query.Until.UnixNano())
+	}
+
+	sql += " order by birthtime desc"
+	if query.Limit > 0 {
+		sql += fmt.Sprintf(" limit %d", query.Limit)
+	}
+
+	docs, err := selectDocs(a.store.db, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []schema.AuditEvent
+	for _, doc := range docs {
+		var event schema.AuditEvent
+		if err := decodeDoc(doc, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
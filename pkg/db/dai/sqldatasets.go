@@ -0,0 +1,123 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlDatasets implements the Datasets interface for a SQLStore.
+type sqlDatasets struct {
+	store *SQLStore
+}
+
+// ByID looks up a dataset by its primary key.
+func (d sqlDatasets) ByID(id string) (*schema.Dataset, error) {
+	return datasetByID(d.store.db, id, "")
+}
+
+// Published returns the published datasets ordered by id.
+func (d sqlDatasets) Published() ([]schema.Dataset, error) {
+	return selectDatasets(d.store.db, "select doc from datasets where published = ? order by id", true)
+}
+
+// Files returns the files in a dataset.
+func (d sqlDatasets) Files(datasetID string) ([]schema.File, error) {
+	query := `
+            select f.doc from datafiles f
+                join dataset2datafile df on df.datafile_id = f.id
+            where df.dataset_id = ?
+        `
+	return selectFiles(d.store.db, query, datasetID)
+}
+
+// Insert adds a new dataset.
+func (d sqlDatasets) Insert(dataset *schema.Dataset) (*schema.Dataset, error) {
+	newDataset := *dataset
+	if newDataset.ID == "" {
+		newDataset.ID = newID()
+	} else if exists, err := rowExists(d.store.db, "datasets", newDataset.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, app.ErrCreate
+	}
+
+	query := "insert into datasets(id, project_id, published, doc) values(?, ?, ?, ?)"
+	err := insertDoc(d.store.db, query, &newDataset, newDataset.ID, newDataset.ProjectID, newDataset.Published)
+	if err != nil {
+		return nil, err
+	}
+	return &newDataset, nil
+}
+
+// AddFiles adds the given files to a dataset.
+func (d sqlDatasets) AddFiles(datasetID string, fileIDs []string) error {
+	return d.store.transact(func(tx *sqlx.Tx) error {
+		query := tx.Rebind("insert into dataset2datafile(id, dataset_id, datafile_id) values(?, ?, ?)")
+		for _, fileID := range fileIDs {
+			if _, err := tx.Exec(query, newID(), datasetID, fileID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateFields updates the fields for the given dataset.
+func (d sqlDatasets) UpdateFields(id string, fields map[string]interface{}) error {
+	return d.update(id, func(dataset *schema.Dataset) error {
+		return setFields(dataset, fields)
+	})
+}
+
+// IncrementDownloads increments the download count for a dataset.
+func (d sqlDatasets) IncrementDownloads(id string) error {
+	return d.update(id, func(dataset *schema.Dataset) error {
+		dataset.Downloads++
+		return nil
+	})
+}
+
+// update reads the dataset, lets fn change it and writes it back.
+func (d sqlDatasets) update(id string, fn func(dataset *schema.Dataset) error) error {
+	return d.store.transact(func(tx *sqlx.Tx) error {
+		dataset, err := datasetByID(tx, id, d.store.forUpdate())
+		if err != nil {
+			return err
+		}
+
+		if err := fn(dataset); err != nil {
+			return err
+		}
+
+		query := "update datasets set doc = ?, project_id = ?, published = ? where id = ?"
+		return updateDoc(tx, query, dataset, dataset.ProjectID, dataset.Published, dataset.ID)
+	})
+}
+
+// datasetByID looks up a dataset. The suffix is added to the query.
+func datasetByID(q sqlx.Ext, id, suffix string) (*schema.Dataset, error) {
+	var dataset schema.Dataset
+	if err := getDoc(q, &dataset, "select doc from datasets where id = ?"+suffix, id); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// selectDatasets runs a query that selects dataset docs.
+func selectDatasets(q sqlx.Ext, query string, args ...interface{}) ([]schema.Dataset, error) {
+	docs, err := selectDocs(q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var datasets []schema.Dataset
+	for _, doc := range docs {
+		var dataset schema.Dataset
+		if err := decodeDoc(doc, &dataset); err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, dataset)
+	}
+	return datasets, nil
+}
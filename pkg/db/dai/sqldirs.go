@@ -0,0 +1,112 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlDirs implements the Dirs interface for a SQLStore.
+type sqlDirs struct {
+	store *SQLStore
+}
+
+// ByID looks up a directory by the given id.
+func (d sqlDirs) ByID(id string) (*schema.Directory, error) {
+	var dir schema.Directory
+	if err := getDoc(d.store.db, &dir, "select doc from datadirs where id = ?", id); err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
+// ByPath looks up a directory in a project by its path.
+func (d sqlDirs) ByPath(path, projectID string) (*schema.Directory, error) {
+	var dir schema.Directory
+	query := "select doc from datadirs where project = ? and name = ?"
+	if err := getDoc(d.store.db, &dir, query, projectID, path); err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
+// Files returns the files for the given directory id.
+func (d sqlDirs) Files(dirID string) ([]schema.File, error) {
+	query := `
+            select f.doc from datafiles f
+                join datadir2datafile d on d.datafile_id = f.id
+            where d.datadir_id = ?
+        `
+	return selectFiles(d.store.db, query, dirID)
+}
+
+// Children returns the directories whose parent is dirID.
+func (d sqlDirs) Children(dirID string) ([]schema.Directory, error) {
+	docs, err := selectDocs(d.store.db, "select doc from datadirs where parent = ?", dirID)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []schema.Directory
+	for _, doc := range docs {
+		var dir schema.Directory
+		if err := decodeDoc(doc, &dir); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// Insert creates a new dir.
+func (d sqlDirs) Insert(dir *schema.Directory) (*schema.Directory, error) {
+	var newDir *schema.Directory
+	err := d.store.transact(func(tx *sqlx.Tx) error {
+		var err error
+		newDir, err = insertDir(tx, dir)
+		return err
+	})
+	return newDir, err
+}
+
+// insertDir creates a new dir and adds it to its project.
+func insertDir(e sqlx.Ext, dir *schema.Directory) (*schema.Directory, error) {
+	newDir := *dir
+	if newDir.ID == "" {
+		newDir.ID = newID()
+	} else if exists, err := rowExists(e, "datadirs", newDir.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, app.ErrCreate
+	}
+
+	query := "insert into datadirs(id, name, project, parent, doc) values(?, ?, ?, ?, ?)"
+	if err := insertDoc(e, query, &newDir, newDir.ID, newDir.Name, newDir.Project, newDir.Parent); err != nil {
+		return nil, err
+	}
+
+	query = "insert into project2datadir(id, project_id, datadir_id) values(?, ?, ?)"
+	if _, err := e.Exec(e.Rebind(query), newID(), newDir.Project, newDir.ID); err != nil {
+		return nil, err
+	}
+
+	return &newDir, nil
+}
+
+// Update updates an existing directory.
+func (d sqlDirs) Update(dir *schema.Directory) error {
+	query := "update datadirs set doc = ?, name = ?, project = ?, parent = ? where id = ?"
+	return updateDoc(d.store.db, query, dir, dir.Name, dir.Project, dir.Parent, dir.ID)
+}
+
+// Delete will delete the directory from the project and the directory
+// entry. If there are files, you should call the delete for files before
+// deleting the directory.
+func (d sqlDirs) Delete(dirID string) error {
+	return d.store.transact(func(tx *sqlx.Tx) error {
+		if err := execExpectRows(tx, "delete from datadirs where id = ?", dirID); err != nil {
+			return err
+		}
+		return execExpectRows(tx, "delete from project2datadir where datadir_id = ?", dirID)
+	})
+}
@@ -0,0 +1,279 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlFiles implements the Files interface for a SQLStore.
+type sqlFiles struct {
+	store *SQLStore
+}
+
+// ByID looks up a file by its primary key.
+func (f sqlFiles) ByID(id string) (*schema.File, error) {
+	return fileByID(f.store.db, id, "")
+}
+
+// ByChecksum looks up a file by its checksum. This routine only returns the original
+// root entry, it will not return entries that are duplicates and point at the root.
+func (f sqlFiles) ByChecksum(checksum string) (*schema.File, error) {
+	var file schema.File
+	query := "select doc from datafiles where checksum = ? and usesid = ''"
+	if err := getDoc(f.store.db, &file, query, checksum); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// AllByChecksum returns all the files with the given checksum, including duplicates.
+func (f sqlFiles) AllByChecksum(checksum string) ([]schema.File, error) {
+	return selectFiles(f.store.db, "select doc from datafiles where checksum = ?", checksum)
+}
+
+// UsedBy returns all the files that point at this file.
+func (f sqlFiles) UsedBy(fileID string) ([]schema.File, error) {
+	return selectFiles(f.store.db, "select doc from datafiles where usesid = ?", fileID)
+}
+
+// ByPath looks up a file by its name in a specific directory. It only returns the
+// current file, not hidden files.
+func (f sqlFiles) ByPath(name, dirID string) (*schema.File, error) {
+	var file schema.File
+	query := `
+            select f.doc from datafiles f
+                join datadir2datafile d on d.datafile_id = f.id
+            where d.datadir_id = ? and f.name = ? and f.current = ?
+        `
+	if err := getDoc(f.store.db, &file, query, dirID, name, true); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Insert adds a new file to the given directory and project.
+func (f sqlFiles) Insert(file *schema.File, dirID string, projectID string) (*schema.File, error) {
+	newFile := *file
+	err := f.store.transact(func(tx *sqlx.Tx) error {
+		if newFile.ID == "" {
+			newFile.ID = newID()
+		} else if exists, err := rowExists(tx, "datafiles", newFile.ID); err != nil {
+			return err
+		} else if exists {
+			return app.ErrCreate
+		}
+
+		query := "insert into datafiles(id, name, checksum, usesid, current, doc) values(?, ?, ?, ?, ?, ?)"
+		err := insertDoc(tx, query, &newFile, newFile.ID, newFile.Name, newFile.Checksum, newFile.UsesID, newFile.Current)
+		if err != nil {
+			return err
+		}
+
+		query = "insert into datadir2datafile(id, datadir_id, datafile_id) values(?, ?, ?)"
+		if _, err := tx.Exec(tx.Rebind(query), newID(), dirID, newFile.ID); err != nil {
+			return err
+		}
+
+		query = "insert into project2datafile(id, project_id, datafile_id) values(?, ?, ?)"
+		_, err = tx.Exec(tx.Rebind(query), newID(), projectID, newFile.ID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &newFile, nil
+}
+
+// Delete will delete the file from the directory and project. See rFiles.Delete
+// for the rules on when the file entry itself is removed.
+func (f sqlFiles) Delete(fileID, directoryID, projectID string) (*schema.File, error) {
+	var (
+		file       *schema.File
+		firstError error
+	)
+
+	err := f.store.transact(func(tx *sqlx.Tx) error {
+		projects, err := count(tx, "select count(*) from project2datafile where datafile_id = ?", fileID)
+		if err != nil {
+			return err
+		}
+
+		dirs, err := count(tx, "select count(*) from datadir2datafile where datafile_id = ?", fileID)
+		if err != nil {
+			return err
+		}
+
+		query := "delete from datadir2datafile where datafile_id = ? and datadir_id = ?"
+		switch err := execExpectRows(tx, query, fileID, directoryID); {
+		case err == app.ErrNotFound:
+			firstError = err
+		case err != nil:
+			return err
+		}
+
+		if file, err = fileByID(tx, fileID, f.store.forUpdate()); err != nil {
+			return err
+		}
+
+		usedBy, err := count(tx, "select count(*) from datafiles where usesid = ?", fileID)
+		if err != nil {
+			return err
+		}
+
+		query = `
+                select count(*) from datasets d
+                    join dataset2datafile df on df.dataset_id = d.id
+                where df.datafile_id = ? and d.published = ?
+            `
+		published, err := count(tx, query, fileID, true)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case dirs == 1 && projects == 1 && usedBy == 0 && published == 0:
+			query := "delete from project2datafile where datafile_id = ? and project_id = ?"
+			if _, err := tx.Exec(tx.Rebind(query), fileID, projectID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(tx.Rebind("delete from datafiles where id = ?"), fileID); err != nil {
+				return err
+			}
+		case file.Current:
+			// File is referenced by somebody, so just mark it as
+			// not current, since we cannot delete it.
+			file.Current = false
+			if err := updateFile(tx, file); err != nil {
+				return err
+			}
+		}
+
+		// The previous version of the file becomes the current file.
+		if file.Parent != "" {
+			switch parent, err := fileByID(tx, file.Parent, f.store.forUpdate()); {
+			case err == app.ErrNotFound:
+			case err != nil:
+				return err
+			default:
+				parent.Current = true
+				return updateFile(tx, parent)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return file, firstError
+}
+
+// Move moves the file from one directory to another.
+func (f sqlFiles) Move(fileID, fromDirID, toDirID string) error {
+	query := "update datadir2datafile set datadir_id = ? where datafile_id = ? and datadir_id = ?"
+	return execExpectRows(f.store.db, query, toDirID, fileID, fromDirID)
+}
+
+// Update updates an existing datafile.
+func (f sqlFiles) Update(file *schema.File) error {
+	return updateFile(f.store.db, file)
+}
+
+// UpdateFields updates the fields for the given id.
+func (f sqlFiles) UpdateFields(fileID string, fields map[string]interface{}) error {
+	return f.store.transact(func(tx *sqlx.Tx) error {
+		file, err := fileByID(tx, fileID, f.store.forUpdate())
+		if err != nil {
+			return err
+		}
+
+		if err := setFields(file, fields); err != nil {
+			return err
+		}
+		return updateFile(tx, file)
+	})
+}
+
+// GetProject returns the project the file is in.
+func (f sqlFiles) GetProject(fileID string) (*schema.Project, error) {
+	var project schema.Project
+	query := `
+            select p.doc from projects p
+                join project2datadir pd on pd.project_id = p.id
+                join datadir2datafile df on df.datadir_id = pd.datadir_id
+            where df.datafile_id = ?
+        `
+	if err := getDoc(f.store.db, &project, query, fileID); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// Directory returns the directory the file is in.
+func (f sqlFiles) Directory(fileID string) (*schema.Directory, error) {
+	var dir schema.Directory
+	query := `
+            select d.doc from datadirs d
+                join datadir2datafile df on df.datadir_id = d.id
+            where df.datafile_id = ?
+        `
+	if err := getDoc(f.store.db, &dir, query, fileID); err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
+// FileDatasets returns the datasets the file is in.
+func (f sqlFiles) FileDatasets(fileID string) ([]schema.Dataset, error) {
+	query := `
+            select d.doc from datasets d
+                join dataset2datafile df on df.dataset_id = d.id
+            where df.datafile_id = ?
+        `
+	datasets, err := selectDatasets(f.store.db, query, fileID)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(datasets) == 0:
+		return nil, app.ErrNotFound
+	default:
+		return datasets, nil
+	}
+}
+
+// fileByID looks up a file. The suffix is added to the query, it is used
+// to lock the row when the file is going to be updated.
+func fileByID(q sqlx.Ext, id, suffix string) (*schema.File, error) {
+	var file schema.File
+	if err := getDoc(q, &file, "select doc from datafiles where id = ?"+suffix, id); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// updateFile writes file over the existing entry.
+func updateFile(e sqlx.Ext, file *schema.File) error {
+	query := "update datafiles set doc = ?, name = ?, checksum = ?, usesid = ?, current = ? where id = ?"
+	return updateDoc(e, query, file, file.Name, file.Checksum, file.UsesID, file.Current, file.ID)
+}
+
+// selectFiles runs a query that selects file docs.
+func selectFiles(q sqlx.Ext, query string, args ...interface{}) ([]schema.File, error) {
+	docs, err := selectDocs(q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []schema.File
+	for _, doc := range docs {
+		var file schema.File
+		if err := decodeDoc(doc, &file); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,70 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlInventory implements the Inventory interface for a SQLStore.
+type sqlInventory struct {
+	store *SQLStore
+}
+
+// Files returns every file.
+func (i sqlInventory) Files() ([]schema.File, error) {
+	return selectFiles(i.store.db, "select doc from datafiles")
+}
+
+// ProjectFiles returns every project to file join entry.
+func (i sqlInventory) ProjectFiles() ([]schema.Project2DataFile, error) {
+	rows, err := i.store.db.Query("select id, project_id, datafile_id from project2datafile")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []schema.Project2DataFile
+	for rows.Next() {
+		var entry schema.Project2DataFile
+		if err := rows.Scan(&entry.ID, &entry.ProjectID, &entry.DataFileID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DirFiles returns every directory to file join entry.
+func (i sqlInventory) DirFiles() ([]schema.DataDir2DataFile, error) {
+	rows, err := i.store.db.Query("select id, datadir_id, datafile_id from datadir2datafile")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []schema.DataDir2DataFile
+	for rows.Next() {
+		var entry schema.DataDir2DataFile
+		if err := rows.Scan(&entry.ID, &entry.DataDirID, &entry.DataFileID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// AddProjectFile adds the file to the project.
+func (i sqlInventory) AddProjectFile(projectID, fileID string) error {
+	query := "insert into project2datafile(id, project_id, datafile_id) values(?, ?, ?)"
+	_, err := i.store.db.Exec(i.store.db.Rebind(query), newID(), projectID, fileID)
+	return err
+}
+
+// DeleteProjectFile deletes a project to file join entry.
+func (i sqlInventory) DeleteProjectFile(id string) error {
+	return execExpectRows(i.store.db, "delete from project2datafile where id = ?", id)
+}
+
+// DeleteDirFile deletes a directory to file join entry.
+func (i sqlInventory) DeleteDirFile(id string) error {
+	return execExpectRows(i.store.db, "delete from datadir2datafile where id = ?", id)
+}
@@ -0,0 +1,244 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+)
+
+// SQLMigration is a numbered change to the SQL schema. Migrations are applied
+// in order, and each one is applied once.
+type SQLMigration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// sqlMigrations are the changes that build the SQL schema. To change the
+// schema add a new migration to the end, never edit one that has shipped.
+var sqlMigrations = []SQLMigration{
+	{
+		Version:     1,
+		Description: "Create the tables and indexes for users, projects, directories, files and uploads",
+		Statements: []string{
+			`create table users(
+                id varchar(255) primary key,
+                apikey varchar(255),
+                doc text not null
+            )`,
+			"create index users_apikey on users(apikey)",
+
+			`create table projects(
+                id varchar(64) primary key,
+                name text,
+                owner varchar(255),
+                doc text not null
+            )`,
+			"create index projects_owner on projects(owner)",
+
+			`create table access(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                user_id varchar(255),
+                doc text not null
+            )`,
+			"create index access_project_id on access(project_id)",
+			"create index access_user_id on access(user_id)",
+
+			`create table datadirs(
+                id varchar(64) primary key,
+                name text,
+                project varchar(64),
+                parent varchar(64),
+                doc text not null
+            )`,
+			"create index datadirs_project on datadirs(project)",
+			"create index datadirs_parent on datadirs(parent)",
+
+			`create table datafiles(
+                id varchar(64) primary key,
+                name text,
+                checksum varchar(64),
+                usesid varchar(64),
+                current boolean,
+                doc text not null
+            )`,
+			"create index datafiles_checksum on datafiles(checksum)",
+			"create index datafiles_usesid on datafiles(usesid)",
+
+			`create table project2datadir(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                datadir_id varchar(64)
+            )`,
+			"create index project2datadir_project_id on project2datadir(project_id)",
+			"create index project2datadir_datadir_id on project2datadir(datadir_id)",
+
+			`create table project2datafile(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                datafile_id varchar(64)
+            )`,
+			"create index project2datafile_project_id on project2datafile(project_id)",
+			"create index project2datafile_datafile_id on project2datafile(datafile_id)",
+
+			`create table datadir2datafile(
+                id varchar(64) primary key,
+                datadir_id varchar(64),
+                datafile_id varchar(64)
+            )`,
+			"create index datadir2datafile_datadir_id on datadir2datafile(datadir_id)",
+			"create index datadir2datafile_datafile_id on datadir2datafile(datafile_id)",
+
+			`create table uploads(
+                id varchar(64) primary key,
+                owner varchar(255),
+                project_id varchar(64),
+                directory_id varchar(64),
+                file_name text,
+                file_checksum varchar(64),
+                doc text not null
+            )`,
+			"create index uploads_owner on uploads(owner)",
+			"create index uploads_project_id on uploads(project_id)",
+		},
+	},
+
+	{
+		Version:     2,
+		Description: "Create the tables and indexes for api tokens, share links, datasets and audit events",
+		Statements: []string{
+			`create table apitokens(
+                id varchar(64) primary key,
+                hash varchar(255),
+                owner varchar(255),
+                doc text not null
+            )`,
+			"create index apitokens_hash on apitokens(hash)",
+			"create index apitokens_owner on apitokens(owner)",
+
+			`create table sharelinks(
+                id varchar(64) primary key,
+                doc text not null
+            )`,
+
+			`create table datasets(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                published boolean,
+                doc text not null
+            )`,
+			"create index datasets_project_id on datasets(project_id)",
+
+			`create table dataset2datafile(
+                id varchar(64) primary key,
+                dataset_id varchar(64),
+                datafile_id varchar(64)
+            )`,
+			"create index dataset2datafile_dataset_id on dataset2datafile(dataset_id)",
+			"create index dataset2datafile_datafile_id on dataset2datafile(datafile_id)",
+
+			`create table audit_events(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                actor varchar(255),
+                birthtime bigint,
+                doc text not null
+            )`,
+			"create index audit_events_project_id on audit_events(project_id, birthtime)",
+		},
+	},
+	{
+		Version:     3,
+		Description: "Create the table for the project trash",
+		Statements: []string{
+			`create table trash(
+                id varchar(64) primary key,
+                project_id varchar(64),
+                birthtime bigint,
+                doc text not null
+            )`,
+			"create index trash_project_id on trash(project_id, birthtime)",
+			"create index trash_birthtime on trash(birthtime)",
+		},
+	},
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database. It is 0 for a new database.
+func (s *SQLStore) SchemaVersion() (int, error) {
+	if err := s.createVersionTable(); err != nil {
+		return 0, err
+	}
+	return count(s.db, "select coalesce(max(version), 0) from schema_version")
+}
+
+// LatestSchemaVersion is the version the database is at once every migration
+// has been applied.
+func LatestSchemaVersion() int {
+	return sqlMigrations[len(sqlMigrations)-1].Version
+}
+
+// PendingMigrations returns the migrations the database doesn't have yet, in
+// the order Migrate applies them.
+func (s *SQLStore) PendingMigrations() ([]SQLMigration, error) {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []SQLMigration
+	for _, migration := range sqlMigrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the migrations the database doesn't have yet. It returns
+// the number of migrations applied.
+func (s *SQLStore) Migrate() (int, error) {
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, migration := range pending {
+		if err := s.ApplyMigration(migration); err != nil {
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+// ApplyMigration runs the statements in migration and records its version, all
+// in one transaction.
+func (s *SQLStore) ApplyMigration(migration SQLMigration) error {
+	err := s.transact(func(tx *sqlx.Tx) error {
+		for _, statement := range migration.Statements {
+			if _, err := tx.Exec(statement); err != nil {
+				app.Log.Errorf("migration %d failed on %s: %s", migration.Version, statement, err)
+				return err
+			}
+		}
+		_, err := tx.Exec(tx.Rebind("insert into schema_version(version, description) values(?, ?)"),
+			migration.Version, migration.Description)
+		return err
+	})
+
+	if err != nil {
+		return app.Errorf(err, "migration %d (%s)", migration.Version, migration.Description)
+	}
+	return nil
+}
+
+// createVersionTable creates the table that records the applied migrations.
+func (s *SQLStore) createVersionTable() error {
+	_, err := s.db.Exec(`
+            create table if not exists schema_version(
+                version integer primary key,
+                description text
+            )`)
+	return err
+}
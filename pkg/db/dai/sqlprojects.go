@@ -0,0 +1,122 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlProjects implements the Projects interface for a SQLStore.
+type sqlProjects struct {
+	store *SQLStore
+}
+
+// ByID looks up a project by the given id.
+func (p sqlProjects) ByID(id string) (*schema.Project, error) {
+	var project schema.Project
+	if err := getDoc(p.store.db, &project, "select doc from projects where id = ?", id); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ByName looks up a project by its name and owner.
+func (p sqlProjects) ByName(name string, owner string) (*schema.Project, error) {
+	var project schema.Project
+	query := "select doc from projects where owner = ? and name = ?"
+	if err := getDoc(p.store.db, &project, query, owner, name); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ForUser returns the projects owned by user, or all the projects the user
+// has access to when ownedOnly is false.
+func (p sqlProjects) ForUser(user string, ownedOnly bool) ([]schema.Project, error) {
+	query := `
+            select p.doc from projects p
+                join access a on a.project_id = p.id
+            where a.user_id = ?
+        `
+	if ownedOnly {
+		query = "select doc from projects where owner = ?"
+	}
+
+	docs, err := selectDocs(p.store.db, query, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []schema.Project
+	for _, doc := range docs {
+		var project schema.Project
+		if err := decodeDoc(doc, &project); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// Insert creates a new project for the given owner. It also creates the directory associated
+// with the project.
+func (p sqlProjects) Insert(project *schema.Project) (*schema.Project, error) {
+	if project.DataDir != "" {
+		return nil, app.ErrInvalid
+	}
+
+	newProject := *project
+	err := p.store.transact(func(tx *sqlx.Tx) error {
+		if newProject.ID == "" {
+			newProject.ID = newID()
+		} else if exists, err := rowExists(tx, "projects", newProject.ID); err != nil {
+			return err
+		} else if exists {
+			return app.ErrCreate
+		}
+
+		if err := insertAccess(tx, schema.NewAccess(newProject.ID, newProject.Name, newProject.Owner)); err != nil {
+			return err
+		}
+
+		dir := schema.NewDirectory(project.Name, project.Owner, newProject.ID, "")
+		newDir, err := insertDir(tx, &dir)
+		if err != nil {
+			return app.ErrCreate
+		}
+
+		newProject.DataDir = newDir.ID
+		query := "insert into projects(id, name, owner, doc) values(?, ?, ?, ?)"
+		return insertDoc(tx, query, &newProject, newProject.ID, newProject.Name, newProject.Owner)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &newProject, nil
+}
+
+// HasDirectory checks if the given directoryID is in the given project.
+func (p sqlProjects) HasDirectory(projectID, dirID string) bool {
+	query := "select count(*) from project2datadir where project_id = ? and datadir_id = ?"
+	n, err := count(p.store.db, query, projectID, dirID)
+	return err == nil && n != 0
+}
+
+// AccessList returns the access list for this project.
+func (p sqlProjects) AccessList(projectID string) ([]schema.Access, error) {
+	docs, err := selectDocs(p.store.db, "select doc from access where project_id = ?", projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var access []schema.Access
+	for _, doc := range docs {
+		var entry schema.Access
+		if err := decodeDoc(doc, &entry); err != nil {
+			return nil, err
+		}
+		access = append(access, entry)
+	}
+	return access, nil
+}
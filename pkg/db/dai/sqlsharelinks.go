@@ -0,0 +1,61 @@
+package dai
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlShareLinks implements the ShareLinks interface for a SQLStore.
+type sqlShareLinks struct {
+	store *SQLStore
+}
+
+// ByID looks up a share link by its primary key.
+func (l sqlShareLinks) ByID(id string) (*schema.ShareLink, error) {
+	return linkByID(l.store.db, id, "")
+}
+
+// Insert adds a new share link.
+func (l sqlShareLinks) Insert(link *schema.ShareLink) (*schema.ShareLink, error) {
+	newLink := *link
+	if newLink.ID == "" {
+		newLink.ID = newID()
+	} else if exists, err := rowExists(l.store.db, "sharelinks", newLink.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, app.ErrCreate
+	}
+
+	if err := insertDoc(l.store.db, "insert into sharelinks(id, doc) values(?, ?)", &newLink, newLink.ID); err != nil {
+		return nil, err
+	}
+	return &newLink, nil
+}
+
+// IncrementDownloads increments the download count for a link. It returns
+// app.ErrNoAccess if the link has already reached its download limit.
+func (l sqlShareLinks) IncrementDownloads(id string) error {
+	return l.store.transact(func(tx *sqlx.Tx) error {
+		link, err := linkByID(tx, id, l.store.forUpdate())
+		if err != nil {
+			return err
+		}
+
+		if link.MaxDownloads != 0 && link.Downloads >= link.MaxDownloads {
+			return app.ErrNoAccess
+		}
+
+		link.Downloads++
+		return updateDoc(tx, "update sharelinks set doc = ? where id = ?", link, link.ID)
+	})
+}
+
+// linkByID looks up a share link. The suffix is added to the query.
+func linkByID(q sqlx.Ext, id, suffix string) (*schema.ShareLink, error) {
+	var link schema.ShareLink
+	if err := getDoc(q, &link, "select doc from sharelinks where id = ?"+suffix, id); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
@@ -0,0 +1,299 @@
+package dai
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/dancannon/gorethink/encoding"
+	"github.com/jmoiron/sqlx"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLStore is a Store on a database/sql database, either SQLite or
+// PostgreSQL. Each table has columns for the fields that queries use, and
+// a doc column that holds the whole entry, encoded the same way RethinkDB
+// stores it. The schema is created and updated by Migrate.
+type SQLStore struct {
+	db *sqlx.DB
+}
+
+// OpenSQLStore opens a SQLStore. The driver is sqlite3 or postgres, and dsn
+// is the driver's data source name. PostgreSQL support is only built in when
+// building with the postgres tag.
+func OpenSQLStore(driver, dsn string) (*SQLStore, error) {
+	if !driverRegistered(driver) {
+		return nil, app.Errorf(app.ErrInvalid, "the %s driver isn't built in, build with -tags %s", driver, driver)
+	}
+
+	db, err := sqlx.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == "sqlite3" {
+		// SQLite allows a single writer. Sharing one connection serializes
+		// access, and keeps in-memory databases from being one per connection.
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLStore{db: db}, nil
+}
+
+// driverRegistered returns true if a database/sql driver is linked in.
+func driverRegistered(driver string) bool {
+	for _, name := range sql.Drivers() {
+		if name == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Check verifies that the database can be reached.
+func (s *SQLStore) Check() error {
+	return s.db.Ping()
+}
+
+func (s *SQLStore) Users() Users             { return sqlUsers{s} }
+func (s *SQLStore) Files() Files             { return sqlFiles{s} }
+func (s *SQLStore) Uploads() Uploads         { return sqlUploads{s} }
+func (s *SQLStore) Projects() Projects       { return sqlProjects{s} }
+func (s *SQLStore) Dirs() Dirs               { return sqlDirs{s} }
+func (s *SQLStore) APITokens() APITokens     { return sqlAPITokens{s} }
+func (s *SQLStore) ShareLinks() ShareLinks   { return sqlShareLinks{s} }
+func (s *SQLStore) Datasets() Datasets       { return sqlDatasets{s} }
+func (s *SQLStore) AuditEvents() AuditEvents { return sqlAuditEvents{s} }
+func (s *SQLStore) Trash() Trash             { return sqlTrash{s} }
+func (s *SQLStore) Inventory() Inventory     { return sqlInventory{s} }
+
+// Store returns the SQLStore itself. The database handle is safe for
+// concurrent use, so every request shares it.
+func (s *SQLStore) Store() (Store, error) {
+	return s, nil
+}
+
+// Release does nothing, the SQLStore is shared.
+func (s *SQLStore) Release(store Store) {
+}
+
+// AddUser adds a user. It returns app.ErrExists if there is already a user
+// with the same id.
+func (s *SQLStore) AddUser(user schema.User) error {
+	return s.transact(func(tx *sqlx.Tx) error {
+		if exists, err := rowExists(tx, "users", user.ID); err != nil {
+			return err
+		} else if exists {
+			return app.ErrExists
+		}
+		return insertDoc(tx, "insert into users(id, apikey, doc) values(?, ?, ?)", &user, user.ID, user.APIKey)
+	})
+}
+
+// AddAccess gives a user access to a project.
+func (s *SQLStore) AddAccess(access schema.Access) error {
+	return insertAccess(s.db, access)
+}
+
+// Load adds the users and projects in a JSON Fixture to the store.
+func (s *SQLStore) Load(r io.Reader) error {
+	return loadFixture(s, r)
+}
+
+// transact runs fn in a transaction. The transaction is committed if fn
+// succeeds, and rolled back otherwise. Everything fn does must go through tx.
+func (s *SQLStore) transact(fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// forUpdate is added to selects that are followed by an update in the same
+// transaction. SQLite locks the whole database on write, so it only matters
+// for PostgreSQL.
+func (s *SQLStore) forUpdate() string {
+	if s.db.DriverName() == "postgres" {
+		return " for update"
+	}
+	return ""
+}
+
+// insertAccess adds an access entry, giving it an id if it doesn't have one.
+func insertAccess(e sqlx.Ext, access schema.Access) error {
+	if access.ID == "" {
+		access.ID = newID()
+	}
+	return insertDoc(e, "insert into access(id, project_id, user_id, doc) values(?, ?, ?, ?)",
+		&access, access.ID, access.ProjectID, access.UserID)
+}
+
+// insertDoc runs an insert whose last column is the doc. The args are the
+// values for the other columns.
+func insertDoc(e sqlx.Ext, query string, v interface{}, args ...interface{}) error {
+	doc, err := encodeDoc(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(e.Rebind(query), append(args, doc)...)
+	return err
+}
+
+// updateDoc runs an update whose first column is the doc. The args are the
+// values for the other columns and the where clause. It returns app.ErrNotFound
+// if no rows were updated.
+func updateDoc(e sqlx.Ext, query string, v interface{}, args ...interface{}) error {
+	doc, err := encodeDoc(v)
+	if err != nil {
+		return err
+	}
+
+	res, err := e.Exec(e.Rebind(query), append([]interface{}{doc}, args...)...)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+// execExpectRows runs a statement and returns app.ErrNotFound if it didn't
+// change any rows.
+func execExpectRows(e sqlx.Ext, query string, args ...interface{}) error {
+	res, err := e.Exec(e.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+// expectRows returns app.ErrNotFound if res didn't change any rows.
+func expectRows(res sql.Result) error {
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return app.ErrNotFound
+	}
+	return nil
+}
+
+// getDoc runs a query that selects a doc column and decodes the first row
+// into v. It returns app.ErrNotFound if there are no rows.
+func getDoc(q sqlx.Ext, v interface{}, query string, args ...interface{}) error {
+	var doc string
+	switch err := q.QueryRowx(q.Rebind(query), args...).Scan(&doc); {
+	case err == sql.ErrNoRows:
+		return app.ErrNotFound
+	case err != nil:
+		return err
+	default:
+		return decodeDoc(doc, v)
+	}
+}
+
+// selectDocs runs a query that selects a doc column and returns the docs.
+func selectDocs(q sqlx.Ext, query string, args ...interface{}) ([]string, error) {
+	var docs []string
+	if err := sqlx.Select(q, &docs, q.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// count runs a query that selects a count.
+func count(q sqlx.Ext, query string, args ...interface{}) (int, error) {
+	var n int
+	err := q.QueryRowx(q.Rebind(query), args...).Scan(&n)
+	return n, err
+}
+
+// rowExists returns true if table has a row with the given id.
+func rowExists(q sqlx.Ext, table, id string) (bool, error) {
+	n, err := count(q, "select count(*) from "+table+" where id = ?", id)
+	return n != 0, err
+}
+
+// encodeDoc encodes v with the gorethink encoder, so the doc has the same
+// field names and values as the RethinkDB document.
+func encodeDoc(v interface{}) (string, error) {
+	rv, err := encoding.Encode(v)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(rv)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeDoc decodes a doc created by encodeDoc into v.
+func decodeDoc(doc string, v interface{}) error {
+	var rv interface{}
+	if err := json.Unmarshal([]byte(doc), &rv); err != nil {
+		return err
+	}
+
+	rv, err := convertPseudoTypes(rv)
+	if err != nil {
+		return err
+	}
+	return encoding.Decode(v, rv)
+}
+
+// convertPseudoTypes turns the TIME and BINARY values the gorethink encoder
+// creates back into time.Time and []byte, which is what the RethinkDB driver
+// does for query results.
+func convertPseudoTypes(v interface{}) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			if v[i], err = convertPseudoTypes(v[i]); err != nil {
+				return nil, err
+			}
+		}
+
+	case map[string]interface{}:
+		switch v["$reql_type$"] {
+		case "TIME":
+			// The encoder stores seconds as a float, which only holds
+			// about a microsecond of precision for current times.
+			epoch, _ := v["epoch_time"].(float64)
+			sec, frac := math.Modf(epoch)
+			t := time.Unix(int64(sec), int64(frac*float64(time.Second)))
+			return t.Round(time.Microsecond).UTC(), nil
+		case "BINARY":
+			data, _ := v["data"].(string)
+			return base64.StdEncoding.DecodeString(data)
+		}
+
+		for key, value := range v {
+			if v[key], err = convertPseudoTypes(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
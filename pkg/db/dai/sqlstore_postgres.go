@@ -0,0 +1,10 @@
+//go:build postgres
+// +build postgres
+
+package dai
+
+// PostgreSQL support needs the lib/pq driver, which is vendored in
+// Godeps/_workspace. It is only linked into builds that ask for it with the
+// postgres tag, without the tag the postgres store fails with an unknown
+// driver error.
+import _ "github.com/lib/pq"
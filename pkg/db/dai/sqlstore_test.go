@@ -0,0 +1,94 @@
+package dai
+
+import (
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLStore", func() {
+	var store *SQLStore
+
+	BeforeEach(func() {
+		var err error
+		store, err = OpenSQLStore("sqlite3", ":memory:")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		store.Close()
+	})
+
+	Describe("Migrate", func() {
+		It("Should apply every migration once", func() {
+			version, err := store.SchemaVersion()
+			Expect(err).To(BeNil())
+			Expect(version).To(Equal(0))
+
+			applied, err := store.Migrate()
+			Expect(err).To(BeNil())
+			Expect(applied).To(Equal(len(sqlMigrations)))
+
+			version, err = store.SchemaVersion()
+			Expect(err).To(BeNil())
+			Expect(version).To(Equal(LatestSchemaVersion()))
+
+			applied, err = store.Migrate()
+			Expect(err).To(BeNil())
+			Expect(applied).To(Equal(0))
+		})
+
+		It("Should list the migrations that haven't been applied", func() {
+			pending, err := store.PendingMigrations()
+			Expect(err).To(BeNil())
+			Expect(pending).To(HaveLen(len(sqlMigrations)))
+
+			Expect(store.ApplyMigration(pending[0])).To(BeNil())
+			version, err := store.SchemaVersion()
+			Expect(err).To(BeNil())
+			Expect(version).To(Equal(pending[0].Version))
+
+			pending, err = store.PendingMigrations()
+			Expect(err).To(BeNil())
+			Expect(pending).To(HaveLen(len(sqlMigrations) - 1))
+			Expect(pending[0].Version).To(Equal(version + 1))
+		})
+	})
+
+	Describe("Docs", func() {
+		It("Should keep fields that the JSON tags hide or repeat", func() {
+			dir := schema.NewDirectory("dir", "test@mc.org", "proj", "")
+			dir.ATime = time.Date(2015, 6, 1, 12, 30, 15, 250000000, time.UTC)
+
+			doc, err := encodeDoc(&dir)
+			Expect(err).To(BeNil())
+
+			var decoded schema.Directory
+			Expect(decodeDoc(doc, &decoded)).To(BeNil())
+			Expect(decoded.ATime.Equal(dir.ATime)).To(BeTrue())
+			Expect(decoded.MTime.Sub(dir.MTime)).To(BeNumerically("~", 0, time.Microsecond))
+		})
+
+		It("Should keep maps and binary values", func() {
+			project := schema.NewProject("proj", "test@mc.org")
+			project.MediaTypes = map[string]schema.MediaTypeSummary{
+				"text/plain": {Count: 2, Size: 100},
+			}
+			upload := schema.Upload{File: schema.FileUpload{BitString: []byte("[1,2]")}}
+
+			doc, err := encodeDoc(&project)
+			Expect(err).To(BeNil())
+			var decodedProject schema.Project
+			Expect(decodeDoc(doc, &decodedProject)).To(BeNil())
+			Expect(decodedProject.MediaTypes).To(Equal(project.MediaTypes))
+
+			doc, err = encodeDoc(&upload)
+			Expect(err).To(BeNil())
+			var decodedUpload schema.Upload
+			Expect(decodeDoc(doc, &decodedUpload)).To(BeNil())
+			Expect(decodedUpload.File.BitString).To(Equal([]byte("[1,2]")))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package dai
+
+import (
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlTrash implements the Trash interface for a SQLStore. Like audit events,
+// the birthtime column holds nanoseconds since the epoch.
+type sqlTrash struct {
+	store *SQLStore
+}
+
+// ByID looks up a trash item.
+func (t sqlTrash) ByID(id string) (*schema.TrashItem, error) {
+	var item schema.TrashItem
+	if err := getDoc(t.store.db, &item, "select doc from trash where id = ?", id); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ForProject returns the items in a project's trash, newest first.
+func (t sqlTrash) ForProject(projectID string) ([]schema.TrashItem, error) {
+	return t.selectItems("select doc from trash where project_id = ? order by birthtime desc", projectID)
+}
+
+// DeletedBefore returns the items in every project's trash that were deleted
+// before the given time, newest first.
+func (t sqlTrash) DeletedBefore(before time.Time) ([]schema.TrashItem, error) {
+	return t.selectItems("select doc from trash where birthtime < ? order by birthtime desc", before.UnixNano())
+}
+
+// selectItems runs a query that selects trash item docs.
+func (t sqlTrash) selectItems(query string, args ...interface{}) ([]schema.TrashItem, error) {
+	docs, err := selectDocs(t.store.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []schema.TrashItem
+	for _, doc := range docs {
+		var item schema.TrashItem
+		if err := decodeDoc(doc, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Insert adds a new trash item.
+func (t sqlTrash) Insert(item *schema.TrashItem) (*schema.TrashItem, error) {
+	newItem := *item
+	if newItem.ID == "" {
+		newItem.ID = newID()
+	}
+
+	query := "insert into trash(id, project_id, birthtime, doc) values(?, ?, ?, ?)"
+	if err := insertDoc(t.store.db, query, &newItem, newItem.ID, newItem.ProjectID,
+		newItem.Birthtime.UnixNano()); err != nil {
+		return nil, err
+	}
+	return &newItem, nil
+}
+
+// Delete removes a trash item. It doesn't touch what the item holds.
+func (t sqlTrash) Delete(id string) error {
+	return execExpectRows(t.store.db, "delete from trash where id = ?", id)
+}
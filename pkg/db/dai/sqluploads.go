@@ -0,0 +1,127 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlUploads implements the Uploads interface for a SQLStore. Like rUploads
+// the block state is stored in BitString, and Blocks is rebuilt from it when
+// an upload is read.
+type sqlUploads struct {
+	store *SQLStore
+}
+
+// ByID looks up an upload by its primary key (id).
+func (u sqlUploads) ByID(id string) (*schema.Upload, error) {
+	return u.get("select doc from uploads where id = ?", id)
+}
+
+// Search attempts to find a matching upload request matching the given
+// parameters.
+func (u sqlUploads) Search(params UploadSearch) (*schema.Upload, error) {
+	query := `
+            select doc from uploads
+            where project_id = ? and directory_id = ? and file_name = ? and file_checksum = ?
+        `
+	return u.get(query, params.ProjectID, params.DirectoryID, params.FileName, params.Checksum)
+}
+
+// Insert adds a new upload.
+func (u sqlUploads) Insert(upload *schema.Upload) (*schema.Upload, error) {
+	newUpload := *upload
+	if newUpload.ID == "" {
+		newUpload.ID = newID()
+	} else if exists, err := rowExists(u.store.db, "uploads", newUpload.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, app.ErrCreate
+	}
+
+	if upload.File.Blocks != nil {
+		newUpload.File.BitString = toBitStr(upload.File.Blocks)
+	}
+	newUpload.File.Blocks = nil
+
+	query := `
+            insert into uploads(id, owner, project_id, directory_id, file_name, file_checksum, doc)
+                        values(?, ?, ?, ?, ?, ?, ?)
+        `
+	err := insertDoc(u.store.db, query, &newUpload, newUpload.ID, newUpload.Owner, newUpload.ProjectID,
+		newUpload.DirectoryID, newUpload.File.Name, newUpload.File.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	newUpload.File.Blocks = toBitSet(newUpload.File.BitString)
+	return &newUpload, nil
+}
+
+// Update updates an existing upload entry.
+func (u sqlUploads) Update(upload *schema.Upload) error {
+	updated := *upload
+	updated.File.Blocks = nil
+
+	query := `
+            update uploads set doc = ?, owner = ?, project_id = ?, directory_id = ?,
+                               file_name = ?, file_checksum = ?
+            where id = ?
+        `
+	return updateDoc(u.store.db, query, &updated, updated.Owner, updated.ProjectID, updated.DirectoryID,
+		updated.File.Name, updated.File.Checksum, updated.ID)
+}
+
+// ForUser retrieves all the uploads for the named user.
+func (u sqlUploads) ForUser(user string) ([]schema.Upload, error) {
+	return u.selectUploads("select doc from uploads where owner = ?", user)
+}
+
+// ForProject returns all uploads for a particular project.
+func (u sqlUploads) ForProject(projectID string) ([]schema.Upload, error) {
+	return u.selectUploads("select doc from uploads where project_id = ?", projectID)
+}
+
+// All returns every upload.
+func (u sqlUploads) All() ([]schema.Upload, error) {
+	return u.selectUploads("select doc from uploads")
+}
+
+// Delete deletes the given upload id.
+func (u sqlUploads) Delete(uploadID string) error {
+	return execExpectRows(u.store.db, "delete from uploads where id = ?", uploadID)
+}
+
+// DeleteAll deletes all uploads.
+func (u sqlUploads) DeleteAll() error {
+	_, err := u.store.db.Exec("delete from uploads")
+	return err
+}
+
+// get runs a query for a single upload and sets its Blocks.
+func (u sqlUploads) get(query string, args ...interface{}) (*schema.Upload, error) {
+	var upload schema.Upload
+	if err := getDoc(u.store.db, &upload, query, args...); err != nil {
+		return nil, err
+	}
+	upload.File.Blocks = toBitSet(upload.File.BitString)
+	return &upload, nil
+}
+
+// selectUploads runs a query for uploads and sets their Blocks.
+func (u sqlUploads) selectUploads(query string, args ...interface{}) ([]schema.Upload, error) {
+	docs, err := selectDocs(u.store.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []schema.Upload
+	for _, doc := range docs {
+		var upload schema.Upload
+		if err := decodeDoc(doc, &upload); err != nil {
+			return nil, err
+		}
+		upload.File.Blocks = toBitSet(upload.File.BitString)
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
@@ -0,0 +1,28 @@
+package dai
+
+import (
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// sqlUsers implements the Users interface for a SQLStore.
+type sqlUsers struct {
+	store *SQLStore
+}
+
+// ByID looks up users by their primary key.
+func (u sqlUsers) ByID(id string) (*schema.User, error) {
+	var user schema.User
+	if err := getDoc(u.store.db, &user, "select doc from users where id = ?", id); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ByAPIKey looks up users by their apikey.
+func (u sqlUsers) ByAPIKey(apikey string) (*schema.User, error) {
+	var user schema.User
+	if err := getDoc(u.store.db, &user, "select doc from users where apikey = ?", apikey); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,146 @@
+package dai
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"strings"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db"
+)
+
+// A Store gives access to all the collections in a database. Code that
+// needs several collections should take a Store so that it can run against
+// any backend.
+type Store interface {
+	Users() Users
+	Files() Files
+	Uploads() Uploads
+	Projects() Projects
+	Dirs() Dirs
+	APITokens() APITokens
+	ShareLinks() ShareLinks
+	Datasets() Datasets
+	AuditEvents() AuditEvents
+	Trash() Trash
+	Inventory() Inventory
+}
+
+// rStore implements the Store interface for RethinkDB.
+type rStore struct {
+	session *r.Session
+}
+
+// NewRStore creates a Store that uses the given RethinkDB session.
+func NewRStore(session *r.Session) Store {
+	return rStore{
+		session: session,
+	}
+}
+
+func (s rStore) Users() Users             { return NewRUsers(s.session) }
+func (s rStore) Files() Files             { return NewRFiles(s.session) }
+func (s rStore) Uploads() Uploads         { return NewRUploads(s.session) }
+func (s rStore) Projects() Projects       { return NewRProjects(s.session) }
+func (s rStore) Dirs() Dirs               { return NewRDirs(s.session) }
+func (s rStore) APITokens() APITokens     { return NewRAPITokens(s.session) }
+func (s rStore) ShareLinks() ShareLinks   { return NewRShareLinks(s.session) }
+func (s rStore) Datasets() Datasets       { return NewRDatasets(s.session) }
+func (s rStore) AuditEvents() AuditEvents { return NewRAuditEvents(s.session) }
+func (s rStore) Trash() Trash             { return NewRTrash(s.session) }
+func (s rStore) Inventory() Inventory     { return NewRInventory(s.session) }
+
+// A StoreSource provides the store for a unit of work, such as a request,
+// and takes it back once the work is done.
+type StoreSource interface {
+	Store() (Store, error)
+	Release(store Store)
+}
+
+// rStoreSource is a StoreSource that creates RethinkDB stores from a
+// db.SessionCreater.
+type rStoreSource struct {
+	sc db.SessionCreater
+}
+
+// NewRStoreSource creates a StoreSource whose stores use sessions from sc.
+// Sessions from a db.Pool are shared, other sessions are closed on Release.
+func NewRStoreSource(sc db.SessionCreater) StoreSource {
+	return rStoreSource{
+		sc: sc,
+	}
+}
+
+// Store creates a store with a new session.
+func (s rStoreSource) Store() (Store, error) {
+	session, err := s.sc.RSession()
+	if err != nil {
+		return nil, err
+	}
+	return NewRStore(session), nil
+}
+
+// Release gives back the store's session.
+func (s rStoreSource) Release(store Store) {
+	if rs, ok := store.(rStore); ok {
+		db.Release(s.sc, rs.session)
+	}
+}
+
+// Store returns the MemStore itself, a MemStore is its own StoreSource.
+func (s *MemStore) Store() (Store, error) {
+	return s, nil
+}
+
+// Release does nothing, the MemStore is shared.
+func (s *MemStore) Release(store Store) {
+}
+
+// newID generates a random id in the same format RethinkDB uses.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("unable to generate id: %s", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// setFields sets the fields of the struct pointed to by v. Fields are named
+// by their gorethink tag, the same as for an update in RethinkDB.
+func setFields(v interface{}, fields map[string]interface{}) error {
+	sv := reflect.ValueOf(v).Elem()
+	st := sv.Type()
+
+	for name, value := range fields {
+		found := false
+		for i := 0; i < st.NumField(); i++ {
+			tag := strings.Split(st.Field(i).Tag.Get("gorethink"), ",")[0]
+			if tag != name {
+				continue
+			}
+
+			field := sv.Field(i)
+			fv := reflect.ValueOf(value)
+			switch {
+			case fv.Type().AssignableTo(field.Type()):
+				field.Set(fv)
+			case fv.Type().ConvertibleTo(field.Type()):
+				field.Set(fv.Convert(field.Type()))
+			default:
+				return app.Errorf(app.ErrInvalid, "bad value for field %s", name)
+			}
+			found = true
+			break
+		}
+
+		if !found {
+			return app.Errorf(app.ErrInvalid, "unknown field %s", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,650 @@
+package dai
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/willf/bitset"
+)
+
+var _ = Describe("MemStore", func() {
+	itBehavesLikeAStore(func() fixtureStore {
+		return NewMemStore()
+	})
+})
+
+var _ = Describe("SQLStore on SQLite", func() {
+	itBehavesLikeAStore(func() fixtureStore {
+		return newTestSQLStore("sqlite3", ":memory:")
+	})
+})
+
+// The PostgreSQL run needs a build with the postgres tag, and the data source
+// name of a scratch database in MCDB_TEST_POSTGRES. It drops the tables.
+var _ = Describe("SQLStore on PostgreSQL", func() {
+	dsn := os.Getenv("MCDB_TEST_POSTGRES")
+	if dsn == "" {
+		return
+	}
+
+	itBehavesLikeAStore(func() fixtureStore {
+		store, err := OpenSQLStore("postgres", dsn)
+		Expect(err).To(BeNil())
+		dropSQLTables(store)
+		store.Close()
+		return newTestSQLStore("postgres", dsn)
+	})
+})
+
+// newTestSQLStore opens a SQLStore with the latest schema.
+func newTestSQLStore(driver, dsn string) *SQLStore {
+	store, err := OpenSQLStore(driver, dsn)
+	Expect(err).To(BeNil())
+	_, err = store.Migrate()
+	Expect(err).To(BeNil())
+	return store
+}
+
+// dropSQLTables removes the tables the migrations create.
+func dropSQLTables(store *SQLStore) {
+	tables := []string{
+		"schema_version", "users", "projects", "access", "datadirs", "datafiles", "project2datadir",
+		"project2datafile", "datadir2datafile", "uploads", "apitokens", "sharelinks", "datasets",
+		"dataset2datafile", "audit_events", "trash",
+	}
+	for _, table := range tables {
+		_, err := store.db.Exec("drop table if exists " + table)
+		Expect(err).To(BeNil())
+	}
+}
+
+// itBehavesLikeAStore checks that a Store implementation has the semantics
+// of the RethinkDB implementations. newStore must return an empty store.
+func itBehavesLikeAStore(newStore func() fixtureStore) {
+	var (
+		store   fixtureStore
+		project *schema.Project
+	)
+
+	BeforeEach(func() {
+		var err error
+		store = newStore()
+		p := schema.NewProject("proj1", "test@mc.org")
+		project, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+	})
+
+	Describe("Projects", func() {
+		It("Should create the top level directory and access entry", func() {
+			dir, err := store.Dirs().ByID(project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(dir.Name).To(Equal("proj1"))
+			Expect(store.Projects().HasDirectory(project.ID, dir.ID)).To(BeTrue())
+
+			access, err := store.Projects().AccessList(project.ID)
+			Expect(err).To(BeNil())
+			Expect(access).To(HaveLen(1))
+			Expect(access[0].UserID).To(Equal("test@mc.org"))
+		})
+
+		It("Should find the project by name", func() {
+			p, err := store.Projects().ByName("proj1", "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(p.ID).To(Equal(project.ID))
+		})
+
+		It("Should return ErrNotFound for an unknown project name", func() {
+			p, err := store.Projects().ByName("proj1", "other@mc.org")
+			Expect(err).To(Equal(app.ErrNotFound))
+			Expect(p).To(BeNil())
+		})
+	})
+
+	Describe("Files", func() {
+		var (
+			files Files
+			f1    *schema.File
+		)
+
+		BeforeEach(func() {
+			var err error
+			files = store.Files()
+			f := schema.NewFile("f.txt", "test@mc.org")
+			f.Checksum = "abc123"
+			f1, err = files.Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+		})
+
+		It("Should ignore duplicates when looking up by checksum", func() {
+			dup := schema.NewFile("dup.txt", "test@mc.org")
+			dup.Checksum = "abc123"
+			dup.UsesID = f1.ID
+			_, err := files.Insert(&dup, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			f, err := files.ByChecksum("abc123")
+			Expect(err).To(BeNil())
+			Expect(f.ID).To(Equal(f1.ID))
+
+			all, err := files.AllByChecksum("abc123")
+			Expect(err).To(BeNil())
+			Expect(all).To(HaveLen(2))
+
+			usedBy, err := files.UsedBy(f1.ID)
+			Expect(err).To(BeNil())
+			Expect(usedBy).To(HaveLen(1))
+			Expect(usedBy[0].Name).To(Equal("dup.txt"))
+		})
+
+		It("Should make the parent current when the new version is deleted", func() {
+			Expect(files.UpdateFields(f1.ID, map[string]interface{}{"current": false})).To(BeNil())
+			f2 := schema.NewFile("f.txt", "test@mc.org")
+			f2.Parent = f1.ID
+			newf2, err := files.Insert(&f2, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			f, err := files.ByPath("f.txt", project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(f.ID).To(Equal(newf2.ID))
+
+			_, err = files.Delete(newf2.ID, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			_, err = files.ByID(newf2.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+
+			f, err = files.ByPath("f.txt", project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(f.ID).To(Equal(f1.ID))
+		})
+
+		It("Should keep a file that other files use", func() {
+			dup := schema.NewFile("dup.txt", "test@mc.org")
+			dup.UsesID = f1.ID
+			_, err := files.Insert(&dup, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			_, err = files.Delete(f1.ID, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			f, err := files.ByID(f1.ID)
+			Expect(err).To(BeNil())
+			Expect(f.Current).To(BeFalse())
+		})
+
+		It("Should return the file's directory and project", func() {
+			dir, err := files.Directory(f1.ID)
+			Expect(err).To(BeNil())
+			Expect(dir.ID).To(Equal(project.DataDir))
+
+			p, err := files.GetProject(f1.ID)
+			Expect(err).To(BeNil())
+			Expect(p.ID).To(Equal(project.ID))
+		})
+	})
+
+	Describe("Inventory", func() {
+		It("Should list files and join entries and repair the join tables", func() {
+			f := schema.NewFile("f.txt", "test@mc.org")
+			f1, err := store.Files().Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			inventory := store.Inventory()
+			files, err := inventory.Files()
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].ID).To(Equal(f1.ID))
+
+			projectFiles, err := inventory.ProjectFiles()
+			Expect(err).To(BeNil())
+			Expect(projectFiles).To(HaveLen(1))
+			Expect(projectFiles[0].ProjectID).To(Equal(project.ID))
+			Expect(projectFiles[0].DataFileID).To(Equal(f1.ID))
+
+			dirFiles, err := inventory.DirFiles()
+			Expect(err).To(BeNil())
+			Expect(dirFiles).To(HaveLen(1))
+			Expect(dirFiles[0].DataDirID).To(Equal(project.DataDir))
+
+			Expect(inventory.DeleteProjectFile(projectFiles[0].ID)).To(BeNil())
+			Expect(inventory.DeleteProjectFile(projectFiles[0].ID)).To(Equal(app.ErrNotFound))
+			Expect(inventory.DeleteDirFile(dirFiles[0].ID)).To(BeNil())
+			Expect(inventory.DeleteDirFile(dirFiles[0].ID)).To(Equal(app.ErrNotFound))
+
+			Expect(inventory.AddProjectFile(project.ID, f1.ID)).To(BeNil())
+			projectFiles, err = inventory.ProjectFiles()
+			Expect(err).To(BeNil())
+			Expect(projectFiles).To(HaveLen(1))
+			Expect(projectFiles[0].DataFileID).To(Equal(f1.ID))
+
+			dirFiles, err = inventory.DirFiles()
+			Expect(err).To(BeNil())
+			Expect(dirFiles).To(BeEmpty())
+		})
+	})
+
+	Describe("Uploads", func() {
+		It("Should keep the blocks across reads", func() {
+			blocks := bitset.New(10)
+			blocks.Set(3)
+			upload := schema.Upload{
+				ProjectID: project.ID,
+				File: schema.FileUpload{
+					Name:   "f.txt",
+					Blocks: blocks,
+				},
+			}
+
+			u, err := store.Uploads().Insert(&upload)
+			Expect(err).To(BeNil())
+
+			u, err = store.Uploads().ByID(u.ID)
+			Expect(err).To(BeNil())
+			Expect(u.File.Blocks.Test(3)).To(BeTrue())
+			Expect(u.File.Blocks.Test(4)).To(BeFalse())
+		})
+
+		It("Should fail to update an upload that doesn't exist", func() {
+			upload := schema.Upload{ID: "does-not-exist"}
+			Expect(store.Uploads().Update(&upload)).To(Equal(app.ErrNotFound))
+		})
+	})
+
+	Describe("Load", func() {
+		It("Should load users and projects from a fixture", func() {
+			fixture := `{
+				"users": [{"id": "a@mc.org", "fullname": "A", "apikey": "akey"}, {"id": "b@mc.org", "apikey": "bkey"}],
+				"projects": [{"name": "shared", "owner": "a@mc.org", "members": ["b@mc.org"]}]
+			}`
+			Expect(loadFixture(store, strings.NewReader(fixture))).To(BeNil())
+
+			u, err := store.Users().ByAPIKey("bkey")
+			Expect(err).To(BeNil())
+			Expect(u.ID).To(Equal("b@mc.org"))
+
+			projects, err := store.Projects().ForUser("b@mc.org", false)
+			Expect(err).To(BeNil())
+			Expect(projects).To(HaveLen(1))
+			Expect(projects[0].Name).To(Equal("shared"))
+		})
+
+		It("Should fail on a bad fixture", func() {
+			Expect(loadFixture(store, strings.NewReader("{"))).NotTo(BeNil())
+		})
+	})
+
+	Describe("Users", func() {
+		BeforeEach(func() {
+			user := schema.NewUser("User", "user@mc.org", "secret", "userkey")
+			Expect(store.AddUser(user)).To(BeNil())
+		})
+
+		It("Should find users by id and apikey and keep hidden fields", func() {
+			u, err := store.Users().ByID("user@mc.org")
+			Expect(err).To(BeNil())
+			Expect(u.Fullname).To(Equal("User"))
+			Expect(u.Password).To(Equal("secret"))
+
+			u, err = store.Users().ByAPIKey("userkey")
+			Expect(err).To(BeNil())
+			Expect(u.ID).To(Equal("user@mc.org"))
+
+			_, err = store.Users().ByAPIKey("nokey")
+			Expect(err).To(Equal(app.ErrNotFound))
+		})
+
+		It("Should not add a user twice", func() {
+			user := schema.NewUser("User", "user@mc.org", "", "otherkey")
+			Expect(store.AddUser(user)).To(Equal(app.ErrExists))
+		})
+	})
+
+	Describe("Dirs", func() {
+		var child *schema.Directory
+
+		BeforeEach(func() {
+			var err error
+			dir := schema.NewDirectory("proj1/sub", "test@mc.org", project.ID, project.DataDir)
+			child, err = store.Dirs().Insert(&dir)
+			Expect(err).To(BeNil())
+		})
+
+		It("Should find directories by path and parent", func() {
+			d, err := store.Dirs().ByPath("proj1/sub", project.ID)
+			Expect(err).To(BeNil())
+			Expect(d.ID).To(Equal(child.ID))
+
+			children, err := store.Dirs().Children(project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(children).To(HaveLen(1))
+			Expect(store.Projects().HasDirectory(project.ID, child.ID)).To(BeTrue())
+		})
+
+		It("Should delete a directory from the project", func() {
+			Expect(store.Dirs().Delete(child.ID)).To(BeNil())
+			_, err := store.Dirs().ByID(child.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+			Expect(store.Projects().HasDirectory(project.ID, child.ID)).To(BeFalse())
+			Expect(store.Dirs().Delete(child.ID)).To(Equal(app.ErrNotFound))
+		})
+
+		It("Should update a directory", func() {
+			child.Name = "proj1/renamed"
+			child.Parent = ""
+			Expect(store.Dirs().Update(child)).To(BeNil())
+
+			_, err := store.Dirs().ByPath("proj1/sub", project.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+			d, err := store.Dirs().ByPath("proj1/renamed", project.ID)
+			Expect(err).To(BeNil())
+			Expect(d.Parent).To(Equal(""))
+
+			children, err := store.Dirs().Children(project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(children).To(BeEmpty())
+		})
+
+		It("Should move a file between directories", func() {
+			f := schema.NewFile("f.txt", "test@mc.org")
+			file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			Expect(store.Files().Move(file.ID, project.DataDir, child.ID)).To(BeNil())
+			_, err = store.Files().ByPath("f.txt", project.DataDir)
+			Expect(err).To(Equal(app.ErrNotFound))
+			moved, err := store.Files().ByPath("f.txt", child.ID)
+			Expect(err).To(BeNil())
+			Expect(moved.ID).To(Equal(file.ID))
+
+			Expect(store.Files().Move(file.ID, project.DataDir, child.ID)).To(Equal(app.ErrNotFound))
+		})
+
+		It("Should refuse an id that is in use", func() {
+			dir := schema.NewDirectory("proj1/other", "test@mc.org", project.ID, project.DataDir)
+			dir.ID = child.ID
+			_, err := store.Dirs().Insert(&dir)
+			Expect(err).To(Equal(app.ErrCreate))
+		})
+	})
+
+	Describe("Projects ForUser", func() {
+		It("Should separate owned projects from shared ones", func() {
+			p := schema.NewProject("proj2", "other@mc.org")
+			other, err := store.Projects().Insert(&p)
+			Expect(err).To(BeNil())
+			Expect(store.AddAccess(schema.NewAccess(other.ID, other.Name, "test@mc.org"))).To(BeNil())
+
+			owned, err := store.Projects().ForUser("test@mc.org", true)
+			Expect(err).To(BeNil())
+			Expect(owned).To(HaveLen(1))
+
+			all, err := store.Projects().ForUser("test@mc.org", false)
+			Expect(err).To(BeNil())
+			Expect(all).To(HaveLen(2))
+		})
+
+		It("Should refuse a project that already has a directory", func() {
+			p := schema.NewProject("proj2", "test@mc.org")
+			p.DataDir = "somedir"
+			_, err := store.Projects().Insert(&p)
+			Expect(err).To(Equal(app.ErrInvalid))
+		})
+	})
+
+	Describe("Upload queries", func() {
+		BeforeEach(func() {
+			upload := schema.Upload{
+				Owner:       "test@mc.org",
+				ProjectID:   project.ID,
+				DirectoryID: project.DataDir,
+				File:        schema.FileUpload{Name: "f.txt", Checksum: "abc123"},
+			}
+			_, err := store.Uploads().Insert(&upload)
+			Expect(err).To(BeNil())
+		})
+
+		It("Should search and list uploads", func() {
+			u, err := store.Uploads().Search(UploadSearch{
+				ProjectID:   project.ID,
+				DirectoryID: project.DataDir,
+				FileName:    "f.txt",
+				Checksum:    "abc123",
+			})
+			Expect(err).To(BeNil())
+			Expect(u.Owner).To(Equal("test@mc.org"))
+
+			uploads, err := store.Uploads().ForUser("test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(uploads).To(HaveLen(1))
+
+			uploads, err = store.Uploads().ForProject(project.ID)
+			Expect(err).To(BeNil())
+			Expect(uploads).To(HaveLen(1))
+		})
+
+		It("Should update and delete uploads", func() {
+			uploads, _ := store.Uploads().All()
+			u := uploads[0]
+			u.Host = "host1"
+			Expect(store.Uploads().Update(&u)).To(BeNil())
+
+			updated, err := store.Uploads().ByID(u.ID)
+			Expect(err).To(BeNil())
+			Expect(updated.Host).To(Equal("host1"))
+
+			Expect(store.Uploads().Delete(u.ID)).To(BeNil())
+			Expect(store.Uploads().Delete(u.ID)).To(Equal(app.ErrNotFound))
+			Expect(store.Uploads().DeleteAll()).To(BeNil())
+		})
+	})
+
+	Describe("APITokens", func() {
+		It("Should find and revoke tokens", func() {
+			t := schema.NewAPIToken("token", "test@mc.org", "hash1", time.Time{})
+			t.Scopes = []string{schema.TokenScopeRead}
+			token, err := store.APITokens().Insert(&t)
+			Expect(err).To(BeNil())
+
+			found, err := store.APITokens().ByHash("hash1")
+			Expect(err).To(BeNil())
+			Expect(found.ID).To(Equal(token.ID))
+			Expect(found.Scopes).To(Equal([]string{schema.TokenScopeRead}))
+
+			Expect(store.APITokens().Revoke(token.ID)).To(BeNil())
+			found, err = store.APITokens().ByID(token.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Revoked).To(BeTrue())
+
+			tokens, err := store.APITokens().ForUser("test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(tokens).To(HaveLen(1))
+
+			Expect(store.APITokens().Revoke("does-not-exist")).To(Equal(app.ErrNotFound))
+		})
+	})
+
+	Describe("ShareLinks", func() {
+		It("Should stop counting downloads at the limit", func() {
+			l := schema.NewShareLink("file1", "test@mc.org", false, time.Time{}, 1)
+			link, err := store.ShareLinks().Insert(&l)
+			Expect(err).To(BeNil())
+
+			Expect(store.ShareLinks().IncrementDownloads(link.ID)).To(BeNil())
+			Expect(store.ShareLinks().IncrementDownloads(link.ID)).To(Equal(app.ErrNoAccess))
+
+			found, err := store.ShareLinks().ByID(link.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Downloads).To(Equal(1))
+		})
+	})
+
+	Describe("Datasets", func() {
+		var (
+			dataset *schema.Dataset
+			file    *schema.File
+		)
+
+		BeforeEach(func() {
+			var err error
+			d := schema.NewDataset("ds", "test@mc.org", project.ID)
+			dataset, err = store.Datasets().Insert(&d)
+			Expect(err).To(BeNil())
+
+			f := schema.NewFile("f.txt", "test@mc.org")
+			file, err = store.Files().Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+			Expect(store.Datasets().AddFiles(dataset.ID, []string{file.ID})).To(BeNil())
+		})
+
+		It("Should track the files in a dataset", func() {
+			files, err := store.Datasets().Files(dataset.ID)
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(1))
+
+			datasets, err := store.Files().FileDatasets(file.ID)
+			Expect(err).To(BeNil())
+			Expect(datasets).To(HaveLen(1))
+		})
+
+		It("Should update fields and count downloads", func() {
+			Expect(store.Datasets().UpdateFields(dataset.ID, map[string]interface{}{"title": "new"})).To(BeNil())
+			Expect(store.Datasets().IncrementDownloads(dataset.ID)).To(BeNil())
+
+			d, err := store.Datasets().ByID(dataset.ID)
+			Expect(err).To(BeNil())
+			Expect(d.Title).To(Equal("new"))
+			Expect(d.Downloads).To(BeNumerically("==", 1))
+
+			Expect(store.Datasets().UpdateFields(dataset.ID, map[string]interface{}{"nofield": 1})).NotTo(BeNil())
+		})
+
+		It("Should list only published datasets", func() {
+			published, err := store.Datasets().Published()
+			Expect(err).To(BeNil())
+			Expect(published).To(BeEmpty())
+
+			Expect(store.Datasets().UpdateFields(dataset.ID, map[string]interface{}{"published": true})).To(BeNil())
+			published, err = store.Datasets().Published()
+			Expect(err).To(BeNil())
+			Expect(published).To(HaveLen(1))
+			Expect(published[0].ID).To(Equal(dataset.ID))
+		})
+
+		It("Should keep files in a published dataset", func() {
+			Expect(store.Datasets().UpdateFields(dataset.ID, map[string]interface{}{"published": true})).To(BeNil())
+			_, err := store.Files().Delete(file.ID, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			f, err := store.Files().ByID(file.ID)
+			Expect(err).To(BeNil())
+			Expect(f.Current).To(BeFalse())
+		})
+	})
+
+	Describe("AuditEvents", func() {
+		It("Should return matching events newest first", func() {
+			now := time.Now()
+			for i, actor := range []string{"a@mc.org", "b@mc.org", "a@mc.org"} {
+				event := schema.NewAuditEvent(actor, "upload", "host")
+				event.ProjectID = project.ID
+				event.Birthtime = now.Add(time.Duration(i) * time.Minute)
+				Expect(store.AuditEvents().Insert(&event)).To(BeNil())
+			}
+
+			events, err := store.AuditEvents().Query(AuditQuery{ProjectID: project.ID, Actor: "a@mc.org"})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(2))
+			Expect(events[0].Birthtime.After(events[1].Birthtime)).To(BeTrue())
+
+			events, err = store.AuditEvents().Query(AuditQuery{
+				ProjectID: project.ID,
+				Since:     now.Add(30 * time.Second),
+				Limit:     1,
+			})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Birthtime.Sub(now.Add(2 * time.Minute))).To(BeNumerically("~", 0, time.Millisecond))
+		})
+	})
+
+	Describe("Trash", func() {
+		It("Should list a project's items newest first", func() {
+			now := time.Now()
+			for i, path := range []string{"proj1/a.txt", "proj1/b.txt"} {
+				item := schema.NewTrashItem(schema.TrashFile, "f"+path, project.ID, path, "test@mc.org")
+				item.Birthtime = now.Add(time.Duration(i) * time.Minute)
+				_, err := store.Trash().Insert(&item)
+				Expect(err).To(BeNil())
+			}
+			other := schema.NewTrashItem(schema.TrashDirectory, "d1", "other", "other/d", "test@mc.org")
+			other.Birthtime = now.Add(-time.Hour)
+			_, err := store.Trash().Insert(&other)
+			Expect(err).To(BeNil())
+
+			items, err := store.Trash().ForProject(project.ID)
+			Expect(err).To(BeNil())
+			Expect(items).To(HaveLen(2))
+			Expect(items[0].Path).To(Equal("proj1/b.txt"))
+
+			items, err = store.Trash().DeletedBefore(now.Add(30 * time.Second))
+			Expect(err).To(BeNil())
+			Expect(items).To(HaveLen(2))
+			Expect(items[0].Path).To(Equal("proj1/a.txt"))
+			Expect(items[1].ProjectID).To(Equal("other"))
+
+			items, err = store.Trash().ForProject("none")
+			Expect(err).To(BeNil())
+			Expect(items).To(BeEmpty())
+		})
+
+		It("Should find and delete items", func() {
+			item := schema.NewTrashItem(schema.TrashFile, "f1", project.ID, "proj1/a.txt", "test@mc.org")
+			item.Files = 2
+			item.Size = 10
+			newItem, err := store.Trash().Insert(&item)
+			Expect(err).To(BeNil())
+
+			found, err := store.Trash().ByID(newItem.ID)
+			Expect(err).To(BeNil())
+			Expect(found.ItemID).To(Equal("f1"))
+			Expect(found.Files).To(Equal(2))
+			Expect(found.Size).To(BeEquivalentTo(10))
+
+			Expect(store.Trash().Delete(newItem.ID)).To(BeNil())
+			_, err = store.Trash().ByID(newItem.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+			Expect(store.Trash().Delete(newItem.ID)).To(Equal(app.ErrNotFound))
+		})
+	})
+
+	It("Should allow concurrent access", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f := schema.NewFile("f.txt", "test@mc.org")
+				store.Files().Insert(&f, project.DataDir, project.ID)
+				store.Dirs().Files(project.DataDir)
+			}()
+		}
+		wg.Wait()
+
+		files, err := store.Dirs().Files(project.DataDir)
+		Expect(err).To(BeNil())
+		Expect(files).To(HaveLen(20))
+	})
+}
@@ -0,0 +1,289 @@
+// Package migrate creates and updates the RethinkDB tables and secondary
+// indexes the server uses. Changes are numbered migrations, and the versions
+// applied to a database are recorded in its schema_version table.
+package migrate
+
+import (
+	"fmt"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+)
+
+// versionTable records the migrations applied to a database.
+const versionTable = "schema_version"
+
+// A Step creates a table, or a secondary index when Index is set. Steps
+// that are already done are skipped, so migrations can be run against
+// databases created before versions were recorded.
+type Step struct {
+	Table string
+	Index string
+}
+
+// String describes the step.
+func (s Step) String() string {
+	if s.Index != "" {
+		return fmt.Sprintf("create index %s on %s", s.Index, s.Table)
+	}
+	return fmt.Sprintf("create table %s", s.Table)
+}
+
+// A Migration is a numbered change to the schema.
+type Migration struct {
+	Version     int
+	Description string
+	Steps       []Step
+}
+
+// Migrations are the changes that build the schema. To change the schema add
+// a new migration to the end, never edit one that has shipped.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create the tables and indexes for users, projects, directories, files, uploads, samples and processes",
+		Steps: steps(
+			table("projects", "name", "owner"),
+			table("project2datadir", "datadir_id", "project_id"),
+			table("datadirs", "name", "project", "parent"),
+			table("datafiles", "name", "owner", "checksum", "usesid", "mediatype"),
+			table("project2datafile", "project_id", "datafile_id"),
+			table("datadir2datafile", "datadir_id", "datafile_id"),
+			table("users", "apikey"),
+			table("access", "user_id", "project_id"),
+			table("uploads", "owner", "project_id"),
+			table("processes"),
+			table("project2process", "process_id"),
+			table("process2setup", "process_id"),
+			table("samples"),
+			table("project2sample", "sample_id"),
+			table("sample2datafile", "sample_id"),
+			table("sample2propertyset", "property_set_id"),
+			table("propertyset2property", "property_set_id"),
+			table("notes"),
+			table("note2item", "note_id", "item_id"),
+			table("tag2item", "item_id"),
+		),
+	},
+
+	{
+		Version:     2,
+		Description: "Create the tables and indexes for api tokens, share links, datasets and audit events",
+		Steps: steps(
+			table("apitokens", "hash", "owner"),
+			table("sharelinks"),
+			table("datasets"),
+			table("dataset2datafile", "dataset_id", "datafile_id"),
+			table("audit_events", "project_id"),
+		),
+	},
+
+	{
+		Version:     3,
+		Description: "Create the sample and process indexes and tables that search and project export use",
+		Steps: steps(
+			index("project2sample", "project_id"),
+			index("project2process", "project_id"),
+			index("sample2propertyset", "sample_id"),
+			table("properties"),
+			table("setupproperties", "setup_id"),
+		),
+	},
+	{
+		Version:     4,
+		Description: "Create the table for the project trash",
+		Steps: steps(
+			table("trash", "project_id"),
+		),
+	},
+	{
+		Version:     5,
+		Description: "Create the tags table that project export and import use",
+		Steps: steps(
+			table("tags"),
+		),
+	},
+	{
+		Version:     6,
+		Description: "Create the index the search indexer uses to find the samples of a file",
+		Steps: steps(
+			index("sample2datafile", "datafile_id"),
+		),
+	},
+}
+
+// table returns the steps that create a table and its indexes.
+func table(name string, indexes ...string) []Step {
+	tableSteps := []Step{{Table: name}}
+	for _, index := range indexes {
+		tableSteps = append(tableSteps, Step{Table: name, Index: index})
+	}
+	return tableSteps
+}
+
+// index returns the step that adds an index to an existing table.
+func index(table, name string) []Step {
+	return []Step{{Table: table, Index: name}}
+}
+
+// steps joins the steps for several tables.
+func steps(tables ...[]Step) []Step {
+	var all []Step
+	for _, tableSteps := range tables {
+		all = append(all, tableSteps...)
+	}
+	return all
+}
+
+// Latest is the version a database is at once every migration has been applied.
+func Latest() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// A Migrator applies migrations to a database.
+type Migrator struct {
+	session *r.Session
+	name    string
+	db      r.Term
+}
+
+// New creates a Migrator for the database named name.
+func New(session *r.Session, name string) *Migrator {
+	return &Migrator{
+		session: session,
+		name:    name,
+		db:      r.DB(name),
+	}
+}
+
+// DatabaseExists returns true if the database has been created.
+func (m *Migrator) DatabaseExists() (bool, error) {
+	return m.contains(r.DBList(), m.name)
+}
+
+// CreateDatabase creates the database if it doesn't exist.
+func (m *Migrator) CreateDatabase() error {
+	switch exists, err := m.DatabaseExists(); {
+	case err != nil:
+		return err
+	case exists:
+		return nil
+	default:
+		return r.DBCreate(m.name).Exec(m.session)
+	}
+}
+
+// Version returns the version of the last migration applied to the database.
+// It is 0 for a database that doesn't exist or has no recorded versions.
+func (m *Migrator) Version() (int, error) {
+	if exists, err := m.DatabaseExists(); err != nil || !exists {
+		return 0, err
+	}
+
+	if exists, err := m.contains(m.db.TableList(), versionTable); err != nil || !exists {
+		return 0, err
+	}
+
+	res, err := m.db.Table(versionTable).Map(r.Row.Field("id")).Max().Default(0).Run(m.session)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	var version int
+	err = res.One(&version)
+	return version, err
+}
+
+// Pending returns the migrations the database doesn't have yet, in the order
+// they need to be applied.
+func (m *Migrator) Pending() ([]Migration, error) {
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range Migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Apply runs the steps in migration and records its version. RethinkDB has no
+// transactions, so a migration that fails part way leaves the steps already
+// done in place. They are skipped when the migration is applied again.
+func (m *Migrator) Apply(migration Migration) error {
+	if err := m.CreateDatabase(); err != nil {
+		return err
+	}
+
+	if err := m.createTable(versionTable); err != nil {
+		return err
+	}
+
+	for _, step := range migration.Steps {
+		if err := m.run(step); err != nil {
+			return fmt.Errorf("migration %d (%s): %s: %s", migration.Version, migration.Description, step, err)
+		}
+	}
+
+	entry := map[string]interface{}{
+		"id":          migration.Version,
+		"description": migration.Description,
+		"applied":     time.Now(),
+	}
+	return m.db.Table(versionTable).Insert(entry, r.InsertOpts{Conflict: "replace"}).Exec(m.session)
+}
+
+// run runs a step if it hasn't already been done.
+func (m *Migrator) run(step Step) error {
+	if step.Index == "" {
+		return m.createTable(step.Table)
+	}
+	return m.createIndex(step.Table, step.Index)
+}
+
+// createTable creates a table if it doesn't exist.
+func (m *Migrator) createTable(name string) error {
+	switch exists, err := m.contains(m.db.TableList(), name); {
+	case err != nil:
+		return err
+	case exists:
+		return nil
+	default:
+		return m.db.TableCreate(name).Exec(m.session)
+	}
+}
+
+// createIndex creates a secondary index if it doesn't exist, and waits for
+// it to be built.
+func (m *Migrator) createIndex(tableName, index string) error {
+	t := m.db.Table(tableName)
+	switch exists, err := m.contains(t.IndexList(), index); {
+	case err != nil:
+		return err
+	case exists:
+		return nil
+	}
+
+	if err := t.IndexCreate(index).Exec(m.session); err != nil {
+		return err
+	}
+	return t.IndexWait(index).Exec(m.session)
+}
+
+// contains returns true if the list returned by the list query contains name.
+func (m *Migrator) contains(list r.Term, name string) (bool, error) {
+	res, err := list.Contains(name).Run(m.session)
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+
+	var found bool
+	err = res.One(&found)
+	return found, err
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrations", func() {
+	It("Should number the migrations in order starting at 1", func() {
+		for i, migration := range Migrations {
+			Expect(migration.Version).To(Equal(i + 1))
+			Expect(migration.Description).NotTo(BeEmpty())
+		}
+		Expect(Latest()).To(Equal(len(Migrations)))
+	})
+
+	It("Should create each table before its indexes", func() {
+		created := make(map[string]bool)
+		for _, migration := range Migrations {
+			for _, step := range migration.Steps {
+				if step.Index == "" {
+					Expect(created[step.Table]).To(BeFalse(), step.String())
+					created[step.Table] = true
+				} else {
+					Expect(created[step.Table]).To(BeTrue(), step.String())
+				}
+			}
+		}
+	})
+
+	It("Should create the indexes the queries use", func() {
+		indexes := make(map[Step]bool)
+		for _, migration := range Migrations {
+			for _, step := range migration.Steps {
+				indexes[step] = true
+			}
+		}
+
+		for _, step := range []Step{
+			{Table: "datafiles", Index: "checksum"},
+			{Table: "datafiles", Index: "usesid"},
+			{Table: "datadir2datafile", Index: "datafile_id"},
+			{Table: "project2datafile", Index: "datafile_id"},
+			{Table: "projects", Index: "owner"},
+			{Table: "uploads", Index: "owner"},
+			{Table: "access", Index: "user_id"},
+			{Table: "users", Index: "apikey"},
+			{Table: "apitokens", Index: "hash"},
+			{Table: "audit_events", Index: "project_id"},
+			{Table: "trash", Index: "project_id"},
+		} {
+			Expect(indexes[step]).To(BeTrue(), step.String())
+		}
+	})
+
+	It("Should describe steps", func() {
+		Expect(Step{Table: "datafiles"}.String()).To(Equal("create table datafiles"))
+		Expect(Step{Table: "datafiles", Index: "checksum"}.String()).To(Equal("create index checksum on datafiles"))
+	})
+})
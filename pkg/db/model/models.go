@@ -74,3 +74,33 @@ var Datasets = &rModel{
 	schema: schema.Dataset{},
 	table:  "datasets",
 }
+
+// Dataset files
+var DatasetFiles = &rModel{
+	schema: schema.Dataset2DataFile{},
+	table:  "dataset2datafile",
+}
+
+// APITokens
+var APITokens = &rModel{
+	schema: schema.APIToken{},
+	table:  "apitokens",
+}
+
+// ShareLinks
+var ShareLinks = &rModel{
+	schema: schema.ShareLink{},
+	table:  "sharelinks",
+}
+
+// AuditEvents
+var AuditEvents = &rModel{
+	schema: schema.AuditEvent{},
+	table:  "audit_events",
+}
+
+// Trash
+var Trash = &rModel{
+	schema: schema.TrashItem{},
+	table:  "trash",
+}
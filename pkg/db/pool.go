@@ -0,0 +1,274 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/config"
+)
+
+// Pool defaults used when the MCDB_ settings aren't given.
+const (
+	defaultMaxOpen        = 20
+	defaultMaxIdle        = 5
+	defaultQueryTimeout   = 30 * time.Second
+	defaultConnectTimeout = 5 * time.Second
+	defaultHealthInterval = 10 * time.Second
+)
+
+// ErrNotConnected is returned by a Pool that hasn't been able to connect to
+// the database yet.
+var ErrNotConnected = errors.New("not connected to database")
+
+// PoolOptions configure a Pool.
+type PoolOptions struct {
+	Address        string        // Database address (host:port)
+	Database       string        // Database name
+	MaxOpen        int           // Most connections open at once
+	MaxIdle        int           // Most idle connections kept open
+	QueryTimeout   time.Duration // How long a single query read or write can take
+	ConnectTimeout time.Duration // How long connecting can take
+	HealthInterval time.Duration // How often the connection is checked
+}
+
+// PoolOptionsFromConfig reads the pool options from MCDB_CONNECTION, MCDB_NAME,
+// MCDB_MAX_OPEN, MCDB_MAX_IDLE, and the timeouts in seconds MCDB_QUERY_TIMEOUT,
+// MCDB_CONNECT_TIMEOUT and MCDB_HEALTH_INTERVAL.
+func PoolOptionsFromConfig() PoolOptions {
+	return PoolOptions{
+		Address:        config.GetString("MCDB_CONNECTION"),
+		Database:       config.GetString("MCDB_NAME"),
+		MaxOpen:        configInt("MCDB_MAX_OPEN", defaultMaxOpen),
+		MaxIdle:        configInt("MCDB_MAX_IDLE", defaultMaxIdle),
+		QueryTimeout:   configSeconds("MCDB_QUERY_TIMEOUT", defaultQueryTimeout),
+		ConnectTimeout: configSeconds("MCDB_CONNECT_TIMEOUT", defaultConnectTimeout),
+		HealthInterval: configSeconds("MCDB_HEALTH_INTERVAL", defaultHealthInterval),
+	}
+}
+
+// A Pool shares a single RethinkDB session, backed by a pool of connections,
+// across the server. The session is checked periodically and reconnected if
+// the database stops responding. Sessions from a Pool must not be closed,
+// use Release instead.
+type Pool struct {
+	opts    PoolOptions
+	mutex   sync.RWMutex
+	session *r.Session
+	lastErr error
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewPool creates a new Pool and starts checking its connection. It doesn't
+// fail if the database is down, the pool keeps trying to connect.
+func NewPool(opts PoolOptions) *Pool {
+	p := &Pool{
+		opts: opts,
+		stop: make(chan struct{}),
+	}
+	p.connect()
+
+	p.stopped.Add(1)
+	go p.monitor()
+	return p
+}
+
+// RSession returns the shared session. It returns an error if the pool isn't
+// connected.
+func (p *Pool) RSession() (*r.Session, error) {
+	p.mutex.RLock()
+	session, err := p.session, p.lastErr
+	p.mutex.RUnlock()
+
+	switch {
+	case session == nil && err != nil:
+		return nil, err
+	case session == nil:
+		return nil, ErrNotConnected
+	default:
+		return session, nil
+	}
+}
+
+// RSessionMust returns the shared session and panics if the pool isn't connected.
+func (p *Pool) RSessionMust() *r.Session {
+	session, err := p.RSession()
+	if err != nil {
+		panic("Couldn't get rethinkdb session: " + err.Error())
+	}
+	return session
+}
+
+// FeedSession creates a new session for following a changefeed. A changefeed
+// can go a long time between changes, so the session has no read timeout and
+// is separate from the shared session. The caller must close it.
+func (p *Pool) FeedSession() (*r.Session, error) {
+	return r.Connect(r.ConnectOpts{
+		Address:  p.opts.Address,
+		Database: p.opts.Database,
+		Timeout:  p.opts.ConnectTimeout,
+	})
+}
+
+// WaitConnected waits up to timeout for the pool to connect.
+func (p *Pool) WaitConnected(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := p.RSession()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Second)
+		p.connect()
+	}
+}
+
+// Check runs a trivial query on the shared session.
+func (p *Pool) Check() error {
+	session, err := p.RSession()
+	if err != nil {
+		return err
+	}
+
+	res, err := r.Expr(1).Run(session)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	var one int
+	return res.One(&one)
+}
+
+// Close stops checking the connection and closes the shared session.
+func (p *Pool) Close() {
+	close(p.stop)
+	p.stopped.Wait()
+
+	p.mutex.Lock()
+	session := p.session
+	p.mutex.Unlock()
+	if session != nil {
+		session.Close()
+	}
+}
+
+// connect creates the shared session if it doesn't exist yet. The database is
+// dialed without holding the mutex, so RSession isn't blocked while the
+// database is slow to answer.
+func (p *Pool) connect() {
+	p.mutex.RLock()
+	connected := p.session != nil
+	p.mutex.RUnlock()
+	if connected {
+		return
+	}
+
+	session, err := r.Connect(r.ConnectOpts{
+		Address:      p.opts.Address,
+		Database:     p.opts.Database,
+		MaxOpen:      p.opts.MaxOpen,
+		MaxIdle:      p.opts.MaxIdle,
+		Timeout:      p.opts.ConnectTimeout,
+		ReadTimeout:  p.opts.QueryTimeout,
+		WriteTimeout: p.opts.QueryTimeout,
+	})
+
+	p.mutex.Lock()
+	if p.session != nil {
+		// Another connect got there first, keep its session.
+		p.mutex.Unlock()
+		if session != nil {
+			session.Close()
+		}
+		return
+	}
+	p.session, p.lastErr = session, err
+	p.mutex.Unlock()
+}
+
+// monitor checks the connection every HealthInterval. A session that fails
+// its check is reconnected in place, so anything holding the session keeps
+// working once the database is back.
+func (p *Pool) monitor() {
+	defer p.stopped.Done()
+	ticker := time.NewTicker(p.opts.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.connect()
+			p.recordCheck(p.Check())
+		}
+	}
+}
+
+// recordCheck records the result of a check. A session that failed its check
+// is reconnected once the database answers again. The database is probed with
+// a separate connection first because a failed reconnect leaves the session
+// unusable. The mutex is only held to read and record state, not while
+// talking to the database.
+func (p *Pool) recordCheck(checkErr error) {
+	p.mutex.Lock()
+	p.lastErr = checkErr
+	session := p.session
+	p.mutex.Unlock()
+	if checkErr == nil || session == nil {
+		return
+	}
+
+	probe, err := r.Connect(r.ConnectOpts{
+		Address:  p.opts.Address,
+		Database: p.opts.Database,
+		Timeout:  p.opts.ConnectTimeout,
+	})
+	if err != nil {
+		return
+	}
+	probe.Close()
+
+	if err := session.Reconnect(); err != nil {
+		p.mutex.Lock()
+		p.lastErr = err
+		p.mutex.Unlock()
+	}
+}
+
+// Release gives back a session obtained from sc. Sessions from a Pool are
+// shared and stay open, other sessions are closed.
+func Release(sc SessionCreater, session *r.Session) {
+	if _, ok := sc.(*Pool); ok {
+		return
+	}
+	session.Close()
+}
+
+// FeedSession creates a session for following a changefeed using sc.
+func FeedSession(sc SessionCreater) (*r.Session, error) {
+	if p, ok := sc.(*Pool); ok {
+		return p.FeedSession()
+	}
+	return sc.RSession()
+}
+
+// configInt returns the integer setting for key, or def if it isn't set.
+func configInt(key string, def int) int {
+	if value := config.GetInt(key); value > 0 {
+		return value
+	}
+	return def
+}
+
+// configSeconds returns the setting for key as a number of seconds, or def
+// if it isn't set.
+func configSeconds(key string, def time.Duration) time.Duration {
+	if value := config.GetInt(key); value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return def
+}
@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"time"
+)
+
+// Scopes that can be granted to an APIToken.
+const (
+	// TokenScopeRead allows read only access.
+	TokenScopeRead = "read"
+
+	// TokenScopeUpload allows uploading files.
+	TokenScopeUpload = "upload"
+
+	// TokenScopeWrite allows all operations, it implies read and upload.
+	TokenScopeWrite = "write"
+)
+
+// APIToken models a named token that a user can use in place of their
+// apikey. A token can be limited to a set of projects and scopes, and
+// expires at a given time. Only the hash of the token is stored.
+type APIToken struct {
+	ID        string    `gorethink:"id,omitempty" json:"id"`
+	Name      string    `gorethink:"name" json:"name"`
+	Owner     string    `gorethink:"owner" json:"owner"`
+	Hash      string    `gorethink:"hash" json:"-"`
+	Projects  []string  `gorethink:"projects" json:"projects"`
+	Scopes    []string  `gorethink:"scopes" json:"scopes"`
+	Birthtime time.Time `gorethink:"birthtime" json:"birthtime"`
+	Expires   time.Time `gorethink:"expires" json:"expires"`
+	Revoked   bool      `gorethink:"revoked" json:"revoked"`
+	Type      string    `gorethink:"otype" json:"otype"`
+}
+
+// NewAPIToken creates a new APIToken instance.
+func NewAPIToken(name, owner, hash string, expires time.Time) APIToken {
+	return APIToken{
+		Name:      name,
+		Owner:     owner,
+		Hash:      hash,
+		Projects:  []string{},
+		Scopes:    []string{},
+		Birthtime: time.Now(),
+		Expires:   expires,
+		Type:      "apitoken",
+	}
+}
+
+// Expired returns true if the token has expired as of now.
+func (t *APIToken) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && now.After(t.Expires)
+}
+
+// Usable returns true if the token hasn't been revoked and hasn't expired.
+func (t *APIToken) Usable(now time.Time) bool {
+	return !t.Revoked && !t.Expired(now)
+}
+
+// AllowsProject returns true if the token can be used on the given project. A
+// token with no projects listed isn't restricted to any projects.
+func (t *APIToken) AllowsProject(projectID string) bool {
+	if len(t.Projects) == 0 {
+		return true
+	}
+
+	for _, id := range t.Projects {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictedToProjects returns true if the token is limited to a set of projects.
+func (t *APIToken) RestrictedToProjects() bool {
+	return len(t.Projects) != 0
+}
+
+// HasScope returns true if the token was granted scope. The write scope
+// implies all other scopes.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == TokenScopeWrite {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"time"
+)
+
+// AuditEvent records an action a user took on data in the system. Actor is
+// the id of the user, or a description of who acted when there isn't a user,
+// such as an anonymous download. Result is "success" or "failure".
+type AuditEvent struct {
+	ID        string    `gorethink:"id,omitempty" json:"id"`
+	Actor     string    `gorethink:"actor" json:"actor"`
+	Action    string    `gorethink:"action" json:"action"`
+	ProjectID string    `gorethink:"project_id" json:"project_id"`
+	FileID    string    `gorethink:"datafile_id" json:"datafile_id"`
+	Host      string    `gorethink:"host" json:"host"`
+	Result    string    `gorethink:"result" json:"result"`
+	Detail    string    `gorethink:"detail" json:"detail"`
+	Birthtime time.Time `gorethink:"birthtime" json:"birthtime"`
+	Type      string    `gorethink:"otype" json:"otype"`
+}
+
+// NewAuditEvent creates a new AuditEvent instance.
+func NewAuditEvent(actor, action, host string) AuditEvent {
+	return AuditEvent{
+		Actor:     actor,
+		Action:    action,
+		Host:      host,
+		Birthtime: time.Now(),
+		Type:      "audit_event",
+	}
+}
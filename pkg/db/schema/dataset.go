@@ -1,6 +1,39 @@
 package schema
 
+import (
+	"time"
+)
+
+// Dataset models a collection of project files that can be published. The
+// files in a dataset are the file versions that were current when the
+// dataset was created. They are stored in the dataset2datafile join table.
 type Dataset struct {
-	ID        string `gorethink:"id,omitempty" json:"id"`
-	Published bool   `gorethink:"published" json:"published"`
+	ID            string    `gorethink:"id,omitempty" json:"id"`
+	Type          string    `gorethink:"otype" json:"otype"`
+	Title         string    `gorethink:"title" json:"title"`
+	Description   string    `gorethink:"description" json:"description"`
+	Authors       []string  `gorethink:"authors" json:"authors"`
+	License       string    `gorethink:"license" json:"license"`
+	Keywords      []string  `gorethink:"keywords" json:"keywords"`
+	DOI           string    `gorethink:"doi" json:"doi"` // DOI registered for the dataset, without the doi: or URL prefix.
+	Owner         string    `gorethink:"owner" json:"owner"`
+	ProjectID     string    `gorethink:"project_id" json:"project_id"`
+	Birthtime     time.Time `gorethink:"birthtime" json:"birthtime"`
+	MTime         time.Time `gorethink:"mtime" json:"mtime"`
+	Published     bool      `gorethink:"published" json:"published"`
+	PublishedDate time.Time `gorethink:"published_date" json:"published_date"`
+	Downloads     int64     `gorethink:"downloads" json:"downloads"` // Number of times files in the dataset were downloaded.
+}
+
+// NewDataset creates a new unpublished Dataset instance.
+func NewDataset(title, owner, projectID string) Dataset {
+	now := time.Now()
+	return Dataset{
+		Type:      "dataset",
+		Title:     title,
+		Owner:     owner,
+		ProjectID: projectID,
+		Birthtime: now,
+		MTime:     now,
+	}
 }
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"strings"
 	"time"
 )
 
@@ -32,3 +33,9 @@ func NewDirectory(name, owner, project, parent string) Directory {
 		ATime:     now,
 	}
 }
+
+// InTrash returns true if the directory is, or is under, a directory in its
+// project's trash.
+func (d *Directory) InTrash() bool {
+	return strings.HasPrefix(d.Name, TrashDirPrefix)
+}
@@ -23,6 +23,7 @@ func (f fileFields) Size() string        { return "size" }
 func (f fileFields) Uploaded() string    { return "uploaded" }
 func (f fileFields) Parent() string      { return "parent" }
 func (f fileFields) UsesID() string      { return "usesid" }
+func (f fileFields) Verified() string    { return "verified" }
 
 // MediaType describes the mime media type and its description.
 type MediaType struct {
@@ -52,6 +53,7 @@ type File struct {
 	Uploaded    int64     `gorethink:"uploaded" json:"-"`              // Number of bytes uploaded. When Size != Uploaded file is only partially uploaded.
 	Parent      string    `gorethink:"parent" json:"parent"`           // If there are multiple ids then parent is the id of the previous version.
 	UsesID      string    `gorethink:"usesid" json:"usesid"`           // If file is a duplicate, then usesid points to the real file. This allows multiple files to share a single physical file.
+	Verified    time.Time `gorethink:"verified" json:"verified"`       // Last time the stored file was checked against Checksum.
 }
 
 // NewFile creates a new File instance.
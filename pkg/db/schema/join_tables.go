@@ -27,3 +27,10 @@ type Sample2DataFile struct {
 	SampleID   string `gorethink:"sample_id"`
 	DataFileID string `gorethink:"datafile_id"`
 }
+
+// Dataset2DataFile is a join table that maps datasets to their files.
+type Dataset2DataFile struct {
+	ID         string `gorethink:"id,omitempty"`
+	DatasetID  string `gorethink:"dataset_id"`
+	DataFileID string `gorethink:"datafile_id"`
+}
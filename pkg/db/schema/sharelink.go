@@ -0,0 +1,33 @@
+package schema
+
+import (
+	"time"
+)
+
+// ShareLink models a signed link that gives access to a single file without
+// an apikey. A link expires at a given time and can optionally be limited
+// to a number of downloads. A MaxDownloads of 0 means there is no limit.
+type ShareLink struct {
+	ID           string    `gorethink:"id,omitempty" json:"id"`
+	FileID       string    `gorethink:"datafile_id" json:"datafile_id"`
+	Owner        string    `gorethink:"owner" json:"owner"`
+	Original     bool      `gorethink:"original" json:"original"`
+	Birthtime    time.Time `gorethink:"birthtime" json:"birthtime"`
+	Expires      time.Time `gorethink:"expires" json:"expires"`
+	MaxDownloads int       `gorethink:"max_downloads" json:"max_downloads"`
+	Downloads    int       `gorethink:"downloads" json:"downloads"`
+	Type         string    `gorethink:"otype" json:"otype"`
+}
+
+// NewShareLink creates a new ShareLink instance.
+func NewShareLink(fileID, owner string, original bool, expires time.Time, maxDownloads int) ShareLink {
+	return ShareLink{
+		FileID:       fileID,
+		Owner:        owner,
+		Original:     original,
+		Birthtime:    time.Now(),
+		Expires:      expires,
+		MaxDownloads: maxDownloads,
+		Type:         "sharelink",
+	}
+}
@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"time"
+)
+
+// Kinds of item in the trash.
+const (
+	TrashFile      = "file"
+	TrashDirectory = "directory"
+)
+
+// TrashItem is a file or directory that was deleted from a project. It is
+// kept, and counts toward the project's storage, until it is restored or
+// purged. A file item holds every version of the file. A directory item holds
+// the directory and everything under it.
+//
+// While an item is in the trash its contents are in a holding directory that
+// is outside the project's directory tree. For a file this is a directory
+// created for the item, for a directory it is the directory itself, renamed.
+type TrashItem struct {
+	ID          string    `gorethink:"id,omitempty" json:"id"`
+	Type        string    `gorethink:"otype" json:"otype"`
+	ProjectID   string    `gorethink:"project_id" json:"project_id"`
+	ItemType    string    `gorethink:"item_type" json:"item_type"`       // TrashFile or TrashDirectory.
+	ItemID      string    `gorethink:"item_id" json:"item_id"`           // The file or directory that was deleted.
+	Path        string    `gorethink:"path" json:"path"`                 // Path the item was deleted from.
+	DirectoryID string    `gorethink:"directory_id" json:"directory_id"` // Directory the item was deleted from.
+	HolderID    string    `gorethink:"holder_id" json:"holder_id"`       // Directory holding the item in the trash.
+	Files       int       `gorethink:"files" json:"files"`               // Number of file entries, including versions.
+	Size        int64     `gorethink:"size" json:"size"`                 // Total size of the file entries.
+	Owner       string    `gorethink:"owner" json:"owner"`               // Who deleted the item.
+	Birthtime   time.Time `gorethink:"birthtime" json:"birthtime"`       // When the item was deleted.
+}
+
+// TrashDirPrefix starts the name of every directory in a project's trash.
+// Directory paths given by users must start with the project name, so they can
+// never refer to a directory in the trash.
+const TrashDirPrefix = ".trash/"
+
+// NewTrashItem creates a new TrashItem instance.
+func NewTrashItem(itemType, itemID, projectID, path, owner string) TrashItem {
+	return TrashItem{
+		Type:      "trash",
+		ItemType:  itemType,
+		ItemID:    itemID,
+		ProjectID: projectID,
+		Path:      path,
+		Owner:     owner,
+		Birthtime: time.Now(),
+	}
+}
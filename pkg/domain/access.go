@@ -11,6 +11,8 @@ import (
 type Access interface {
 	AllowedByOwner(projectID, user string) bool
 	GetFile(apikey, fileID string) (*schema.File, error)
+	GetTokenFile(token schema.APIToken, fileID string) (*schema.File, error)
+	GetPublishedFile(fileID string) (*schema.File, *schema.Dataset, error)
 }
 
 // access validates access to data. It checks if a user
@@ -65,41 +67,91 @@ func (a *access) GetFile(apikey, fileID string) (*schema.File, error) {
 		return nil, app.ErrNoAccess
 	}
 
+	return a.userFile(user.ID, fileID, nil)
+}
+
+// GetTokenFile validates access to a file for a request made with an api
+// token. The token's owner must have access to the file, and the token
+// must not be restricted from the file's project.
+func (a *access) GetTokenFile(token schema.APIToken, fileID string) (*schema.File, error) {
+	return a.userFile(token.Owner, fileID, &token)
+}
+
+// userFile returns the file if user has access to it. Files in a published
+// dataset can be read by anyone, other files can't be read while they are in
+// the trash. When token isn't nil the file's project must also be one the
+// token allows.
+func (a *access) userFile(user, fileID string, token *schema.APIToken) (*schema.File, error) {
 	file, err := a.files.ByID(fileID)
 	if err != nil {
 		app.Log.Error("File lookup failed", "error", err, "fileid", fileID)
 		return nil, app.ErrNoAccess
 	}
 
-	if a.isInPublishedDataset(fileID) {
+	if a.publishedDataset(fileID) != nil {
 		return file, nil
 	}
 
+	if fileInTrash(a.files, fileID) {
+		return nil, app.Errorf(app.ErrNotFound, "file %s is in the trash", fileID)
+	}
+
 	project, err := a.files.GetProject(fileID)
 	if err != nil {
 		app.Log.Error("Project lookup for file failed", "error", err, "fileid", fileID)
 		return nil, app.ErrNoAccess
 	}
 
-	if !a.AllowedByOwner(project.ID, user.ID) {
-		app.Log.Info("Access denied", "fileid", file.ID, "user", user.ID, "projectid", project.ID)
+	if token != nil && !token.AllowsProject(project.ID) {
+		app.Log.Info("Token not allowed on project", "fileid", file.ID, "tokenid", token.ID, "projectid", project.ID)
+		return nil, app.ErrNoAccess
+	}
+
+	if !a.AllowedByOwner(project.ID, user) {
+		app.Log.Info("Access denied", "fileid", file.ID, "user", user, "projectid", project.ID)
 		return nil, app.ErrNoAccess
 	}
 
 	return file, nil
 }
 
-// isInPublishedDataset checks if the file is in a published dataset. If it is then the file is accessible.
-func (a *access) isInPublishedDataset(fileID string) bool {
+// GetPublishedFile validates anonymous access to a file. A file can be accessed
+// without credentials only when it is in a published dataset. It returns the file
+// and the published dataset it was found in, otherwise it returns ErrNoAccess.
+func (a *access) GetPublishedFile(fileID string) (*schema.File, *schema.Dataset, error) {
+	dataset := a.publishedDataset(fileID)
+	if dataset == nil {
+		return nil, nil, app.ErrNoAccess
+	}
+
+	file, err := a.files.ByID(fileID)
+	if err != nil {
+		app.Log.Error("File lookup failed", "error", err, "fileid", fileID)
+		return nil, nil, app.ErrNoAccess
+	}
+
+	return file, dataset, nil
+}
+
+// publishedDataset returns the first published dataset the file is in. If the file
+// isn't in a published dataset then it returns nil.
+func (a *access) publishedDataset(fileID string) *schema.Dataset {
 	datasets, err := a.files.FileDatasets(fileID)
 	if err != nil || datasets == nil {
-		return false
+		return nil
 	}
 
-	for _, ds := range datasets {
-		if ds.Published {
-			return true
+	for i := range datasets {
+		if datasets[i].Published {
+			return &datasets[i]
 		}
 	}
-	return false
+	return nil
+}
+
+// fileInTrash returns true if a file is in its project's trash. Files in the
+// trash can only be reached through the trash.
+func fileInTrash(files dai.Files, fileID string) bool {
+	dir, err := files.Directory(fileID)
+	return err == nil && dir.InTrash()
 }
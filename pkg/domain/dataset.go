@@ -0,0 +1,252 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// A DatasetRequest describes a new dataset. The dataset is made up of the
+// files in FileIDs plus all the current files in DirectoryIDs and their
+// subdirectories.
+type DatasetRequest struct {
+	ProjectID    string
+	Title        string
+	Description  string
+	Authors      []string
+	License      string
+	Keywords     []string
+	DOI          string // Optional, with or without a doi: or https://doi.org/ prefix.
+	FileIDs      []string
+	DirectoryIDs []string
+}
+
+// Datasets creates, publishes and unpublishes datasets.
+type Datasets interface {
+	Create(user schema.User, req DatasetRequest) (*schema.Dataset, error)
+	Publish(user schema.User, datasetID string) (*schema.Dataset, error)
+	Unpublish(user schema.User, datasetID string) (*schema.Dataset, error)
+}
+
+// datasets implements the Datasets interface. The file versions in a dataset
+// are fixed when the dataset is created. Later uploads create new file versions
+// and so never change the contents of an existing dataset.
+type datasets struct {
+	datasets dai.Datasets
+	files    dai.Files
+	dirs     dai.Dirs
+	access   Access
+}
+
+// NewDatasets creates a new Datasets.
+func NewDatasets(ds dai.Datasets, files dai.Files, dirs dai.Dirs, access Access) *datasets {
+	return &datasets{
+		datasets: ds,
+		files:    files,
+		dirs:     dirs,
+		access:   access,
+	}
+}
+
+// Create creates a new unpublished dataset from files and directories in a
+// project. The user must have access to the project.
+func (d *datasets) Create(user schema.User, req DatasetRequest) (*schema.Dataset, error) {
+	switch {
+	case req.ProjectID == "":
+		return nil, app.Errorf(app.ErrInvalid, "project_id is required")
+	case strings.TrimSpace(req.Title) == "":
+		return nil, app.Errorf(app.ErrInvalid, "title is required")
+	case len(req.FileIDs) == 0 && len(req.DirectoryIDs) == 0:
+		return nil, app.Errorf(app.ErrInvalid, "at least one file or directory is required")
+	}
+
+	doi, ok := normalizeDOI(req.DOI)
+	if !ok {
+		return nil, app.Errorf(app.ErrInvalid, "invalid doi %s", req.DOI)
+	}
+
+	if !d.access.AllowedByOwner(req.ProjectID, user.ID) {
+		return nil, app.ErrNoAccess
+	}
+
+	fileIDs, err := d.selectFiles(req)
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := schema.NewDataset(strings.TrimSpace(req.Title), user.ID, req.ProjectID)
+	dataset.Description = req.Description
+	dataset.Authors = req.Authors
+	dataset.License = req.License
+	dataset.Keywords = req.Keywords
+	dataset.DOI = doi
+
+	newDataset, err := d.datasets.Insert(&dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.datasets.AddFiles(newDataset.ID, fileIDs); err != nil {
+		app.Log.Error("Unable to add files to dataset", "datasetid", newDataset.ID, "error", err)
+		return nil, err
+	}
+
+	return newDataset, nil
+}
+
+// normalizeDOI strips the doi: or resolver prefix from a DOI. It returns false
+// if what is left isn't a DOI, which is 10.PREFIX/SUFFIX. An empty DOI is
+// allowed.
+func normalizeDOI(doi string) (string, bool) {
+	doi = strings.TrimSpace(doi)
+	for _, prefix := range []string{"doi:", "https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/"} {
+		if strings.HasPrefix(strings.ToLower(doi), prefix) {
+			doi = doi[len(prefix):]
+			break
+		}
+	}
+
+	if doi == "" {
+		return "", true
+	}
+
+	slash := strings.Index(doi, "/")
+	if !strings.HasPrefix(doi, "10.") || slash < len("10.x") || slash == len(doi)-1 {
+		return "", false
+	}
+	return doi, true
+}
+
+// selectFiles resolves the files and directories in a request to the list of
+// file ids to put in the dataset. Every file and directory must be in the
+// request's project, and not in its trash.
+func (d *datasets) selectFiles(req DatasetRequest) ([]string, error) {
+	seen := make(map[string]bool)
+	var fileIDs []string
+	add := func(fileID string) {
+		if !seen[fileID] {
+			seen[fileID] = true
+			fileIDs = append(fileIDs, fileID)
+		}
+	}
+
+	for _, fileID := range req.FileIDs {
+		project, err := d.files.GetProject(fileID)
+		if err != nil || project.ID != req.ProjectID || fileInTrash(d.files, fileID) {
+			return nil, app.Errorf(app.ErrInvalid, "file %s is not in project %s", fileID, req.ProjectID)
+		}
+		add(fileID)
+	}
+
+	for _, dirID := range req.DirectoryIDs {
+		dir, err := d.dirs.ByID(dirID)
+		if err != nil || dir.Project != req.ProjectID || dir.InTrash() {
+			return nil, app.Errorf(app.ErrInvalid, "directory %s is not in project %s", dirID, req.ProjectID)
+		}
+		if err := d.addDirFiles(dirID, add); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(fileIDs) == 0 {
+		return nil, app.Errorf(app.ErrInvalid, "no files selected")
+	}
+
+	return fileIDs, nil
+}
+
+// addDirFiles calls add for the current version of every file in dirID and
+// its subdirectories.
+func (d *datasets) addDirFiles(dirID string, add func(fileID string)) error {
+	files, err := d.dirs.Files(dirID)
+	if err != nil && err != app.ErrNotFound {
+		return err
+	}
+
+	for _, file := range files {
+		if file.Current {
+			add(file.ID)
+		}
+	}
+
+	children, err := d.dirs.Children(dirID)
+	if err != nil && err != app.ErrNotFound {
+		return err
+	}
+
+	for _, child := range children {
+		if err := d.addDirFiles(child.ID, add); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Publish makes a dataset publicly available. Only the dataset owner or an
+// admin can publish a dataset. A dataset needs authors and a license before
+// it can be published.
+func (d *datasets) Publish(user schema.User, datasetID string) (*schema.Dataset, error) {
+	dataset, err := d.datasets.ByID(datasetID)
+	switch {
+	case err != nil:
+		return nil, app.ErrNotFound
+	case dataset.Owner != user.ID && !user.Admin:
+		return nil, app.ErrNoAccess
+	case dataset.Published:
+		return dataset, nil
+	case len(dataset.Authors) == 0:
+		return nil, app.Errorf(app.ErrInvalid, "a dataset must have authors to be published")
+	case dataset.License == "":
+		return nil, app.Errorf(app.ErrInvalid, "a dataset must have a license to be published")
+	}
+
+	now := time.Now()
+	fields := map[string]interface{}{
+		"published":      true,
+		"published_date": now,
+		"mtime":          now,
+	}
+	if err := d.datasets.UpdateFields(datasetID, fields); err != nil {
+		return nil, err
+	}
+
+	dataset.Published = true
+	dataset.PublishedDate = now
+	dataset.MTime = now
+	app.Log.Info("Dataset published", "datasetid", datasetID, "user", user.ID)
+	return dataset, nil
+}
+
+// Unpublish removes public access to a dataset. Only admins can unpublish a
+// dataset.
+func (d *datasets) Unpublish(user schema.User, datasetID string) (*schema.Dataset, error) {
+	if !user.Admin {
+		return nil, app.ErrNoAccess
+	}
+
+	dataset, err := d.datasets.ByID(datasetID)
+	switch {
+	case err != nil:
+		return nil, app.ErrNotFound
+	case !dataset.Published:
+		return dataset, nil
+	}
+
+	now := time.Now()
+	fields := map[string]interface{}{
+		"published": false,
+		"mtime":     now,
+	}
+	if err := d.datasets.UpdateFields(datasetID, fields); err != nil {
+		return nil, err
+	}
+
+	dataset.Published = false
+	dataset.MTime = now
+	app.Log.Info("Dataset unpublished", "datasetid", datasetID, "user", user.ID)
+	return dataset, nil
+}
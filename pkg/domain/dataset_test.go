@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai/mocks"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// projectAccess is an Access that allows or denies all project access.
+type projectAccess struct {
+	allowed bool
+}
+
+func (a *projectAccess) AllowedByOwner(projectID, user string) bool { return a.allowed }
+
+func (a *projectAccess) GetFile(apikey, fileID string) (*schema.File, error) {
+	return nil, app.ErrNoAccess
+}
+
+func (a *projectAccess) GetTokenFile(token schema.APIToken, fileID string) (*schema.File, error) {
+	return nil, app.ErrNoAccess
+}
+
+func (a *projectAccess) GetPublishedFile(fileID string) (*schema.File, *schema.Dataset, error) {
+	return nil, nil, app.ErrNoAccess
+}
+
+func TestCreateDatasetFromDirectory(t *testing.T) {
+	mdatasets := mocks.NewMDatasets()
+	mfiles := mocks.NewMFiles()
+	mdirs := mocks.NewMDirs()
+	maccess := &projectAccess{}
+	d := NewDatasets(mdatasets, mfiles, mdirs, maccess)
+	user := schema.NewUser("test", "test@mc.org", "", "abc123")
+
+	// Test no access to project
+	req := DatasetRequest{ProjectID: "proj1", Title: "ds", DirectoryIDs: []string{"dir1"}}
+	_, err := d.Create(user, req)
+	require.Equal(t, app.ErrNoAccess, err, "Wrong error %s", err)
+
+	// Test only current files, including subdirectories, are added
+	maccess.allowed = true
+	mdirs.On("ByID", "dir1").Return(&schema.Directory{ID: "dir1", Project: "proj1"}, nil)
+	mdirs.On("Files", "dir1").Return([]schema.File{{ID: "f1", Current: true}, {ID: "f1-old", Current: false}}, nil)
+	mdirs.On("Children", "dir1").Return([]schema.Directory{{ID: "dir2"}}, nil)
+	mdirs.On("Files", "dir2").Return([]schema.File{{ID: "f2", Current: true}}, nil)
+	mdirs.On("Children", "dir2").Return([]schema.Directory{}, nil)
+	mdatasets.On("Insert", mock.Anything).Return(&schema.Dataset{ID: "ds1"}, nil)
+	mdatasets.On("AddFiles", "ds1", []string{"f1", "f2"}).Return(nil)
+	ds, err := d.Create(user, req)
+	require.Nil(t, err, "Unexpected error %s", err)
+	require.Equal(t, "ds1", ds.ID)
+	mdatasets.AssertCalled(t, "AddFiles", "ds1", []string{"f1", "f2"})
+}
+
+func TestCreateDatasetValidation(t *testing.T) {
+	mfiles := mocks.NewMFiles()
+	d := NewDatasets(mocks.NewMDatasets(), mfiles, mocks.NewMDirs(), &projectAccess{allowed: true})
+	user := schema.NewUser("test", "test@mc.org", "", "abc123")
+
+	// Test missing title
+	_, err := d.Create(user, DatasetRequest{ProjectID: "proj1", FileIDs: []string{"f1"}})
+	require.NotNil(t, err, "Expected missing title to be rejected")
+
+	// Test nothing selected
+	_, err = d.Create(user, DatasetRequest{ProjectID: "proj1", Title: "ds"})
+	require.NotNil(t, err, "Expected empty selection to be rejected")
+
+	// Test file from another project
+	mfiles.On("GetProject", "f1").Return(&schema.Project{ID: "proj2"}, nil)
+	_, err = d.Create(user, DatasetRequest{ProjectID: "proj1", Title: "ds", FileIDs: []string{"f1"}})
+	require.NotNil(t, err, "Expected file from another project to be rejected")
+}
+
+func TestCreateDatasetDOI(t *testing.T) {
+	d := NewDatasets(mocks.NewMDatasets(), mocks.NewMFiles(), mocks.NewMDirs(), &projectAccess{allowed: true})
+	user := schema.NewUser("test", "test@mc.org", "", "abc123")
+
+	// Test DOI that isn't a DOI
+	req := DatasetRequest{ProjectID: "proj1", Title: "ds", FileIDs: []string{"f1"}, DOI: "materials-commons/ds"}
+	_, err := d.Create(user, req)
+	require.True(t, app.Is(err, app.ErrInvalid), "Wrong error %s", err)
+
+	// Test prefixes are removed
+	for _, doi := range []string{"10.1234/ds", "doi:10.1234/ds", "https://doi.org/10.1234/ds", " 10.1234/ds "} {
+		normalized, ok := normalizeDOI(doi)
+		require.True(t, ok, "Expected %s to be accepted", doi)
+		require.Equal(t, "10.1234/ds", normalized)
+	}
+
+	// Test incomplete DOIs
+	for _, doi := range []string{"10./ds", "10.1234/", "10.1234", "11.1234/ds"} {
+		_, ok := normalizeDOI(doi)
+		require.False(t, ok, "Expected %s to be rejected", doi)
+	}
+}
+
+func TestPublishDataset(t *testing.T) {
+	mdatasets := mocks.NewMDatasets()
+	d := NewDatasets(mdatasets, nil, nil, nil)
+	owner := schema.NewUser("test", "test@mc.org", "", "abc123")
+	other := schema.NewUser("other", "other@mc.org", "", "def456")
+
+	// Test dataset without a license
+	mdatasets.On("ByID", "ds1").Return(&schema.Dataset{ID: "ds1", Owner: "test@mc.org", Authors: []string{"A"}}, nil).Once()
+	_, err := d.Publish(owner, "ds1")
+	require.NotNil(t, err, "Expected dataset without a license to be rejected")
+
+	// Test not the owner
+	mdatasets.On("ByID", "ds1").Return(&schema.Dataset{ID: "ds1", Owner: "test@mc.org", Authors: []string{"A"}, License: "CC-BY"}, nil)
+	_, err = d.Publish(other, "ds1")
+	require.Equal(t, app.ErrNoAccess, err, "Wrong error %s", err)
+
+	// Test owner can publish
+	mdatasets.On("UpdateFields", "ds1", mock.Anything).Return(nil)
+	ds, err := d.Publish(owner, "ds1")
+	require.Nil(t, err, "Unexpected error %s", err)
+	require.True(t, ds.Published, "Dataset should be published")
+}
+
+func TestUnpublishDatasetRequiresAdmin(t *testing.T) {
+	mdatasets := mocks.NewMDatasets()
+	d := NewDatasets(mdatasets, nil, nil, nil)
+	owner := schema.NewUser("test", "test@mc.org", "", "abc123")
+	admin := schema.NewUser("admin", "admin@mc.org", "", "def456")
+	admin.Admin = true
+
+	_, err := d.Unpublish(owner, "ds1")
+	require.Equal(t, app.ErrNoAccess, err, "Wrong error %s", err)
+
+	mdatasets.On("ByID", "ds1").Return(&schema.Dataset{ID: "ds1", Owner: "test@mc.org", Published: true}, nil)
+	mdatasets.On("UpdateFields", "ds1", mock.Anything).Return(nil)
+	ds, err := d.Unpublish(admin, "ds1")
+	require.Nil(t, err, "Unexpected error %s", err)
+	require.False(t, ds.Published, "Dataset should not be published")
+}
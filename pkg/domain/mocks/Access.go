@@ -28,3 +28,22 @@ func (m *Access) GetFile(apikey, fileID string) (*schema.File, error) {
 
 	return r0, r1
 }
+
+func (m *Access) GetTokenFile(token schema.APIToken, fileID string) (*schema.File, error) {
+	ret := m.Called(token, fileID)
+
+	r0 := ret.Get(0).(*schema.File)
+	r1 := ret.Error(1)
+
+	return r0, r1
+}
+
+func (m *Access) GetPublishedFile(fileID string) (*schema.File, *schema.Dataset, error) {
+	ret := m.Called(fileID)
+
+	r0 := ret.Get(0).(*schema.File)
+	r1 := ret.Get(1).(*schema.Dataset)
+	r2 := ret.Error(2)
+
+	return r0, r1, r2
+}
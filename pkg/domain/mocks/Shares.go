@@ -0,0 +1,38 @@
+package mocks
+
+import "github.com/materials-commons/testify/mock"
+
+import (
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
+)
+
+type Shares struct {
+	mock.Mock
+}
+
+func NewMShares() *Shares {
+	return &Shares{}
+}
+
+func (m *Shares) Create(user schema.User, fileID string, original bool, expires time.Time, maxDownloads int) (*schema.ShareLink, string, error) {
+	ret := m.Called(user, fileID, original, expires, maxDownloads)
+
+	r0 := ret.Get(0).(*schema.ShareLink)
+	r1 := ret.Get(1).(string)
+	r2 := ret.Error(2)
+
+	return r0, r1, r2
+}
+
+func (m *Shares) Redeem(req domain.ShareRequest, now time.Time) (*schema.File, *schema.ShareLink, error) {
+	ret := m.Called(req, now)
+
+	r0 := ret.Get(0).(*schema.File)
+	r1 := ret.Get(1).(*schema.ShareLink)
+	r2 := ret.Error(2)
+
+	return r0, r1, r2
+}
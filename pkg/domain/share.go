@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// A ShareRequest contains the values passed on a share link URL.
+type ShareRequest struct {
+	LinkID    string
+	FileID    string
+	Expires   int64
+	Original  bool
+	Signature string
+
+	// CountDownload is false for requests that can't return any
+	// of the file, such as a HEAD request. Every other request
+	// counts against the download limit, including ranged requests,
+	// so that a client can't fetch a file in parts without being
+	// counted.
+	CountDownload bool
+}
+
+// Shares creates and redeems signed links to individual files.
+type Shares interface {
+	Create(user schema.User, fileID string, original bool, expires time.Time, maxDownloads int) (*schema.ShareLink, string, error)
+	Redeem(req ShareRequest, now time.Time) (*schema.File, *schema.ShareLink, error)
+}
+
+// shares implements the Shares interface. Links are signed with an
+// HMAC so that the server doesn't need to trust any of the values
+// passed on the URL.
+type shares struct {
+	links  dai.ShareLinks
+	files  dai.Files
+	access Access
+	key    []byte
+}
+
+// NewShares creates a new Shares that signs links with key.
+func NewShares(links dai.ShareLinks, files dai.Files, access Access, key []byte) *shares {
+	return &shares{
+		links:  links,
+		files:  files,
+		access: access,
+		key:    key,
+	}
+}
+
+// Create creates a new share link for fileID. The user must have access to the
+// file. It returns the link and the signature to put on the URL.
+func (s *shares) Create(user schema.User, fileID string, original bool, expires time.Time, maxDownloads int) (*schema.ShareLink, string, error) {
+	if _, err := s.access.GetFile(user.APIKey, fileID); err != nil {
+		return nil, "", err
+	}
+
+	link := schema.NewShareLink(fileID, user.ID, original, expires, maxDownloads)
+	newLink, err := s.links.Insert(&link)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sig := SignShareLink(s.key, newLink.ID, newLink.FileID, newLink.Expires.Unix(), newLink.Original)
+	return newLink, sig, nil
+}
+
+// Redeem validates a share request. The signature must match, the link must
+// not have expired, the user who created the link must still have access to
+// the file, the file must not be in the trash and, when the request counts as
+// a download, the link must not have reached its download limit. It returns
+// the file to serve.
+func (s *shares) Redeem(req ShareRequest, now time.Time) (*schema.File, *schema.ShareLink, error) {
+	expected := SignShareLink(s.key, req.LinkID, req.FileID, req.Expires, req.Original)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		app.Log.Info("Share link signature mismatch", "linkid", req.LinkID, "fileid", req.FileID)
+		return nil, nil, app.ErrNoAccess
+	}
+
+	if now.Unix() > req.Expires {
+		return nil, nil, app.Errorf(app.ErrNoAccess, "share link expired")
+	}
+
+	link, err := s.links.ByID(req.LinkID)
+	switch {
+	case err != nil:
+		return nil, nil, app.ErrNoAccess
+	case link.FileID != req.FileID:
+		return nil, nil, app.ErrNoAccess
+	case !s.ownerHasAccess(link):
+		app.Log.Info("Share link owner no longer has access", "linkid", link.ID, "owner", link.Owner, "fileid", link.FileID)
+		return nil, nil, app.ErrNoAccess
+	case fileInTrash(s.files, link.FileID):
+		return nil, nil, app.Errorf(app.ErrNotFound, "shared file is in the trash")
+	}
+
+	if req.CountDownload {
+		if err := s.links.IncrementDownloads(link.ID); err != nil {
+			return nil, nil, app.Errorf(app.ErrNoAccess, "share link download limit reached")
+		}
+		link.Downloads++
+	}
+
+	file, err := s.files.ByID(req.FileID)
+	if err != nil {
+		return nil, nil, app.ErrNoAccess
+	}
+
+	return file, link, nil
+}
+
+// ownerHasAccess checks that the user who created a link still has access to
+// the project the file is in, so that a link stops working when its owner is
+// removed from the project.
+func (s *shares) ownerHasAccess(link *schema.ShareLink) bool {
+	project, err := s.files.GetProject(link.FileID)
+	if err != nil {
+		return false
+	}
+	return s.access.AllowedByOwner(project.ID, link.Owner)
+}
+
+// SignShareLink computes the signature for a share link.
+func SignShareLink(key []byte, linkID, fileID string, expires int64, original bool) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s:%s:%d:%t", linkID, fileID, expires, original)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignShareLink(t *testing.T) {
+	key := []byte("secret")
+	sig := SignShareLink(key, "link", "file", 100, false)
+	require.Equal(t, sig, SignShareLink(key, "link", "file", 100, false), "Signatures should be stable")
+
+	require.NotEqual(t, sig, SignShareLink(key, "link", "file", 101, false), "Changing expires should change signature")
+	require.NotEqual(t, sig, SignShareLink(key, "link", "file", 100, true), "Changing original should change signature")
+	require.NotEqual(t, sig, SignShareLink(key, "link", "file2", 100, false), "Changing file should change signature")
+	require.NotEqual(t, sig, SignShareLink([]byte("other"), "link", "file", 100, false), "Changing key should change signature")
+}
+
+func TestRedeemRejectsBadSignature(t *testing.T) {
+	s := NewShares(nil, nil, nil, []byte("secret"))
+	req := ShareRequest{
+		LinkID:    "link",
+		FileID:    "file",
+		Expires:   100,
+		Signature: "bad",
+	}
+	_, _, err := s.Redeem(req, time.Unix(50, 0))
+	require.NotNil(t, err, "Expected bad signature to be rejected")
+}
+
+func TestRedeemRejectsExpiredLink(t *testing.T) {
+	key := []byte("secret")
+	s := NewShares(nil, nil, nil, key)
+	req := ShareRequest{
+		LinkID:    "link",
+		FileID:    "file",
+		Expires:   100,
+		Signature: SignShareLink(key, "link", "file", 100, false),
+	}
+	_, _, err := s.Redeem(req, time.Unix(200, 0))
+	require.NotNil(t, err, "Expected expired link to be rejected")
+}
+
+// newShareLink creates a store with a file shared by a link limited to
+// maxDownloads. It returns the shares, the store and a request for the link.
+func newShareLink(t *testing.T, access Access, maxDownloads int) (*shares, ShareRequest) {
+	key := []byte("secret")
+	store := dai.NewMemStore()
+	p := schema.NewProject("proj", "test@mc.org")
+	project, err := store.Projects().Insert(&p)
+	require.Nil(t, err)
+	f := schema.NewFile("f.txt", "test@mc.org")
+	file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+	require.Nil(t, err)
+
+	expires := time.Unix(100, 0)
+	l := schema.NewShareLink(file.ID, "test@mc.org", false, expires, maxDownloads)
+	link, err := store.ShareLinks().Insert(&l)
+	require.Nil(t, err)
+
+	s := NewShares(store.ShareLinks(), store.Files(), access, key)
+	req := ShareRequest{
+		LinkID:    link.ID,
+		FileID:    file.ID,
+		Expires:   expires.Unix(),
+		Signature: SignShareLink(key, link.ID, file.ID, expires.Unix(), false),
+	}
+	return s, req
+}
+
+func TestRedeemCountsEveryDownload(t *testing.T) {
+	s, req := newShareLink(t, &projectAccess{allowed: true}, 2)
+	now := time.Unix(50, 0)
+
+	req.CountDownload = true
+	for i := 0; i < 2; i++ {
+		_, _, err := s.Redeem(req, now)
+		require.Nil(t, err, "Download %d should be allowed: %s", i, err)
+	}
+	_, _, err := s.Redeem(req, now)
+	require.NotNil(t, err, "Expected the download limit to be enforced")
+
+	req.CountDownload = false
+	_, _, err = s.Redeem(req, now)
+	require.Nil(t, err, "Requests that don't download shouldn't be limited")
+}
+
+func TestRedeemRejectsOwnerWithoutAccess(t *testing.T) {
+	s, req := newShareLink(t, &projectAccess{allowed: false}, 0)
+	_, _, err := s.Redeem(req, time.Unix(50, 0))
+	require.NotNil(t, err, "Expected a link whose owner lost access to be rejected")
+}
+
+func TestTrashedFilesAreNotReachable(t *testing.T) {
+	store := dai.NewMemStore()
+	user := schema.NewUser("test", "test@mc.org", "", "testkey")
+	require.Nil(t, store.AddUser(user))
+	p := schema.NewProject("proj", user.ID)
+	project, err := store.Projects().Insert(&p)
+	require.Nil(t, err)
+	f := schema.NewFile("f.txt", user.ID)
+	file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+	require.Nil(t, err)
+
+	access := NewAccess(store.Projects(), store.Files(), store.Users())
+	s := NewShares(store.ShareLinks(), store.Files(), access, []byte("secret"))
+	expires := time.Now().Add(time.Hour)
+	link, sig, err := s.Create(user, file.ID, false, expires, 0)
+	require.Nil(t, err)
+	req := ShareRequest{LinkID: link.ID, FileID: file.ID, Expires: expires.Unix(), Signature: sig}
+	_, _, err = s.Redeem(req, time.Now())
+	require.Nil(t, err, "Expected the link to work before the file is in the trash")
+
+	h := schema.NewDirectory(schema.TrashDirPrefix+file.ID, user.ID, project.ID, "")
+	holder, err := store.Dirs().Insert(&h)
+	require.Nil(t, err)
+	require.Nil(t, store.Files().Move(file.ID, project.DataDir, holder.ID))
+
+	_, err = access.GetFile(user.APIKey, file.ID)
+	require.NotNil(t, err, "Expected a file in the trash to not be served")
+
+	_, _, err = s.Redeem(req, time.Now())
+	require.NotNil(t, err, "Expected a share link to a file in the trash to be rejected")
+
+	_, _, err = s.Create(user, file.ID, false, expires, 0)
+	require.NotNil(t, err, "Expected a file in the trash to not be shared")
+
+	d := NewDatasets(store.Datasets(), store.Files(), store.Dirs(), access)
+	_, err = d.Create(user, DatasetRequest{ProjectID: project.ID, Title: "ds", FileIDs: []string{file.ID}})
+	require.NotNil(t, err, "Expected a file in the trash to not be put in a dataset")
+	_, err = d.Create(user, DatasetRequest{ProjectID: project.ID, Title: "ds", DirectoryIDs: []string{holder.ID}})
+	require.NotNil(t, err, "Expected a directory in the trash to not be put in a dataset")
+}
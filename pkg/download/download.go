@@ -0,0 +1,329 @@
+// Package download fetches files over HTTP using parallel ranged requests.
+// Data is written to a temporary file next to the destination. Progress is
+// recorded as chunks complete so that an interrupted download can be resumed.
+// Once all the data has arrived the checksum is verified and the temporary
+// file is renamed to the destination.
+package download
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultChunkSize is the size of each ranged request.
+	DefaultChunkSize int64 = 8 * 1024 * 1024
+
+	// defaultRetries is the number of times a chunk is retried.
+	defaultRetries = 3
+
+	// partialSuffix is added to the destination path for the temporary file.
+	partialSuffix = ".mcdownload"
+
+	// stateSuffix is added to the temporary file path for the progress file.
+	stateSuffix = ".state"
+)
+
+var (
+	// ErrChecksumMismatch is returned when the downloaded data doesn't match
+	// the expected checksum.
+	ErrChecksumMismatch = errors.New("downloaded file doesn't match checksum")
+
+	// ErrFileChanged is returned when the file on the server no longer matches
+	// the file being downloaded.
+	ErrFileChanged = errors.New("file changed on server during download")
+)
+
+// A Request describes a file to download.
+type Request struct {
+	URL      string // URL to download from
+	Path     string // Destination path
+	Size     int64  // Expected size, 0 if unknown
+	Checksum string // Expected MD5 checksum (hex), empty to skip verification
+	Parallel int    // Number of simultaneous ranged requests
+}
+
+// state is the progress of a download. It is saved next to the temporary file.
+type state struct {
+	Checksum  string `json:"checksum"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	Done      []bool `json:"done"`
+}
+
+// A Downloader downloads files.
+type Downloader struct {
+	client    *http.Client
+	ChunkSize int64
+	Retries   int
+}
+
+// New creates a new Downloader that makes requests using client.
+func New(client *http.Client) *Downloader {
+	return &Downloader{
+		client:    client,
+		ChunkSize: DefaultChunkSize,
+		Retries:   defaultRetries,
+	}
+}
+
+// Download downloads a file. The destination is only replaced once the
+// complete file has been downloaded and verified. If a previous download
+// of the same file was interrupted, only the missing chunks are fetched.
+func (d *Downloader) Download(req Request) error {
+	tmpPath := req.Path + partialSuffix
+	if req.Size <= 0 {
+		if err := d.fetchWhole(req, tmpPath); err != nil {
+			return err
+		}
+		return finish(req, tmpPath)
+	}
+
+	st := d.loadState(req, tmpPath)
+	out, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return err
+	}
+
+	if err := out.Truncate(req.Size); err != nil {
+		out.Close()
+		return err
+	}
+
+	err = d.fetchChunks(req, out, st, tmpPath+stateSuffix)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return finish(req, tmpPath)
+}
+
+// loadState loads the progress of an earlier download. The earlier progress is
+// only used if it was for the same file, otherwise a new download is started.
+func (d *Downloader) loadState(req Request, tmpPath string) *state {
+	chunks := int((req.Size + d.ChunkSize - 1) / d.ChunkSize)
+	fresh := &state{
+		Checksum:  req.Checksum,
+		Size:      req.Size,
+		ChunkSize: d.ChunkSize,
+		Done:      make([]bool, chunks),
+	}
+
+	data, err := ioutil.ReadFile(tmpPath + stateSuffix)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fresh
+	}
+
+	var saved state
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Checksum != req.Checksum ||
+		saved.Size != req.Size || saved.ChunkSize != d.ChunkSize || len(saved.Done) != chunks {
+		os.Remove(tmpPath)
+		return fresh
+	}
+
+	return &saved
+}
+
+// fetchChunks downloads all the chunks that haven't been downloaded yet
+// using req.Parallel workers. Progress is saved after each chunk.
+func (d *Downloader) fetchChunks(req Request, out *os.File, st *state, statePath string) error {
+	var (
+		mutex    sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		chunks   = make(chan int, len(st.Done))
+	)
+
+	for i, done := range st.Done {
+		if !done {
+			chunks <- i
+		}
+	}
+	close(chunks)
+
+	workers := req.Parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				mutex.Lock()
+				failed := firstErr != nil
+				mutex.Unlock()
+				if failed {
+					return
+				}
+
+				err := d.fetchChunkWithRetry(req, out, st, chunk)
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil {
+					st.Done[chunk] = true
+					saveState(st, statePath)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// fetchChunkWithRetry fetches a chunk, retrying on failure. A change to the
+// file on the server is not retried.
+func (d *Downloader) fetchChunkWithRetry(req Request, out *os.File, st *state, chunk int) error {
+	var err error
+	for attempt := 0; attempt <= d.Retries; attempt++ {
+		if err = d.fetchChunk(req, out, st, chunk); err == nil || err == ErrFileChanged {
+			return err
+		}
+	}
+	return err
+}
+
+// fetchChunk downloads a single chunk and writes it to its offset in out.
+func (d *Downloader) fetchChunk(req Request, out *os.File, st *state, chunk int) error {
+	start := int64(chunk) * st.ChunkSize
+	end := start + st.ChunkSize - 1
+	if end >= st.Size {
+		end = st.Size - 1
+	}
+
+	httpReq, err := http.NewRequest("GET", req.URL, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if req.Checksum != "" {
+		httpReq.Header.Set("If-Range", `"`+req.Checksum+`"`)
+	}
+
+	resp, err := d.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Expected response.
+	case resp.StatusCode == http.StatusOK && req.Checksum != "":
+		// The If-Range check failed, so the server sent a different file.
+		return ErrFileChanged
+	case resp.StatusCode == http.StatusOK && start == 0 && resp.ContentLength == st.Size:
+		// The server ignored the range and sent the whole file. Its start is
+		// the first chunk, the rest is left unread.
+	case resp.StatusCode == http.StatusOK:
+		return ErrFileChanged
+	default:
+		return fmt.Errorf("download of %s failed: %s", req.URL, resp.Status)
+	}
+
+	_, err = io.CopyN(&offsetWriter{w: out, offset: start}, resp.Body, end-start+1)
+	return err
+}
+
+// offsetWriter writes to w sequentially from offset, so that chunks can be
+// copied into place without holding a whole chunk in memory.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+// Write writes p at the current offset and moves the offset past it.
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
+
+// fetchWhole downloads a file of unknown size with a single request.
+func (d *Downloader) fetchWhole(req Request, tmpPath string) error {
+	resp, err := d.client.Get(req.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of %s failed: %s", req.URL, resp.Status)
+	}
+
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// saveState writes the progress of a download. A failure to save only means
+// that more data will be fetched if the download is resumed.
+func saveState(st *state, statePath string) {
+	if data, err := json.Marshal(st); err == nil {
+		ioutil.WriteFile(statePath, data, 0660)
+	}
+}
+
+// finish verifies the checksum of the downloaded file and moves it to its
+// destination. A file that fails verification is removed so that the next
+// attempt starts over.
+func finish(req Request, tmpPath string) error {
+	statePath := tmpPath + stateSuffix
+	if req.Checksum != "" {
+		checksum, err := fileMD5(tmpPath)
+		if err != nil {
+			return err
+		}
+
+		if checksum != req.Checksum {
+			os.Remove(tmpPath)
+			os.Remove(statePath)
+			return ErrChecksumMismatch
+		}
+	}
+
+	if err := os.Rename(tmpPath, req.Path); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+	return nil
+}
+
+// fileMD5 computes the hex encoded MD5 checksum of a file.
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,166 @@
+package download
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fileServer serves content with ETag and Range support and records the
+// ranges requested. Requests for a range listed in fail are rejected. With
+// noRanges set the whole content is sent whatever range was asked for.
+type fileServer struct {
+	content  []byte
+	mutex    sync.Mutex
+	ranges   []string
+	fail     map[string]bool
+	noRanges bool
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rangeHeader := req.Header.Get("Range")
+	s.mutex.Lock()
+	s.ranges = append(s.ranges, rangeHeader)
+	fail := s.fail[rangeHeader]
+	s.mutex.Unlock()
+
+	if fail {
+		http.Error(w, "failed", http.StatusInternalServerError)
+		return
+	}
+
+	if s.noRanges {
+		req.Header.Del("Range")
+	}
+
+	sum := md5.Sum(s.content)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	http.ServeContent(w, req, "file", time.Time{}, bytes.NewReader(s.content))
+}
+
+func checksum(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("Downloader", func() {
+	var (
+		dir     string
+		dest    string
+		content []byte
+		fs      *fileServer
+		server  *httptest.Server
+		d       *Downloader
+	)
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "download")
+		dest = filepath.Join(dir, "file.txt")
+		content = []byte(strings.Repeat("0123456789", 10))
+		fs = &fileServer{content: content, fail: make(map[string]bool)}
+		server = httptest.NewServer(fs)
+		d = New(http.DefaultClient)
+		d.ChunkSize = 16
+		d.Retries = 0
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Should download a file in parallel ranges", func() {
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Checksum: checksum(content), Parallel: 3}
+		Expect(d.Download(req)).To(BeNil())
+		data, _ := ioutil.ReadFile(dest)
+		Expect(data).To(Equal(content))
+		Expect(fs.ranges).To(HaveLen(7))
+		Expect(dest + partialSuffix).NotTo(BeAnExistingFile())
+		Expect(dest + partialSuffix + stateSuffix).NotTo(BeAnExistingFile())
+	})
+
+	It("Should download a file of unknown size with a single request", func() {
+		req := Request{URL: server.URL, Path: dest, Checksum: checksum(content), Parallel: 3}
+		Expect(d.Download(req)).To(BeNil())
+		data, _ := ioutil.ReadFile(dest)
+		Expect(data).To(Equal(content))
+		Expect(fs.ranges).To(Equal([]string{""}))
+	})
+
+	It("Should not create the destination when a chunk fails", func() {
+		fs.fail["bytes=32-47"] = true
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Checksum: checksum(content), Parallel: 1}
+		Expect(d.Download(req)).NotTo(BeNil())
+		Expect(dest).NotTo(BeAnExistingFile())
+		Expect(dest + partialSuffix).To(BeAnExistingFile())
+	})
+
+	It("Should resume an interrupted download fetching only the missing chunks", func() {
+		fs.fail["bytes=32-47"] = true
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Checksum: checksum(content), Parallel: 1}
+		Expect(d.Download(req)).NotTo(BeNil())
+
+		fs.fail = make(map[string]bool)
+		fs.ranges = nil
+		Expect(d.Download(req)).To(BeNil())
+		data, _ := ioutil.ReadFile(dest)
+		Expect(data).To(Equal(content))
+		Expect(fs.ranges).NotTo(ContainElement("bytes=0-15"))
+		Expect(fs.ranges).NotTo(ContainElement("bytes=16-31"))
+		Expect(fs.ranges).To(ContainElement("bytes=32-47"))
+	})
+
+	It("Should restart when the earlier download was for a different file", func() {
+		fs.fail["bytes=32-47"] = true
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Checksum: "abc", Parallel: 1}
+		Expect(d.Download(req)).NotTo(BeNil())
+
+		fs.fail = make(map[string]bool)
+		fs.ranges = nil
+		req.Checksum = checksum(content)
+		Expect(d.Download(req)).To(BeNil())
+		Expect(fs.ranges).To(ContainElement("bytes=0-15"))
+	})
+
+	It("Should stop when the file on the server no longer matches the checksum", func() {
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Parallel: 2}
+		req.Checksum = checksum([]byte("something else"))
+		Expect(d.Download(req)).To(Equal(ErrFileChanged))
+		Expect(dest).NotTo(BeAnExistingFile())
+	})
+
+	It("Should stop when a file of the same size replaces the one being downloaded", func() {
+		changed := []byte(strings.Repeat("9876543210", 10))
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Parallel: 1}
+		req.Checksum = checksum(changed)
+		Expect(d.Download(req)).To(Equal(ErrFileChanged))
+		Expect(fs.ranges).To(HaveLen(1))
+		Expect(dest).NotTo(BeAnExistingFile())
+	})
+
+	It("Should accept the whole file as the first chunk from a server that ignores ranges", func() {
+		fs.noRanges = true
+		d.ChunkSize = int64(len(content))
+		req := Request{URL: server.URL, Path: dest, Size: int64(len(content)), Parallel: 1}
+		Expect(d.Download(req)).To(BeNil())
+		Expect(ioutil.ReadFile(dest)).To(Equal(content))
+	})
+
+	It("Should remove the download when the checksum doesn't match", func() {
+		req := Request{URL: server.URL, Path: dest, Checksum: checksum([]byte("something else"))}
+		Expect(d.Download(req)).To(Equal(ErrChecksumMismatch))
+		Expect(dest).NotTo(BeAnExistingFile())
+		Expect(dest + partialSuffix).NotTo(BeAnExistingFile())
+	})
+})
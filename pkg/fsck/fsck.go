@@ -0,0 +1,425 @@
+// Package fsck checks that the file entries in the database agree with each
+// other and with the blobs stored under MCDIR. Problems are reported by
+// category, and the ones that can be fixed without losing data are repaired
+// on request.
+package fsck
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// A Category is a kind of problem.
+type Category string
+
+// The problems fsck looks for.
+const (
+	// A file that isn't in any project. Repaired by adding it to the
+	// project of its directory.
+	FileWithoutProject Category = "file-without-project"
+
+	// A file that isn't in any directory.
+	FileWithoutDirectory Category = "file-without-directory"
+
+	// A project to file entry for a file that doesn't exist. Repaired by
+	// deleting the entry.
+	DanglingProjectFile Category = "dangling-project-file"
+
+	// A directory to file entry for a file that doesn't exist. Repaired by
+	// deleting the entry.
+	DanglingDirectoryFile Category = "dangling-directory-file"
+
+	// A duplicate file whose UsesID points at a file that doesn't exist.
+	DanglingUsesID Category = "dangling-usesid"
+
+	// An uploaded file whose blob isn't under any MCDIR root.
+	MissingBlob Category = "missing-blob"
+
+	// A blob under MCDIR that no file entry uses. Repaired by moving it to
+	// the lost+found directory of its MCDIR root.
+	OrphanBlob Category = "orphan-blob"
+
+	// More than one version of a file in a directory is marked current.
+	// Repaired by keeping the newest version current.
+	MultipleCurrent Category = "multiple-current"
+)
+
+// LostAndFound is the directory in each MCDIR root that orphan blobs are
+// moved to.
+const LostAndFound = "lost+found"
+
+// A Finding is a single problem.
+type Finding struct {
+	Category Category
+	ID       string // The file, join entry or blob path the problem is about.
+	Detail   string
+	Repaired bool
+	Err      error // Set when a repair failed.
+}
+
+// A Report lists the problems found.
+type Report struct {
+	Files    int
+	Blobs    int
+	Findings []Finding
+}
+
+// ByCategory groups the findings by category.
+func (r *Report) ByCategory() map[Category][]Finding {
+	categories := make(map[Category][]Finding)
+	for _, finding := range r.Findings {
+		categories[finding.Category] = append(categories[finding.Category], finding)
+	}
+	return categories
+}
+
+// Categories returns the categories that have findings, sorted by name.
+func (r *Report) Categories() []Category {
+	var categories []Category
+	for category := range r.ByCategory() {
+		categories = append(categories, category)
+	}
+	sort.Sort(byName(categories))
+	return categories
+}
+
+// byName sorts categories by name.
+type byName []Category
+
+func (c byName) Len() int           { return len(c) }
+func (c byName) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byName) Less(i, j int) bool { return c[i] < c[j] }
+
+// A Checker checks a store and the MCDIR roots its blobs are in.
+type Checker struct {
+	store  dai.Store
+	roots  []string
+	repair bool
+	report *Report
+
+	files        map[string]schema.File
+	projectFiles []schema.Project2DataFile
+	dirFiles     []schema.DataDir2DataFile
+}
+
+// New creates a Checker. When repair is true the problems that can be fixed
+// without losing data are repaired.
+func New(store dai.Store, roots []string, repair bool) *Checker {
+	return &Checker{
+		store:  store,
+		roots:  roots,
+		repair: repair,
+	}
+}
+
+// Run checks the store and MCDIR roots. An error is returned if the check
+// couldn't be done, problems that are found are in the report.
+func (c *Checker) Run() (*Report, error) {
+	c.report = &Report{}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	c.checkJoinEntries()
+	c.checkFiles()
+	c.checkCurrent()
+	if err := c.checkBlobs(); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+// load reads the file entries and join tables.
+func (c *Checker) load() error {
+	inventory := c.store.Inventory()
+	files, err := inventory.Files()
+	if err != nil {
+		return err
+	}
+
+	c.files = make(map[string]schema.File, len(files))
+	for _, file := range files {
+		c.files[file.ID] = file
+	}
+	c.report.Files = len(files)
+
+	if c.projectFiles, err = inventory.ProjectFiles(); err != nil {
+		return err
+	}
+
+	c.dirFiles, err = inventory.DirFiles()
+	return err
+}
+
+// checkJoinEntries finds join entries for files that don't exist.
+func (c *Checker) checkJoinEntries() {
+	inventory := c.store.Inventory()
+	for _, entry := range c.projectFiles {
+		if _, found := c.files[entry.DataFileID]; !found {
+			detail := fmt.Sprintf("project %s has missing file %s", entry.ProjectID, entry.DataFileID)
+			c.add(DanglingProjectFile, entry.ID, detail, func() error {
+				return inventory.DeleteProjectFile(entry.ID)
+			})
+		}
+	}
+
+	for _, entry := range c.dirFiles {
+		if _, found := c.files[entry.DataFileID]; !found {
+			detail := fmt.Sprintf("directory %s has missing file %s", entry.DataDirID, entry.DataFileID)
+			c.add(DanglingDirectoryFile, entry.ID, detail, func() error {
+				return inventory.DeleteDirFile(entry.ID)
+			})
+		}
+	}
+}
+
+// checkFiles finds files that aren't in a project or directory, duplicates
+// that point at missing files, and uploaded files whose blob is missing.
+func (c *Checker) checkFiles() {
+	inProject := make(map[string]bool)
+	for _, entry := range c.projectFiles {
+		inProject[entry.DataFileID] = true
+	}
+
+	fileDirs := make(map[string]string)
+	for _, entry := range c.dirFiles {
+		fileDirs[entry.DataFileID] = entry.DataDirID
+	}
+
+	for _, file := range c.sortedFiles() {
+		file := file
+		dirID, inDir := fileDirs[file.ID]
+		if !inDir {
+			c.add(FileWithoutDirectory, file.ID, file.Name, nil)
+		}
+
+		if !inProject[file.ID] {
+			c.add(FileWithoutProject, file.ID, file.Name, c.addToDirProject(file.ID, dirID))
+		}
+
+		if file.UsesID != "" {
+			if _, found := c.files[file.UsesID]; !found {
+				c.add(DanglingUsesID, file.ID, fmt.Sprintf("%s uses missing file %s", file.Name, file.UsesID), nil)
+			}
+			continue
+		}
+
+		// Files without a checksum haven't finished uploading.
+		if file.Checksum != "" && c.blobPath(file.ID) == "" {
+			c.add(MissingBlob, file.ID, file.Name, nil)
+		}
+	}
+}
+
+// addToDirProject returns the repair that adds a file to the project of its
+// directory. It returns nil when the directory isn't known.
+func (c *Checker) addToDirProject(fileID, dirID string) func() error {
+	if dirID == "" {
+		return nil
+	}
+
+	return func() error {
+		dir, err := c.store.Dirs().ByID(dirID)
+		if err != nil {
+			return err
+		}
+		return c.store.Inventory().AddProjectFile(dir.Project, fileID)
+	}
+}
+
+// checkCurrent finds directories where more than one version of a file is
+// marked current.
+func (c *Checker) checkCurrent() {
+	type dirFile struct {
+		dirID string
+		name  string
+	}
+
+	current := make(map[dirFile][]schema.File)
+	var order []dirFile
+	for _, entry := range c.dirFiles {
+		file, found := c.files[entry.DataFileID]
+		if !found || !file.Current {
+			continue
+		}
+		key := dirFile{dirID: entry.DataDirID, name: file.Name}
+		if len(current[key]) == 0 {
+			order = append(order, key)
+		}
+		current[key] = append(current[key], file)
+	}
+
+	for _, key := range order {
+		versions := current[key]
+		if len(versions) < 2 {
+			continue
+		}
+
+		newest := versions[0]
+		for _, file := range versions[1:] {
+			if file.Birthtime.After(newest.Birthtime) {
+				newest = file
+			}
+		}
+
+		for _, file := range versions {
+			if file.ID == newest.ID {
+				continue
+			}
+			fileID := file.ID
+			detail := fmt.Sprintf("%s in directory %s, %s is newer", file.Name, key.dirID, newest.ID)
+			c.add(MultipleCurrent, fileID, detail, func() error {
+				fields := map[string]interface{}{schema.FileFields.Current(): false}
+				return c.store.Files().UpdateFields(fileID, fields)
+			})
+		}
+	}
+}
+
+// checkBlobs finds blobs under the MCDIR roots that no file entry uses.
+// Blobs are stored as root/xx/yy/id. The file entries were loaded before the
+// walk, so a blob without one is looked up again in case its upload finished
+// in between.
+func (c *Checker) checkBlobs() error {
+	for _, root := range c.roots {
+		blobs, err := blobsIn(root)
+		if err != nil {
+			return err
+		}
+
+		c.report.Blobs += len(blobs)
+		for _, blob := range blobs {
+			blob := blob
+			id := filepath.Base(blob)
+			if _, found := c.files[id]; found {
+				continue
+			}
+
+			switch _, err := c.store.Files().ByID(id); {
+			case err == nil:
+				// Created since the file entries were loaded.
+			case !app.Is(err, app.ErrNotFound):
+				return err
+			default:
+				c.add(OrphanBlob, blob, "", func() error {
+					return moveToLostAndFound(root, blob)
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// blobsIn returns the paths of the blobs in an MCDIR root.
+func blobsIn(root string) ([]string, error) {
+	var blobs []string
+	level1, err := twoCharDirs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir1 := range level1 {
+		level2, err := twoCharDirs(dir1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dir2 := range level2 {
+			entries, err := ioutil.ReadDir(dir2)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				// Directories, such as .conversion, hold files derived from the blobs.
+				if entry.Mode().IsRegular() {
+					blobs = append(blobs, filepath.Join(dir2, entry.Name()))
+				}
+			}
+		}
+	}
+	return blobs, nil
+}
+
+// twoCharDirs returns the subdirectories of dir with two character names,
+// which are the directories blobs are spread across.
+func twoCharDirs(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && len(entry.Name()) == 2 {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// moveToLostAndFound moves a blob to the lost+found directory of its root.
+func moveToLostAndFound(root, blob string) error {
+	dir := filepath.Join(root, LostAndFound)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.Rename(blob, filepath.Join(dir, filepath.Base(blob)))
+}
+
+// blobPath returns the path to the blob for id, or the empty string if
+// it isn't under any root.
+func (c *Checker) blobPath(id string) string {
+	for _, root := range c.roots {
+		dir := app.MCDir.FileDirFromPath(root, id)
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, id)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// sortedFiles returns the files sorted by id, so reports are stable.
+func (c *Checker) sortedFiles() []schema.File {
+	ids := make([]string, 0, len(c.files))
+	for id := range c.files {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	files := make([]schema.File, 0, len(ids))
+	for _, id := range ids {
+		files = append(files, c.files[id])
+	}
+	return files
+}
+
+// add adds a finding to the report. When repairing, and the finding has a
+// repair, the repair is run and its result recorded.
+func (c *Checker) add(category Category, id, detail string, repair func() error) {
+	finding := Finding{
+		Category: category,
+		ID:       id,
+		Detail:   detail,
+	}
+
+	if c.repair && repair != nil {
+		if err := repair(); err != nil {
+			app.Log.Error("fsck repair failed", "category", category, "id", id, "error", err)
+			finding.Err = err
+		} else {
+			finding.Repaired = true
+		}
+	}
+	c.report.Findings = append(c.report.Findings, finding)
+}
@@ -0,0 +1,159 @@
+package fsck
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker", func() {
+	var (
+		store   *dai.MemStore
+		project *schema.Project
+		root    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "fsck")
+		Expect(err).To(BeNil())
+
+		store = dai.NewMemStore()
+		p := schema.NewProject("proj", "test@mc.org")
+		project, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	writeBlob := func(id string) string {
+		dir := app.MCDir.FileDirFromPath(root, id)
+		Expect(os.MkdirAll(dir, 0700)).To(BeNil())
+		path := filepath.Join(dir, id)
+		Expect(ioutil.WriteFile(path, []byte("data"), 0600)).To(BeNil())
+		return path
+	}
+
+	insertFile := func(name, checksum string) *schema.File {
+		f := schema.NewFile(name, "test@mc.org")
+		f.Checksum = checksum
+		file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+		Expect(err).To(BeNil())
+		return file
+	}
+
+	run := func(repair bool) *Report {
+		report, err := New(store, []string{root}, repair).Run()
+		Expect(err).To(BeNil())
+		return report
+	}
+
+	It("Should find nothing wrong with a consistent store", func() {
+		f := insertFile("a.txt", "abc")
+		writeBlob(f.ID)
+
+		report := run(false)
+		Expect(report.Findings).To(BeEmpty())
+		Expect(report.Files).To(Equal(1))
+		Expect(report.Blobs).To(Equal(1))
+	})
+
+	It("Should find missing blobs and skip unfinished uploads", func() {
+		f := insertFile("a.txt", "abc")
+		insertFile("b.txt", "")
+
+		report := run(false)
+		Expect(report.Findings).To(HaveLen(1))
+		Expect(report.Findings[0].Category).To(Equal(MissingBlob))
+		Expect(report.Findings[0].ID).To(Equal(f.ID))
+	})
+
+	It("Should move orphan blobs to lost+found when repairing", func() {
+		orphan := writeBlob("d7b1c4a0-5e2f-4c1a-9e3b-2f6a8c0d1e4f")
+
+		report := run(false)
+		Expect(report.ByCategory()[OrphanBlob]).To(HaveLen(1))
+		Expect(report.Findings[0].Repaired).To(BeFalse())
+		_, err := os.Stat(orphan)
+		Expect(err).To(BeNil())
+
+		report = run(true)
+		Expect(report.Findings[0].Repaired).To(BeTrue())
+		_, err = os.Stat(filepath.Join(root, LostAndFound, filepath.Base(orphan)))
+		Expect(err).To(BeNil())
+
+		Expect(run(false).Findings).To(BeEmpty())
+	})
+
+	It("Should leave a blob alone when its file is created during the check", func() {
+		checker := New(store, []string{root}, true)
+		checker.report = &Report{}
+		Expect(checker.load()).To(BeNil())
+
+		f := insertFile("a.txt", "abc")
+		blob := writeBlob(f.ID)
+		Expect(checker.checkBlobs()).To(BeNil())
+		Expect(checker.report.Findings).To(BeEmpty())
+		_, err := os.Stat(blob)
+		Expect(err).To(BeNil())
+	})
+
+	It("Should delete join entries for missing files and re-add files to their project", func() {
+		f := insertFile("a.txt", "")
+		inventory := store.Inventory()
+		projectFiles, _ := inventory.ProjectFiles()
+		Expect(inventory.DeleteProjectFile(projectFiles[0].ID)).To(BeNil())
+		Expect(inventory.AddProjectFile(project.ID, "missing")).To(BeNil())
+
+		report := run(true)
+		Expect(report.Categories()).To(Equal([]Category{DanglingProjectFile, FileWithoutProject}))
+		for _, finding := range report.Findings {
+			Expect(finding.Repaired).To(BeTrue())
+		}
+
+		p, err := store.Files().GetProject(f.ID)
+		Expect(err).To(BeNil())
+		Expect(p.ID).To(Equal(project.ID))
+		Expect(run(false).Findings).To(BeEmpty())
+	})
+
+	It("Should report duplicates that use missing files", func() {
+		f := schema.NewFile("dup.txt", "test@mc.org")
+		f.UsesID = "missing"
+		_, err := store.Files().Insert(&f, project.DataDir, project.ID)
+		Expect(err).To(BeNil())
+
+		report := run(true)
+		Expect(report.Findings).To(HaveLen(1))
+		Expect(report.Findings[0].Category).To(Equal(DanglingUsesID))
+		Expect(report.Findings[0].Repaired).To(BeFalse())
+	})
+
+	It("Should keep only the newest version current", func() {
+		older := insertFile("a.txt", "")
+		f := schema.NewFile("a.txt", "test@mc.org")
+		f.Birthtime = older.Birthtime.Add(time.Minute)
+		f.Parent = older.ID
+		newer, err := store.Files().Insert(&f, project.DataDir, project.ID)
+		Expect(err).To(BeNil())
+
+		report := run(true)
+		Expect(report.Findings).To(HaveLen(1))
+		Expect(report.Findings[0].Category).To(Equal(MultipleCurrent))
+		Expect(report.Findings[0].ID).To(Equal(older.ID))
+
+		current, err := store.Files().ByPath("a.txt", project.DataDir)
+		Expect(err).To(BeNil())
+		Expect(current.ID).To(Equal(newer.ID))
+		Expect(run(false).Findings).To(BeEmpty())
+	})
+})
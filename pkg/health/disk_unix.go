@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package health
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errFreeSpaceUnsupported is returned on platforms where the free space
+// can't be determined.
+var errFreeSpaceUnsupported = errors.New("free space check not supported")
+
+// freeSpace returns the number of bytes available to the server in the
+// file system containing path.
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,14 @@
+package health
+
+import (
+	"errors"
+)
+
+// errFreeSpaceUnsupported is returned on platforms where the free space
+// can't be determined.
+var errFreeSpaceUnsupported = errors.New("free space check not supported")
+
+// freeSpace isn't implemented on Windows.
+func freeSpace(path string) (uint64, error) {
+	return 0, errFreeSpaceUnsupported
+}
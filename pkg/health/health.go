@@ -0,0 +1,192 @@
+// Package health runs checks against the services and resources a server
+// depends on, and reports the results as JSON for load balancers and monitors.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// A Check tests a single dependency. A failed critical check means the server
+// isn't ready to handle requests. Other failed checks are reported as warnings.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func() error
+}
+
+// A Result is the outcome of running a check.
+type Result struct {
+	Status     string  `json:"status"`
+	Critical   bool    `json:"critical"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// A Report contains the results of all the checks. Status is StatusFail if any
+// critical check failed, StatusWarn if any other check failed, otherwise StatusOK.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// A Checker runs a set of checks.
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker creates a new Checker. A check that takes longer than timeout fails.
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// Run runs all the checks in parallel and returns a report of their results.
+func (c *Checker) Run() Report {
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(c.checks)),
+	}
+
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.run(check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[check.Name] = result
+			switch {
+			case result.Status == StatusFail:
+				report.Status = StatusFail
+			case result.Status == StatusWarn && report.Status == StatusOK:
+				report.Status = StatusWarn
+			}
+		}(check)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// run runs a single check, failing it if it doesn't finish within the timeout.
+// A check that is timed out is left to finish in the background.
+func (c *Checker) run(check Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- runRecovered(check.Run)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(c.timeout):
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := Result{
+		Status:     StatusOK,
+		Critical:   check.Critical,
+		DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusWarn
+		if check.Critical {
+			result.Status = StatusFail
+		}
+	}
+	return result
+}
+
+// runRecovered runs fn, turning a panic into an error.
+func runRecovered(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// Handler serves the report from running the checks. It responds with
+// http.StatusServiceUnavailable when a critical check fails.
+func (c *Checker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := c.Run()
+		status := http.StatusOK
+		if report.Status == StatusFail {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// LiveHandler reports that the server is running. It doesn't check any
+// dependencies, so it only fails if the server can't handle requests at all.
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Result{}})
+	})
+}
+
+// writeJSON writes a report with the given status code.
+func writeJSON(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// DirWritable returns a check that a file can be created in dir and that dir
+// has at least minFree bytes available.
+func DirWritable(dir string, minFree uint64) func() error {
+	return func() error {
+		f, err := ioutil.TempFile(dir, ".healthcheck")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		f.Close()
+		os.Remove(name)
+		if err != nil {
+			return err
+		}
+
+		free, err := freeSpace(dir)
+		switch {
+		case err == errFreeSpaceUnsupported:
+			return nil
+		case err != nil:
+			return err
+		case free < minFree:
+			return fmt.Errorf("%d MB free, need at least %d MB", free/(1024*1024), minFree/(1024*1024))
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	ok := func() error { return nil }
+	failed := func() error { return errors.New("down") }
+
+	Describe("Checker Tests", func() {
+		It("Should report ok when all checks pass", func() {
+			report := NewChecker(time.Second, Check{Name: "a", Critical: true, Run: ok}, Check{Name: "b", Run: ok}).Run()
+			Expect(report.Status).To(Equal(StatusOK))
+			Expect(report.Checks).To(HaveLen(2))
+			Expect(report.Checks["a"].Status).To(Equal(StatusOK))
+		})
+
+		It("Should warn when a non critical check fails", func() {
+			report := NewChecker(time.Second, Check{Name: "a", Critical: true, Run: ok}, Check{Name: "b", Run: failed}).Run()
+			Expect(report.Status).To(Equal(StatusWarn))
+			Expect(report.Checks["b"].Status).To(Equal(StatusWarn))
+			Expect(report.Checks["b"].Error).To(Equal("down"))
+		})
+
+		It("Should fail when a critical check fails", func() {
+			report := NewChecker(time.Second, Check{Name: "a", Critical: true, Run: failed}, Check{Name: "b", Run: failed}).Run()
+			Expect(report.Status).To(Equal(StatusFail))
+			Expect(report.Checks["a"].Status).To(Equal(StatusFail))
+		})
+
+		It("Should fail a check that times out", func() {
+			slow := func() error {
+				time.Sleep(time.Second)
+				return nil
+			}
+			report := NewChecker(10*time.Millisecond, Check{Name: "slow", Critical: true, Run: slow}).Run()
+			Expect(report.Status).To(Equal(StatusFail))
+			Expect(report.Checks["slow"].Error).To(ContainSubstring("timed out"))
+		})
+
+		It("Should fail a check that panics", func() {
+			report := NewChecker(time.Second, Check{Name: "p", Critical: true, Run: func() error { panic("MCDIR not set") }}).Run()
+			Expect(report.Status).To(Equal(StatusFail))
+			Expect(report.Checks["p"].Error).To(ContainSubstring("MCDIR not set"))
+		})
+	})
+
+	Describe("Handler Tests", func() {
+		serve := func(h http.Handler) (*httptest.ResponseRecorder, Report) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/readyz", nil)
+			h.ServeHTTP(w, req)
+			var report Report
+			Expect(json.Unmarshal(w.Body.Bytes(), &report)).To(BeNil())
+			return w, report
+		}
+
+		It("Should return 200 when only a non critical check fails", func() {
+			w, report := serve(NewChecker(time.Second, Check{Name: "b", Run: failed}).Handler())
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(report.Status).To(Equal(StatusWarn))
+		})
+
+		It("Should return 503 when a critical check fails", func() {
+			w, report := serve(NewChecker(time.Second, Check{Name: "a", Critical: true, Run: failed}).Handler())
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(report.Checks["a"].Critical).To(BeTrue())
+		})
+
+		It("Should always report a live server as ok", func() {
+			w, report := serve(LiveHandler())
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(report.Status).To(Equal(StatusOK))
+		})
+	})
+
+	Describe("DirWritable Tests", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "health")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("Should pass for a writable directory and leave nothing behind", func() {
+			Expect(DirWritable(dir, 0)()).To(BeNil())
+			entries, _ := ioutil.ReadDir(dir)
+			Expect(entries).To(HaveLen(0))
+		})
+
+		It("Should fail for a directory that doesn't exist", func() {
+			Expect(DirWritable(filepath.Join(dir, "missing"), 0)()).NotTo(BeNil())
+		})
+
+		It("Should fail when there isn't enough free space", func() {
+			err := DirWritable(dir, 1<<62)()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("MB free"))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+)
+
+// DataCite schema version 4 (the kernel 4 metadata schema).
+const (
+	DataCiteNamespace = "http://datacite.org/schema/kernel-4"
+	DataCiteSchema    = "http://schema.datacite.org/meta/kernel-4/metadata.xsd"
+)
+
+// xsiNamespace is the XML Schema instance namespace used for schemaLocation.
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// DataCite is a DataCite kernel 4 resource. Optional lists are pointers so
+// that they are left out, rather than written empty, when there is nothing in
+// them.
+type DataCite struct {
+	XMLName              xml.Name                      `xml:"http://datacite.org/schema/kernel-4 resource"`
+	XSI                  string                        `xml:"xmlns:xsi,attr"`
+	SchemaLocation       string                        `xml:"xsi:schemaLocation,attr"`
+	Identifier           dataCiteIdentifier            `xml:"identifier"`
+	Creators             []dataCiteCreator             `xml:"creators>creator"`
+	Titles               []string                      `xml:"titles>title"`
+	Publisher            string                        `xml:"publisher"`
+	PublicationYear      int                           `xml:"publicationYear"`
+	ResourceType         dataCiteResourceType          `xml:"resourceType"`
+	Subjects             *dataCiteSubjects             `xml:"subjects"`
+	Dates                []dataCiteDate                `xml:"dates>date"`
+	AlternateIdentifiers *dataCiteAlternateIdentifiers `xml:"alternateIdentifiers"`
+	Sizes                *dataCiteSizes                `xml:"sizes"`
+	Formats              *dataCiteFormats              `xml:"formats"`
+	RightsList           *dataCiteRightsList           `xml:"rightsList"`
+	Descriptions         *dataCiteDescriptions         `xml:"descriptions"`
+}
+
+type dataCiteSubjects struct {
+	Subjects []string `xml:"subject"`
+}
+
+type dataCiteAlternateIdentifiers struct {
+	AlternateIdentifiers []dataCiteAltIdentifier `xml:"alternateIdentifier"`
+}
+
+type dataCiteSizes struct {
+	Sizes []string `xml:"size"`
+}
+
+type dataCiteFormats struct {
+	Formats []string `xml:"format"`
+}
+
+type dataCiteRightsList struct {
+	Rights []dataCiteRights `xml:"rights"`
+}
+
+type dataCiteDescriptions struct {
+	Descriptions []dataCiteDescription `xml:"description"`
+}
+
+type dataCiteIdentifier struct {
+	Type  string `xml:"identifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataCiteCreator struct {
+	Name string `xml:"creatorName"`
+}
+
+type dataCiteResourceType struct {
+	General string `xml:"resourceTypeGeneral,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type dataCiteDate struct {
+	Type  string `xml:"dateType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataCiteAltIdentifier struct {
+	Type  string `xml:"alternateIdentifierType,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataCiteRights struct {
+	Value string `xml:",chardata"`
+}
+
+type dataCiteDescription struct {
+	Type  string `xml:"descriptionType,attr"`
+	Value string `xml:",chardata"`
+}
+
+// NewDataCite describes a record as a DataCite resource. DataCite metadata
+// identifies a resource by its DOI, so the dataset must have one, and a
+// publisher is required. DataCite has no place for per file details, so the
+// files are summarized as sizes and formats.
+func NewDataCite(r Record) (*DataCite, error) {
+	ds := r.Dataset
+	switch {
+	case ds.DOI == "":
+		return nil, app.Errorf(app.ErrInvalid, "dataset %s has no DOI", ds.ID)
+	case r.Publisher == "":
+		return nil, app.Errorf(app.ErrInvalid, "dataset %s has no publisher", ds.ID)
+	}
+
+	d := &DataCite{
+		XSI:             xsiNamespace,
+		SchemaLocation:  DataCiteNamespace + " " + DataCiteSchema,
+		Identifier:      dataCiteIdentifier{Type: "DOI", Value: ds.DOI},
+		Titles:          []string{ds.Title},
+		Publisher:       r.Publisher,
+		PublicationYear: r.issued().UTC().Year(),
+		ResourceType:    dataCiteResourceType{General: "Dataset", Value: "Dataset"},
+		Dates: []dataCiteDate{
+			{Type: "Created", Value: day(ds.Birthtime)},
+			{Type: "Updated", Value: day(ds.MTime)},
+		},
+	}
+
+	for _, creator := range r.creators() {
+		d.Creators = append(d.Creators, dataCiteCreator{Name: creator})
+	}
+
+	if len(ds.Keywords) != 0 {
+		d.Subjects = &dataCiteSubjects{Subjects: ds.Keywords}
+	}
+
+	if ds.Published {
+		d.Dates = append(d.Dates, dataCiteDate{Type: "Issued", Value: day(r.issued())})
+	}
+
+	if r.URL != "" {
+		d.AlternateIdentifiers = &dataCiteAlternateIdentifiers{
+			AlternateIdentifiers: []dataCiteAltIdentifier{{Type: "URL", Value: r.URL}},
+		}
+	}
+
+	if len(r.Files) != 0 {
+		d.Sizes = &dataCiteSizes{Sizes: []string{
+			fmt.Sprintf("%d files", len(r.Files)),
+			fmt.Sprintf("%d bytes", r.size()),
+		}}
+	}
+
+	if mimes := r.mediaTypes(); len(mimes) != 0 {
+		d.Formats = &dataCiteFormats{Formats: mimes}
+	}
+
+	if ds.License != "" {
+		d.RightsList = &dataCiteRightsList{Rights: []dataCiteRights{{Value: ds.License}}}
+	}
+
+	if description := strings.TrimSpace(ds.Description); description != "" {
+		d.Descriptions = &dataCiteDescriptions{
+			Descriptions: []dataCiteDescription{{Type: "Abstract", Value: description}},
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// The OAI-PMH Dublin Core format (oai_dc) and the Dublin Core elements it uses.
+const (
+	OAIDCNamespace = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	OAIDCSchema    = "http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	DCNamespace    = "http://purl.org/dc/elements/1.1/"
+)
+
+// OAIDC is a record in the oai_dc format. The element names carry their
+// prefixes, which are declared on the root element.
+type OAIDC struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	OAIDC          string   `xml:"xmlns:oai_dc,attr"`
+	DC             string   `xml:"xmlns:dc,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Titles         []string `xml:"dc:title"`
+	Creators       []string `xml:"dc:creator"`
+	Subjects       []string `xml:"dc:subject"`
+	Descriptions   []string `xml:"dc:description"`
+	Publishers     []string `xml:"dc:publisher"`
+	Dates          []string `xml:"dc:date"`
+	Types          []string `xml:"dc:type"`
+	Formats        []string `xml:"dc:format"`
+	Identifiers    []string `xml:"dc:identifier"`
+	Rights         []string `xml:"dc:rights"`
+}
+
+// NewOAIDC describes a record in the oai_dc format.
+func NewOAIDC(r Record) *OAIDC {
+	ds := r.Dataset
+	dc := &OAIDC{
+		OAIDC:          OAIDCNamespace,
+		DC:             DCNamespace,
+		XSI:            xsiNamespace,
+		SchemaLocation: OAIDCNamespace + " " + OAIDCSchema,
+		Titles:         []string{ds.Title},
+		Creators:       r.creators(),
+		Subjects:       ds.Keywords,
+		Dates:          []string{day(r.issued())},
+		Types:          []string{"Dataset"},
+		Formats:        r.mediaTypes(),
+	}
+
+	if description := strings.TrimSpace(ds.Description); description != "" {
+		dc.Descriptions = []string{description}
+	}
+
+	if r.Publisher != "" {
+		dc.Publishers = []string{r.Publisher}
+	}
+
+	if ds.DOI != "" {
+		dc.Identifiers = append(dc.Identifiers, doiURL(ds.DOI))
+	}
+	if r.URL != "" {
+		dc.Identifiers = append(dc.Identifiers, r.URL)
+	}
+
+	if ds.License != "" {
+		dc.Rights = []string{ds.License}
+	}
+
+	return dc
+}
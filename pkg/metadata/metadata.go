@@ -0,0 +1,129 @@
+// Package metadata describes datasets in the metadata formats used by
+// repositories and indexers: DataCite for DOI registration, Dublin Core for
+// OAI-PMH harvesting and schema.org JSON-LD for search engines.
+package metadata
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Format is a format a dataset can be exported in.
+type Format string
+
+const (
+	// DataCiteXML is DataCite kernel 4 XML. The dataset must have a DOI.
+	DataCiteXML Format = "datacite"
+
+	// SchemaOrgJSONLD is a schema.org Dataset as JSON-LD.
+	SchemaOrgJSONLD Format = "schemaorg"
+)
+
+// ParseFormat converts a format name to a Format. An empty name is
+// SchemaOrgJSONLD, since every dataset can be described in it.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "schemaorg", "jsonld":
+		return SchemaOrgJSONLD, nil
+	case "datacite":
+		return DataCiteXML, nil
+	default:
+		return "", app.Errorf(app.ErrInvalid, "unknown metadata format %s", name)
+	}
+}
+
+// ContentType returns the mime type for the format.
+func (f Format) ContentType() string {
+	if f == DataCiteXML {
+		return "application/xml"
+	}
+	return "application/ld+json"
+}
+
+// A Record is a dataset and what is needed to describe it.
+type Record struct {
+	Dataset   *schema.Dataset
+	Files     []schema.File
+	URL       string // The dataset's landing page.
+	Publisher string // Who makes the dataset available.
+
+	// FileURL returns where a file in the dataset can be downloaded. It is
+	// optional.
+	FileURL func(fileID string) string
+}
+
+// creators returns the dataset authors, or its owner when it lists none.
+func (r Record) creators() []string {
+	if len(r.Dataset.Authors) != 0 {
+		return r.Dataset.Authors
+	}
+	return []string{r.Dataset.Owner}
+}
+
+// issued returns when the dataset was published, or created if it hasn't
+// been published.
+func (r Record) issued() time.Time {
+	if r.Dataset.Published && !r.Dataset.PublishedDate.IsZero() {
+		return r.Dataset.PublishedDate
+	}
+	return r.Dataset.Birthtime
+}
+
+// size returns the total size of the files.
+func (r Record) size() int64 {
+	var size int64
+	for _, file := range r.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// mediaTypes returns the distinct mime types of the files, sorted.
+func (r Record) mediaTypes() []string {
+	seen := make(map[string]bool)
+	var mimes []string
+	for _, file := range r.Files {
+		if mime := file.MediaType.Mime; mime != "" && !seen[mime] {
+			seen[mime] = true
+			mimes = append(mimes, mime)
+		}
+	}
+	sort.Strings(mimes)
+	return mimes
+}
+
+// doiURL returns the resolver URL for a DOI.
+func doiURL(doi string) string {
+	return "https://doi.org/" + doi
+}
+
+// day formats a time as an ISO 8601 date.
+func day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// Write writes a record in the format. Nothing is written if the record can't
+// be described in the format.
+func Write(w io.Writer, format Format, r Record) error {
+	if format == SchemaOrgJSONLD {
+		return json.NewEncoder(w).Encode(NewSchemaOrg(r))
+	}
+
+	d, err := NewDataCite(r)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(d)
+}
@@ -0,0 +1,431 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	dayPattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	yearPattern = regexp.MustCompile(`^\d{4}$`)
+	doiPattern  = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+)
+
+// kernel4 holds the DataCite kernel 4 resource as written, keeping every
+// element so unknown ones can be found.
+type kernel4 struct {
+	XMLName    xml.Name
+	Identifier struct {
+		Type  string `xml:"identifierType,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"identifier"`
+	Creators        []string `xml:"creators>creator>creatorName"`
+	Titles          []string `xml:"titles>title"`
+	Publisher       string   `xml:"publisher"`
+	PublicationYear string   `xml:"publicationYear"`
+	ResourceType    struct {
+		General string `xml:"resourceTypeGeneral,attr"`
+	} `xml:"resourceType"`
+	Dates []struct {
+		Type  string `xml:"dateType,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"dates>date"`
+	AlternateIdentifiers []struct {
+		Type  string `xml:"alternateIdentifierType,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"alternateIdentifiers>alternateIdentifier"`
+	Descriptions []struct {
+		Type  string `xml:"descriptionType,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"descriptions>description"`
+	Elements []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// kernel4Elements are the children a kernel 4 resource may have.
+var kernel4Elements = set("identifier", "creators", "titles", "publisher", "publicationYear",
+	"resourceType", "subjects", "contributors", "dates", "language", "alternateIdentifiers",
+	"relatedIdentifiers", "sizes", "formats", "version", "rightsList", "descriptions",
+	"geoLocations", "fundingReferences")
+
+// Controlled vocabularies from the kernel 4 schema that the export uses.
+var (
+	resourceTypes    = set("Audiovisual", "Collection", "DataPaper", "Dataset", "Event", "Image", "InteractiveResource", "Model", "PhysicalObject", "Service", "Software", "Sound", "Text", "Workflow", "Other")
+	dateTypes        = set("Accepted", "Available", "Copyrighted", "Collected", "Created", "Issued", "Submitted", "Updated", "Valid")
+	descriptionTypes = set("Abstract", "Methods", "SeriesInformation", "TableOfContents", "TechnicalInfo", "Other")
+)
+
+func set(values ...string) map[string]bool {
+	s := make(map[string]bool)
+	for _, value := range values {
+		s[value] = true
+	}
+	return s
+}
+
+// kernel4XSD is the published DataCite kernel 4 schema, with the files it
+// includes. It isn't distributed with mcstore; scripts/fetch_datacite_xsd.sh
+// downloads it.
+var kernel4XSD = filepath.Join("testdata", "kernel-4", "metadata.xsd")
+
+// canValidateDataCiteXSD returns true if xmllint and the kernel 4 XSD are
+// available.
+func canValidateDataCiteXSD() bool {
+	if _, err := exec.LookPath("xmllint"); err != nil {
+		return false
+	}
+	_, err := os.Stat(kernel4XSD)
+	return err == nil
+}
+
+// validateDataCiteXSD validates a document against the kernel 4 XSD with
+// xmllint. It returns the problems found.
+func validateDataCiteXSD(doc []byte) []string {
+	f, err := ioutil.TempFile("", "datacite")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer os.Remove(f.Name())
+	f.Write(doc)
+	f.Close()
+
+	out, err := exec.Command("xmllint", "--noout", "--nonet", "--schema", kernel4XSD, f.Name()).CombinedOutput()
+	if err != nil {
+		return []string{string(out)}
+	}
+	return nil
+}
+
+// validateDataCite checks a document against a hand written subset of the
+// DataCite kernel 4 schema: the mandatory properties, their formats and the
+// controlled vocabularies. It returns the problems found. It runs everywhere,
+// unlike validateDataCiteXSD, but only catches what it knows to look for.
+func validateDataCite(doc []byte) []string {
+	var (
+		r        kernel4
+		problems []string
+	)
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if err := xml.Unmarshal(doc, &r); err != nil {
+		return []string{err.Error()}
+	}
+
+	if r.XMLName.Space != DataCiteNamespace || r.XMLName.Local != "resource" {
+		fail("root element is %s %s", r.XMLName.Space, r.XMLName.Local)
+	}
+	for _, e := range r.Elements {
+		if !kernel4Elements[e.XMLName.Local] || e.XMLName.Space != DataCiteNamespace {
+			fail("unknown element %s", e.XMLName.Local)
+		}
+	}
+	if r.Identifier.Type != "DOI" || !doiPattern.MatchString(r.Identifier.Value) {
+		fail("bad identifier %s %s", r.Identifier.Type, r.Identifier.Value)
+	}
+	if len(r.Creators) == 0 {
+		fail("no creators")
+	}
+	for _, creator := range r.Creators {
+		if creator == "" {
+			fail("empty creatorName")
+		}
+	}
+	if len(r.Titles) == 0 || r.Titles[0] == "" {
+		fail("no title")
+	}
+	if r.Publisher == "" {
+		fail("no publisher")
+	}
+	if !yearPattern.MatchString(r.PublicationYear) {
+		fail("bad publicationYear %s", r.PublicationYear)
+	}
+	if !resourceTypes[r.ResourceType.General] {
+		fail("bad resourceTypeGeneral %s", r.ResourceType.General)
+	}
+	for _, date := range r.Dates {
+		if !dateTypes[date.Type] || !dayPattern.MatchString(date.Value) {
+			fail("bad date %s %s", date.Type, date.Value)
+		}
+	}
+	for _, id := range r.AlternateIdentifiers {
+		if id.Type == "" || id.Value == "" {
+			fail("bad alternateIdentifier %s %s", id.Type, id.Value)
+		}
+	}
+	for _, description := range r.Descriptions {
+		if !descriptionTypes[description.Type] {
+			fail("bad descriptionType %s", description.Type)
+		}
+	}
+	return problems
+}
+
+// schemaOrgProperties are the schema.org Dataset properties the export may
+// use, along with the JSON-LD keywords.
+var schemaOrgProperties = set("@context", "@type", "@id", "name", "description", "url", "identifier",
+	"creator", "keywords", "license", "dateCreated", "dateModified", "datePublished", "publisher",
+	"distribution")
+
+// validateSchemaOrg checks that a document uses the schema.org Dataset type,
+// only known properties, and has the properties search engines require. It
+// returns the problems found. The values aren't checked against the schema.org
+// vocabulary, which has no schema to validate against.
+func validateSchemaOrg(doc []byte) []string {
+	var (
+		d        map[string]interface{}
+		problems []string
+	)
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return []string{err.Error()}
+	}
+
+	for property := range d {
+		if !schemaOrgProperties[property] {
+			fail("unknown property %s", property)
+		}
+	}
+	if d["@context"] != SchemaOrgContext {
+		fail("bad @context %v", d["@context"])
+	}
+	if d["@type"] != "Dataset" {
+		fail("bad @type %v", d["@type"])
+	}
+	if name, _ := d["name"].(string); name == "" {
+		fail("no name")
+	}
+	for _, property := range []string{"dateCreated", "dateModified", "datePublished"} {
+		if value, ok := d[property]; ok {
+			if s, _ := value.(string); !dayPattern.MatchString(s) {
+				fail("bad %s %v", property, value)
+			}
+		}
+	}
+
+	creators, _ := d["creator"].([]interface{})
+	if len(creators) == 0 {
+		fail("no creator")
+	}
+	for _, c := range creators {
+		creator, _ := c.(map[string]interface{})
+		if creator["@type"] != "Person" || creator["name"] == "" {
+			fail("bad creator %v", c)
+		}
+	}
+
+	if p, ok := d["publisher"]; ok {
+		publisher, _ := p.(map[string]interface{})
+		if publisher["@type"] != "Organization" || publisher["name"] == "" {
+			fail("bad publisher %v", p)
+		}
+	}
+
+	distribution, _ := d["distribution"].([]interface{})
+	for _, dd := range distribution {
+		download, _ := dd.(map[string]interface{})
+		if download["@type"] != "DataDownload" {
+			fail("bad distribution @type %v", download["@type"])
+		}
+		for _, property := range []string{"name", "contentUrl", "contentSize", "encodingFormat"} {
+			if s, _ := download[property].(string); s == "" {
+				fail("distribution has no %s", property)
+			}
+		}
+		if id, ok := download["identifier"]; ok {
+			value, _ := id.(map[string]interface{})
+			if value["@type"] != "PropertyValue" || value["propertyID"] == "" || value["value"] == "" {
+				fail("bad distribution identifier %v", id)
+			}
+		}
+	}
+	return problems
+}
+
+var _ = Describe("Metadata", func() {
+	var r Record
+
+	write := func(format Format) ([]byte, error) {
+		var buf bytes.Buffer
+		err := Write(&buf, format, r)
+		return buf.Bytes(), err
+	}
+
+	BeforeEach(func() {
+		ds := schema.NewDataset("Tensile tests", "alice@mc.org", "project1")
+		ds.ID = "ds1"
+		ds.Description = "Tensile tests of AZ31 magnesium sheet at room temperature."
+		ds.Authors = []string{"Alice Smith", "Bob Jones"}
+		ds.License = "CC-BY-4.0"
+		ds.Keywords = []string{"magnesium", "tensile"}
+		ds.DOI = "10.12345/mc.ds1"
+		ds.Birthtime = time.Date(2016, time.January, 2, 10, 0, 0, 0, time.UTC)
+		ds.MTime = ds.Birthtime
+		ds.Published = true
+		ds.PublishedDate = time.Date(2017, time.March, 4, 10, 0, 0, 0, time.UTC)
+
+		f1 := schema.NewFile("strain.csv", "alice@mc.org")
+		f1.ID = "f1"
+		f1.Size = 100
+		f1.Checksum = "0cc175b9c0f1b6a831c399e269772661"
+		f1.MediaType.Mime = "text/csv"
+		f2 := schema.NewFile("sample.tif", "alice@mc.org")
+		f2.ID = "f2"
+		f2.Size = 2000
+		f2.Checksum = "92eb5ffee6ae2fec3ad71c777531578f"
+		f2.MediaType.Mime = "image/tiff"
+
+		r = Record{
+			Dataset:   &ds,
+			Files:     []schema.File{f1, f2},
+			URL:       "http://mc.org/public/datasets/ds1",
+			Publisher: "Materials Commons",
+			FileURL:   func(id string) string { return "http://mc.org/datafiles/static/" + id },
+		}
+	})
+
+	Describe("ParseFormat", func() {
+		It("Should default to schema.org", func() {
+			format, err := ParseFormat("")
+			Expect(err).To(BeNil())
+			Expect(format).To(Equal(SchemaOrgJSONLD))
+			Expect(format.ContentType()).To(Equal("application/ld+json"))
+
+			format, err = ParseFormat("DataCite")
+			Expect(err).To(BeNil())
+			Expect(format).To(Equal(DataCiteXML))
+
+			_, err = ParseFormat("marc21")
+			Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+		})
+	})
+
+	Describe("DataCite", func() {
+		It("Should write a valid kernel 4 resource", func() {
+			doc, err := write(DataCiteXML)
+			Expect(err).To(BeNil())
+			Expect(validateDataCite(doc)).To(BeEmpty())
+
+			var res kernel4
+			Expect(xml.Unmarshal(doc, &res)).To(BeNil())
+			Expect(res.Identifier.Value).To(Equal("10.12345/mc.ds1"))
+			Expect(res.Creators).To(Equal([]string{"Alice Smith", "Bob Jones"}))
+			Expect(res.PublicationYear).To(Equal("2017"))
+			Expect(string(doc)).To(ContainSubstring("<size>2 files</size>"))
+			Expect(string(doc)).To(ContainSubstring("<size>2100 bytes</size>"))
+			Expect(string(doc)).To(ContainSubstring("<format>image/tiff</format>"))
+		})
+
+		It("Should write a valid resource with only the required properties", func() {
+			r.Dataset.Description = ""
+			r.Dataset.Authors = nil
+			r.Dataset.Keywords = nil
+			r.Dataset.License = ""
+			r.Dataset.Published = false
+			r.Files = nil
+			r.URL = ""
+
+			doc, err := write(DataCiteXML)
+			Expect(err).To(BeNil())
+			Expect(validateDataCite(doc)).To(BeEmpty())
+			Expect(string(doc)).NotTo(ContainSubstring("descriptions"))
+			Expect(string(doc)).To(ContainSubstring("<creatorName>alice@mc.org</creatorName>"))
+		})
+
+		// The XSD check is pending, rather than failing, where xmllint or the
+		// XSD from scripts/fetch_datacite_xsd.sh is missing.
+		if canValidateDataCiteXSD() {
+			It("Should pass the published kernel 4 XSD", func() {
+				full, err := write(DataCiteXML)
+				Expect(err).To(BeNil())
+				Expect(validateDataCiteXSD(full)).To(BeEmpty())
+
+				r.Dataset.Description = ""
+				r.Dataset.Authors = nil
+				r.Dataset.Keywords = nil
+				r.Dataset.License = ""
+				r.Files = nil
+				minimal, err := write(DataCiteXML)
+				Expect(err).To(BeNil())
+				Expect(validateDataCiteXSD(minimal)).To(BeEmpty())
+			})
+		} else {
+			PIt("Should pass the published kernel 4 XSD")
+		}
+
+		It("Should require a DOI and a publisher", func() {
+			r.Dataset.DOI = ""
+			doc, err := write(DataCiteXML)
+			Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+			Expect(doc).To(BeEmpty())
+
+			r.Dataset.DOI = "10.12345/mc.ds1"
+			r.Publisher = ""
+			_, err = write(DataCiteXML)
+			Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+		})
+	})
+
+	Describe("schema.org", func() {
+		It("Should write a valid Dataset with file distributions", func() {
+			doc, err := write(SchemaOrgJSONLD)
+			Expect(err).To(BeNil())
+			Expect(validateSchemaOrg(doc)).To(BeEmpty())
+
+			var d SchemaOrgDataset
+			Expect(json.Unmarshal(doc, &d)).To(BeNil())
+			Expect(d.ID).To(Equal("https://doi.org/10.12345/mc.ds1"))
+			Expect(d.DatePublished).To(Equal("2017-03-04"))
+			Expect(d.Distribution).To(HaveLen(2))
+			Expect(d.Distribution[0].ContentURL).To(Equal("http://mc.org/datafiles/static/f1"))
+			Expect(d.Distribution[0].ContentSize).To(Equal("100 B"))
+			Expect(d.Distribution[0].EncodingFormat).To(Equal("text/csv"))
+			Expect(d.Distribution[0].Identifier.Value).To(Equal("0cc175b9c0f1b6a831c399e269772661"))
+		})
+
+		It("Should describe an unpublished dataset without a DOI", func() {
+			r.Dataset.DOI = ""
+			r.Dataset.Published = false
+
+			doc, err := write(SchemaOrgJSONLD)
+			Expect(err).To(BeNil())
+			Expect(validateSchemaOrg(doc)).To(BeEmpty())
+
+			var d SchemaOrgDataset
+			Expect(json.Unmarshal(doc, &d)).To(BeNil())
+			Expect(d.ID).To(Equal("http://mc.org/public/datasets/ds1"))
+			Expect(d.Identifier).To(BeEmpty())
+			Expect(d.DatePublished).To(Equal(""))
+		})
+	})
+
+	Describe("Validation", func() {
+		It("Should find problems in invalid documents", func() {
+			doc := []byte(`<resource xmlns="` + DataCiteNamespace + `"><identifier identifierType="URL">x</identifier><color/></resource>`)
+			Expect(validateDataCite(doc)).To(ContainElement("unknown element color"))
+			Expect(validateDataCite(doc)).To(ContainElement("no publisher"))
+
+			doc = []byte(`{"@context": "http://schema.org", "@type": "Dataset", "author": "x"}`)
+			Expect(validateSchemaOrg(doc)).To(ContainElement("unknown property author"))
+			Expect(validateSchemaOrg(doc)).To(ContainElement("no name"))
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaOrgContext is the JSON-LD context for schema.org terms.
+const SchemaOrgContext = "http://schema.org"
+
+// SchemaOrgDataset is a schema.org Dataset, written as JSON-LD. Search
+// engines read it to index datasets.
+type SchemaOrgDataset struct {
+	Context       string                  `json:"@context"`
+	Type          string                  `json:"@type"`
+	ID            string                  `json:"@id,omitempty"`
+	Name          string                  `json:"name"`
+	Description   string                  `json:"description,omitempty"`
+	URL           string                  `json:"url,omitempty"`
+	Identifier    []string                `json:"identifier,omitempty"`
+	Creator       []schemaOrgPerson       `json:"creator"`
+	Keywords      []string                `json:"keywords,omitempty"`
+	License       string                  `json:"license,omitempty"`
+	DateCreated   string                  `json:"dateCreated"`
+	DateModified  string                  `json:"dateModified"`
+	DatePublished string                  `json:"datePublished,omitempty"`
+	Publisher     *schemaOrgOrganization  `json:"publisher,omitempty"`
+	Distribution  []schemaOrgDataDownload `json:"distribution,omitempty"`
+}
+
+type schemaOrgPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type schemaOrgOrganization struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// schemaOrgDataDownload is a file in the dataset. The checksum is given as an
+// identifier, since schema.org has no property for an MD5 checksum.
+type schemaOrgDataDownload struct {
+	Type           string                  `json:"@type"`
+	Name           string                  `json:"name"`
+	ContentURL     string                  `json:"contentUrl,omitempty"`
+	ContentSize    string                  `json:"contentSize"`
+	EncodingFormat string                  `json:"encodingFormat,omitempty"`
+	Identifier     *schemaOrgPropertyValue `json:"identifier,omitempty"`
+}
+
+type schemaOrgPropertyValue struct {
+	Type       string `json:"@type"`
+	PropertyID string `json:"propertyID"`
+	Value      string `json:"value"`
+}
+
+// NewSchemaOrg describes a record as a schema.org Dataset. The dataset's @id
+// is its DOI when it has one, otherwise its landing page.
+func NewSchemaOrg(r Record) *SchemaOrgDataset {
+	ds := r.Dataset
+	d := &SchemaOrgDataset{
+		Context:      SchemaOrgContext,
+		Type:         "Dataset",
+		ID:           r.URL,
+		Name:         ds.Title,
+		Description:  strings.TrimSpace(ds.Description),
+		URL:          r.URL,
+		Keywords:     ds.Keywords,
+		License:      ds.License,
+		DateCreated:  day(ds.Birthtime),
+		DateModified: day(ds.MTime),
+	}
+
+	if ds.DOI != "" {
+		d.ID = doiURL(ds.DOI)
+		d.Identifier = []string{doiURL(ds.DOI)}
+	}
+
+	for _, creator := range r.creators() {
+		d.Creator = append(d.Creator, schemaOrgPerson{Type: "Person", Name: creator})
+	}
+
+	if ds.Published {
+		d.DatePublished = day(r.issued())
+	}
+
+	if r.Publisher != "" {
+		d.Publisher = &schemaOrgOrganization{Type: "Organization", Name: r.Publisher}
+	}
+
+	for _, file := range r.Files {
+		download := schemaOrgDataDownload{
+			Type:           "DataDownload",
+			Name:           file.Name,
+			ContentSize:    fmt.Sprintf("%d B", file.Size),
+			EncodingFormat: file.MediaType.Mime,
+		}
+		if r.FileURL != nil {
+			download.ContentURL = r.FileURL(file.ID)
+		}
+		if file.Checksum != "" {
+			download.Identifier = &schemaOrgPropertyValue{
+				Type:       "PropertyValue",
+				PropertyID: "md5",
+				Value:      file.Checksum,
+			}
+		}
+		d.Distribution = append(d.Distribution, download)
+	}
+
+	return d
+}
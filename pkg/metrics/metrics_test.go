@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		registry *Registry
+	)
+
+	text := func() string {
+		var buf bytes.Buffer
+		Expect(registry.WriteText(&buf)).To(BeNil())
+		return buf.String()
+	}
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+	})
+
+	Describe("Counter Tests", func() {
+		It("Should count by label values", func() {
+			c := registry.NewCounter("requests_total", "Requests handled.", "method", "code")
+			c.Inc("GET", "200")
+			c.Inc("GET", "200")
+			c.Add(3, "POST", "500")
+			c.Add(-1, "POST", "500")
+			Expect(c.Value("GET", "200")).To(BeNumerically("==", 2))
+			Expect(text()).To(Equal("# HELP requests_total Requests handled.\n" +
+				"# TYPE requests_total counter\n" +
+				`requests_total{method="GET",code="200"} 2` + "\n" +
+				`requests_total{method="POST",code="500"} 3` + "\n"))
+		})
+
+		It("Should panic when given the wrong number of label values", func() {
+			c := registry.NewCounter("requests_total", "Requests handled.", "method")
+			Expect(func() { c.Inc() }).To(Panic())
+		})
+
+		It("Should escape label values", func() {
+			c := registry.NewCounter("paths_total", "Paths.", "path")
+			c.Inc("a\"b\\c\nd")
+			Expect(text()).To(ContainSubstring(`paths_total{path="a\"b\\c\nd"} 1`))
+		})
+	})
+
+	Describe("Gauge Tests", func() {
+		It("Should write gauges and gauge functions without labels", func() {
+			g := registry.NewGauge("temperature", "Temperature.")
+			g.Set(10)
+			g.Add(-2.5)
+			registry.NewGaugeFunc("active", "Active things.", func() float64 { return 4 })
+			Expect(text()).To(Equal("# HELP active Active things.\n# TYPE active gauge\nactive 4\n" +
+				"# HELP temperature Temperature.\n# TYPE temperature gauge\ntemperature 7.5\n"))
+		})
+	})
+
+	Describe("Histogram Tests", func() {
+		It("Should write cumulative buckets, sum and count", func() {
+			h := registry.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+			h.Observe(0.05, "/a")
+			h.Observe(0.5, "/a")
+			h.Observe(2, "/a")
+			Expect(text()).To(Equal("# HELP latency_seconds Latency.\n# TYPE latency_seconds histogram\n" +
+				`latency_seconds_bucket{route="/a",le="0.1"} 1` + "\n" +
+				`latency_seconds_bucket{route="/a",le="1"} 2` + "\n" +
+				`latency_seconds_bucket{route="/a",le="+Inf"} 3` + "\n" +
+				`latency_seconds_sum{route="/a"} 2.55` + "\n" +
+				`latency_seconds_count{route="/a"} 3` + "\n"))
+		})
+	})
+
+	It("Should not allow two metrics with the same name", func() {
+		registry.NewCounter("x", "X.")
+		Expect(func() { registry.NewGauge("x", "X.") }).To(Panic())
+	})
+
+	It("Should serve metrics over http", func() {
+		registry.NewCounter("x_total", "X.").Inc()
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		registry.Handler().ServeHTTP(rr, req)
+		Expect(rr.Header().Get("Content-Type")).To(ContainSubstring("text/plain"))
+		Expect(rr.Body.String()).To(ContainSubstring("x_total 1\n"))
+	})
+})
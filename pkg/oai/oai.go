@@ -0,0 +1,175 @@
+// Package oai implements an OAI-PMH 2.0 provider for published datasets, so
+// that institutional repositories and indexers can harvest their metadata.
+// Each published dataset is an item. Items are in a set for the project the
+// dataset came from, and are described in oai_dc and, for datasets with a
+// DOI, oai_datacite.
+package oai
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/metadata"
+)
+
+// OAI-PMH protocol constants.
+const (
+	Namespace       = "http://www.openarchives.org/OAI/2.0/"
+	Schema          = "http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd"
+	ProtocolVersion = "2.0"
+
+	// Granularity is the finest datestamp granularity the provider supports.
+	Granularity = "YYYY-MM-DDThh:mm:ssZ"
+)
+
+// Metadata prefixes the provider can disseminate.
+const (
+	OAIDC    = "oai_dc"
+	DataCite = "oai_datacite"
+)
+
+// Error codes defined by the protocol.
+const (
+	BadArgument             = "badArgument"
+	BadResumptionToken      = "badResumptionToken"
+	BadVerb                 = "badVerb"
+	CannotDisseminateFormat = "cannotDisseminateFormat"
+	IDDoesNotExist          = "idDoesNotExist"
+	NoRecordsMatch          = "noRecordsMatch"
+	NoMetadataFormats       = "noMetadataFormats"
+	NoSetHierarchy          = "noSetHierarchy"
+)
+
+// Datestamp formats for the two granularities.
+const (
+	secondsFormat = "2006-01-02T15:04:05Z"
+	dayFormat     = "2006-01-02"
+)
+
+// projectSet is the set that holds a set for each project.
+const projectSet = "project"
+
+// A Response is an OAI-PMH response. Only one of the verb elements, or
+// Errors, is set.
+type Response struct {
+	XMLName             xml.Name             `xml:"http://www.openarchives.org/OAI/2.0/ OAI-PMH"`
+	XSI                 string               `xml:"xmlns:xsi,attr"`
+	SchemaLocation      string               `xml:"xsi:schemaLocation,attr"`
+	ResponseDate        string               `xml:"responseDate"`
+	Request             Request              `xml:"request"`
+	Errors              []Error              `xml:"error"`
+	Identify            *Identify            `xml:"Identify"`
+	ListMetadataFormats *ListMetadataFormats `xml:"ListMetadataFormats"`
+	ListSets            *ListSets            `xml:"ListSets"`
+	GetRecord           *GetRecord           `xml:"GetRecord"`
+	ListIdentifiers     *ListIdentifiers     `xml:"ListIdentifiers"`
+	ListRecords         *ListRecords         `xml:"ListRecords"`
+}
+
+// Request echoes the request. The arguments are left out when the verb or
+// arguments were bad.
+type Request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+	BaseURL         string `xml:",chardata"`
+}
+
+// An Error is a protocol error.
+type Error struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+// Identify describes the repository.
+type Identify struct {
+	RepositoryName    string `xml:"repositoryName"`
+	BaseURL           string `xml:"baseURL"`
+	ProtocolVersion   string `xml:"protocolVersion"`
+	AdminEmail        string `xml:"adminEmail"`
+	EarliestDatestamp string `xml:"earliestDatestamp"`
+	DeletedRecord     string `xml:"deletedRecord"`
+	Granularity       string `xml:"granularity"`
+}
+
+// ListMetadataFormats lists metadata formats.
+type ListMetadataFormats struct {
+	Formats []MetadataFormat `xml:"metadataFormat"`
+}
+
+// A MetadataFormat is a format records can be disseminated in.
+type MetadataFormat struct {
+	Prefix    string `xml:"metadataPrefix"`
+	Schema    string `xml:"schema"`
+	Namespace string `xml:"metadataNamespace"`
+}
+
+// ListSets lists sets.
+type ListSets struct {
+	Sets []Set `xml:"set"`
+}
+
+// A Set is a group of items.
+type Set struct {
+	Spec string `xml:"setSpec"`
+	Name string `xml:"setName"`
+}
+
+// GetRecord holds a single record.
+type GetRecord struct {
+	Record Record `xml:"record"`
+}
+
+// ListIdentifiers holds a page of headers.
+type ListIdentifiers struct {
+	Headers         []Header         `xml:"header"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken"`
+}
+
+// ListRecords holds a page of records.
+type ListRecords struct {
+	Records         []Record         `xml:"record"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken"`
+}
+
+// A Record is an item's header and its metadata in one format.
+type Record struct {
+	Header   Header   `xml:"header"`
+	Metadata Metadata `xml:"metadata"`
+}
+
+// Metadata wraps the metadata of a record. Value is marshaled using its own
+// element name, such as oai_dc:dc.
+type Metadata struct {
+	Value interface{}
+}
+
+// A Header identifies an item.
+type Header struct {
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpecs   []string `xml:"setSpec"`
+}
+
+// A ResumptionToken continues an incomplete list. The last page of a list has
+// an empty token.
+type ResumptionToken struct {
+	CompleteListSize int    `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Token            string `xml:",chardata"`
+}
+
+// formats are the metadata formats the provider supports.
+var formats = []MetadataFormat{
+	{Prefix: OAIDC, Schema: metadata.OAIDCSchema, Namespace: metadata.OAIDCNamespace},
+	{Prefix: DataCite, Schema: metadata.DataCiteSchema, Namespace: metadata.DataCiteNamespace},
+}
+
+// datestamp formats a time with seconds granularity.
+func datestamp(t time.Time) string {
+	return t.UTC().Format(secondsFormat)
+}
@@ -0,0 +1,13 @@
+package oai
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestOai(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Oai Suite")
+}
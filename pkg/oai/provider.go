@@ -0,0 +1,563 @@
+package oai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/metadata"
+)
+
+// PageSize is the number of headers or records in each page of a list.
+var PageSize = 100
+
+// A Repository describes the repository a Provider answers for.
+type Repository struct {
+	Name       string
+	BaseURL    string
+	AdminEmail string
+
+	// Identifier is the namespace part of item identifiers, which have the
+	// form oai:IDENTIFIER:DATASET_ID. It is usually the repository's domain.
+	Identifier string
+
+	// Publisher is who publishes the datasets, for the metadata.
+	Publisher string
+
+	// DatasetURL returns the landing page of a dataset.
+	DatasetURL func(datasetID string) string
+}
+
+// A Provider answers OAI-PMH requests about the published datasets in a store.
+type Provider struct {
+	store dai.Store
+	repo  Repository
+}
+
+// NewProvider creates a Provider for the datasets in store.
+func NewProvider(store dai.Store, repo Repository) *Provider {
+	return &Provider{
+		store: store,
+		repo:  repo,
+	}
+}
+
+// verbs are the arguments each verb accepts, and whether they are required.
+// A resumption token is exclusive, it can't be given with any other argument.
+var verbs = map[string]map[string]bool{
+	"Identify":            {},
+	"ListMetadataFormats": {"identifier": false},
+	"ListSets":            {"resumptionToken": false},
+	"GetRecord":           {"identifier": true, "metadataPrefix": true},
+	"ListIdentifiers":     {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+	"ListRecords":         {"metadataPrefix": true, "from": false, "until": false, "set": false, "resumptionToken": false},
+}
+
+// Handle answers a request made with args, the query or form arguments.
+// Protocol errors are reported in the response. An error is only returned if
+// the store can't be read.
+func (p *Provider) Handle(args url.Values) (*Response, error) {
+	resp := &Response{
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: Namespace + " " + Schema,
+		ResponseDate:   datestamp(time.Now()),
+		Request:        Request{BaseURL: p.repo.BaseURL},
+	}
+
+	verb := args.Get("verb")
+	allowed, ok := verbs[verb]
+	if !ok || len(args["verb"]) != 1 {
+		return resp.fail(BadVerb, "Illegal or missing verb"), nil
+	}
+
+	if msg := checkArguments(args, allowed); msg != "" {
+		return resp.fail(BadArgument, msg), nil
+	}
+
+	resp.Request = Request{
+		Verb:            verb,
+		Identifier:      args.Get("identifier"),
+		MetadataPrefix:  args.Get("metadataPrefix"),
+		From:            args.Get("from"),
+		Until:           args.Get("until"),
+		Set:             args.Get("set"),
+		ResumptionToken: args.Get("resumptionToken"),
+		BaseURL:         p.repo.BaseURL,
+	}
+
+	switch verb {
+	case "Identify":
+		return p.identify(resp)
+	case "ListMetadataFormats":
+		return p.listMetadataFormats(resp, args.Get("identifier"))
+	case "ListSets":
+		return p.listSets(resp, args.Get("resumptionToken"))
+	case "GetRecord":
+		return p.getRecord(resp, args.Get("identifier"), args.Get("metadataPrefix"))
+	default:
+		return p.list(resp, verb, args)
+	}
+}
+
+// checkArguments returns a message describing what is wrong with the
+// arguments for a verb, or the empty string if nothing is.
+func checkArguments(args url.Values, allowed map[string]bool) string {
+	for name, values := range args {
+		if name == "verb" {
+			continue
+		}
+		if _, ok := allowed[name]; !ok {
+			return fmt.Sprintf("Illegal argument %s", name)
+		}
+		if len(values) != 1 {
+			return fmt.Sprintf("Repeated argument %s", name)
+		}
+	}
+
+	if args.Get("resumptionToken") != "" {
+		if len(args) != 2 {
+			return "resumptionToken is an exclusive argument"
+		}
+		return ""
+	}
+
+	for name, required := range allowed {
+		if required && args.Get(name) == "" {
+			return fmt.Sprintf("Missing argument %s", name)
+		}
+	}
+	return ""
+}
+
+// fail replaces the response's contents with an error.
+func (resp *Response) fail(code, msg string) *Response {
+	resp.Errors = []Error{{Code: code, Message: msg}}
+	return resp
+}
+
+// identify describes the repository.
+func (p *Provider) identify(resp *Response) (*Response, error) {
+	items, err := p.items()
+	if err != nil {
+		return nil, err
+	}
+
+	earliest := time.Now()
+	for _, item := range items {
+		if item.datestamp.Before(earliest) {
+			earliest = item.datestamp
+		}
+	}
+
+	resp.Identify = &Identify{
+		RepositoryName:    p.repo.Name,
+		BaseURL:           p.repo.BaseURL,
+		ProtocolVersion:   ProtocolVersion,
+		AdminEmail:        p.repo.AdminEmail,
+		EarliestDatestamp: datestamp(earliest),
+		DeletedRecord:     "no",
+		Granularity:       Granularity,
+	}
+	return resp, nil
+}
+
+// listMetadataFormats lists the formats for the repository, or for one item.
+func (p *Provider) listMetadataFormats(resp *Response, identifier string) (*Response, error) {
+	if identifier == "" {
+		resp.ListMetadataFormats = &ListMetadataFormats{Formats: formats}
+		return resp, nil
+	}
+
+	item, err := p.item(identifier)
+	switch {
+	case app.Is(err, app.ErrNotFound):
+		return resp.fail(IDDoesNotExist, "No item with identifier "+identifier), nil
+	case err != nil:
+		return nil, err
+	}
+
+	var itemFormats []MetadataFormat
+	for _, format := range formats {
+		if item.canDisseminate(format.Prefix) {
+			itemFormats = append(itemFormats, format)
+		}
+	}
+	resp.ListMetadataFormats = &ListMetadataFormats{Formats: itemFormats}
+	return resp, nil
+}
+
+// listSets lists the project sets that have items, under the set holding
+// them all. Sets aren't paged, so there are never resumption tokens.
+func (p *Provider) listSets(resp *Response, token string) (*Response, error) {
+	if token != "" {
+		return resp.fail(BadResumptionToken, "ListSets has no resumption tokens"), nil
+	}
+
+	items, err := p.items()
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []Set{{Spec: projectSet, Name: "Projects"}}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		projectID := item.dataset.ProjectID
+		if seen[projectID] {
+			continue
+		}
+		seen[projectID] = true
+
+		name := projectID
+		if project, err := p.store.Projects().ByID(projectID); err == nil {
+			name = project.Name
+		}
+		sets = append(sets, Set{Spec: projectSetSpec(projectID), Name: name})
+	}
+
+	resp.ListSets = &ListSets{Sets: sets}
+	return resp, nil
+}
+
+// getRecord returns a single record.
+func (p *Provider) getRecord(resp *Response, identifier, prefix string) (*Response, error) {
+	item, err := p.item(identifier)
+	switch {
+	case app.Is(err, app.ErrNotFound):
+		return resp.fail(IDDoesNotExist, "No item with identifier "+identifier), nil
+	case err != nil:
+		return nil, err
+	case !item.canDisseminate(prefix):
+		return resp.fail(CannotDisseminateFormat, "Item can't be disseminated as "+prefix), nil
+	}
+
+	record, err := p.record(item, prefix)
+	if err != nil {
+		return nil, err
+	}
+	resp.GetRecord = &GetRecord{Record: record}
+	return resp, nil
+}
+
+// list answers ListIdentifiers and ListRecords.
+func (p *Provider) list(resp *Response, verb string, args url.Values) (*Response, error) {
+	var (
+		q   query
+		msg string
+	)
+	if token := args.Get("resumptionToken"); token != "" {
+		if q, msg = parseToken(token, verb); msg != "" {
+			return resp.fail(BadResumptionToken, msg), nil
+		}
+	} else if q, msg = parseQuery(args); msg != "" {
+		return resp.fail(BadArgument, msg), nil
+	}
+
+	if !knownFormat(q.prefix) {
+		return resp.fail(CannotDisseminateFormat, "Unknown metadata format "+q.prefix), nil
+	}
+
+	items, err := p.items()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []item
+	for _, item := range items {
+		if q.matches(item) {
+			matches = append(matches, item)
+		}
+	}
+
+	// Items are sorted by id, so resuming after the last id returned doesn't
+	// skip or repeat items when datasets are published or unpublished
+	// between requests.
+	cursor := 0
+	for cursor < len(matches) && matches[cursor].dataset.ID <= q.after {
+		cursor++
+	}
+
+	switch {
+	case len(matches) == 0:
+		return resp.fail(NoRecordsMatch, "No records match the request"), nil
+	case cursor == len(matches):
+		return resp.fail(NoRecordsMatch, "No records are left after the resumption token"), nil
+	}
+
+	end := cursor + PageSize
+	if end > len(matches) {
+		end = len(matches)
+	}
+	page := matches[cursor:end]
+
+	var token *ResumptionToken
+	if cursor != 0 || end < len(matches) {
+		token = &ResumptionToken{CompleteListSize: len(matches), Cursor: cursor}
+		if end < len(matches) {
+			next := q
+			next.after = page[len(page)-1].dataset.ID
+			token.Token = next.token(verb)
+		}
+	}
+
+	if verb == "ListIdentifiers" {
+		headers := make([]Header, 0, len(page))
+		for _, item := range page {
+			headers = append(headers, p.header(item))
+		}
+		resp.ListIdentifiers = &ListIdentifiers{Headers: headers, ResumptionToken: token}
+		return resp, nil
+	}
+
+	records := make([]Record, 0, len(page))
+	for _, item := range page {
+		record, err := p.record(item, q.prefix)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	resp.ListRecords = &ListRecords{Records: records, ResumptionToken: token}
+	return resp, nil
+}
+
+// An item is a published dataset.
+type item struct {
+	dataset   schema.Dataset
+	datestamp time.Time
+}
+
+// newItem creates an item for a dataset. Its datestamp is when the dataset was
+// last changed or published, whichever is later.
+func newItem(dataset schema.Dataset) item {
+	stamp := dataset.MTime
+	if dataset.PublishedDate.After(stamp) {
+		stamp = dataset.PublishedDate
+	}
+	return item{dataset: dataset, datestamp: stamp.UTC().Truncate(time.Second)}
+}
+
+// canDisseminate returns true if the item can be described in the format.
+// DataCite metadata needs a DOI.
+func (i item) canDisseminate(prefix string) bool {
+	switch prefix {
+	case OAIDC:
+		return true
+	case DataCite:
+		return i.dataset.DOI != ""
+	default:
+		return false
+	}
+}
+
+// items returns all the items, ordered by dataset id.
+func (p *Provider) items() ([]item, error) {
+	datasets, err := p.store.Datasets().Published()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(datasets))
+	for _, dataset := range datasets {
+		items = append(items, newItem(dataset))
+	}
+	return items, nil
+}
+
+// item looks up an item by its identifier. Unpublished datasets aren't items.
+func (p *Provider) item(identifier string) (item, error) {
+	prefix := "oai:" + p.repo.Identifier + ":"
+	if !strings.HasPrefix(identifier, prefix) {
+		return item{}, app.ErrNotFound
+	}
+
+	dataset, err := p.store.Datasets().ByID(strings.TrimPrefix(identifier, prefix))
+	switch {
+	case err != nil:
+		return item{}, app.ErrNotFound
+	case !dataset.Published:
+		return item{}, app.ErrNotFound
+	default:
+		return newItem(*dataset), nil
+	}
+}
+
+// header returns the header for an item.
+func (p *Provider) header(item item) Header {
+	return Header{
+		Identifier: "oai:" + p.repo.Identifier + ":" + item.dataset.ID,
+		Datestamp:  datestamp(item.datestamp),
+		SetSpecs:   []string{projectSetSpec(item.dataset.ProjectID)},
+	}
+}
+
+// record describes an item in the format.
+func (p *Provider) record(item item, prefix string) (Record, error) {
+	files, err := p.store.Datasets().Files(item.dataset.ID)
+	if err != nil && !app.Is(err, app.ErrNotFound) {
+		return Record{}, err
+	}
+
+	r := metadata.Record{
+		Dataset:   &item.dataset,
+		Files:     files,
+		Publisher: p.repo.Publisher,
+	}
+	if p.repo.DatasetURL != nil {
+		r.URL = p.repo.DatasetURL(item.dataset.ID)
+	}
+
+	record := Record{Header: p.header(item)}
+	if prefix == DataCite {
+		d, err := metadata.NewDataCite(r)
+		if err != nil {
+			return Record{}, err
+		}
+		record.Metadata.Value = d
+	} else {
+		record.Metadata.Value = metadata.NewOAIDC(r)
+	}
+	return record, nil
+}
+
+// projectSetSpec returns the set for a project's datasets.
+func projectSetSpec(projectID string) string {
+	return projectSet + ":" + projectID
+}
+
+// knownFormat returns true if prefix is a supported metadata format.
+func knownFormat(prefix string) bool {
+	for _, format := range formats {
+		if format.Prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// A query selects the items in a list, and where the page starts.
+type query struct {
+	prefix string
+	from   string
+	until  string
+	set    string
+	after  string // id of the last item already returned
+
+	fromTime  time.Time
+	untilTime time.Time
+}
+
+// parseQuery reads the list arguments. It returns a message describing what is
+// wrong with them, or the empty string.
+func parseQuery(args url.Values) (query, string) {
+	q := query{
+		prefix: args.Get("metadataPrefix"),
+		from:   args.Get("from"),
+		until:  args.Get("until"),
+		set:    args.Get("set"),
+	}
+
+	var fromDay, untilDay bool
+	var ok bool
+	if q.from != "" {
+		if q.fromTime, fromDay, ok = parseDatestamp(q.from); !ok {
+			return q, "Bad from date " + q.from
+		}
+	}
+
+	if q.until != "" {
+		if q.untilTime, untilDay, ok = parseDatestamp(q.until); !ok {
+			return q, "Bad until date " + q.until
+		}
+		if untilDay {
+			// A day includes every time in it.
+			q.untilTime = q.untilTime.Add(24*time.Hour - time.Second)
+		}
+	}
+
+	switch {
+	case q.from != "" && q.until != "" && fromDay != untilDay:
+		return q, "from and until have different granularities"
+	case q.from != "" && q.until != "" && q.fromTime.After(q.untilTime):
+		return q, "from is after until"
+	}
+	return q, ""
+}
+
+// parseDatestamp parses a datestamp with day or seconds granularity.
+func parseDatestamp(s string) (t time.Time, isDay, ok bool) {
+	if t, err := time.Parse(secondsFormat, s); err == nil {
+		return t, false, true
+	}
+	if t, err := time.Parse(dayFormat, s); err == nil {
+		return t, true, true
+	}
+	return time.Time{}, false, false
+}
+
+// matches returns true if the item is selected by the query.
+func (q query) matches(item item) bool {
+	switch {
+	case !item.canDisseminate(q.prefix):
+		return false
+	case q.from != "" && item.datestamp.Before(q.fromTime):
+		return false
+	case q.until != "" && item.datestamp.After(q.untilTime):
+		return false
+	case q.set == "":
+		return true
+	default:
+		spec := projectSetSpec(item.dataset.ProjectID)
+		return spec == q.set || strings.HasPrefix(spec, q.set+":")
+	}
+}
+
+// token encodes the query as a resumption token for verb.
+func (q query) token(verb string) string {
+	values := url.Values{
+		"verb":           {verb},
+		"metadataPrefix": {q.prefix},
+		"after":          {q.after},
+	}
+	if q.from != "" {
+		values.Set("from", q.from)
+	}
+	if q.until != "" {
+		values.Set("until", q.until)
+	}
+	if q.set != "" {
+		values.Set("set", q.set)
+	}
+	return base64.URLEncoding.EncodeToString([]byte(values.Encode()))
+}
+
+// parseToken decodes a resumption token made by token for verb.
+func parseToken(token, verb string) (query, string) {
+	const bad = "Bad resumption token"
+
+	b, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return query{}, bad
+	}
+
+	values, err := url.ParseQuery(string(b))
+	if err != nil || values.Get("verb") != verb || values.Get("metadataPrefix") == "" {
+		return query{}, bad
+	}
+
+	after := values.Get("after")
+	if after == "" {
+		return query{}, bad
+	}
+
+	q, msg := parseQuery(values)
+	if msg != "" {
+		return query{}, bad
+	}
+	q.after = after
+	return q, ""
+}
@@ -0,0 +1,327 @@
+package oai
+
+import (
+	"encoding/xml"
+	"net/url"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/metadata"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provider", func() {
+	var (
+		store            *dai.MemStore
+		provider         *Provider
+		project1         *schema.Project
+		project2         *schema.Project
+		ds1, ds2, ds3    *schema.Dataset
+		unpublished      *schema.Dataset
+		savedPageSize    int
+		jan1, feb1, mar1 time.Time
+	)
+
+	handle := func(args ...string) *Response {
+		values := url.Values{}
+		for i := 0; i < len(args); i += 2 {
+			values.Add(args[i], args[i+1])
+		}
+		resp, err := provider.Handle(values)
+		Expect(err).To(BeNil())
+
+		// Every response must be writable.
+		_, err = xml.Marshal(resp)
+		Expect(err).To(BeNil())
+		return resp
+	}
+
+	errorCode := func(resp *Response) string {
+		if len(resp.Errors) == 0 {
+			return ""
+		}
+		return resp.Errors[0].Code
+	}
+
+	addDataset := func(title string, project *schema.Project, published bool, mtime time.Time, doi string) *schema.Dataset {
+		ds := schema.NewDataset(title, "alice@mc.org", project.ID)
+		ds.Published = published
+		ds.Birthtime = mtime
+		ds.MTime = mtime
+		ds.PublishedDate = mtime
+		ds.DOI = doi
+		ds.Authors = []string{"Alice", "Bob"}
+		dataset, err := store.Datasets().Insert(&ds)
+		Expect(err).To(BeNil())
+		return dataset
+	}
+
+	identifier := func(ds *schema.Dataset) string {
+		return "oai:mc.org:" + ds.ID
+	}
+
+	BeforeEach(func() {
+		savedPageSize = PageSize
+		store = dai.NewMemStore()
+
+		var err error
+		p := schema.NewProject("project1", "alice@mc.org")
+		project1, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+		p = schema.NewProject("project2", "alice@mc.org")
+		project2, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+
+		jan1 = time.Date(2016, time.January, 1, 10, 0, 0, 0, time.UTC)
+		feb1 = time.Date(2016, time.February, 1, 10, 0, 0, 0, time.UTC)
+		mar1 = time.Date(2016, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+		ds1 = addDataset("ds1", project1, true, jan1, "10.1234/ds1")
+		ds2 = addDataset("ds2", project1, true, feb1, "")
+		ds3 = addDataset("ds3", project2, true, mar1, "10.1234/ds3")
+		unpublished = addDataset("unpublished", project2, false, mar1, "")
+
+		provider = NewProvider(store, Repository{
+			Name:       "Test Repository",
+			BaseURL:    "http://mc.org/oai",
+			AdminEmail: "admin@mc.org",
+			Identifier: "mc.org",
+			Publisher:  "Materials Commons",
+			DatasetURL: func(id string) string { return "http://mc.org/public/datasets/" + id },
+		})
+	})
+
+	AfterEach(func() {
+		PageSize = savedPageSize
+	})
+
+	Describe("Arguments", func() {
+		It("Should reject a missing or unknown verb", func() {
+			resp := handle()
+			Expect(errorCode(resp)).To(Equal(BadVerb))
+
+			resp = handle("verb", "Harvest")
+			Expect(errorCode(resp)).To(Equal(BadVerb))
+			Expect(resp.Request.Verb).To(Equal(""))
+			Expect(resp.Request.BaseURL).To(Equal("http://mc.org/oai"))
+		})
+
+		It("Should reject illegal, repeated and missing arguments", func() {
+			resp := handle("verb", "Identify", "set", "project")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+			Expect(resp.Request.Verb).To(Equal(""))
+
+			resp = handle("verb", "ListRecords", "metadataPrefix", "oai_dc", "metadataPrefix", "oai_dc")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+
+			resp = handle("verb", "GetRecord", "identifier", identifier(ds1))
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "resumptionToken", "abc")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+		})
+
+		It("Should reject bad dates", func() {
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "from", "yesterday")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc",
+				"from", "2016-01-01", "until", "2016-02-01T00:00:00Z")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc",
+				"from", "2016-02-01", "until", "2016-01-01")
+			Expect(errorCode(resp)).To(Equal(BadArgument))
+		})
+	})
+
+	Describe("Identify", func() {
+		It("Should describe the repository", func() {
+			resp := handle("verb", "Identify")
+			Expect(resp.Errors).To(BeEmpty())
+			Expect(resp.Request.Verb).To(Equal("Identify"))
+			Expect(resp.Identify.RepositoryName).To(Equal("Test Repository"))
+			Expect(resp.Identify.BaseURL).To(Equal("http://mc.org/oai"))
+			Expect(resp.Identify.ProtocolVersion).To(Equal("2.0"))
+			Expect(resp.Identify.AdminEmail).To(Equal("admin@mc.org"))
+			Expect(resp.Identify.EarliestDatestamp).To(Equal("2016-01-01T10:00:00Z"))
+			Expect(resp.Identify.DeletedRecord).To(Equal("no"))
+			Expect(resp.Identify.Granularity).To(Equal(Granularity))
+		})
+	})
+
+	Describe("ListMetadataFormats", func() {
+		It("Should list every format for the repository", func() {
+			resp := handle("verb", "ListMetadataFormats")
+			Expect(resp.ListMetadataFormats.Formats).To(HaveLen(2))
+		})
+
+		It("Should only list oai_dc for an item without a DOI", func() {
+			resp := handle("verb", "ListMetadataFormats", "identifier", identifier(ds2))
+			Expect(resp.ListMetadataFormats.Formats).To(HaveLen(1))
+			Expect(resp.ListMetadataFormats.Formats[0].Prefix).To(Equal(OAIDC))
+
+			resp = handle("verb", "ListMetadataFormats", "identifier", identifier(ds1))
+			Expect(resp.ListMetadataFormats.Formats).To(HaveLen(2))
+		})
+
+		It("Should fail for an unknown item", func() {
+			resp := handle("verb", "ListMetadataFormats", "identifier", "oai:mc.org:nosuch")
+			Expect(errorCode(resp)).To(Equal(IDDoesNotExist))
+		})
+	})
+
+	Describe("ListSets", func() {
+		It("Should list the projects with published datasets", func() {
+			resp := handle("verb", "ListSets")
+			Expect(resp.ListSets.Sets).To(ConsistOf(
+				Set{Spec: "project", Name: "Projects"},
+				Set{Spec: "project:" + project1.ID, Name: "project1"},
+				Set{Spec: "project:" + project2.ID, Name: "project2"},
+			))
+		})
+
+		It("Should reject a resumption token", func() {
+			resp := handle("verb", "ListSets", "resumptionToken", "abc")
+			Expect(errorCode(resp)).To(Equal(BadResumptionToken))
+		})
+	})
+
+	Describe("GetRecord", func() {
+		It("Should return oai_dc metadata", func() {
+			resp := handle("verb", "GetRecord", "identifier", identifier(ds1), "metadataPrefix", "oai_dc")
+			Expect(resp.Errors).To(BeEmpty())
+
+			record := resp.GetRecord.Record
+			Expect(record.Header.Identifier).To(Equal(identifier(ds1)))
+			Expect(record.Header.Datestamp).To(Equal("2016-01-01T10:00:00Z"))
+			Expect(record.Header.SetSpecs).To(Equal([]string{"project:" + project1.ID}))
+
+			dc := record.Metadata.Value.(*metadata.OAIDC)
+			Expect(dc.Titles).To(Equal([]string{"ds1"}))
+			Expect(dc.Creators).To(Equal([]string{"Alice", "Bob"}))
+			Expect(dc.Identifiers).To(ContainElement("http://mc.org/public/datasets/" + ds1.ID))
+		})
+
+		It("Should return DataCite metadata for a dataset with a DOI", func() {
+			resp := handle("verb", "GetRecord", "identifier", identifier(ds1), "metadataPrefix", "oai_datacite")
+			Expect(resp.Errors).To(BeEmpty())
+			d := resp.GetRecord.Record.Metadata.Value.(*metadata.DataCite)
+			Expect(d.Publisher).To(Equal("Materials Commons"))
+
+			resp = handle("verb", "GetRecord", "identifier", identifier(ds2), "metadataPrefix", "oai_datacite")
+			Expect(errorCode(resp)).To(Equal(CannotDisseminateFormat))
+		})
+
+		It("Should fail for unknown items and formats", func() {
+			resp := handle("verb", "GetRecord", "identifier", identifier(unpublished), "metadataPrefix", "oai_dc")
+			Expect(errorCode(resp)).To(Equal(IDDoesNotExist))
+
+			resp = handle("verb", "GetRecord", "identifier", "oai:other.org:"+ds1.ID, "metadataPrefix", "oai_dc")
+			Expect(errorCode(resp)).To(Equal(IDDoesNotExist))
+
+			resp = handle("verb", "GetRecord", "identifier", identifier(ds1), "metadataPrefix", "marc21")
+			Expect(errorCode(resp)).To(Equal(CannotDisseminateFormat))
+		})
+	})
+
+	Describe("ListIdentifiers and ListRecords", func() {
+		identifiers := func(headers []Header) []string {
+			var ids []string
+			for _, header := range headers {
+				ids = append(ids, header.Identifier)
+			}
+			return ids
+		}
+
+		It("Should list only published datasets", func() {
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc")
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(
+				identifier(ds1), identifier(ds2), identifier(ds3)))
+			Expect(resp.ListIdentifiers.ResumptionToken).To(BeNil())
+
+			resp = handle("verb", "ListRecords", "metadataPrefix", "oai_dc")
+			Expect(resp.ListRecords.Records).To(HaveLen(3))
+		})
+
+		It("Should skip datasets without a DOI for oai_datacite", func() {
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_datacite")
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(identifier(ds1), identifier(ds3)))
+		})
+
+		It("Should select by date", func() {
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "from", "2016-02-01")
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(identifier(ds2), identifier(ds3)))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "until", "2016-02-01")
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(identifier(ds1), identifier(ds2)))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc",
+				"from", "2016-02-01T10:00:01Z", "until", "2016-03-01T10:00:00Z")
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(identifier(ds3)))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "from", "2017-01-01")
+			Expect(errorCode(resp)).To(Equal(NoRecordsMatch))
+		})
+
+		It("Should select by set", func() {
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "set", "project:"+project1.ID)
+			Expect(identifiers(resp.ListIdentifiers.Headers)).To(ConsistOf(identifier(ds1), identifier(ds2)))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "set", "project")
+			Expect(resp.ListIdentifiers.Headers).To(HaveLen(3))
+
+			resp = handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc", "set", "proj")
+			Expect(errorCode(resp)).To(Equal(NoRecordsMatch))
+		})
+
+		It("Should page with resumption tokens", func() {
+			PageSize = 2
+			resp := handle("verb", "ListRecords", "metadataPrefix", "oai_dc")
+			Expect(resp.ListRecords.Records).To(HaveLen(2))
+			token := resp.ListRecords.ResumptionToken
+			Expect(token.CompleteListSize).To(Equal(3))
+			Expect(token.Cursor).To(Equal(0))
+			Expect(token.Token).NotTo(BeEmpty())
+
+			// The token can't be used with another verb.
+			resp = handle("verb", "ListIdentifiers", "resumptionToken", token.Token)
+			Expect(errorCode(resp)).To(Equal(BadResumptionToken))
+
+			resp = handle("verb", "ListRecords", "resumptionToken", token.Token)
+			Expect(resp.Errors).To(BeEmpty())
+			Expect(resp.ListRecords.Records).To(HaveLen(1))
+			last := resp.ListRecords.ResumptionToken
+			Expect(last.CompleteListSize).To(Equal(3))
+			Expect(last.Cursor).To(Equal(2))
+			Expect(last.Token).To(BeEmpty())
+
+			resp = handle("verb", "ListRecords", "resumptionToken", "not a token")
+			Expect(errorCode(resp)).To(Equal(BadResumptionToken))
+		})
+
+		It("Should resume after the last record when datasets are unpublished", func() {
+			PageSize = 2
+			resp := handle("verb", "ListIdentifiers", "metadataPrefix", "oai_dc")
+			first := identifiers(resp.ListIdentifiers.Headers)
+			Expect(first).To(HaveLen(2))
+			token := resp.ListIdentifiers.ResumptionToken.Token
+
+			// Unpublishing a dataset already returned shouldn't skip the
+			// one that is left.
+			for _, ds := range []*schema.Dataset{ds1, ds2, ds3} {
+				if identifier(ds) == first[0] {
+					Expect(store.Datasets().UpdateFields(ds.ID, map[string]interface{}{"published": false})).To(BeNil())
+				}
+			}
+
+			resp = handle("verb", "ListIdentifiers", "resumptionToken", token)
+			Expect(resp.Errors).To(BeEmpty())
+			rest := identifiers(resp.ListIdentifiers.Headers)
+			Expect(rest).To(HaveLen(1))
+			Expect(append(first, rest...)).To(ConsistOf(identifier(ds1), identifier(ds2), identifier(ds3)))
+		})
+	})
+})
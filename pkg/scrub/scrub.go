@@ -0,0 +1,273 @@
+// Package scrub verifies stored files in the background. Each blob under MCDIR
+// is re-hashed on a schedule and compared against the checksum computed when
+// it was uploaded, so that bit rot and truncation are found before the file is
+// needed.
+package scrub
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/metrics"
+)
+
+// Results of verifying a blob.
+const (
+	OK        = "ok"
+	Corrupt   = "corrupt"
+	Truncated = "truncated"
+	Missing   = "missing"
+)
+
+var (
+	// verified counts verified blobs by result.
+	verified = metrics.NewCounter("mcstore_scrub_files_total",
+		"Stored files verified by the scrubber, by result (ok, corrupt, truncated or missing).", "result")
+
+	// bytesRead counts the bytes read to verify blobs.
+	bytesRead = metrics.NewCounter("mcstore_scrub_bytes_total", "Bytes read by the scrubber.")
+)
+
+// passDelay is how long the scrubber waits between passes. Each pass only
+// verifies the blobs that are due, so passes are cheap when nothing is.
+var passDelay = time.Hour
+
+// errStopped is returned when a verification is cut short by Stop.
+var errStopped = errors.New("scrubber stopped")
+
+// Options control how often blobs are verified and how fast they are read.
+type Options struct {
+	// Interval is how long after a blob is verified that it is verified again.
+	Interval time.Duration
+
+	// BytesPerSecond limits how fast blobs are read. Zero means no limit.
+	BytesPerSecond int64
+}
+
+// A Scrubber verifies the blobs in a store.
+type Scrubber struct {
+	store   dai.Store
+	options Options
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	// failed holds when blobs that failed were last checked, so that a bad
+	// blob is reported once an Interval rather than on every pass.
+	failed map[string]time.Time
+}
+
+// New creates a Scrubber for the files in store.
+func New(store dai.Store, options Options) *Scrubber {
+	return &Scrubber{
+		store:   store,
+		options: options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		failed:  make(map[string]time.Time),
+	}
+}
+
+// Start runs passes in a new goroutine until Stop is called.
+func (s *Scrubber) Start() {
+	go func() {
+		defer close(s.done)
+		for {
+			if err := s.pass(); err != nil && err != errStopped {
+				app.Log.Error("Scrubber pass failed", "error", err)
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(passDelay):
+			}
+		}
+	}()
+}
+
+// Stop ends the scrubber and waits up to timeout for it to return. It returns
+// false if it didn't return in time.
+func (s *Scrubber) Stop(timeout time.Duration) bool {
+	s.once.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// pass verifies the blobs that are due, least recently verified first.
+func (s *Scrubber) pass() error {
+	due, err := s.due(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range due {
+		select {
+		case <-s.stop:
+			return errStopped
+		default:
+		}
+
+		if err := s.verify(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// due returns the files whose blob needs to be verified. Duplicates are left
+// out since they share the blob of the file they use, and so are files that
+// are still being uploaded.
+func (s *Scrubber) due(now time.Time) ([]schema.File, error) {
+	files, err := s.store.Inventory().Files()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.Add(-s.options.Interval)
+	var due []schema.File
+	for _, file := range files {
+		switch {
+		case file.UsesID != "" || file.Checksum == "":
+		case file.Verified.After(cutoff):
+		case s.failed[file.ID].After(cutoff):
+		default:
+			due = append(due, file)
+		}
+	}
+
+	sort.Sort(byVerified(due))
+	return due, nil
+}
+
+// byVerified sorts files by when they were last verified.
+type byVerified []schema.File
+
+func (f byVerified) Len() int           { return len(f) }
+func (f byVerified) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byVerified) Less(i, j int) bool { return f[i].Verified.Before(f[j].Verified) }
+
+// verify checks the blob for file. When it matches, the verified time is set
+// on the file and every duplicate that uses it. When it doesn't, the problem
+// is logged and counted. An error is only returned if the scrubber is stopped
+// or the store can't be updated.
+func (s *Scrubber) verify(file schema.File) error {
+	result, err := s.check(file)
+	if err == errStopped {
+		return err
+	}
+	verified.Inc(result)
+
+	users, err := s.users(file)
+	if err != nil {
+		return err
+	}
+
+	if result != OK {
+		s.failed[file.ID] = time.Now()
+		app.Log.Crit("Stored file failed verification", "file", file.ID, "name", file.Name,
+			"result", result, "records", len(users))
+		return nil
+	}
+
+	delete(s.failed, file.ID)
+	fields := map[string]interface{}{schema.FileFields.Verified(): time.Now()}
+	for _, id := range users {
+		if err := s.store.Files().UpdateFields(id, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check hashes the blob for file and compares it with the file's size and
+// checksum.
+func (s *Scrubber) check(file schema.File) (string, error) {
+	f, err := os.Open(app.MCDir.FilePath(file.ID))
+	if err != nil {
+		return Missing, nil
+	}
+	defer f.Close()
+
+	hasher := md5.New()
+	n, err := io.Copy(hasher, &throttledReader{r: f, rate: s.options.BytesPerSecond, stop: s.stop, start: time.Now()})
+	bytesRead.Add(float64(n))
+	switch {
+	case err == errStopped:
+		return "", err
+	case err != nil:
+		app.Log.Error("Unable to read stored file", "file", file.ID, "error", err)
+		return Missing, nil
+	case n < file.Size:
+		return Truncated, nil
+	case hex.EncodeToString(hasher.Sum(nil)) != file.Checksum:
+		return Corrupt, nil
+	default:
+		return OK, nil
+	}
+}
+
+// users returns the ids of the file and the duplicates that share its blob.
+func (s *Scrubber) users(file schema.File) ([]string, error) {
+	usedBy, err := s.store.Files().UsedBy(file.ID)
+	if err != nil && err != app.ErrNotFound {
+		return nil, err
+	}
+
+	ids := []string{file.ID}
+	for _, dup := range usedBy {
+		ids = append(ids, dup.ID)
+	}
+	return ids, nil
+}
+
+// throttledReader limits how fast r is read to rate bytes per second, and
+// returns errStopped once stop is closed.
+type throttledReader struct {
+	r     io.Reader
+	rate  int64
+	stop  chan struct{}
+	start time.Time
+	read  int64
+}
+
+// Read reads from r, first sleeping long enough to keep the average rate at
+// or below the limit.
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.rate > 0 {
+		if len(p) > int(t.rate) {
+			p = p[:t.rate]
+		}
+		expected := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+		if wait := expected - time.Since(t.start); wait > 0 {
+			select {
+			case <-t.stop:
+				return 0, errStopped
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	select {
+	case <-t.stop:
+		return 0, errStopped
+	default:
+	}
+
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+	return n, err
+}
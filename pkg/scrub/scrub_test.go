@@ -0,0 +1,146 @@
+package scrub
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scrubber", func() {
+	var (
+		store    *dai.MemStore
+		project  *schema.Project
+		root     string
+		scrubber *Scrubber
+	)
+
+	contents := []byte("stored file contents")
+	sum := md5.Sum(contents)
+	checksum := hex.EncodeToString(sum[:])
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "scrub")
+		Expect(err).To(BeNil())
+		config.Set("MCDIR", root)
+
+		store = dai.NewMemStore()
+		p := schema.NewProject("proj", "test@mc.org")
+		project, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+
+		scrubber = New(store, Options{Interval: time.Hour})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	insertFile := func(name, usesID string) *schema.File {
+		f := schema.NewFile(name, "test@mc.org")
+		f.Checksum = checksum
+		f.Size = int64(len(contents))
+		f.UsesID = usesID
+		file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+		Expect(err).To(BeNil())
+		return file
+	}
+
+	writeBlob := func(id string, data []byte) {
+		dir := app.MCDir.FileDir(id)
+		Expect(os.MkdirAll(dir, 0700)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(dir, id), data, 0600)).To(BeNil())
+	}
+
+	verifiedAt := func(id string) time.Time {
+		f, err := store.Files().ByID(id)
+		Expect(err).To(BeNil())
+		return f.Verified
+	}
+
+	It("Should verify a shared blob once and record it on every record", func() {
+		root := insertFile("a.txt", "")
+		dup := insertFile("b.txt", root.ID)
+		writeBlob(root.ID, contents)
+
+		due, err := scrubber.due(time.Now())
+		Expect(err).To(BeNil())
+		Expect(due).To(HaveLen(1))
+		Expect(due[0].ID).To(Equal(root.ID))
+
+		before := verified.Value(OK)
+		Expect(scrubber.pass()).To(BeNil())
+		Expect(verified.Value(OK)).To(Equal(before + 1))
+		Expect(verifiedAt(root.ID).IsZero()).To(BeFalse())
+		Expect(verifiedAt(dup.ID).IsZero()).To(BeFalse())
+
+		due, err = scrubber.due(time.Now())
+		Expect(err).To(BeNil())
+		Expect(due).To(BeEmpty())
+
+		due, err = scrubber.due(time.Now().Add(2 * time.Hour))
+		Expect(err).To(BeNil())
+		Expect(due).To(HaveLen(1))
+	})
+
+	It("Should report corrupt, truncated and missing blobs", func() {
+		corrupt := insertFile("corrupt.txt", "")
+		writeBlob(corrupt.ID, bytes.ToUpper(contents))
+		truncated := insertFile("truncated.txt", "")
+		writeBlob(truncated.ID, contents[:4])
+		insertFile("missing.txt", "")
+
+		corruptBefore, truncatedBefore, missingBefore := verified.Value(Corrupt), verified.Value(Truncated), verified.Value(Missing)
+		Expect(scrubber.pass()).To(BeNil())
+		Expect(verified.Value(Corrupt)).To(Equal(corruptBefore + 1))
+		Expect(verified.Value(Truncated)).To(Equal(truncatedBefore + 1))
+		Expect(verified.Value(Missing)).To(Equal(missingBefore + 1))
+		Expect(verifiedAt(corrupt.ID).IsZero()).To(BeTrue())
+
+		// Failures aren't checked again until the interval has passed.
+		due, err := scrubber.due(time.Now())
+		Expect(err).To(BeNil())
+		Expect(due).To(BeEmpty())
+	})
+
+	It("Should skip files that are still uploading", func() {
+		f := schema.NewFile("partial.txt", "test@mc.org")
+		_, err := store.Files().Insert(&f, project.DataDir, project.ID)
+		Expect(err).To(BeNil())
+
+		due, err := scrubber.due(time.Now())
+		Expect(err).To(BeNil())
+		Expect(due).To(BeEmpty())
+	})
+
+	It("Should throttle reads and stop when asked", func() {
+		stop := make(chan struct{})
+		r := &throttledReader{r: bytes.NewReader(make([]byte, 300)), rate: 1000, stop: stop, start: time.Now()}
+		start := time.Now()
+		n, err := ioutil.ReadAll(r)
+		Expect(err).To(BeNil())
+		Expect(n).To(HaveLen(300))
+		Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+
+		close(stop)
+		_, err = r.Read(make([]byte, 10))
+		Expect(err).To(Equal(errStopped))
+	})
+
+	It("Should stop a running scrubber", func() {
+		scrubber.Start()
+		Expect(scrubber.Stop(time.Second)).To(BeTrue())
+		Expect(scrubber.Stop(time.Second)).To(BeTrue())
+	})
+})
@@ -0,0 +1,71 @@
+package trash
+
+import (
+	"sync"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+)
+
+// passDelay is how long the purger waits between passes.
+var passDelay = time.Hour
+
+// A Purger purges items from the trash once they have been there longer
+// than the retention period.
+type Purger struct {
+	trash     *Trash
+	retention time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewPurger creates a Purger for the projects in store.
+func NewPurger(store dai.Store, retention time.Duration) *Purger {
+	return &Purger{
+		trash:     New(store),
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs passes in a new goroutine until Stop is called.
+func (p *Purger) Start() {
+	go func() {
+		defer close(p.done)
+		for {
+			p.pass(time.Now())
+
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(passDelay):
+			}
+		}
+	}()
+}
+
+// Stop ends the purger and waits up to timeout for it to return. It returns
+// false if it didn't return in time.
+func (p *Purger) Stop(timeout time.Duration) bool {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// pass purges the items that have expired.
+func (p *Purger) pass(now time.Time) {
+	n, err := p.trash.PurgeExpired(now.Add(-p.retention))
+	if err != nil {
+		app.Log.Error("Purging the trash failed", "error", err)
+	}
+	if n != 0 {
+		app.Log.Info("Purged expired items from the trash", "count", n)
+	}
+}
@@ -0,0 +1,411 @@
+// Package trash keeps the files and directories that users delete from a
+// project so that they can be restored. Deleted items stay in the project's
+// trash, and count toward its storage, until they are restored or purged.
+// Purging removes the entries for good and removes blobs that nothing else
+// uses. A Purger purges items once they are older than the retention period.
+package trash
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Trash moves items into and out of the trash for the projects in a store.
+type Trash struct {
+	store dai.Store
+}
+
+// New creates a Trash for the projects in store.
+func New(store dai.Store) *Trash {
+	return &Trash{store: store}
+}
+
+// List returns the items in a project's trash, newest first.
+func (t *Trash) List(projectID string) ([]schema.TrashItem, error) {
+	items, err := t.store.Trash().ForProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []schema.TrashItem{}
+	}
+	return items, nil
+}
+
+// DeleteFile moves a file, with all its versions, from a directory into the
+// project's trash. The versions are moved into a holding directory created
+// for the item, so the version chain is kept as it was.
+func (t *Trash) DeleteFile(fileID, dirID, projectID, user string) (*schema.TrashItem, error) {
+	dir, err := t.projectDir(dirID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := t.store.Files().ByID(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := t.versions(file.Name, dir.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, app.Errorf(app.ErrNotFound, "file %s is not in directory %s", fileID, dirID)
+	}
+
+	h := schema.NewDirectory(schema.TrashDirPrefix+file.ID, user, projectID, "")
+	holder, err := t.store.Dirs().Insert(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.moveFiles(versions, dir.ID, holder.ID); err != nil {
+		t.store.Dirs().Delete(holder.ID)
+		return nil, err
+	}
+
+	item := schema.NewTrashItem(schema.TrashFile, file.ID, projectID, dir.Name+"/"+file.Name, user)
+	item.DirectoryID = dir.ID
+	item.HolderID = holder.ID
+	item.Files, item.Size = count(versions)
+	return t.insert(&item, func() {
+		t.moveFiles(versions, holder.ID, dir.ID)
+		t.store.Dirs().Delete(holder.ID)
+	})
+}
+
+// DeleteDir moves a directory, and everything under it, into the project's
+// trash. The directory is detached from its parent and it and its descendants
+// are renamed out of the project's paths. The top level directory can't be
+// deleted.
+func (t *Trash) DeleteDir(dirID, projectID, user string) (*schema.TrashItem, error) {
+	dir, err := t.projectDir(dirID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, files, err := t.subtree(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := func(d schema.Directory) schema.Directory {
+		d.Name = schema.TrashDirPrefix + dir.ID + "/" + d.Name
+		if d.ID == dir.ID {
+			d.Parent = ""
+		}
+		return d
+	}
+	if err := t.updateDirs(dirs, renamed); err != nil {
+		return nil, err
+	}
+
+	item := schema.NewTrashItem(schema.TrashDirectory, dir.ID, projectID, dir.Name, user)
+	item.DirectoryID = dir.Parent
+	item.HolderID = dir.ID
+	item.Files, item.Size = count(files)
+	return t.insert(&item, func() {
+		t.updateDirs(dirs, func(d schema.Directory) schema.Directory { return d })
+	})
+}
+
+// Restore moves an item out of the trash and back to where it was deleted
+// from. It fails with app.ErrExists if something has taken its place, and
+// with app.ErrInvalid if the directory it was in is gone or in the trash.
+func (t *Trash) Restore(itemID, projectID string) error {
+	item, err := t.item(itemID, projectID)
+	if err != nil {
+		return err
+	}
+
+	dir, err := t.store.Dirs().ByID(item.DirectoryID)
+	switch {
+	case app.Is(err, app.ErrNotFound) || (err == nil && dir.InTrash()):
+		return app.Errorf(app.ErrInvalid, "the directory %s was in is gone, restore it first", item.Path)
+	case err != nil:
+		return err
+	}
+
+	if item.ItemType == schema.TrashFile {
+		err = t.restoreFile(item, dir)
+	} else {
+		err = t.restoreDir(item)
+	}
+
+	if err != nil {
+		return err
+	}
+	return t.store.Trash().Delete(item.ID)
+}
+
+// restoreFile moves a file's versions from its holding directory back to dir.
+func (t *Trash) restoreFile(item *schema.TrashItem, dir *schema.Directory) error {
+	versions, err := t.dirFiles(item.HolderID)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) != 0 {
+		if _, err := t.store.Files().ByPath(versions[0].Name, dir.ID); err == nil {
+			return app.Errorf(app.ErrExists, "%s already exists", item.Path)
+		}
+	}
+
+	if err := t.moveFiles(versions, item.HolderID, dir.ID); err != nil {
+		return err
+	}
+	return t.store.Dirs().Delete(item.HolderID)
+}
+
+// restoreDir renames a directory and its descendants back to their original
+// paths and reattaches it to its parent.
+func (t *Trash) restoreDir(item *schema.TrashItem) error {
+	if _, err := t.store.Dirs().ByPath(item.Path, item.ProjectID); err == nil {
+		return app.Errorf(app.ErrExists, "%s already exists", item.Path)
+	}
+
+	holder, err := t.store.Dirs().ByID(item.HolderID)
+	if err != nil {
+		return err
+	}
+
+	dirs, _, err := t.subtree(holder)
+	if err != nil {
+		return err
+	}
+
+	prefix := schema.TrashDirPrefix + item.HolderID + "/"
+	return t.updateDirs(dirs, func(d schema.Directory) schema.Directory {
+		d.Name = strings.TrimPrefix(d.Name, prefix)
+		if d.ID == item.HolderID {
+			d.Parent = item.DirectoryID
+		}
+		return d
+	})
+}
+
+// Purge removes an item from the trash for good. File entries that nothing
+// else refers to are removed along with their blobs.
+func (t *Trash) Purge(itemID, projectID string) error {
+	item, err := t.item(itemID, projectID)
+	if err != nil {
+		return err
+	}
+	return t.purge(item)
+}
+
+// PurgeExpired purges the items, in every project, that were deleted before
+// the given time. An item that can't be purged is logged and left for the next
+// pass. It returns the number of items purged and the last error.
+func (t *Trash) PurgeExpired(before time.Time) (int, error) {
+	items, err := t.store.Trash().DeletedBefore(before)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		purged  int
+		lastErr error
+	)
+	for i := range items {
+		if err := t.purge(&items[i]); err != nil {
+			app.Log.Error("Unable to purge trash item", "itemid", items[i].ID, "projectid", items[i].ProjectID, "path", items[i].Path, "error", err)
+			lastErr = app.Errorf(err, "purging %s from project %s", items[i].Path, items[i].ProjectID)
+			continue
+		}
+		purged++
+	}
+	return purged, lastErr
+}
+
+// purge removes the contents of an item's holding directory, deepest
+// directories first, and then the item itself.
+func (t *Trash) purge(item *schema.TrashItem) error {
+	holder, err := t.store.Dirs().ByID(item.HolderID)
+	switch {
+	case app.Is(err, app.ErrNotFound):
+		// An earlier purge got this far and then failed.
+		return t.store.Trash().Delete(item.ID)
+	case err != nil:
+		return err
+	}
+
+	dirs, _, err := t.subtree(holder)
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		files, err := t.dirFiles(dirs[i].ID)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err := t.purgeFile(file, dirs[i].ID, item.ProjectID); err != nil {
+				return err
+			}
+		}
+
+		if err := t.store.Dirs().Delete(dirs[i].ID); err != nil {
+			return err
+		}
+	}
+
+	return t.store.Trash().Delete(item.ID)
+}
+
+// purgeFile deletes a file from a directory. If that removed the file entry,
+// and the file owns its blob, the blob is removed too.
+func (t *Trash) purgeFile(file schema.File, dirID, projectID string) error {
+	if _, err := t.store.Files().Delete(file.ID, dirID, projectID); err != nil {
+		return err
+	}
+
+	if _, err := t.store.Files().ByID(file.ID); !app.Is(err, app.ErrNotFound) || file.UsesID != "" {
+		return nil
+	}
+
+	if err := os.Remove(app.MCDir.FilePath(file.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// item looks up an item in a project's trash.
+func (t *Trash) item(itemID, projectID string) (*schema.TrashItem, error) {
+	item, err := t.store.Trash().ByID(itemID)
+	switch {
+	case err != nil:
+		return nil, err
+	case item.ProjectID != projectID:
+		return nil, app.ErrNotFound
+	default:
+		return item, nil
+	}
+}
+
+// insert adds an item to the trash. If that fails undo is called to put
+// things back the way they were.
+func (t *Trash) insert(item *schema.TrashItem, undo func()) (*schema.TrashItem, error) {
+	newItem, err := t.store.Trash().Insert(item)
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return newItem, nil
+}
+
+// projectDir looks up a directory in a project that can be deleted from.
+func (t *Trash) projectDir(dirID, projectID string) (*schema.Directory, error) {
+	dir, err := t.store.Dirs().ByID(dirID)
+	switch {
+	case err != nil:
+		return nil, err
+	case dir.Project != projectID || dir.InTrash():
+		return nil, app.Errorf(app.ErrNotFound, "directory %s is not in project %s", dirID, projectID)
+	case dir.Parent == "":
+		return nil, app.Errorf(app.ErrInvalid, "the top level directory of a project can't be deleted")
+	default:
+		return dir, nil
+	}
+}
+
+// versions returns every version of the named file in a directory.
+func (t *Trash) versions(name, dirID string) ([]schema.File, error) {
+	files, err := t.dirFiles(dirID)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []schema.File
+	for _, file := range files {
+		if file.Name == name {
+			versions = append(versions, file)
+		}
+	}
+	return versions, nil
+}
+
+// moveFiles moves files from one directory to another. If a move fails the
+// files already moved are moved back.
+func (t *Trash) moveFiles(files []schema.File, fromDirID, toDirID string) error {
+	for i, file := range files {
+		if err := t.store.Files().Move(file.ID, fromDirID, toDirID); err != nil {
+			for _, moved := range files[:i] {
+				t.store.Files().Move(moved.ID, toDirID, fromDirID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// updateDirs updates each directory to what change returns for it. If an
+// update fails the directories already updated are put back.
+func (t *Trash) updateDirs(dirs []schema.Directory, change func(d schema.Directory) schema.Directory) error {
+	for i, dir := range dirs {
+		changed := change(dir)
+		if err := t.store.Dirs().Update(&changed); err != nil {
+			for j := range dirs[:i] {
+				t.store.Dirs().Update(&dirs[j])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// subtree returns dir and its descendants, each directory after its parent,
+// and the files in them.
+func (t *Trash) subtree(dir *schema.Directory) ([]schema.Directory, []schema.File, error) {
+	var (
+		dirs  []schema.Directory
+		files []schema.File
+	)
+
+	queue := []schema.Directory{*dir}
+	for len(queue) != 0 {
+		d := queue[0]
+		queue = queue[1:]
+		dirs = append(dirs, d)
+
+		dirFiles, err := t.dirFiles(d.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, dirFiles...)
+
+		children, err := t.store.Dirs().Children(d.ID)
+		if err != nil && !app.Is(err, app.ErrNotFound) {
+			return nil, nil, err
+		}
+		queue = append(queue, children...)
+	}
+
+	return dirs, files, nil
+}
+
+// dirFiles returns the files in a directory. An empty directory isn't an error.
+func (t *Trash) dirFiles(dirID string) ([]schema.File, error) {
+	files, err := t.store.Dirs().Files(dirID)
+	if err != nil && !app.Is(err, app.ErrNotFound) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// count returns the number of files and their total size.
+func count(files []schema.File) (int, int64) {
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+	return len(files), size
+}
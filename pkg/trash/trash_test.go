@@ -0,0 +1,287 @@
+package trash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trash", func() {
+	var (
+		store   *dai.MemStore
+		project *schema.Project
+		sub     *schema.Directory
+		root    string
+		trash   *Trash
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "trash")
+		Expect(err).To(BeNil())
+		config.Set("MCDIR", root)
+
+		store = dai.NewMemStore()
+		p := schema.NewProject("proj", "test@mc.org")
+		project, err = store.Projects().Insert(&p)
+		Expect(err).To(BeNil())
+
+		sub = insertDir(store, "proj/sub", project.ID, project.DataDir)
+		trash = New(store)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	// insertVersions adds a file with a version chain to a directory and
+	// writes the blobs. It returns the versions, oldest first.
+	insertVersions := func(name, dirID string, sizes ...int64) []*schema.File {
+		var versions []*schema.File
+		for i, size := range sizes {
+			f := schema.NewFile(name, "test@mc.org")
+			f.Size = size
+			f.Checksum = name + strconv.Itoa(i)
+			if i != 0 {
+				f.Parent = versions[i-1].ID
+				Expect(store.Files().UpdateFields(f.Parent, map[string]interface{}{"current": false})).To(BeNil())
+			}
+			file, err := store.Files().Insert(&f, dirID, project.ID)
+			Expect(err).To(BeNil())
+			writeBlob(file.ID)
+			versions = append(versions, file)
+		}
+		return versions
+	}
+
+	Describe("Files", func() {
+		var versions []*schema.File
+
+		BeforeEach(func() {
+			versions = insertVersions("f.txt", sub.ID, 10, 20)
+		})
+
+		It("Should move every version of a file into the trash and restore it", func() {
+			item, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(item.Path).To(Equal("proj/sub/f.txt"))
+			Expect(item.Files).To(Equal(2))
+			Expect(item.Size).To(BeEquivalentTo(30))
+
+			files, err := store.Dirs().Files(sub.ID)
+			Expect(err).To(BeNil())
+			Expect(files).To(BeEmpty())
+
+			items, err := trash.List(project.ID)
+			Expect(err).To(BeNil())
+			Expect(items).To(HaveLen(1))
+
+			Expect(trash.Restore(item.ID, project.ID)).To(BeNil())
+			files, err = store.Dirs().Files(sub.ID)
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(2))
+			current, err := store.Files().ByPath("f.txt", sub.ID)
+			Expect(err).To(BeNil())
+			Expect(current.ID).To(Equal(versions[1].ID))
+
+			_, err = store.Dirs().ByID(item.HolderID)
+			Expect(err).To(Equal(app.ErrNotFound))
+			items, err = trash.List(project.ID)
+			Expect(err).To(BeNil())
+			Expect(items).To(BeEmpty())
+		})
+
+		It("Should not restore over a file with the same name", func() {
+			item, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			insertVersions("f.txt", sub.ID, 5)
+
+			Expect(app.Is(trash.Restore(item.ID, project.ID), app.ErrExists)).To(BeTrue())
+		})
+
+		It("Should purge the entries and blobs", func() {
+			item, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+
+			Expect(trash.Purge(item.ID, project.ID)).To(BeNil())
+			for _, version := range versions {
+				_, err := store.Files().ByID(version.ID)
+				Expect(err).To(Equal(app.ErrNotFound))
+				_, err = os.Stat(app.MCDir.FilePath(version.ID))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			}
+			_, err = store.Trash().ByID(item.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+		})
+
+		It("Should keep a blob that another file uses", func() {
+			dup := schema.NewFile("dup.txt", "test@mc.org")
+			dup.UsesID = versions[0].ID
+			_, err := store.Files().Insert(&dup, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+
+			item, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(trash.Purge(item.ID, project.ID)).To(BeNil())
+
+			_, err = os.Stat(app.MCDir.FilePath(versions[0].ID))
+			Expect(err).To(BeNil())
+			_, err = os.Stat(app.MCDir.FilePath(versions[1].ID))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("Should only act on items in the project", func() {
+			_, err := trash.DeleteFile(versions[1].ID, sub.ID, "other", "test@mc.org")
+			Expect(app.Is(err, app.ErrNotFound)).To(BeTrue())
+
+			item, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(trash.Restore(item.ID, "other")).To(Equal(app.ErrNotFound))
+			Expect(trash.Purge(item.ID, "other")).To(Equal(app.ErrNotFound))
+		})
+	})
+
+	Describe("Directories", func() {
+		var (
+			nested *schema.Directory
+			files  []*schema.File
+		)
+
+		BeforeEach(func() {
+			nested = insertDir(store, "proj/sub/nested", project.ID, sub.ID)
+			files = append(insertVersions("a.txt", sub.ID, 1), insertVersions("b.txt", nested.ID, 2, 3)...)
+		})
+
+		It("Should move a directory tree into the trash and restore it", func() {
+			item, err := trash.DeleteDir(sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(item.Path).To(Equal("proj/sub"))
+			Expect(item.Files).To(Equal(3))
+			Expect(item.Size).To(BeEquivalentTo(6))
+
+			children, err := store.Dirs().Children(project.DataDir)
+			Expect(err).To(BeNil())
+			Expect(children).To(BeEmpty())
+			_, err = store.Dirs().ByPath("proj/sub/nested", project.ID)
+			Expect(err).To(Equal(app.ErrNotFound))
+
+			Expect(trash.Restore(item.ID, project.ID)).To(BeNil())
+			d, err := store.Dirs().ByPath("proj/sub/nested", project.ID)
+			Expect(err).To(BeNil())
+			Expect(d.Parent).To(Equal(sub.ID))
+			d, err = store.Dirs().ByPath("proj/sub", project.ID)
+			Expect(err).To(BeNil())
+			Expect(d.Parent).To(Equal(project.DataDir))
+		})
+
+		It("Should not restore a file until its directory is restored", func() {
+			fileItem, err := trash.DeleteFile(files[0].ID, sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			dirItem, err := trash.DeleteDir(sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+
+			Expect(app.Is(trash.Restore(fileItem.ID, project.ID), app.ErrInvalid)).To(BeTrue())
+			Expect(trash.Restore(dirItem.ID, project.ID)).To(BeNil())
+			Expect(trash.Restore(fileItem.ID, project.ID)).To(BeNil())
+		})
+
+		It("Should refuse the top level directory", func() {
+			_, err := trash.DeleteDir(project.DataDir, project.ID, "test@mc.org")
+			Expect(app.Is(err, app.ErrInvalid)).To(BeTrue())
+		})
+
+		It("Should purge the tree", func() {
+			item, err := trash.DeleteDir(sub.ID, project.ID, "test@mc.org")
+			Expect(err).To(BeNil())
+			Expect(trash.Purge(item.ID, project.ID)).To(BeNil())
+
+			for _, id := range []string{sub.ID, nested.ID} {
+				_, err := store.Dirs().ByID(id)
+				Expect(err).To(Equal(app.ErrNotFound))
+			}
+			for _, file := range files {
+				_, err := store.Files().ByID(file.ID)
+				Expect(err).To(Equal(app.ErrNotFound))
+			}
+		})
+	})
+
+	It("Should purge only expired items", func() {
+		versions := insertVersions("old.txt", sub.ID, 1)
+		old, err := trash.DeleteFile(versions[0].ID, sub.ID, project.ID, "test@mc.org")
+		Expect(err).To(BeNil())
+
+		time.Sleep(10 * time.Millisecond)
+		cutoff := time.Now()
+		versions = insertVersions("new.txt", sub.ID, 1)
+		recent, err := trash.DeleteFile(versions[0].ID, sub.ID, project.ID, "test@mc.org")
+		Expect(err).To(BeNil())
+
+		purger := NewPurger(store, time.Hour)
+		purger.pass(cutoff.Add(time.Hour))
+
+		_, err = store.Trash().ByID(old.ID)
+		Expect(err).To(Equal(app.ErrNotFound))
+		_, err = store.Trash().ByID(recent.ID)
+		Expect(err).To(BeNil())
+	})
+
+	It("Should keep purging after an item fails", func() {
+		versions := insertVersions("bad.txt", sub.ID, 1)
+		bad, err := trash.DeleteFile(versions[0].ID, sub.ID, project.ID, "test@mc.org")
+		Expect(err).To(BeNil())
+
+		// A directory in place of the blob can't be removed.
+		blob := app.MCDir.FilePath(versions[0].ID)
+		Expect(os.Remove(blob)).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(blob, "keep"), 0700)).To(BeNil())
+
+		versions = insertVersions("good.txt", sub.ID, 1)
+		good, err := trash.DeleteFile(versions[0].ID, sub.ID, project.ID, "test@mc.org")
+		Expect(err).To(BeNil())
+
+		n, err := trash.PurgeExpired(time.Now().Add(time.Hour))
+		Expect(err).NotTo(BeNil())
+		Expect(n).To(Equal(1))
+
+		_, err = store.Trash().ByID(good.ID)
+		Expect(err).To(Equal(app.ErrNotFound))
+		_, err = store.Trash().ByID(bad.ID)
+		Expect(err).To(BeNil())
+	})
+
+	It("Should count the trash toward usage", func() {
+		insertVersions("a.txt", project.DataDir, 4)
+		versions := insertVersions("b.txt", sub.ID, 1, 2)
+		_, err := trash.DeleteFile(versions[1].ID, sub.ID, project.ID, "test@mc.org")
+		Expect(err).To(BeNil())
+
+		usage, err := trash.Usage(project)
+		Expect(err).To(BeNil())
+		Expect(*usage).To(Equal(Usage{Files: 1, Bytes: 4, TrashFiles: 2, TrashBytes: 3, TotalBytes: 7}))
+	})
+})
+
+// insertDir adds a directory to a project.
+func insertDir(store dai.Store, name, projectID, parentID string) *schema.Directory {
+	d := schema.NewDirectory(name, "test@mc.org", projectID, parentID)
+	dir, err := store.Dirs().Insert(&d)
+	Expect(err).To(BeNil())
+	return dir
+}
+
+// writeBlob writes a blob for a file.
+func writeBlob(id string) {
+	dir := app.MCDir.FileDir(id)
+	Expect(os.MkdirAll(dir, 0700)).To(BeNil())
+	Expect(ioutil.WriteFile(filepath.Join(dir, id), []byte(id), 0600)).To(BeNil())
+}
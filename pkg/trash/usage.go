@@ -0,0 +1,42 @@
+package trash
+
+import (
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// Usage is the storage a project uses. Every version of a file counts, and so
+// does everything in the project's trash until it is purged.
+type Usage struct {
+	Files      int   `json:"files"`
+	Bytes      int64 `json:"bytes"`
+	TrashFiles int   `json:"trash_files"`
+	TrashBytes int64 `json:"trash_bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// Usage returns the storage used by a project.
+func (t *Trash) Usage(project *schema.Project) (*Usage, error) {
+	top, err := t.store.Dirs().ByID(project.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	_, files, err := t.subtree(top)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := t.store.Trash().ForProject(project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage Usage
+	usage.Files, usage.Bytes = count(files)
+	for _, item := range items {
+		usage.TrashFiles += item.Files
+		usage.TrashBytes += item.Size
+	}
+	usage.TotalBytes = usage.Bytes + usage.TrashBytes
+	return &usage, nil
+}
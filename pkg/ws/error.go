@@ -1,28 +1,114 @@
 package ws
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime"
 
 	"github.com/emicklei/go-restful"
 	"github.com/materials-commons/mcstore/pkg/app"
 )
 
+// statusTooManyRequests is the HTTP status code for a rate limited request. It
+// isn't defined by net/http.
+const statusTooManyRequests = 429
+
+// Error codes sent in error responses. Clients can rely on these not changing.
+const (
+	CodeNotFound         = "not_found"
+	CodeInvalid          = "invalid"
+	CodeExists           = "exists"
+	CodeNoAccess         = "no_access"
+	CodeForbidden        = "forbidden"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeNotAllowed       = "method_not_allowed"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeInternal         = "internal"
+)
+
+// errorCode describes how an application error is sent to clients.
+type errorCode struct {
+	err        error
+	code       string
+	statusCode int
+}
+
+// errorCodes maps application errors to error codes and HTTP status codes.
+var errorCodes = []errorCode{
+	{app.ErrNotFound, CodeNotFound, http.StatusNotFound},
+	{app.ErrInvalid, CodeInvalid, http.StatusBadRequest},
+	{app.ErrExists, CodeExists, http.StatusForbidden},
+	{app.ErrNoAccess, CodeNoAccess, http.StatusUnauthorized},
+	{app.ErrForbidden, CodeForbidden, http.StatusForbidden},
+	{app.ErrRateLimited, CodeRateLimited, statusTooManyRequests},
+	{app.ErrUnavailable, CodeUnavailable, http.StatusServiceUnavailable},
+	{app.ErrInternal, CodeInternal, http.StatusInternalServerError},
+}
+
+// ErrorForCode returns the application error for an error code. Unknown codes
+// return app.ErrUnclassified.
+func ErrorForCode(code string) error {
+	for _, ec := range errorCodes {
+		if ec.code == code {
+			return ec.err
+		}
+	}
+	return app.ErrUnclassified
+}
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes an error.
+type ErrorBody struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
 // HTTPError is the error and message to respond with.
 type HTTPError struct {
 	statusCode int
-	message    string
+	body       ErrorBody
+}
+
+// StatusCode returns the HTTP status code for the error.
+func (e *HTTPError) StatusCode() int {
+	return e.statusCode
+}
+
+// Code returns the error code.
+func (e *HTTPError) Code() string {
+	return e.body.Code
 }
 
-// Write writes the HTTPError to the ResponseWriter
+// Message returns the error message.
+func (e *HTTPError) Message() string {
+	return e.body.Message
+}
+
+// Write writes the HTTPError to the ResponseWriter as a JSON ErrorResponse.
+// The request ID is taken from the response's RequestIDHeader if it was set.
 func (e *HTTPError) Write(response http.ResponseWriter) {
-	r, ok := response.(*restful.Response)
-	if ok {
-		r.WriteErrorString(e.statusCode, e.message)
+	body := e.body
+	body.RequestID = response.Header().Get(RequestIDHeader)
+
+	response.Header().Set("Content-Type", restful.MIME_JSON)
+	if r, ok := response.(*restful.Response); ok {
+		// A restful.Response only records the status code, it leaves writing
+		// it to WriteEntity.
+		r.WriteHeader(e.statusCode)
+		r.ResponseWriter.WriteHeader(e.statusCode)
 	} else {
 		response.WriteHeader(e.statusCode)
-		response.Write([]byte(e.message))
 	}
+	json.NewEncoder(response).Encode(ErrorResponse{Error: body})
 }
 
 // WriteError writes the specified error to the writer. It translates the
@@ -46,26 +132,79 @@ func ToHTTPError(err error) *HTTPError {
 // appToHTTPError tranlates an mcerr.Error to an httpError.
 func appErrToHTTPError(err *app.Error) *HTTPError {
 	httpErr := otherErrorToHTTPError(err.Err)
-	httpErr.message = fmt.Sprintf("%s: %s", httpErr.message, err.Message)
+	if err.Message != "" {
+		httpErr.body.Message = fmt.Sprintf("%s: %s", httpErr.body.Message, err.Message)
+	}
+	httpErr.body.Details = err.Details
 	return httpErr
 }
 
 // otherErrorToHTTPError translates other error types to an httpError.
 func otherErrorToHTTPError(err error) *HTTPError {
-	var httpErr HTTPError
-	switch err {
-	case app.ErrNotFound:
-		httpErr.statusCode = http.StatusNotFound
-	case app.ErrInvalid:
-		httpErr.statusCode = http.StatusBadRequest
-	case app.ErrExists:
-		httpErr.statusCode = http.StatusForbidden
-	case app.ErrNoAccess:
-		httpErr.statusCode = http.StatusUnauthorized
-	default:
-		httpErr.statusCode = http.StatusInternalServerError
+	httpErr := &HTTPError{
+		statusCode: http.StatusInternalServerError,
+		body: ErrorBody{
+			Code:    CodeInternal,
+			Message: err.Error(),
+		},
 	}
 
-	httpErr.message = err.Error()
-	return &httpErr
+	for _, ec := range errorCodes {
+		if ec.err == err {
+			httpErr.statusCode = ec.statusCode
+			httpErr.body.Code = ec.code
+			break
+		}
+	}
+
+	return httpErr
+}
+
+// statusCodes maps the status codes of errors found by go-restful when
+// routing a request to error codes.
+var statusCodes = map[int]string{
+	http.StatusNotFound:             CodeNotFound,
+	http.StatusMethodNotAllowed:     CodeNotAllowed,
+	http.StatusUnsupportedMediaType: CodeUnsupportedMedia,
+	http.StatusNotAcceptable:        CodeNotAcceptable,
+	http.StatusBadRequest:           CodeInvalid,
+	http.StatusUnauthorized:         CodeNoAccess,
+	http.StatusForbidden:            CodeForbidden,
+	http.StatusInternalServerError:  CodeInternal,
+	http.StatusServiceUnavailable:   CodeUnavailable,
+	statusTooManyRequests:           CodeRateLimited,
+}
+
+// WriteServiceError writes the errors go-restful finds when routing a request,
+// such as an unknown path, as an ErrorResponse. Set it on a container with
+// ServiceErrorHandler.
+func WriteServiceError(err restful.ServiceError, request *restful.Request, response *restful.Response) {
+	code, found := statusCodes[err.Code]
+	if !found {
+		code = CodeInternal
+	}
+
+	httpErr := &HTTPError{
+		statusCode: err.Code,
+		body: ErrorBody{
+			Code:    code,
+			Message: err.Message,
+		},
+	}
+	httpErr.Write(response)
+}
+
+// NotFound responds with a not found error response. Register it on a
+// container's ServeMux to handle paths that don't belong to any service.
+func NotFound(writer http.ResponseWriter, request *http.Request) {
+	WriteError(app.Errorf(app.ErrNotFound, "no route for %s", request.URL.Path), writer)
+}
+
+// RecoverHandler logs a panic in a route and responds with an internal error.
+// Set it on a container with RecoverHandler.
+func RecoverHandler(panicReason interface{}, writer http.ResponseWriter) {
+	stack := make([]byte, 8192)
+	stack = stack[:runtime.Stack(stack, false)]
+	app.Log.Error("Panic handling request", "reason", fmt.Sprint(panicReason), "stack", string(stack))
+	WriteError(app.ErrInternal, writer)
 }
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error", func() {
+	var rr *httptest.ResponseRecorder
+
+	decode := func() ErrorBody {
+		var resp ErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		return resp.Error
+	}
+
+	BeforeEach(func() {
+		rr = httptest.NewRecorder()
+	})
+
+	It("Should write an application error as a JSON error response", func() {
+		WriteError(app.Errorf(app.ErrNotFound, "no such file %s", "abc").WithDetail("file_id", "abc"), rr)
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+		Expect(rr.Header().Get("Content-Type")).To(Equal(restful.MIME_JSON))
+		body := decode()
+		Expect(body.Code).To(Equal(CodeNotFound))
+		Expect(body.Message).To(Equal("not found: no such file abc"))
+		Expect(body.Details).To(HaveKeyWithValue("file_id", "abc"))
+	})
+
+	It("Should include the request ID when it was set", func() {
+		rr.Header().Set(RequestIDHeader, "req-1")
+		WriteError(app.ErrRateLimited, rr)
+		Expect(rr.Code).To(Equal(429))
+		body := decode()
+		Expect(body.Code).To(Equal(CodeRateLimited))
+		Expect(body.RequestID).To(Equal("req-1"))
+	})
+
+	It("Should write other errors as internal errors", func() {
+		WriteError(errors.New("disk on fire"), rr)
+		Expect(rr.Code).To(Equal(http.StatusInternalServerError))
+		body := decode()
+		Expect(body.Code).To(Equal(CodeInternal))
+		Expect(body.Message).To(Equal("disk on fire"))
+	})
+
+	It("Should map every code back to its application error", func() {
+		for _, ec := range errorCodes {
+			Expect(ErrorForCode(ec.code)).To(Equal(ec.err))
+		}
+		Expect(ErrorForCode("no_such_code")).To(Equal(app.ErrUnclassified))
+	})
+
+	It("Should write routing errors from a container as JSON error responses", func() {
+		container := restful.NewContainer()
+		container.ServiceErrorHandler(WriteServiceError)
+		container.ServeMux.HandleFunc("/", NotFound)
+		ws := new(restful.WebService)
+		ws.Path("/things").Route(ws.GET("").To(func(request *restful.Request, response *restful.Response) {}))
+		container.Add(ws)
+
+		req, _ := http.NewRequest("GET", "/nothing", nil)
+		container.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+		Expect(decode().Code).To(Equal(CodeNotFound))
+
+		rr = httptest.NewRecorder()
+		req, _ = http.NewRequest("DELETE", "/things", nil)
+		container.ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(decode().Code).To(Equal(CodeNotAllowed))
+	})
+})
+
+var _ = Describe("app.Is", func() {
+	It("Should compare errors with a Cause by their cause", func() {
+		Expect(app.Is(causeErr{app.ErrNoAccess}, app.ErrNoAccess)).To(BeTrue())
+		Expect(app.Is(causeErr{app.ErrNoAccess}, app.ErrNotFound)).To(BeFalse())
+	})
+})
+
+type causeErr struct {
+	cause error
+}
+
+func (e causeErr) Error() string { return e.cause.Error() }
+func (e causeErr) Cause() error  { return e.cause }
@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader is the header holding the ID of a request. Error responses
+// include it so a failure can be matched with the server logs.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID accepted from a client.
+const maxRequestIDLength = 128
+
+// NewRequestID generates a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing means the system is unusable, but a request
+		// without an ID is still better than no request.
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID returns true if id can be used as a request ID. IDs are
+// written to logs and headers, so only a limited set of characters is allowed.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
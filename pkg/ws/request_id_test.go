@@ -0,0 +1,24 @@
+package ws
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestID", func() {
+	It("Should generate different valid IDs", func() {
+		id1, id2 := NewRequestID(), NewRequestID()
+		Expect(ValidRequestID(id1)).To(BeTrue())
+		Expect(id1).NotTo(Equal(id2))
+	})
+
+	It("Should only accept IDs made of safe characters", func() {
+		Expect(ValidRequestID("abc-123_x.y:z")).To(BeTrue())
+		Expect(ValidRequestID("")).To(BeFalse())
+		Expect(ValidRequestID("has space")).To(BeFalse())
+		Expect(ValidRequestID("line\nbreak")).To(BeFalse())
+		Expect(ValidRequestID(strings.Repeat("a", maxRequestIDLength+1))).To(BeFalse())
+	})
+})
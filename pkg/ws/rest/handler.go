@@ -1,29 +1,22 @@
 package rest
 
 import (
+	"net/http"
+
 	"github.com/emicklei/go-restful"
-	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/pkg/ws"
 )
 
-// httpError is the error and message to respond with.
-type httpError struct {
-	statusCode int
-	message    string
-}
-
-// Write writes an httpError as the response.
-func (e *httpError) Write(response *restful.Response) {
-	response.WriteErrorString(e.statusCode, e.message)
-}
-
 // RouteFunc represents the routes function
 type RouteFunc func(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error)
 
 // RouteFunc1 is a route function that only returns an error, but no value
 type RouteFunc1 func(request *restful.Request, response *restful.Response, user schema.User) error
 
+// PublicRouteFunc is a route function for routes that don't have an authenticated user.
+type PublicRouteFunc func(request *restful.Request, response *restful.Response) (interface{}, error)
+
 // Handler represents the way a route function should actually be written.
 type Handler func(request *restful.Request, response *restful.Response)
 
@@ -33,18 +26,36 @@ func RouteHandler(f RouteFunc) restful.RouteFunction {
 	return func(request *restful.Request, response *restful.Response) {
 		user := request.Attribute("user").(schema.User)
 		val, err := f(request, response, user)
-		switch {
-		case err != nil:
-			httpErr := ws.ToHTTPError(err)
-			httpErr.Write(response)
-		case val != nil:
-			err = response.WriteEntity(val)
-			if err != nil {
-				app.Log.Errorf("response.WriteEntity failed: %s", err)
-			}
-		default:
-			// No error and no value to write - nothing to do.
+		writeResponse(request, response, val, err)
+	}
+}
+
+// PublicRouteHandler creates a wrapper function for route methods that don't
+// require an authenticated user. See RouteHandler for details.
+func PublicRouteHandler(f PublicRouteFunc) restful.RouteFunction {
+	return func(request *restful.Request, response *restful.Response) {
+		val, err := f(request, response)
+		writeResponse(request, response, val, err)
+	}
+}
+
+// writeResponse writes the value or error returned by a route function. Errors
+// that aren't the client's fault are logged.
+func writeResponse(request *restful.Request, response *restful.Response, val interface{}, err error) {
+	switch {
+	case err != nil:
+		httpErr := ws.ToHTTPError(err)
+		if httpErr.StatusCode() >= http.StatusInternalServerError {
+			RequestLog(request).Error("Request failed", "error", err)
+		}
+		httpErr.Write(response)
+	case val != nil:
+		err = response.WriteEntity(val)
+		if err != nil {
+			RequestLog(request).Error("response.WriteEntity failed", "error", err)
 		}
+	default:
+		// No error and no value to write - nothing to do.
 	}
 }
 
@@ -0,0 +1,31 @@
+package rest
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// RequestLog returns the logger for a request. It carries the request ID and
+// route set by the request ID filter, and the user and project once they are
+// known. Requests that didn't pass through the filter use the global log.
+func RequestLog(request *restful.Request) *app.Logger {
+	log, ok := request.Attribute("log").(*app.Logger)
+	if !ok {
+		log = app.Log
+	}
+
+	var ctx []interface{}
+	if user, ok := request.Attribute("user").(schema.User); ok {
+		ctx = append(ctx, "user", user.ID)
+	}
+
+	if project, ok := request.Attribute("project").(schema.Project); ok {
+		ctx = append(ctx, "project", project.ID)
+	}
+
+	if len(ctx) == 0 {
+		return log
+	}
+	return &app.Logger{Logger: log.New(ctx...)}
+}
@@ -0,0 +1,13 @@
+package ws
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ws Suite")
+}
@@ -1,5 +1,8 @@
 #!/usr/bin/env python
 
+import os
+import subprocess
+
 import rethinkdb as r
 
 
@@ -79,16 +82,6 @@ class Access(object):
         self.permissions = ""
 
 
-def create_table(table, conn, *args):
-    run(r.table_create(table), conn)
-    for index_name in args:
-        create_index(table, index_name, conn)
-
-
-def create_index(table, name, conn):
-    run(r.table(table).index_create(name), conn)
-
-
 def run(rql, conn):
     try:
         rql.run(conn)
@@ -106,25 +99,14 @@ def insert(item, table, conn):
     raise DatabaseError()
 
 
-def make_tables(conn):
+def make_tables():
+    # The tables and indexes come from the mcdb migrations, so that the test
+    # database has the same schema as a migrated server.
     print "Creating tables..."
-    create_table("projects", conn, "name", "owner")
-    create_table("project2datadir", conn, "datadir_id", "project_id")
-    create_table("datadirs", conn, "name", "project")
-    create_table("datafiles", conn, "name", "owner", "checksum",
-                 "usesid", "mediatype")
-    create_table("project2datafile", conn, "project_id", "datafile_id")
-    create_table("datadir2datafile", conn, "datadir_id", "datafile_id")
-    create_table("users", conn, "apikey")
-    create_table("access", conn, "user_id", "project_id")
-    create_table("uploads", conn, "owner", "project_id")
-    create_table("processes", conn)
-    create_table("samples", conn)
-    create_table("notes", conn)
-    create_table("note2item", conn)
-    create_table("sample2datafile", conn)
-    create_table("propertyset2property", conn)
-    create_table("tag2item", conn)
+    mcdb = os.environ.get("MCDB", "mcdb")
+    subprocess.check_call([mcdb, "--store", "rethinkdb",
+                           "--db-connection", "localhost:30815",
+                           "--db-name", "mctestdb", "migrate"])
     print "Done..."
 
 
@@ -245,8 +227,8 @@ def create_db():
 
 def main():
     create_db()
+    make_tables()
     conn = r.connect("localhost", 30815, db="mctestdb")
-    make_tables(conn)
     load_tables(conn)
 
 
@@ -0,0 +1,16 @@
+#!/bin/sh
+# Downloads the DataCite kernel 4 XSD, and the files it includes, into the
+# metadata package's testdata so that its tests validate the DataCite export
+# against the published schema. The tests skip that check without it.
+
+set -e
+
+base=https://schema.datacite.org/meta/kernel-4
+dest=$(dirname "$0")/../pkg/metadata/testdata/kernel-4
+
+mkdir -p "$dest/include"
+curl -fsS -o "$dest/metadata.xsd" "$base/metadata.xsd"
+for include in $(sed -n 's/.*schemaLocation="\(include\/[^"]*\)".*/\1/p' "$dest/metadata.xsd" | sort -u); do
+    curl -fsS -o "$dest/$include" "$base/$include"
+done
+echo "Downloaded the kernel 4 XSD to $dest"
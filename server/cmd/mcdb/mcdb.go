@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/db"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/migrate"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "mcdb"
+	app.Usage = "Create and migrate the materials commons database schema"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "store",
+			Value:  "rethinkdb",
+			Usage:  "Database to migrate: rethinkdb, postgres or sqlite",
+			EnvVar: "MCSTORED_STORE",
+		},
+
+		cli.StringFlag{
+			Name:   "db-connection",
+			Value:  "localhost:30815",
+			Usage:  "RethinkDB connection string",
+			EnvVar: "MCDB_CONNECTION",
+		},
+
+		cli.StringFlag{
+			Name:   "db-name",
+			Value:  "materialscommons",
+			Usage:  "RethinkDB database to migrate",
+			EnvVar: "MCDB_NAME",
+		},
+
+		cli.StringFlag{
+			Name:   "db-dsn",
+			Usage:  "Data source name for the postgres and sqlite stores",
+			EnvVar: "MCDB_DSN",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:   "status",
+			Usage:  "Show the schema version and the migrations that haven't been applied",
+			Action: statusCLI,
+		},
+
+		{
+			Name:  "migrate",
+			Usage: "Apply the migrations the database doesn't have yet",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run, n",
+					Usage: "Show the migrations and steps that would be applied without changing anything",
+				},
+			},
+			Action: migrateCLI,
+		},
+	}
+
+	app.Run(os.Args)
+}
+
+// pendingMigration is a migration that hasn't been applied to the database.
+type pendingMigration struct {
+	version     int
+	description string
+	steps       []string
+	apply       func() error
+}
+
+// A schema is the versioned schema of a database.
+type schema interface {
+	version() (int, error)
+	latest() int
+	pending() ([]pendingMigration, error)
+	close()
+}
+
+func statusCLI(c *cli.Context) {
+	s := openSchema(c)
+	defer s.close()
+
+	version, err := s.version()
+	exitOnError("Unable to read schema version", err)
+	fmt.Printf("Schema version %d, latest is %d\n", version, s.latest())
+
+	pending, err := s.pending()
+	exitOnError("Unable to read pending migrations", err)
+	for _, migration := range pending {
+		fmt.Printf("  pending %d: %s\n", migration.version, migration.description)
+	}
+}
+
+func migrateCLI(c *cli.Context) {
+	s := openSchema(c)
+	defer s.close()
+
+	pending, err := s.pending()
+	exitOnError("Unable to read pending migrations", err)
+	if len(pending) == 0 {
+		fmt.Printf("Schema is at the latest version (%d)\n", s.latest())
+		return
+	}
+
+	dryRun := c.Bool("dry-run")
+	for _, migration := range pending {
+		fmt.Printf("Migration %d: %s\n", migration.version, migration.description)
+		if dryRun {
+			for _, step := range migration.steps {
+				fmt.Println("  ", step)
+			}
+			continue
+		}
+
+		exitOnError(fmt.Sprintf("Migration %d failed", migration.version), migration.apply())
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run, %d migrations not applied\n", len(pending))
+	} else {
+		fmt.Printf("Applied %d migrations, schema is at version %d\n", len(pending), s.latest())
+	}
+}
+
+// openSchema opens the database named by the store flag.
+func openSchema(c *cli.Context) schema {
+	switch store := c.GlobalString("store"); store {
+	case "rethinkdb":
+		session, err := db.RSessionUsing(c.GlobalString("db-connection"), c.GlobalString("db-name"))
+		exitOnError("Unable to connect to RethinkDB", err)
+		return &rethinkSchema{
+			session:  session,
+			name:     c.GlobalString("db-name"),
+			migrator: migrate.New(session, c.GlobalString("db-name")),
+		}
+
+	case "postgres", "sqlite":
+		dsn := c.GlobalString("db-dsn")
+		if dsn == "" {
+			exitOnError("Unable to open database", fmt.Errorf("the %s store needs --db-dsn", store))
+		}
+
+		driver := store
+		if store == "sqlite" {
+			driver = "sqlite3"
+		}
+
+		sqlStore, err := dai.OpenSQLStore(driver, dsn)
+		exitOnError("Unable to open database", err)
+		return sqlSchema{sqlStore}
+
+	default:
+		exitOnError("Unable to open database", fmt.Errorf("unknown store %q", store))
+		return nil
+	}
+}
+
+// exitOnError prints msg and err and exits if err isn't nil.
+func exitOnError(msg string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
+		os.Exit(1)
+	}
+}
+
+// rethinkSchema is the schema of a RethinkDB database.
+type rethinkSchema struct {
+	session  *r.Session
+	name     string
+	migrator *migrate.Migrator
+}
+
+func (s *rethinkSchema) version() (int, error) {
+	return s.migrator.Version()
+}
+
+func (s *rethinkSchema) latest() int {
+	return migrate.Latest()
+}
+
+func (s *rethinkSchema) pending() ([]pendingMigration, error) {
+	migrations, err := s.migrator.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.migrator.DatabaseExists()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingMigration
+	for _, migration := range migrations {
+		migration := migration
+		var steps []string
+		if !exists && len(pending) == 0 {
+			// Applying the first migration creates the database.
+			steps = append(steps, "create database "+s.name)
+		}
+		for _, step := range migration.Steps {
+			steps = append(steps, step.String())
+		}
+		pending = append(pending, pendingMigration{
+			version:     migration.Version,
+			description: migration.Description,
+			steps:       steps,
+			apply:       func() error { return s.migrator.Apply(migration) },
+		})
+	}
+	return pending, nil
+}
+
+func (s *rethinkSchema) close() {
+	s.session.Close()
+}
+
+// sqlSchema is the schema of a PostgreSQL or SQLite database.
+type sqlSchema struct {
+	store *dai.SQLStore
+}
+
+func (s sqlSchema) version() (int, error) {
+	return s.store.SchemaVersion()
+}
+
+func (s sqlSchema) latest() int {
+	return dai.LatestSchemaVersion()
+}
+
+func (s sqlSchema) pending() ([]pendingMigration, error) {
+	migrations, err := s.store.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingMigration
+	for _, migration := range migrations {
+		pending = append(pending, pendingMigration{
+			version:     migration.Version,
+			description: migration.Description,
+			steps:       migration.Statements,
+			apply:       s.apply(migration),
+		})
+	}
+	return pending, nil
+}
+
+func (s sqlSchema) apply(migration dai.SQLMigration) func() error {
+	return func() error {
+		return s.store.ApplyMigration(migration)
+	}
+}
+
+func (s sqlSchema) close() {
+	s.store.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/codegangsta/cli"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/archive"
+)
+
+var bagCommand = cli.Command{
+	Name:  "bag",
+	Usage: "Package a dataset as a BagIt bag: bag DATASET_ID",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write the bag to, defaults to dataset-DATASET_ID with the format's extension",
+		},
+		cli.StringFlag{
+			Name:  "format, f",
+			Value: "tar.gz",
+			Usage: "Archive format for the bag, zip or tar.gz",
+		},
+	},
+	Action: bagCLI,
+}
+
+// bagCLI writes the dataset named on the command line as a BagIt bag. Files
+// keep their project paths under the bag's data directory.
+func bagCLI(c *cli.Context) {
+	datasetID := c.Args().First()
+	if datasetID == "" {
+		exitOnError("Unable to make bag", fmt.Errorf("no dataset id given"))
+	}
+
+	format, err := archive.ParseFormat(c.String("format"))
+	exitOnError("Unable to make bag", err)
+
+	name := "dataset-" + datasetID
+	output := c.String("output")
+	if output == "" {
+		output = name + format.Extension()
+	}
+
+	mcdirRoots(c)
+	store, _, closeStore := openStore(c)
+	defer closeStore()
+
+	dataset, err := store.Datasets().ByID(datasetID)
+	exitOnError("Unable to find dataset", err)
+
+	files, err := store.Datasets().Files(dataset.ID)
+	exitOnError("Unable to list dataset files", err)
+
+	f, err := os.Create(output)
+	exitOnError("Unable to create bag", err)
+
+	bag := archive.NewBag(f, format, name, archive.DatasetBagInfo(dataset))
+	for _, file := range files {
+		filePath := file.Name
+		if dir, err := store.Files().Directory(file.ID); err == nil {
+			filePath = path.Join(dir.Name, file.Name)
+		}
+
+		if err = bag.AddFile(filePath, app.MCDir.FilePath(file.FileID()), file.Checksum, file.MTime); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bag.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		exitOnError("Unable to write bag", err)
+	}
+
+	fmt.Printf("Wrote %d files of dataset %s to %s\n", len(files)-len(bag.Skipped()), dataset.Title, output)
+	for _, s := range bag.Skipped() {
+		fmt.Printf("    skipped %s: %s\n", s.Name, s.Reason)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/materials-commons/mcstore/pkg/bundle"
+)
+
+var exportCommand = cli.Command{
+	Name:  "export",
+	Usage: "Export a project, its files and its records to a bundle: export PROJECT_ID",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write the bundle to, defaults to PROJECT_ID.tar.gz",
+		},
+		cli.BoolFlag{
+			Name:  "files-only",
+			Usage: "Export only the directories and files when the store has no samples, processes, notes or tags",
+		},
+	},
+	Action: exportCLI,
+}
+
+// exportCLI writes the project named on the command line to a bundle.
+// Samples, processes, notes and tags are only exported from RethinkDB, so
+// exporting from another store needs --files-only.
+func exportCLI(c *cli.Context) {
+	projectID := c.Args().First()
+	if projectID == "" {
+		exitOnError("Unable to export", fmt.Errorf("no project id given"))
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = projectID + ".tar.gz"
+	}
+
+	mcdirRoots(c)
+	store, session, closeStore := openStore(c)
+	defer closeStore()
+
+	var records bundle.Records
+	switch {
+	case session != nil:
+		records = bundle.NewRRecords(session)
+	case !c.Bool("files-only"):
+		exitOnError("Unable to export", fmt.Errorf("the %s store can't export samples, processes, notes and tags, use --files-only to export without them", c.GlobalString("store")))
+	}
+
+	f, err := os.Create(output)
+	exitOnError("Unable to create bundle", err)
+
+	manifest, err := bundle.NewExporter(store, records).Export(projectID, f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(output)
+		exitOnError("Export failed", err)
+	}
+
+	fmt.Printf("Exported project %s to %s\n", manifest.Project.Name, output)
+	fmt.Printf("%d directories, %d files, %d blobs\n", manifest.Directories, manifest.Files, manifest.Blobs)
+	for table, count := range manifest.Records {
+		fmt.Printf("%-24s %6d records\n", table, count)
+	}
+}
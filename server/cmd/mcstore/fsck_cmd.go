@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/materials-commons/mcstore/pkg/fsck"
+)
+
+var fsckCommand = cli.Command{
+	Name:  "fsck",
+	Usage: "Check that the database and the files under MCDIR agree",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "repair, r",
+			Usage: "Repair the problems that can be fixed without losing data, stop mcstored first",
+		},
+		cli.BoolFlag{
+			Name:  "verbose, v",
+			Usage: "List each problem, not just the count for each category",
+		},
+	},
+	Action: fsckCLI,
+}
+
+// fsckCLI runs the check and prints the findings by category. It exits with
+// status 1 if any problems weren't repaired. Repairs are meant for a quiesced
+// server: uploads and deletes that happen during the check can be taken for
+// problems, and repairing those would undo them.
+func fsckCLI(c *cli.Context) {
+	roots := mcdirRoots(c)
+	store, _, closeStore := openStore(c)
+	defer closeStore()
+
+	report, err := fsck.New(store, roots, c.Bool("repair")).Run()
+	exitOnError("Check failed", err)
+
+	fmt.Printf("Checked %d files and %d blobs\n", report.Files, report.Blobs)
+	if len(report.Findings) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+
+	unrepaired := 0
+	byCategory := report.ByCategory()
+	for _, category := range report.Categories() {
+		findings := byCategory[category]
+		repaired := 0
+		for _, finding := range findings {
+			if finding.Repaired {
+				repaired++
+			}
+		}
+		unrepaired += len(findings) - repaired
+		fmt.Printf("%-24s %6d found %6d repaired\n", category, len(findings), repaired)
+
+		if c.Bool("verbose") {
+			for _, finding := range findings {
+				printFinding(finding)
+			}
+		}
+	}
+
+	if unrepaired != 0 {
+		os.Exit(1)
+	}
+}
+
+// printFinding prints a single finding.
+func printFinding(finding fsck.Finding) {
+	status := ""
+	switch {
+	case finding.Err != nil:
+		status = fmt.Sprintf(" (repair failed: %s)", finding.Err)
+	case finding.Repaired:
+		status = " (repaired)"
+	}
+
+	if finding.Detail != "" {
+		fmt.Printf("    %s: %s%s\n", finding.ID, finding.Detail, status)
+	} else {
+		fmt.Printf("    %s%s\n", finding.ID, status)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/materials-commons/mcstore/pkg/bundle"
+)
+
+var importCommand = cli.Command{
+	Name:  "import",
+	Usage: "Import a bundle as a new project: import BUNDLE",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "name, n",
+			Usage: "Name of the new project, defaults to the exported project's name",
+		},
+		cli.StringFlag{
+			Name:  "owner",
+			Usage: "User that owns everything not listed with --map-owner, defaults to the exported owners",
+		},
+		cli.StringSliceFlag{
+			Name:  "map-owner",
+			Value: &cli.StringSlice{},
+			Usage: "Map an exported owner to a user of this instance: OLD=NEW",
+		},
+		cli.BoolFlag{
+			Name:  "skip-records",
+			Usage: "Leave out the sample, process, note and tag records when the store can't hold them",
+		},
+	},
+	Action: importCLI,
+}
+
+// importCLI loads the bundle named on the command line into the store.
+func importCLI(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		exitOnError("Unable to import", fmt.Errorf("no bundle given"))
+	}
+
+	options := bundle.ImportOptions{
+		Name:        c.String("name"),
+		Owner:       c.String("owner"),
+		Owners:      make(map[string]string),
+		SkipRecords: c.Bool("skip-records"),
+	}
+	for _, mapping := range c.StringSlice("map-owner") {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			exitOnError("Unable to import", fmt.Errorf("bad owner mapping %q, expected OLD=NEW", mapping))
+		}
+		options.Owners[parts[0]] = parts[1]
+	}
+
+	mcdirRoots(c)
+	store, session, closeStore := openStore(c)
+	defer closeStore()
+
+	var records bundle.Records
+	if session != nil {
+		records = bundle.NewRRecords(session)
+	}
+
+	f, err := os.Open(path)
+	exitOnError("Unable to open bundle", err)
+	defer f.Close()
+
+	result, err := bundle.NewImporter(store, records).Import(f, options)
+	if err != nil && result != nil {
+		fmt.Fprintf(os.Stderr, "Project %s (%s) was partly imported\n", result.Project.Name, result.Project.ID)
+	}
+	exitOnError("Import failed", err)
+
+	fmt.Printf("Imported project %s (%s)\n", result.Project.Name, result.Project.ID)
+	fmt.Printf("%d directories, %d files, %d blobs copied, %d files sharing stored blobs\n",
+		result.Directories, result.Files, result.Blobs, result.Shared)
+	for table, count := range result.Records {
+		fmt.Printf("%-24s %6d records\n", table, count)
+	}
+	if result.Skipped != 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: skipped %d sample, process, note and tag records, which only the rethinkdb store holds\n", result.Skipped)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/db"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "mcstore"
+	app.Usage = "Maintain the materials commons file store"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "store",
+			Value:  "rethinkdb",
+			Usage:  "Database to use: rethinkdb, postgres or sqlite",
+			EnvVar: "MCSTORED_STORE",
+		},
+
+		cli.StringFlag{
+			Name:   "db-connection",
+			Value:  "localhost:30815",
+			Usage:  "RethinkDB connection string",
+			EnvVar: "MCDB_CONNECTION",
+		},
+
+		cli.StringFlag{
+			Name:   "db-name",
+			Value:  "materialscommons",
+			Usage:  "RethinkDB database to use",
+			EnvVar: "MCDB_NAME",
+		},
+
+		cli.StringFlag{
+			Name:   "db-dsn",
+			Usage:  "Data source name for the postgres and sqlite stores",
+			EnvVar: "MCDB_DSN",
+		},
+
+		cli.StringFlag{
+			Name:   "mcdir",
+			Usage:  "Colon separated list of file storage directories",
+			EnvVar: "MCDIR",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		fsckCommand,
+		exportCommand,
+		importCommand,
+		bagCommand,
+	}
+
+	app.Run(os.Args)
+}
+
+// openStore opens the store named by the store flag. The RethinkDB session is
+// returned for the tables only RethinkDB has, and is nil for other stores. The
+// returned function closes the store.
+func openStore(c *cli.Context) (dai.Store, *r.Session, func()) {
+	switch store := c.GlobalString("store"); store {
+	case "rethinkdb":
+		session, err := db.RSessionUsing(c.GlobalString("db-connection"), c.GlobalString("db-name"))
+		exitOnError("Unable to connect to RethinkDB", err)
+		return dai.NewRStore(session), session, func() { session.Close() }
+
+	case "postgres", "sqlite":
+		dsn := c.GlobalString("db-dsn")
+		if dsn == "" {
+			exitOnError("Unable to open database", fmt.Errorf("the %s store needs --db-dsn", store))
+		}
+
+		driver := store
+		if store == "sqlite" {
+			driver = "sqlite3"
+		}
+
+		sqlStore, err := dai.OpenSQLStore(driver, dsn)
+		exitOnError("Unable to open database", err)
+		return sqlStore, nil, func() { sqlStore.Close() }
+
+	default:
+		exitOnError("Unable to open database", fmt.Errorf("unknown store %q", store))
+		return nil, nil, nil
+	}
+}
+
+// mcdirRoots returns the MCDIR roots from the mcdir flag. It also sets MCDIR
+// in the config for the code that looks up blobs through app.MCDir.
+func mcdirRoots(c *cli.Context) []string {
+	mcdir := c.GlobalString("mcdir")
+	if mcdir == "" {
+		exitOnError("Unable to find file storage", fmt.Errorf("--mcdir or MCDIR must be set"))
+	}
+	config.Set("MCDIR", mcdir)
+	return strings.Split(mcdir, ":")
+}
+
+// exitOnError prints msg and err and exits if err isn't nil.
+func exitOnError(msg string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
+		os.Exit(1)
+	}
+}
@@ -36,6 +36,12 @@ func (c *apikeyCache) getUser(key string) (bool, schema.User) {
 		user = u
 		found = true
 	})
+
+	if found {
+		apikeyCacheLookups.Inc("hit")
+	} else {
+		apikeyCacheLookups.Inc("miss")
+	}
 	return found, user
 }
 
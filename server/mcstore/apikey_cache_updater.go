@@ -12,7 +12,13 @@ func updateKeyCacheOnChange(session *r.Session, keycache *apikeyCache) {
 		OldUserValue schema.User `gorethink:"old_val"`
 	}
 
-	users, _ := r.Table("users").Changes().Run(session)
+	users, err := r.Table("users").Changes().Run(session)
+	if err != nil {
+		app.Log.Errorf("Unable to follow changes to users: %s", err)
+		return
+	}
+	defer users.Close()
+
 	for users.Next(&c) {
 		switch {
 		case c.OldUserValue.ID == "":
@@ -26,4 +32,8 @@ func updateKeyCacheOnChange(session *r.Session, keycache *apikeyCache) {
 			keycache.resetKey(c.OldUserValue.APIKey, c.NewUserValue.APIKey, &c.NewUserValue)
 		}
 	}
+
+	if !monitors.isStopping() {
+		app.Log.Errorf("Stopped following changes to users: %v", users.Err())
+	}
 }
@@ -1,12 +1,13 @@
 package mcstore
 
 import (
-	"net/http"
+	"time"
 
-	r "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/ws"
 )
 
 // apikeyFilter implements a filter for checking the apikey
@@ -25,16 +26,19 @@ func newAPIKeyFilter(keycache *apikeyCache) *apikeyFilter {
 // Filter implements the Filter interface for apikey lookup. It checks if an apikey is
 // valid. If the apikey is found it sets the "user" attribute to the user structure. If
 // the apikey is invalid then the filter doesn't pass the request on, and instead returns
-// an http.StatusUnauthorized.
+// an http.StatusUnauthorized. Requests that pass an api token in the Authorization
+// header are checked by the token instead of an apikey.
 func (f *apikeyFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
-	if apikey := request.Request.URL.Query().Get("apikey"); apikey == "" {
+	if token := bearerToken(request.Request); token != "" {
+		f.filterToken(token, request, response, chain)
+	} else if apikey := request.Request.URL.Query().Get("apikey"); apikey == "" {
 		// No or blank apikey passed in
-		response.WriteErrorString(http.StatusUnauthorized, "Not authorized")
+		ws.WriteError(app.ErrNoAccess, response)
 	} else {
-		session := request.Attribute("session").(*r.Session)
-		rusers := dai.NewRUsers(session)
+		store := request.Attribute("store").(dai.Store)
+		rusers := store.Users()
 		if user := f.getUser(apikey, rusers); user == nil {
-			response.WriteErrorString(http.StatusUnauthorized, "Not authorized")
+			ws.WriteError(app.ErrNoAccess, response)
 		} else {
 			request.SetAttribute("user", *user)
 			chain.ProcessFilter(request, response)
@@ -60,3 +64,29 @@ func (f *apikeyFilter) loadUserFromDB(apikey string, users dai.Users) *schema.Us
 		return user
 	}
 }
+
+// filterToken checks an api token passed as a bearer token. The token must be usable
+// and have the scope needed for the request. On success it sets the "user" attribute
+// to the token owner and the "apitoken" attribute to the token.
+func (f *apikeyFilter) filterToken(token string, request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	store := request.Attribute("store").(dai.Store)
+	tokens := store.APITokens()
+	apitoken, err := tokens.ByHash(hashAPIToken(token))
+	switch {
+	case err != nil:
+		ws.WriteError(app.ErrNoAccess, response)
+	case !apitoken.Usable(time.Now()):
+		ws.WriteError(app.Errorf(app.ErrNoAccess, "Token expired or revoked"), response)
+	case !apitoken.HasScope(requiredScope(request.Request)):
+		ws.WriteError(app.Errorf(app.ErrForbidden, "Token scope doesn't allow request"), response)
+	default:
+		rusers := store.Users()
+		if user, err := rusers.ByID(apitoken.Owner); err != nil {
+			ws.WriteError(app.ErrNoAccess, response)
+		} else {
+			request.SetAttribute("user", *user)
+			request.SetAttribute("apitoken", *apitoken)
+			chain.ProcessFilter(request, response)
+		}
+	}
+}
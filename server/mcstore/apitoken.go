@@ -0,0 +1,99 @@
+package mcstore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// apiTokenPrefix is prepended to every generated token. It makes tokens
+// easy to recognize when they end up in logs or config files.
+const apiTokenPrefix = "mct_"
+
+// generateAPIToken creates a new random token. The returned string is the
+// only time the token is seen in the clear, the server only stores its hash.
+func generateAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashAPIToken returns the hash that is stored for a token.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token passed in the Authorization header. It
+// returns the empty string if there is no bearer token.
+func bearerToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || strings.ToLower(auth[:len(prefix)]) != prefix {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// A scopedRoute maps a route to the token scope needed to call it. A * in
+// the path matches any single path element.
+type scopedRoute struct {
+	method string
+	path   string
+	scope  string
+}
+
+// scopedRoutes are the routes that a token without the write scope can call.
+// Creating directories is part of uploading, and searches and archives only
+// read data even though they are POSTs.
+var scopedRoutes = []scopedRoute{
+	{"POST", "/upload", schema.TokenScopeUpload},
+	{"POST", "/upload/chunk", schema.TokenScopeUpload},
+	{"DELETE", "/upload/*", schema.TokenScopeUpload},
+	{"GET", "/upload/*", schema.TokenScopeUpload},
+	{"POST", "/project2/directory", schema.TokenScopeUpload},
+	{"POST", "/project2/archive", schema.TokenScopeRead},
+	{"GET", "/project2/*/usage", schema.TokenScopeRead},
+	{"POST", "/search/project/*", schema.TokenScopeRead},
+	{"POST", "/search/project/*/files", schema.TokenScopeRead},
+	{"GET", "/datasets/*", schema.TokenScopeRead},
+	{"GET", "/datasets/*/metadata", schema.TokenScopeRead},
+	{"GET", "/audit/project/*", schema.TokenScopeRead},
+	{"GET", "/trash/project/*", schema.TokenScopeRead},
+	{"GET", "/tokens", schema.TokenScopeRead},
+	{"GET", "/datafiles/static/*", schema.TokenScopeRead},
+}
+
+// requiredScope determines the token scope needed to perform a request. A HEAD
+// needs the same scope as a GET, and routes that aren't in scopedRoutes need
+// the write scope.
+func requiredScope(req *http.Request) string {
+	method := req.Method
+	if method == "HEAD" {
+		method = "GET"
+	}
+
+	elements := pathElements(req.URL.Path)
+	for _, route := range scopedRoutes {
+		if route.method == method && pathMatches(pathElements(route.path), elements) {
+			return route.scope
+		}
+	}
+	return schema.TokenScopeWrite
+}
+
+// requestToken returns the APIToken that authenticated the request. It
+// returns nil if the request was authenticated by apikey.
+func requestToken(request *restful.Request) *schema.APIToken {
+	if token, ok := request.Attribute("apitoken").(schema.APIToken); ok {
+		return &token
+	}
+	return nil
+}
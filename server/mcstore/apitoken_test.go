@@ -0,0 +1,185 @@
+package mcstore
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APIToken", func() {
+	Describe("generateAPIToken", func() {
+		It("Should generate unique prefixed tokens", func() {
+			t1, err := generateAPIToken()
+			Expect(err).To(BeNil())
+			t2, err := generateAPIToken()
+			Expect(err).To(BeNil())
+			Expect(t1).To(HavePrefix(apiTokenPrefix))
+			Expect(t1).NotTo(Equal(t2))
+		})
+
+		It("Should hash the same token to the same value", func() {
+			Expect(hashAPIToken("abc")).To(Equal(hashAPIToken("abc")))
+			Expect(hashAPIToken("abc")).NotTo(Equal(hashAPIToken("abd")))
+		})
+	})
+
+	Describe("bearerToken", func() {
+		It("Should return the token from the Authorization header", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/project2", nil)
+			req.Header.Set("Authorization", "Bearer mct_abc")
+			Expect(bearerToken(req)).To(Equal("mct_abc"))
+		})
+
+		It("Should ignore the case of the bearer keyword", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/project2", nil)
+			req.Header.Set("Authorization", "bearer mct_abc")
+			Expect(bearerToken(req)).To(Equal("mct_abc"))
+		})
+
+		It("Should return the empty string when there is no bearer token", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/project2", nil)
+			Expect(bearerToken(req)).To(Equal(""))
+			req.Header.Set("Authorization", "Basic abc")
+			Expect(bearerToken(req)).To(Equal(""))
+		})
+	})
+
+	Describe("requiredScope", func() {
+		scope := func(method, path string) string {
+			req, _ := http.NewRequest(method, "http://localhost"+path, nil)
+			return requiredScope(req)
+		}
+
+		It("Should require upload scope for uploads", func() {
+			Expect(scope("POST", "/upload/chunk")).To(Equal(schema.TokenScopeUpload))
+			Expect(scope("DELETE", "/upload/abc")).To(Equal(schema.TokenScopeUpload))
+		})
+
+		It("Should require upload scope to create directories", func() {
+			Expect(scope("POST", "/project2/directory")).To(Equal(schema.TokenScopeUpload))
+		})
+
+		It("Should require read scope for reads, including POSTs that only read", func() {
+			Expect(scope("GET", "/datasets/abc")).To(Equal(schema.TokenScopeRead))
+			Expect(scope("HEAD", "/datafiles/static/abc")).To(Equal(schema.TokenScopeRead))
+			Expect(scope("POST", "/project2/archive")).To(Equal(schema.TokenScopeRead))
+			Expect(scope("POST", "/search/project/abc/files")).To(Equal(schema.TokenScopeRead))
+		})
+
+		It("Should require write scope for other requests", func() {
+			Expect(scope("POST", "/project2")).To(Equal(schema.TokenScopeWrite))
+			Expect(scope("PUT", "/datasets/abc/publish")).To(Equal(schema.TokenScopeWrite))
+			Expect(scope("POST", "/trash/project/abc")).To(Equal(schema.TokenScopeWrite))
+		})
+
+		It("Should require write scope for routes it doesn't know", func() {
+			Expect(scope("GET", "/no/such/route")).To(Equal(schema.TokenScopeWrite))
+		})
+	})
+
+	Describe("checkUploadProject", func() {
+		var (
+			store    *dai.MemStore
+			uploadID string
+		)
+
+		BeforeEach(func() {
+			store = dai.NewMemStore()
+			upload, err := store.Uploads().Insert(&schema.Upload{Owner: "test@mc.org", ProjectID: "test"})
+			Expect(err).To(BeNil())
+			uploadID = upload.ID
+		})
+
+		request := func(projects ...string) *restful.Request {
+			req, _ := http.NewRequest("POST", "http://localhost/upload/chunk", nil)
+			request := restful.NewRequest(req)
+			if projects != nil {
+				token := schema.NewAPIToken("instrument", "test@mc.org", "hash", time.Now().Add(time.Hour))
+				token.Projects = projects
+				request.SetAttribute("apitoken", token)
+			}
+			return request
+		}
+
+		It("Should allow apikey requests and tokens for the upload's project", func() {
+			Expect(checkUploadProject(request(), store, uploadID)).To(BeNil())
+			Expect(checkUploadProject(request("test"), store, uploadID)).To(BeNil())
+		})
+
+		It("Should reject tokens limited to other projects", func() {
+			Expect(checkUploadProject(request("test2"), store, uploadID)).To(Equal(app.ErrNoAccess))
+		})
+
+		It("Should fail for unknown uploads", func() {
+			Expect(app.Is(checkUploadProject(request(), store, "missing"), app.ErrNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("schema.APIToken", func() {
+		var token schema.APIToken
+
+		BeforeEach(func() {
+			token = schema.NewAPIToken("instrument", "test@mc.org", "hash", time.Now().Add(time.Hour))
+		})
+
+		It("Should allow all projects when no projects are listed", func() {
+			Expect(token.AllowsProject("test")).To(BeTrue())
+		})
+
+		It("Should only allow listed projects", func() {
+			token.Projects = []string{"test"}
+			Expect(token.AllowsProject("test")).To(BeTrue())
+			Expect(token.AllowsProject("test2")).To(BeFalse())
+		})
+
+		It("Should treat the write scope as granting all scopes", func() {
+			token.Scopes = []string{schema.TokenScopeWrite}
+			Expect(token.HasScope(schema.TokenScopeRead)).To(BeTrue())
+			Expect(token.HasScope(schema.TokenScopeUpload)).To(BeTrue())
+		})
+
+		It("Should not grant scopes an upload only token wasn't given", func() {
+			token.Scopes = []string{schema.TokenScopeUpload}
+			Expect(token.HasScope(schema.TokenScopeUpload)).To(BeTrue())
+			Expect(token.HasScope(schema.TokenScopeRead)).To(BeFalse())
+		})
+
+		It("Should not be usable when revoked or expired", func() {
+			Expect(token.Usable(time.Now())).To(BeTrue())
+			token.Revoked = true
+			Expect(token.Usable(time.Now())).To(BeFalse())
+			token.Revoked = false
+			Expect(token.Usable(time.Now().Add(2 * time.Hour))).To(BeFalse())
+		})
+	})
+
+	Describe("validateTokenRequest", func() {
+		It("Should default the expiration", func() {
+			req := mcstoreapi.CreateTokenRequest{Name: "t", Scopes: []string{"read"}}
+			Expect(validateTokenRequest(&req)).To(BeNil())
+			Expect(req.ExpiresInDays).To(Equal(defaultTokenExpiresInDays))
+		})
+
+		It("Should reject an expiration that is too long", func() {
+			req := mcstoreapi.CreateTokenRequest{Name: "t", Scopes: []string{"read"}, ExpiresInDays: maxTokenExpiresInDays + 1}
+			Expect(validateTokenRequest(&req)).NotTo(BeNil())
+		})
+
+		It("Should reject unknown scopes", func() {
+			req := mcstoreapi.CreateTokenRequest{Name: "t", Scopes: []string{"admin"}}
+			Expect(validateTokenRequest(&req)).NotTo(BeNil())
+		})
+
+		It("Should reject a request with no name", func() {
+			req := mcstoreapi.CreateTokenRequest{Scopes: []string{"read"}}
+			Expect(validateTokenRequest(&req)).NotTo(BeNil())
+		})
+	})
+})
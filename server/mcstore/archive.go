@@ -0,0 +1,175 @@
+package mcstore
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/archive"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// An archiveEntry is a file to add to an archive and its path in the archive.
+type archiveEntry struct {
+	path string
+	file schema.File
+}
+
+// An archiveBuilder collects the files to put in an archive. Entry paths
+// follow the project directory structure. Files that can't be included are
+// recorded so they are listed in the archive's manifest of skipped files.
+type archiveBuilder struct {
+	files    dai.Files
+	dirs     dai.Dirs
+	datasets dai.Datasets
+	entries  []archiveEntry
+	skipped  []archive.Skipped
+	seen     map[string]bool
+}
+
+// newArchiveBuilder creates a new archiveBuilder.
+func newArchiveBuilder(files dai.Files, dirs dai.Dirs, datasets dai.Datasets) *archiveBuilder {
+	return &archiveBuilder{
+		files:    files,
+		dirs:     dirs,
+		datasets: datasets,
+		seen:     make(map[string]bool),
+	}
+}
+
+// addFiles adds files by their id. The allowed function is called for each file,
+// files that it rejects are skipped. Skipped files are listed by id so that the
+// manifest doesn't reveal the names of files the user can't access.
+func (b *archiveBuilder) addFiles(fileIDs []string, allowed func(fileID string) bool) {
+	for _, fileID := range fileIDs {
+		if !allowed(fileID) {
+			b.skip(fileID, "no access")
+			continue
+		}
+
+		file, err := b.files.ByID(fileID)
+		if err != nil {
+			b.skip(fileID, "not found")
+			continue
+		}
+
+		b.add(b.filePath(file), *file)
+	}
+}
+
+// addDirectory adds the current version of every file in dir and its
+// subdirectories.
+func (b *archiveBuilder) addDirectory(dir *schema.Directory) error {
+	files, err := b.dirs.Files(dir.ID)
+	if err != nil && err != app.ErrNotFound {
+		return err
+	}
+
+	for _, file := range files {
+		if file.Current {
+			b.add(path.Join(dir.Name, file.Name), file)
+		}
+	}
+
+	children, err := b.dirs.Children(dir.ID)
+	if err != nil && err != app.ErrNotFound {
+		return err
+	}
+
+	for i := range children {
+		if err := b.addDirectory(&children[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDataset adds all the files in a dataset. Files that were moved to the
+// trash after they were added to an unpublished dataset are skipped.
+// Published datasets keep every file.
+func (b *archiveBuilder) addDataset(dataset *schema.Dataset) error {
+	files, err := b.datasets.Files(dataset.ID)
+	if err != nil && err != app.ErrNotFound {
+		return err
+	}
+
+	for i := range files {
+		dir, err := b.files.Directory(files[i].ID)
+		if err == nil && dir.InTrash() && !dataset.Published {
+			b.skip(files[i].Name, "in trash")
+			continue
+		}
+		b.add(b.filePath(&files[i]), files[i])
+	}
+
+	return nil
+}
+
+// add adds a file to the archive. Each file is only added once.
+func (b *archiveBuilder) add(entryPath string, file schema.File) {
+	if b.seen[file.ID] {
+		return
+	}
+	b.seen[file.ID] = true
+	b.entries = append(b.entries, archiveEntry{path: entryPath, file: file})
+}
+
+// skip records a file that won't be added to the archive.
+func (b *archiveBuilder) skip(name, reason string) {
+	b.skipped = append(b.skipped, archive.Skipped{Name: name, Reason: reason})
+}
+
+// filePath returns the path of a file in its project. If the directory can't
+// be found the file is put at the top of the archive.
+func (b *archiveBuilder) filePath(file *schema.File) string {
+	dir, err := b.files.Directory(file.ID)
+	if err != nil {
+		return file.Name
+	}
+	return path.Join(dir.Name, file.Name)
+}
+
+// writeArchive streams the archive to the response. Once the archive starts
+// streaming an error status can no longer be sent, so the returned error can
+// only be logged.
+func (b *archiveBuilder) writeArchive(response *restful.Response, name string, format archive.Format) error {
+	response.AddHeader("Content-Type", format.ContentType())
+	response.AddHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, name, format.Extension()))
+
+	w := archive.NewWriter(response, format)
+	for _, s := range b.skipped {
+		w.Skip(s.Name, s.Reason)
+	}
+
+	for _, entry := range b.entries {
+		if err := w.AddFile(entry.path, app.MCDir.FilePath(entry.file.FileID()), entry.file.MTime); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// writeBag streams the files as a BagIt bag to the response. As with
+// writeArchive the returned error can only be logged.
+func (b *archiveBuilder) writeBag(response *restful.Response, name string, format archive.Format, info []archive.BagInfoField) error {
+	response.AddHeader("Content-Type", format.ContentType())
+	response.AddHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, name, format.Extension()))
+
+	bag := archive.NewBag(response, format, name, info)
+	for _, s := range b.skipped {
+		bag.Skip(s.Name, s.Reason)
+	}
+
+	for _, entry := range b.entries {
+		err := bag.AddFile(entry.path, app.MCDir.FilePath(entry.file.FileID()), entry.file.Checksum, entry.file.MTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bag.Close()
+}
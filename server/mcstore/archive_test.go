@@ -0,0 +1,62 @@
+package mcstore
+
+import (
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai/mocks"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ArchiveBuilder", func() {
+	var (
+		mfiles   *mocks.Files
+		mdirs    *mocks.Dirs
+		builder  *archiveBuilder
+		allowAll = func(fileID string) bool { return true }
+	)
+
+	BeforeEach(func() {
+		mfiles = mocks.NewMFiles()
+		mdirs = mocks.NewMDirs()
+		builder = newArchiveBuilder(mfiles, mdirs, mocks.NewMDatasets())
+	})
+
+	It("Should add current files in a directory and its subdirectories using project paths", func() {
+		dir := schema.Directory{ID: "dir1", Name: "proj/a"}
+		mdirs.On("Files", "dir1").Return([]schema.File{
+			{ID: "f1", Name: "one.txt", Current: true},
+			{ID: "f1-old", Name: "one.txt", Current: false},
+		}, nil)
+		mdirs.On("Children", "dir1").Return([]schema.Directory{{ID: "dir2", Name: "proj/a/b"}}, nil)
+		mdirs.On("Files", "dir2").Return([]schema.File{{ID: "f2", Name: "two.txt", Current: true}}, nil)
+		mdirs.On("Children", "dir2").Return([]schema.Directory{}, app.ErrNotFound)
+
+		Expect(builder.addDirectory(&dir)).To(BeNil())
+		Expect(builder.entries).To(HaveLen(2))
+		Expect(builder.entries[0].path).To(Equal("proj/a/one.txt"))
+		Expect(builder.entries[1].path).To(Equal("proj/a/b/two.txt"))
+	})
+
+	It("Should skip files that aren't allowed or don't exist", func() {
+		var nilFile *schema.File
+		mfiles.On("ByID", "f1").Return(&schema.File{ID: "f1", Name: "one.txt"}, nil)
+		mfiles.On("ByID", "f2").Return(nilFile, app.ErrNotFound)
+		mfiles.On("Directory", "f1").Return(&schema.Directory{Name: "proj/a"}, nil)
+
+		builder.addFiles([]string{"f1", "f2", "f3"}, func(fileID string) bool { return fileID != "f3" })
+		Expect(builder.entries).To(HaveLen(1))
+		Expect(builder.entries[0].path).To(Equal("proj/a/one.txt"))
+		Expect(builder.skipped).To(HaveLen(2))
+		Expect(builder.skipped[0].Name).To(Equal("f2"))
+		Expect(builder.skipped[1].Name).To(Equal("f3"))
+		Expect(builder.skipped[1].Reason).To(Equal("no access"))
+	})
+
+	It("Should only add a file once", func() {
+		mfiles.On("ByID", "f1").Return(&schema.File{ID: "f1", Name: "one.txt"}, nil)
+		mfiles.On("Directory", "f1").Return(&schema.Directory{Name: "proj"}, nil)
+		builder.addFiles([]string{"f1", "f1"}, allowAll)
+		Expect(builder.entries).To(HaveLen(1))
+	})
+})
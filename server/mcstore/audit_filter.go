@@ -0,0 +1,121 @@
+package mcstore
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/audit"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+)
+
+// An auditedRoute maps a route to the action recorded when it is called. A *
+// in the path matches any single path element.
+type auditedRoute struct {
+	method string
+	path   string
+	action string
+}
+
+// auditedRoutes are the routes that are recorded in the audit log. Uploaded
+// chunks aren't recorded here, the finished upload is recorded instead.
+var auditedRoutes = []auditedRoute{
+	{"POST", "/upload", audit.CreateUpload},
+	{"DELETE", "/upload/*", audit.DeleteUpload},
+	{"POST", "/project2", audit.CreateProject},
+	{"POST", "/project2/archive", audit.DownloadArchive},
+	{"POST", "/shares", audit.Share},
+	{"POST", "/tokens", audit.CreateToken},
+	{"DELETE", "/tokens/*", audit.RevokeToken},
+	{"POST", "/datasets", audit.CreateDataset},
+	{"PUT", "/datasets/*/publish", audit.PublishDataset},
+	{"PUT", "/datasets/*/unpublish", audit.UnpublishDataset},
+	{"POST", "/trash/project/*", audit.Trash},
+	{"POST", "/trash/project/*/*/restore", audit.RestoreTrash},
+	{"DELETE", "/trash/project/*/*", audit.PurgeTrash},
+}
+
+// auditAction returns the action to record for a request, or an empty string
+// if the request isn't audited.
+func auditAction(method, path string) string {
+	elements := pathElements(path)
+	for _, route := range auditedRoutes {
+		if route.method == method && pathMatches(pathElements(route.path), elements) {
+			return route.action
+		}
+	}
+	return ""
+}
+
+// pathElements splits a path into its elements.
+func pathElements(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// pathMatches compares the elements of a path against a pattern.
+func pathMatches(pattern, elements []string) bool {
+	if len(pattern) != len(elements) {
+		return false
+	}
+
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != elements[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// auditFilter records requests that change data, or that download it in bulk,
+// in the audit log. It must come after the apikeyFilter so the user is known.
+type auditFilter struct{}
+
+// Filter records the request in the audit log once it has been handled.
+func (f *auditFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	action := auditAction(request.Request.Method, request.Request.URL.Path)
+	chain.ProcessFilter(request, response)
+	if action == "" {
+		return
+	}
+
+	user, _ := request.Attribute("user").(schema.User)
+	store := request.Attribute("store").(dai.Store)
+
+	event := schema.NewAuditEvent(user.ID, action, clientAddress(request.Request))
+	event.ProjectID, event.FileID = auditTarget(request, store.Datasets())
+	event.Detail = request.Request.Method + " " + request.Request.URL.Path
+	event.Result = audit.Success
+	if response.StatusCode() >= http.StatusBadRequest {
+		event.Result = audit.Failure
+	}
+
+	recorder := audit.NewRecorder(store.AuditEvents(), store.Files())
+	recorder.Record(event)
+}
+
+// auditTarget determines the project and file a request acted on. They come from
+// the project access check, the path parameters, or the request body.
+func auditTarget(request *restful.Request, datasets dai.Datasets) (projectID, fileID string) {
+	var body struct {
+		ProjectID string `json:"project_id"`
+		FileID    string `json:"file_id"`
+	}
+
+	if strings.Contains(request.Request.Header.Get("Content-Type"), restful.MIME_JSON) {
+		request.ReadEntity(&body)
+	}
+
+	projectID, fileID = body.ProjectID, body.FileID
+	if project, ok := request.Attribute("project").(schema.Project); ok {
+		projectID = project.ID
+	} else if id := request.PathParameter("project"); id != "" {
+		projectID = id
+	} else if id := request.PathParameter("dataset"); id != "" && projectID == "" {
+		if dataset, err := datasets.ByID(id); err == nil {
+			projectID = dataset.ProjectID
+		}
+	}
+
+	return projectID, fileID
+}
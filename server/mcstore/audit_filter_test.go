@@ -0,0 +1,69 @@
+package mcstore
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuditFilter", func() {
+	Describe("auditAction Method Tests", func() {
+		It("Should find the action for audited routes", func() {
+			Expect(auditAction("POST", "/shares")).To(Equal("share"))
+			Expect(auditAction("POST", "/project2/archive")).To(Equal("download_archive"))
+			Expect(auditAction("DELETE", "/tokens/abc123")).To(Equal("revoke_token"))
+			Expect(auditAction("PUT", "/datasets/ds1/publish")).To(Equal("publish_dataset"))
+			Expect(auditAction("POST", "/trash/project/p1")).To(Equal("trash"))
+			Expect(auditAction("POST", "/trash/project/p1/t1/restore")).To(Equal("restore_trash"))
+			Expect(auditAction("DELETE", "/trash/project/p1/t1")).To(Equal("purge_trash"))
+		})
+
+		It("Should not audit reads or uploaded chunks", func() {
+			Expect(auditAction("GET", "/datasets/ds1")).To(Equal(""))
+			Expect(auditAction("GET", "/tokens")).To(Equal(""))
+			Expect(auditAction("GET", "/trash/project/p1")).To(Equal(""))
+			Expect(auditAction("POST", "/upload/chunk")).To(Equal(""))
+			Expect(auditAction("PUT", "/datasets/ds1/other")).To(Equal(""))
+		})
+	})
+
+	Describe("auditQuery Method Tests", func() {
+		request := func(query string) *restful.Request {
+			req, _ := http.NewRequest("GET", "http://localhost/audit/project/p1?"+query, nil)
+			return restful.NewRequest(req)
+		}
+
+		It("Should default the limit and leave the times unset", func() {
+			q, err := auditQuery(request(""), "p1")
+			Expect(err).To(BeNil())
+			Expect(q.ProjectID).To(Equal("p1"))
+			Expect(q.Limit).To(Equal(defaultAuditLimit))
+			Expect(q.Since.IsZero()).To(BeTrue())
+			Expect(q.Until.IsZero()).To(BeTrue())
+		})
+
+		It("Should parse the actor, times and limit", func() {
+			q, err := auditQuery(request("actor=test@mc.org&since=2015-06-01T00:00:00Z&limit=50"), "p1")
+			Expect(err).To(BeNil())
+			Expect(q.Actor).To(Equal("test@mc.org"))
+			Expect(q.Since).To(Equal(time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(q.Limit).To(Equal(50))
+		})
+
+		It("Should cap the limit", func() {
+			q, err := auditQuery(request("limit=1000000"), "p1")
+			Expect(err).To(BeNil())
+			Expect(q.Limit).To(Equal(maxAuditLimit))
+		})
+
+		It("Should reject invalid times and limits", func() {
+			_, err := auditQuery(request("since=yesterday"), "p1")
+			Expect(err).NotTo(BeNil())
+			_, err = auditQuery(request("limit=-1"), "p1")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
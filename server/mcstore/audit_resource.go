@@ -0,0 +1,140 @@
+package mcstore
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/audit"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/filters"
+)
+
+const (
+	// defaultAuditLimit is the number of events returned when a query doesn't
+	// give a limit.
+	defaultAuditLimit = 1000
+
+	// maxAuditLimit is the most events a query can return.
+	maxAuditLimit = 10000
+)
+
+// An auditResource gives project admins access to the audit log for their projects.
+type auditResource struct {
+	log *app.Logger
+}
+
+// newAuditResource creates a new audit resource.
+func newAuditResource() rest.Service {
+	return &auditResource{
+		log: app.NewLog("resource", "audit"),
+	}
+}
+
+// WebService creates an instance of the audit web service.
+func (r *auditResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/audit").Produces(restful.MIME_JSON, "text/csv")
+
+	ws.Route(ws.GET("project/{project}").Filter(filters.ProjectAccess).To(rest.RouteHandler(r.queryProjectEvents)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Param(ws.QueryParameter("actor", "only events by this user").DataType("string")).
+		Param(ws.QueryParameter("since", "only events at or after this time (RFC 3339)").DataType("string")).
+		Param(ws.QueryParameter("until", "only events before this time (RFC 3339)").DataType("string")).
+		Param(ws.QueryParameter("limit", "maximum number of events to return").DataType("integer")).
+		Param(ws.QueryParameter("format", "json (the default) or csv").DataType("string")).
+		Doc("Lists the audit events for a project, newest first. Requires project admin access").
+		Writes([]schema.AuditEvent{}))
+
+	return ws
+}
+
+// queryProjectEvents returns the audit events for a project. Only the project
+// owner and system admins can see a project's audit log.
+func (r *auditResource) queryProjectEvents(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	project := request.Attribute("project").(schema.Project)
+	if !user.Admin && project.Owner != user.ID {
+		return nil, app.ErrNoAccess
+	}
+
+	query, err := auditQuery(request, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	format := request.QueryParameter("format")
+	if format != "" && format != "json" && format != "csv" {
+		return nil, app.Errorf(app.ErrInvalid, "unknown format %s", format)
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	events, err := store.AuditEvents().Query(query)
+	switch {
+	case err == app.ErrNotFound:
+		events = []schema.AuditEvent{}
+	case err != nil:
+		return nil, err
+	}
+
+	if format != "csv" {
+		return events, nil
+	}
+
+	response.AddHeader("Content-Type", "text/csv")
+	response.AddHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, project.ID))
+	if err := audit.WriteCSV(response, events); err != nil {
+		r.log.Error("Writing audit CSV failed", "projectid", project.ID, "error", err)
+	}
+	return nil, nil
+}
+
+// auditQuery builds the query for a project's audit events from the request's
+// query parameters.
+func auditQuery(request *restful.Request, projectID string) (dai.AuditQuery, error) {
+	query := dai.AuditQuery{
+		ProjectID: projectID,
+		Actor:     request.QueryParameter("actor"),
+		Limit:     defaultAuditLimit,
+	}
+
+	var err error
+	if query.Since, err = parseAuditTime(request.QueryParameter("since")); err != nil {
+		return query, err
+	}
+
+	if query.Until, err = parseAuditTime(request.QueryParameter("until")); err != nil {
+		return query, err
+	}
+
+	if limit := request.QueryParameter("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return query, app.Errorf(app.ErrInvalid, "invalid limit %s", limit)
+		}
+		query.Limit = n
+	}
+
+	if query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+
+	return query, nil
+}
+
+// parseAuditTime parses an RFC 3339 time. An empty value is the zero time.
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, app.Errorf(app.ErrInvalid, "invalid time %s, expected RFC 3339", value)
+	}
+	return t, nil
+}
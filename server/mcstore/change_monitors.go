@@ -0,0 +1,132 @@
+package mcstore
+
+import (
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/mcstore/pkg/app"
+)
+
+// monitorRetryDelay is how long a monitor waits before reconnecting after
+// its changefeed ends.
+var monitorRetryDelay = 5 * time.Second
+
+// changeMonitors tracks the goroutines that follow database changefeeds. A
+// monitor whose changefeed fails is restarted with a new session, and all
+// the monitors can be stopped when the server shuts down.
+type changeMonitors struct {
+	mutex    sync.Mutex
+	sessions map[*r.Session]bool
+	stopping bool
+	stopped  chan struct{}
+	wg       sync.WaitGroup
+}
+
+// monitors holds all the changefeed goroutines started by the server.
+var monitors = newChangeMonitors()
+
+// newChangeMonitors creates a new changeMonitors.
+func newChangeMonitors() *changeMonitors {
+	return &changeMonitors{
+		sessions: make(map[*r.Session]bool),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// launch runs fn in a new goroutine with a session from connect. When fn
+// returns, because its changefeed ended, it is run again with a new session.
+// The session is closed when the monitors are stopped, which ends any
+// changefeed fn is reading.
+func (m *changeMonitors) launch(connect func() (*r.Session, error), fn func(session *r.Session)) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for {
+			if session, err := connect(); err != nil {
+				app.Log.Error("Unable to connect changefeed monitor", "error", err)
+			} else if m.track(session) {
+				fn(session)
+				m.untrack(session)
+			}
+
+			select {
+			case <-m.stopped:
+				return
+			case <-time.After(monitorRetryDelay):
+			}
+		}
+	}()
+}
+
+// track records a session so it is closed on stop. It returns false, after
+// closing the session, if the monitors are already stopping.
+func (m *changeMonitors) track(session *r.Session) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopping {
+		closeSession(session)
+		return false
+	}
+	m.sessions[session] = true
+	return true
+}
+
+// untrack closes a session that is no longer in use.
+func (m *changeMonitors) untrack(session *r.Session) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.sessions[session] {
+		delete(m.sessions, session)
+		closeSession(session)
+	}
+}
+
+// isStopping returns true once stop has been called. Monitors use it to tell
+// a shutdown from a lost connection.
+func (m *changeMonitors) isStopping() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stopping
+}
+
+// stop closes the monitor sessions and waits up to timeout for the monitors
+// to return. It returns false if they didn't return in time.
+func (m *changeMonitors) stop(timeout time.Duration) bool {
+	m.mutex.Lock()
+	if !m.stopping {
+		m.stopping = true
+		close(m.stopped)
+		for session := range m.sessions {
+			closeSession(session)
+		}
+		m.sessions = make(map[*r.Session]bool)
+	}
+	m.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// closeSession closes a session if there is one.
+func closeSession(session *r.Session) {
+	if session != nil {
+		session.Close()
+	}
+}
+
+// StopChangeMonitors stops the goroutines following database changefeeds. It
+// waits up to timeout for them to finish, and returns false if they didn't.
+func StopChangeMonitors(timeout time.Duration) bool {
+	return monitors.stop(timeout)
+}
@@ -0,0 +1,64 @@
+package mcstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChangeMonitors", func() {
+	var (
+		m          *changeMonitors
+		savedDelay time.Duration
+	)
+
+	BeforeEach(func() {
+		m = newChangeMonitors()
+		savedDelay = monitorRetryDelay
+		monitorRetryDelay = time.Millisecond
+	})
+
+	AfterEach(func() {
+		monitorRetryDelay = savedDelay
+	})
+
+	It("Should restart a monitor whose changefeed ends", func() {
+		var (
+			mutex sync.Mutex
+			runs  int
+		)
+		connect := func() (*r.Session, error) { return nil, nil }
+		m.launch(connect, func(session *r.Session) {
+			mutex.Lock()
+			runs++
+			mutex.Unlock()
+		})
+
+		Eventually(func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return runs
+		}).Should(BeNumerically(">=", 3))
+		Expect(m.stop(time.Second)).To(BeTrue())
+	})
+
+	It("Should keep retrying when it can't connect and stop while waiting", func() {
+		monitorRetryDelay = time.Hour
+		attempts := make(chan bool, 10)
+		connect := func() (*r.Session, error) {
+			attempts <- true
+			return nil, errors.New("down")
+		}
+		m.launch(connect, func(session *r.Session) {
+			Fail("monitor shouldn't run without a session")
+		})
+
+		Eventually(attempts).Should(Receive())
+		Expect(m.stop(time.Second)).To(BeTrue())
+		Expect(m.isStopping()).To(BeTrue())
+	})
+})
@@ -1,10 +1,20 @@
 package mcstore
 
 import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/audit"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/pkg/domain"
 	"github.com/materials-commons/mcstore/pkg/files"
@@ -14,38 +24,236 @@ import (
 // dataHandler implements the http.Handler interface. It provides an interface
 // to serving up data stored in materials commons.
 type dataHandler struct {
-	access domain.Access
+	access   domain.Access
+	shares   domain.Shares
+	datasets dai.Datasets
+	users    dai.Users
+	tokens   dai.APITokens
+	recorder audit.Recorder
+	limiter  *rateLimiter
 }
 
-// NewDataHandler creates a new instance of a dataHandler.
-func NewDataHandler(access domain.Access) http.Handler {
+// NewDataHandler creates a new instance of a dataHandler. Downloads are recorded
+// in the audit log using recorder.
+func NewDataHandler(access domain.Access, shares domain.Shares, datasets dai.Datasets, users dai.Users, tokens dai.APITokens, recorder audit.Recorder) http.Handler {
 	return &dataHandler{
-		access: access,
+		access:   access,
+		shares:   shares,
+		datasets: datasets,
+		users:    users,
+		tokens:   tokens,
+		recorder: recorder,
+		limiter:  anonymousRateLimiter(),
 	}
 }
 
-// ServeHTTP serves data stored in materials commons.
+// ServeHTTP serves data stored in materials commons. Once the response has
+// been written the start of a download is audited, and counted against its
+// published dataset. Revalidations answered with 304 (not modified) aren't
+// downloads, and neither are HEAD requests.
 func (h *dataHandler) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
-	path, mediaType, err := h.serveData(writer, req)
+	w := &countingResponseWriter{ResponseWriter: writer, status: http.StatusOK}
+	file, dataset, path, mediaType, err := h.serveData(w, req)
 	switch {
 	case err != nil:
-		ws.WriteError(err, writer)
+		ws.WriteError(err, w)
 	default:
-		serveFile(writer, req, path, mediaType)
+		serveFile(w, req, file, path, mediaType)
+	}
+
+	if req.Method != "GET" || !isStartOfDownload(req) || w.status == http.StatusNotModified {
+		return
+	}
+
+	if err == nil && w.status >= http.StatusBadRequest {
+		// The stored file couldn't be read, or the range wasn't satisfiable.
+		err = fmt.Errorf("%d %s", w.status, http.StatusText(w.status))
+	}
+	h.auditDownload(req, file, err)
+
+	if dataset != nil && err == nil {
+		if err := h.datasets.IncrementDownloads(dataset.ID); err != nil {
+			app.Log.Error("Unable to count dataset download", "datasetid", dataset.ID, "error", err)
+		}
 	}
 }
 
+// auditDownload records a download, or a failed attempt at one, in the audit
+// log. Only the start of a download is recorded so that the ranged requests
+// used to fetch a file in parts aren't each recorded.
+func (h *dataHandler) auditDownload(req *http.Request, file *schema.File, err error) {
+	event := schema.NewAuditEvent(h.downloadActor(req), audit.Download, clientAddress(req))
+	event.FileID = filepath.Base(req.URL.Path)
+	if file != nil {
+		event.FileID = file.ID
+	}
+	event.Result = audit.ResultOf(err)
+	if err != nil {
+		event.Detail = err.Error()
+	}
+	h.recorder.Record(event)
+}
+
+// downloadActor describes who made a download request. It is the user for
+// requests with an apikey or api token, the share link for shared files and
+// anonymous for published files.
+func (h *dataHandler) downloadActor(req *http.Request) string {
+	if isShareRequest(req) {
+		return "sharelink:" + req.FormValue("share")
+	}
+
+	if token := bearerToken(req); token != "" {
+		apitoken, err := h.tokens.ByHash(hashAPIToken(token))
+		if err != nil {
+			return "unknown"
+		}
+		return apitoken.Owner
+	}
+
+	apikey := req.FormValue("apikey")
+	if apikey == "" {
+		return audit.Anonymous
+	}
+
+	user, err := h.users.ByAPIKey(apikey)
+	if err != nil {
+		return "unknown"
+	}
+	return user.ID
+}
+
 // serveFile serves up the actual file contents. It sets the content-type header to
-// the mediatype specified. If the file doesn't exist, or the server doesn't have
-// permissions to access the file then a 404 (not found) will be returned by the
-// http.ServeFile method.
-func serveFile(writer http.ResponseWriter, req *http.Request, path, mediatype string) {
-	writer.Header().Add("Content-Type", mediatype)
-	if mediatype == "application/pdf" {
-		writer.Header().Add("Content-Disposition", `inline; filename="filename.pdf"`)
+// the mediatype specified and names the file using its real name. The ETag is based
+// on the file's checksum so clients can skip unchanged files (If-None-Match) and
+// safely resume downloads (If-Range). Range requests and If-Modified-Since are
+// handled by http.ServeContent. If the file doesn't exist, or the server doesn't
+// have permissions to access the file then a 404 (not found) will be returned.
+func serveFile(writer http.ResponseWriter, req *http.Request, file *schema.File, path, mediatype string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(writer, req)
+		return
+	}
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err != nil || finfo.IsDir() {
+		http.NotFound(writer, req)
+		return
+	}
+
+	converted := path != app.MCDir.FilePath(file.FileID())
+	etag := fileETag(file.Checksum, converted)
+	name := downloadName(file.Name, mediatype, converted)
+
+	header := writer.Header()
+	header.Set("Content-Type", mediatype)
+	header.Set("Content-Disposition", contentDisposition(name, req.FormValue("download") != ""))
+	if etag != "" {
+		header.Set("Etag", etag)
 	}
 	app.Log.Debugf("Set Content-Type to %s", mediatype)
-	http.ServeFile(writer, req, path)
+
+	if etag != "" && etagMatches(req.Header.Get("If-None-Match"), etag) && (req.Method == "GET" || req.Method == "HEAD") {
+		header.Del("Content-Type")
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// A Range only applies if the client's copy is still current.
+	if ifRange := req.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+		req.Header.Del("Range")
+	}
+
+	// The checksum is of the original file, not any conversion of it.
+	if digest := md5Digest(file.Checksum); digest != "" && !converted {
+		header.Set("Digest", "MD5="+digest)
+		if req.Header.Get("Range") == "" {
+			header.Set("Content-MD5", digest)
+		}
+	}
+
+	http.ServeContent(writer, req, name, finfo.ModTime(), f)
+}
+
+// fileETag creates the ETag for a file from its checksum. Converted files get a
+// different ETag from the original. It returns an empty string if the file
+// doesn't have a checksum.
+func fileETag(checksum string, converted bool) string {
+	switch {
+	case checksum == "":
+		return ""
+	case converted:
+		return `"` + checksum + `-converted"`
+	default:
+		return `"` + checksum + `"`
+	}
+}
+
+// etagMatches returns true if the If-None-Match header value matches etag. The
+// header can contain a list of ETags, which are compared using weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// md5Digest converts a hex encoded MD5 checksum to the base64 form used by the
+// Digest and Content-MD5 headers. It returns an empty string if the checksum
+// isn't a valid MD5.
+func md5Digest(checksum string) string {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil || len(sum) != md5.Size {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// downloadName returns the name to give a file when it is served. Converted
+// files get the extension of the type they were converted to.
+func downloadName(name, mediatype string, converted bool) string {
+	if !converted {
+		return name
+	}
+
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	switch mediatype {
+	case "image/jpeg":
+		return base + ".jpg"
+	case "application/pdf":
+		return base + ".pdf"
+	default:
+		return name
+	}
+}
+
+// contentDisposition creates the Content-Disposition header for a file. Names
+// that aren't plain ASCII are also given in the RFC 5987 filename* form.
+func contentDisposition(name string, attachment bool) string {
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+
+	asciiName := strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	value := fmt.Sprintf(`%s; filename="%s"`, disposition, asciiName)
+	if asciiName != name {
+		value += "; filename*=UTF-8''" + strings.Replace(url.QueryEscape(name), "+", "%20", -1)
+	}
+	return value
 }
 
 // serveData does the actual work of serving the data. It checks the access on each
@@ -53,12 +261,25 @@ func serveFile(writer http.ResponseWriter, req *http.Request, path, mediatype st
 // specified. The assumption is that without the original flag we are actually trying
 // to render an image in a browser. Since browsers do not render all image types we
 // convert some types to jpg files. This routine will serve up these jpg conversions
-// rather than the original file unless the original flag is specified.
-func (h *dataHandler) serveData(writer http.ResponseWriter, req *http.Request) (path string, mediatype string, err error) {
-	// All requests require an apikey.
+// rather than the original file unless the original flag is specified. The
+// dataset is only set for published files served to anonymous requests.
+func (h *dataHandler) serveData(writer http.ResponseWriter, req *http.Request) (file *schema.File, dataset *schema.Dataset, path string, mediatype string, err error) {
+	// Share links are signed and don't need an apikey.
+	if isShareRequest(req) {
+		file, path, mediatype, err = h.serveSharedData(req)
+		return file, nil, path, mediatype, err
+	}
+
+	// Api tokens are passed in the Authorization header.
+	if token := bearerToken(req); token != "" {
+		file, path, mediatype, err = h.serveTokenData(req, token)
+		return file, nil, path, mediatype, err
+	}
+
+	// Requests without an apikey can only access published data.
 	apikey := req.FormValue("apikey")
 	if apikey == "" {
-		return path, mediatype, app.ErrNoAccess
+		return h.servePublishedData(req)
 	}
 	app.Log.Debugf("serveData - Request for apikey %s", apikey)
 
@@ -71,25 +292,94 @@ func (h *dataHandler) serveData(writer http.ResponseWriter, req *http.Request) (
 	app.Log.Debugf("serveData - fileID %s, URL %s", fileID, req.URL.Path)
 
 	// Get the file, checking its access.
-	file, err := h.access.GetFile(apikey, fileID)
+	file, err = h.access.GetFile(apikey, fileID)
 	if err != nil {
-		return path, mediatype, err
+		return nil, nil, path, mediatype, err
 	}
 
-	path = filePath(file, original)
+	path, mediatype = fileToServe(file, original)
 	app.Log.Debugf("serveData - Serving path: %s\n", path)
+	return file, nil, path, mediatype, nil
+}
 
+// serveSharedData validates a share link and returns the file it points at. Every
+// use of a share link is logged.
+func (h *dataHandler) serveSharedData(req *http.Request) (file *schema.File, path string, mediatype string, err error) {
+	fileID := filepath.Base(req.URL.Path)
+	shareReq, err := toShareRequest(req, fileID)
+	if err != nil {
+		return nil, path, mediatype, err
+	}
+
+	file, link, err := h.shares.Redeem(shareReq, time.Now())
+	if err != nil {
+		app.Log.Info("Share link denied", "linkid", shareReq.LinkID, "fileid", fileID, "remote", req.RemoteAddr, "error", err)
+		return nil, path, mediatype, err
+	}
+
+	app.Log.Info("Share link used", "linkid", link.ID, "fileid", file.ID, "owner", link.Owner,
+		"remote", req.RemoteAddr, "downloads", link.Downloads, "range", req.Header.Get("Range"))
+
+	path, mediatype = fileToServe(file, link.Original)
+	return file, path, mediatype, nil
+}
+
+// serveTokenData serves a file to a request made with an api token. The token
+// needs the read scope, and can only read files in the projects it allows.
+func (h *dataHandler) serveTokenData(req *http.Request, token string) (file *schema.File, path string, mediatype string, err error) {
+	apitoken, err := h.tokens.ByHash(hashAPIToken(token))
+	switch {
+	case err != nil:
+		return nil, path, mediatype, app.ErrNoAccess
+	case !apitoken.Usable(time.Now()):
+		return nil, path, mediatype, app.Errorf(app.ErrNoAccess, "Token expired or revoked")
+	case !apitoken.HasScope(requiredScope(req)):
+		return nil, path, mediatype, app.Errorf(app.ErrForbidden, "Token scope doesn't allow request")
+	}
+
+	file, err = h.access.GetTokenFile(*apitoken, filepath.Base(req.URL.Path))
+	if err != nil {
+		return nil, path, mediatype, err
+	}
+
+	path, mediatype = fileToServe(file, getOriginalFormValue(req))
+	return file, path, mediatype, nil
+}
+
+// servePublishedData serves a file in a published dataset to an anonymous
+// user. Anonymous requests are rate limited by client address. The dataset is
+// returned so that the download can be counted against it once it is sent.
+func (h *dataHandler) servePublishedData(req *http.Request) (file *schema.File, dataset *schema.Dataset, path string, mediatype string, err error) {
+	client := clientAddress(req)
+	if !h.limiter.allow(client) {
+		app.Log.Info("Anonymous rate limit exceeded", "client", client, "path", req.URL.Path)
+		return nil, nil, path, mediatype, app.ErrRateLimited
+	}
+
+	fileID := filepath.Base(req.URL.Path)
+	file, dataset, err = h.access.GetPublishedFile(fileID)
+	if err != nil {
+		return nil, nil, path, mediatype, err
+	}
+
+	path, mediatype = fileToServe(file, getOriginalFormValue(req))
+	return file, dataset, path, mediatype, nil
+}
+
+// fileToServe returns the path and content type to serve for a file. The
+// content type is dependent on whether we are serving the original or the
+// converted file.
+func fileToServe(file *schema.File, original bool) (path string, mediatype string) {
+	path = filePath(file, original)
 	mediatype = file.MediaType.Mime
 
-	// The content type is dependent on whether we are
-	// serving the original or the converted file.
 	if !original && isConvertedImage(file.MediaType.Mime) {
 		mediatype = "image/jpeg"
 	} else if !original && files.IsOfficeDocument(file.MediaType.Mime) {
 		mediatype = "application/pdf"
 	}
 
-	return path, mediatype, nil
+	return path, mediatype
 }
 
 // getOriginalFormValue looks for the original argument on the URL. If it exists
@@ -128,3 +418,13 @@ func isConvertedImage(mime string) bool {
 		return false
 	}
 }
+
+// isStartOfDownload returns true if the request isn't a ranged request for
+// a later part of the file. Browsers and download clients will often issue
+// multiple ranged requests for a single download, so only the first one is
+// audited and counted against a published dataset. It is only used for
+// statistics, a client can skip it, so it must not be used to enforce limits.
+func isStartOfDownload(req *http.Request) bool {
+	rangeHeader := req.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
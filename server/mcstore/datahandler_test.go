@@ -1,19 +1,36 @@
 package mcstore
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/materials-commons/config"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
 
 	"net/http/httptest"
 
+	daimocks "github.com/materials-commons/mcstore/pkg/db/dai/mocks"
 	"github.com/materials-commons/mcstore/pkg/domain/mocks"
+	"github.com/materials-commons/testify/mock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// auditRecorder is an audit.Recorder that keeps the events it records.
+type auditRecorder struct {
+	events []schema.AuditEvent
+}
+
+func (r *auditRecorder) Record(event schema.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
 var _ = Describe("DataHandler", func() {
 	Describe("getOriginalFormValue Method Tests", func() {
 		It("Should return false if original flag is not given", func() {
@@ -149,26 +166,49 @@ var _ = Describe("DataHandler", func() {
 			rr          *httptest.ResponseRecorder
 			datahandler http.Handler
 			access      *mocks.Access
+			shares      *mocks.Shares
+			datasets    *daimocks.Datasets
+			users       *daimocks.Users
+			recorder    *auditRecorder
 			dhhandler   *dataHandler
+			root        string
 		)
 
+		// writeFile stores the contents of a file under MCDIR.
+		writeFile := func(id string) {
+			Expect(os.MkdirAll(app.MCDir.FileDir(id), 0700)).To(BeNil())
+			Expect(ioutil.WriteFile(app.MCDir.FilePath(id), []byte("0123456789"), 0600)).To(BeNil())
+		}
+
 		BeforeEach(func() {
 			access = mocks.NewMAccess()
-			datahandler = NewDataHandler(access)
+			shares = mocks.NewMShares()
+			datasets = daimocks.NewMDatasets()
+			users = daimocks.NewMUsers()
+			recorder = &auditRecorder{}
+			datahandler = NewDataHandler(access, shares, datasets, users, dai.NewMemStore().APITokens(), recorder)
 			dhhandler = datahandler.(*dataHandler)
+			dhhandler.limiter = newRateLimiter(1, 100)
 			server = httptest.NewServer(datahandler)
 			rr = httptest.NewRecorder()
-			config.Set("MCDIR", "/tmp/mcdir")
+			root, _ = ioutil.TempDir("", "datahandler")
+			config.Set("MCDIR", root)
 		})
 
 		AfterEach(func() {
 			server.Close()
+			os.RemoveAll(root)
 			config.Set("MCDIR", saved)
 		})
 
-		It("Should fail if no apikey is specified", func() {
-			req, _ := http.NewRequest("GET", server.URL, nil)
-			path, mediatype, err := dhhandler.serveData(rr, req)
+		It("Should fail if no apikey is specified and the file isn't published", func() {
+			req, _ := http.NewRequest("GET", server.URL+"/abc-defg-456", nil)
+			var (
+				nilFile    *schema.File
+				nilDataset *schema.Dataset
+			)
+			access.On("GetPublishedFile", "abc-defg-456").Return(nilFile, nilDataset, app.ErrNoAccess)
+			_, _, path, mediatype, err := dhhandler.serveData(rr, req)
 			Expect(err).To(Equal(app.ErrNoAccess), "Expected ErrNoAccess, got: %s ", err)
 			Expect(path).To(Equal(""), "Got unexpected value for path %s", path)
 			Expect(mediatype).To(Equal(""), "Got unexpected value for mediatype %s", mediatype)
@@ -179,7 +219,7 @@ var _ = Describe("DataHandler", func() {
 			req, _ := http.NewRequest("GET", fileURL, nil)
 			var nilFile *schema.File
 			access.On("GetFile", "abc123", "abc-defg-456").Return(nilFile, app.ErrNoAccess)
-			path, mediatype, err := dhhandler.serveData(rr, req)
+			_, _, path, mediatype, err := dhhandler.serveData(rr, req)
 			Expect(err).To(Equal(app.ErrNoAccess), "Expected ErrNoAccess: %s", err)
 			Expect(path).To(Equal(""), "Got unexpected value for path %s", path)
 			Expect(mediatype).To(Equal(""), "Got unexpected value for mediatype %s", mediatype)
@@ -196,7 +236,7 @@ var _ = Describe("DataHandler", func() {
 			}
 
 			access.On("GetFile", "abc123", "abc-defg-456").Return(&f, nil)
-			path, mediatype, err := dhhandler.serveData(rr, req)
+			_, _, path, mediatype, err := dhhandler.serveData(rr, req)
 			Expect(err).To(BeNil())
 			Expect(mediatype).To(Equal("image/jpeg"), "Expected image/jpeg, got %s", mediatype)
 			Expect(path).To(Equal(app.MCDir.FilePathImageConversion(f.FileID())), "Got unexpected value for path %s", path)
@@ -213,10 +253,360 @@ var _ = Describe("DataHandler", func() {
 			}
 
 			access.On("GetFile", "abc123", "abc-defg-456").Return(&f, nil)
-			path, mediatype, err := dhhandler.serveData(rr, req)
+			_, _, path, mediatype, err := dhhandler.serveData(rr, req)
+			Expect(err).To(BeNil())
+			Expect(mediatype).To(Equal("image/tiff"), "Expected image/tiff, got %s", mediatype)
+			Expect(path).To(Equal(app.MCDir.FilePath(f.FileID())), "Got unexpected value for path %s", path)
+		})
+
+		It("Should serve a published file without an apikey", func() {
+			fileURL := server.URL + "/abc-defg-456?original=true"
+			req, _ := http.NewRequest("GET", fileURL, nil)
+			f := schema.File{
+				ID: "abc-defg-456",
+				MediaType: schema.MediaType{
+					Mime: "image/tiff",
+				},
+			}
+			dataset := schema.Dataset{ID: "ds1", Published: true}
+
+			access.On("GetPublishedFile", "abc-defg-456").Return(&f, &dataset, nil)
+			_, published, path, mediatype, err := dhhandler.serveData(rr, req)
+			Expect(err).To(BeNil())
+			Expect(published.ID).To(Equal("ds1"))
+			Expect(mediatype).To(Equal("image/tiff"), "Expected image/tiff, got %s", mediatype)
+			Expect(path).To(Equal(app.MCDir.FilePath(f.FileID())), "Got unexpected value for path %s", path)
+		})
+
+		It("Should only count published downloads that send the file", func() {
+			f := schema.File{ID: "abc-defg-456", Checksum: "781e5e245d69b566979b86e28d23f2c7"}
+			writeFile(f.ID)
+			dataset := schema.Dataset{ID: "ds1", Published: true}
+			access.On("GetPublishedFile", "abc-defg-456").Return(&f, &dataset, nil)
+			datasets.On("IncrementDownloads", "ds1").Return(nil)
+
+			head, _ := http.NewRequest("HEAD", server.URL+"/abc-defg-456", nil)
+			datahandler.ServeHTTP(httptest.NewRecorder(), head)
+			datasets.AssertNotCalled(GinkgoT(), "IncrementDownloads", "ds1")
+
+			revalidate, _ := http.NewRequest("GET", server.URL+"/abc-defg-456", nil)
+			revalidate.Header.Set("If-None-Match", `"`+f.Checksum+`"`)
+			rr := httptest.NewRecorder()
+			datahandler.ServeHTTP(rr, revalidate)
+			Expect(rr.Code).To(Equal(http.StatusNotModified))
+			datasets.AssertNotCalled(GinkgoT(), "IncrementDownloads", "ds1")
+			Expect(recorder.events).To(BeEmpty())
+
+			get, _ := http.NewRequest("GET", server.URL+"/abc-defg-456", nil)
+			rr = httptest.NewRecorder()
+			datahandler.ServeHTTP(rr, get)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			datasets.AssertNumberOfCalls(GinkgoT(), "IncrementDownloads", 1)
+			Expect(recorder.events).To(HaveLen(1))
+		})
+
+		It("Should record downloads in the audit log", func() {
+			f := schema.File{ID: "abc-defg-456"}
+			writeFile(f.ID)
+			access.On("GetFile", "abc123", "abc-defg-456").Return(&f, nil)
+			users.On("ByAPIKey", "abc123").Return(&schema.User{ID: "test@mc.org"}, nil)
+
+			req, _ := http.NewRequest("GET", server.URL+"/abc-defg-456?apikey=abc123", nil)
+			datahandler.ServeHTTP(rr, req)
+			Expect(recorder.events).To(HaveLen(1))
+			Expect(recorder.events[0].Actor).To(Equal("test@mc.org"))
+			Expect(recorder.events[0].Action).To(Equal("download"))
+			Expect(recorder.events[0].FileID).To(Equal("abc-defg-456"))
+			Expect(recorder.events[0].Result).To(Equal("success"))
+
+			req.Header.Set("Range", "bytes=100-200")
+			datahandler.ServeHTTP(rr, req)
+			Expect(recorder.events).To(HaveLen(1))
+		})
+
+		It("Should record failed anonymous downloads in the audit log", func() {
+			var (
+				nilFile    *schema.File
+				nilDataset *schema.Dataset
+			)
+			access.On("GetPublishedFile", "abc-defg-456").Return(nilFile, nilDataset, app.ErrNoAccess)
+
+			req, _ := http.NewRequest("GET", server.URL+"/abc-defg-456", nil)
+			datahandler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.events).To(HaveLen(1))
+			Expect(recorder.events[0].Actor).To(Equal("anonymous"))
+			Expect(recorder.events[0].FileID).To(Equal("abc-defg-456"))
+			Expect(recorder.events[0].Result).To(Equal("failure"))
+		})
+
+		It("Should rate limit anonymous requests", func() {
+			dhhandler.limiter = newRateLimiter(1, 1)
+			f := schema.File{ID: "abc-defg-456"}
+			dataset := schema.Dataset{ID: "ds1", Published: true}
+			access.On("GetPublishedFile", "abc-defg-456").Return(&f, &dataset, nil)
+			datasets.On("IncrementDownloads", "ds1").Return(nil)
+
+			req, _ := http.NewRequest("GET", server.URL+"/abc-defg-456", nil)
+			_, _, _, _, err := dhhandler.serveData(rr, req)
+			Expect(err).To(BeNil())
+
+			_, _, _, _, err = dhhandler.serveData(rr, req)
+			Expect(err).To(Equal(app.ErrRateLimited))
+		})
+
+		It("Should serve a share link without an apikey", func() {
+			fileURL := server.URL + "/abc-defg-456?share=link1&expires=100&sig=abc&original=true"
+			req, _ := http.NewRequest("GET", fileURL, nil)
+			f := schema.File{
+				ID: "abc-defg-456",
+				MediaType: schema.MediaType{
+					Mime: "image/tiff",
+				},
+			}
+			link := schema.ShareLink{
+				ID:       "link1",
+				FileID:   "abc-defg-456",
+				Original: true,
+			}
+
+			shares.On("Redeem", mock.Anything, mock.Anything).Return(&f, &link, nil)
+			_, _, path, mediatype, err := dhhandler.serveData(rr, req)
 			Expect(err).To(BeNil())
 			Expect(mediatype).To(Equal("image/tiff"), "Expected image/tiff, got %s", mediatype)
 			Expect(path).To(Equal(app.MCDir.FilePath(f.FileID())), "Got unexpected value for path %s", path)
 		})
+
+		It("Should fail when a share link is rejected", func() {
+			fileURL := server.URL + "/abc-defg-456?share=link1&expires=100&sig=bad"
+			req, _ := http.NewRequest("GET", fileURL, nil)
+			var (
+				nilFile *schema.File
+				nilLink *schema.ShareLink
+			)
+
+			shares.On("Redeem", mock.Anything, mock.Anything).Return(nilFile, nilLink, app.ErrNoAccess)
+			_, _, _, _, err := dhhandler.serveData(rr, req)
+			Expect(err).To(Equal(app.ErrNoAccess))
+		})
+	})
+
+	Describe("Share link download limits", func() {
+		var (
+			saved   string = config.GetString("MCDIR")
+			root    string
+			handler http.Handler
+			path    string
+		)
+
+		BeforeEach(func() {
+			var err error
+			root, err = ioutil.TempDir("", "datahandler")
+			Expect(err).To(BeNil())
+			config.Set("MCDIR", root)
+
+			store := dai.NewMemStore()
+			user := schema.NewUser("test", "test@mc.org", "", "testkey")
+			Expect(store.AddUser(user)).To(BeNil())
+			p := schema.NewProject("proj", user.ID)
+			project, err := store.Projects().Insert(&p)
+			Expect(err).To(BeNil())
+			f := schema.NewFile("f.txt", user.ID)
+			f.MediaType.Mime = "text/plain"
+			file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+			Expect(os.MkdirAll(app.MCDir.FileDir(file.ID), 0700)).To(BeNil())
+			Expect(ioutil.WriteFile(app.MCDir.FilePath(file.ID), []byte("0123456789"), 0600)).To(BeNil())
+
+			access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
+			shares := domain.NewShares(store.ShareLinks(), store.Files(), access, []byte("secret"))
+			link, sig, err := shares.Create(user, file.ID, true, time.Now().Add(time.Hour), 2)
+			Expect(err).To(BeNil())
+			path = shareLinkPath(link, sig)
+			handler = NewDataHandler(access, shares, store.Datasets(), store.Users(), store.APITokens(), &auditRecorder{})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(root)
+			config.Set("MCDIR", saved)
+		})
+
+		It("Should count ranged requests that don't start at the beginning", func() {
+			get := func() int {
+				req, _ := http.NewRequest("GET", path, nil)
+				req.Header.Set("Range", "bytes=1-")
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				return rr.Code
+			}
+
+			Expect(get()).To(Equal(http.StatusPartialContent))
+			Expect(get()).To(Equal(http.StatusPartialContent))
+			Expect(get()).To(BeNumerically(">=", http.StatusBadRequest))
+		})
+	})
+
+	Describe("API token downloads", func() {
+		var (
+			saved   string = config.GetString("MCDIR")
+			root    string
+			handler http.Handler
+			store   *dai.MemStore
+			user    schema.User
+			fileURL string
+		)
+
+		BeforeEach(func() {
+			var err error
+			root, err = ioutil.TempDir("", "datahandler")
+			Expect(err).To(BeNil())
+			config.Set("MCDIR", root)
+
+			store = dai.NewMemStore()
+			user = schema.NewUser("test", "test@mc.org", "", "testkey")
+			Expect(store.AddUser(user)).To(BeNil())
+			p := schema.NewProject("proj", user.ID)
+			project, err := store.Projects().Insert(&p)
+			Expect(err).To(BeNil())
+			f := schema.NewFile("f.txt", user.ID)
+			f.MediaType.Mime = "text/plain"
+			file, err := store.Files().Insert(&f, project.DataDir, project.ID)
+			Expect(err).To(BeNil())
+			Expect(os.MkdirAll(app.MCDir.FileDir(file.ID), 0700)).To(BeNil())
+			Expect(ioutil.WriteFile(app.MCDir.FilePath(file.ID), []byte("0123456789"), 0600)).To(BeNil())
+			fileURL = "http://localhost/datafiles/static/" + file.ID
+
+			access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
+			shares := domain.NewShares(store.ShareLinks(), store.Files(), access, []byte("secret"))
+			handler = NewDataHandler(access, shares, store.Datasets(), store.Users(), store.APITokens(), &auditRecorder{})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(root)
+			config.Set("MCDIR", saved)
+		})
+
+		// get downloads the file with a new token that has scopes and is
+		// restricted to projects.
+		get := func(scopes []string, projects []string) int {
+			token, err := generateAPIToken()
+			Expect(err).To(BeNil())
+			t := schema.NewAPIToken("t", user.ID, hashAPIToken(token), time.Now().Add(time.Hour))
+			t.Scopes = scopes
+			t.Projects = projects
+			_, err = store.APITokens().Insert(&t)
+			Expect(err).To(BeNil())
+
+			req, _ := http.NewRequest("GET", fileURL, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		It("Should serve the file to a token with the read scope", func() {
+			Expect(get([]string{schema.TokenScopeRead}, nil)).To(Equal(http.StatusOK))
+		})
+
+		It("Should reject a token without the read scope", func() {
+			Expect(get([]string{schema.TokenScopeUpload}, nil)).To(Equal(http.StatusForbidden))
+		})
+
+		It("Should reject a token restricted to other projects", func() {
+			Expect(get([]string{schema.TokenScopeRead}, []string{"other-project"})).To(BeNumerically(">=", http.StatusBadRequest))
+		})
+
+		It("Should reject an unknown token", func() {
+			req, _ := http.NewRequest("GET", fileURL, nil)
+			req.Header.Set("Authorization", "Bearer mct_unknown")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			Expect(rr.Code).To(BeNumerically(">=", http.StatusBadRequest))
+		})
+	})
+
+	Describe("serveFile Method Tests", func() {
+		var (
+			saved    string = config.GetString("MCDIR")
+			rr       *httptest.ResponseRecorder
+			f        schema.File
+			path     string
+			checksum = "5d41402abc4b2a76b9719d911017c592" // md5 of hello
+		)
+
+		BeforeEach(func() {
+			config.Set("MCDIR", "/tmp/mcdir-servefile")
+			f = schema.File{
+				ID:       "abc-defg-456",
+				Name:     "hello world.txt",
+				Checksum: checksum,
+				MediaType: schema.MediaType{
+					Mime: "text/plain",
+				},
+			}
+			path = app.MCDir.FilePath(f.FileID())
+			os.MkdirAll(filepath.Dir(path), 0700)
+			ioutil.WriteFile(path, []byte("hello"), 0600)
+			rr = httptest.NewRecorder()
+		})
+
+		AfterEach(func() {
+			os.RemoveAll("/tmp/mcdir-servefile")
+			config.Set("MCDIR", saved)
+		})
+
+		It("Should set the ETag, Digest and Content-Disposition from the file", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/abc-defg-456", nil)
+			serveFile(rr, req, &f, path, "text/plain")
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(Equal("hello"))
+			Expect(rr.Header().Get("Etag")).To(Equal(`"` + checksum + `"`))
+			Expect(rr.Header().Get("Content-MD5")).To(Equal("XUFAKrxLKna5cZ2REBfFkg=="))
+			Expect(rr.Header().Get("Digest")).To(Equal("MD5=XUFAKrxLKna5cZ2REBfFkg=="))
+			Expect(rr.Header().Get("Content-Disposition")).To(Equal(`inline; filename="hello world.txt"`))
+		})
+
+		It("Should return not modified when If-None-Match matches", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/abc-defg-456", nil)
+			req.Header.Set("If-None-Match", `"other", W/"`+checksum+`"`)
+			serveFile(rr, req, &f, path, "text/plain")
+			Expect(rr.Code).To(Equal(http.StatusNotModified))
+			Expect(rr.Body.Len()).To(Equal(0))
+		})
+
+		It("Should serve a range when If-Range matches", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/abc-defg-456", nil)
+			req.Header.Set("Range", "bytes=1-")
+			req.Header.Set("If-Range", `"`+checksum+`"`)
+			serveFile(rr, req, &f, path, "text/plain")
+			Expect(rr.Code).To(Equal(http.StatusPartialContent))
+			Expect(rr.Body.String()).To(Equal("ello"))
+			Expect(rr.Header().Get("Content-MD5")).To(Equal(""))
+		})
+
+		It("Should serve the whole file when If-Range doesn't match", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/abc-defg-456", nil)
+			req.Header.Set("Range", "bytes=1-")
+			req.Header.Set("If-Range", `"changed"`)
+			serveFile(rr, req, &f, path, "text/plain")
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(Equal("hello"))
+		})
+
+		It("Should return not found when the file is missing", func() {
+			req, _ := http.NewRequest("GET", "http://localhost/abc-defg-456", nil)
+			serveFile(rr, req, &f, path+".missing", "text/plain")
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("Should encode names that aren't ASCII", func() {
+			Expect(contentDisposition("résumé.pdf", true)).
+				To(Equal(`attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`))
+		})
+
+		It("Should name converted files using the converted type", func() {
+			Expect(downloadName("image.tif", "image/jpeg", true)).To(Equal("image.jpg"))
+			Expect(downloadName("image.tif", "image/tiff", false)).To(Equal("image.tif"))
+		})
 	})
 })
@@ -0,0 +1,63 @@
+package mcstore
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/metadata"
+)
+
+// writeDatasetMetadata writes a dataset's metadata in the format given by the
+// format query parameter. The metadata is built before anything is written,
+// so that an error, such as asking for DataCite metadata for a dataset
+// without a DOI, can still be returned to the client.
+func writeDatasetMetadata(request *restful.Request, response *restful.Response, dataset *schema.Dataset) error {
+	format, err := metadata.ParseFormat(request.QueryParameter("format"))
+	if err != nil {
+		return err
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	files, err := store.Datasets().Files(dataset.ID)
+	if err != nil && !app.Is(err, app.ErrNotFound) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := metadata.Write(&buf, format, datasetRecord(request.Request, dataset, files)); err != nil {
+		return err
+	}
+
+	response.AddHeader("Content-Type", format.ContentType()+"; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	_, err = response.Write(buf.Bytes())
+	return err
+}
+
+// datasetRecord creates the metadata record for a dataset. Its landing page
+// and file URLs are the public dataset URLs, which work once the dataset is
+// published.
+func datasetRecord(req *http.Request, dataset *schema.Dataset, files []schema.File) metadata.Record {
+	return metadata.Record{
+		Dataset:   dataset,
+		Files:     files,
+		URL:       serverURL(req, "/public/datasets/"+dataset.ID),
+		Publisher: publisher(),
+		FileURL: func(fileID string) string {
+			return serverURL(req, "/datafiles/static/"+fileID+"?original=true")
+		},
+	}
+}
+
+// publisher returns who publishes datasets, from MCSTORED_PUBLISHER.
+func publisher() string {
+	if name := config.GetString("MCSTORED_PUBLISHER"); name != "" {
+		return name
+	}
+	return "Materials Commons"
+}
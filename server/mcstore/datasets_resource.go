@@ -0,0 +1,152 @@
+package mcstore
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/filters"
+)
+
+// A datasetsResource handles creating and publishing datasets.
+type datasetsResource struct {
+	log *app.Logger
+}
+
+// newDatasetsResource creates a new datasets resource.
+func newDatasetsResource() rest.Service {
+	return &datasetsResource{
+		log: app.NewLog("resource", "datasets"),
+	}
+}
+
+// WebService creates an instance of the datasets web service.
+func (r *datasetsResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/datasets").Produces(restful.MIME_JSON).Consumes(restful.MIME_JSON)
+
+	ws.Route(ws.POST("").To(rest.RouteHandler(r.createDataset)).
+		Doc("Creates a new dataset from project files and directories").
+		Reads(mcstoreapi.CreateDatasetRequest{}).
+		Writes(schema.Dataset{}))
+
+	ws.Route(ws.GET("{dataset}").To(rest.RouteHandler(r.getDataset)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Doc("Gets a dataset").
+		Writes(schema.Dataset{}))
+
+	ws.Route(ws.GET("{dataset}/metadata").To(rest.RouteHandler(r.getDatasetMetadata)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Param(ws.QueryParameter("format", "metadata format, schemaorg or datacite").DataType("string")).
+		Doc("Gets a dataset's metadata as schema.org JSON-LD or DataCite XML, such as for registering its DOI").
+		Produces("application/ld+json", "application/xml", restful.MIME_JSON))
+
+	ws.Route(ws.PUT("{dataset}/publish").To(rest.RouteHandler(r.publishDataset)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Doc("Publishes a dataset, making it available without credentials").
+		Writes(schema.Dataset{}))
+
+	ws.Route(ws.PUT("{dataset}/unpublish").To(rest.RouteHandler(r.unpublishDataset)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Doc("Unpublishes a dataset. Requires admin access").
+		Writes(schema.Dataset{}))
+
+	return ws
+}
+
+// createDataset creates a new unpublished dataset.
+func (r *datasetsResource) createDataset(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	var req mcstoreapi.CreateDatasetRequest
+	if err := request.ReadEntity(&req); err != nil {
+		r.log.Debugf("createDataset ReadEntity failed: %s", err)
+		return nil, err
+	}
+
+	if !filters.TokenAllowsProject(request, req.ProjectID) {
+		return nil, app.ErrNoAccess
+	}
+
+	datasetReq := domain.DatasetRequest{
+		ProjectID:    req.ProjectID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Authors:      req.Authors,
+		License:      req.License,
+		Keywords:     req.Keywords,
+		DOI:          req.DOI,
+		FileIDs:      req.FileIDs,
+		DirectoryIDs: req.DirectoryIDs,
+	}
+
+	dataset, err := r.datasets(request).Create(user, datasetReq)
+	if err != nil {
+		return nil, err
+	}
+
+	r.log.Info("Created dataset", "user", user.ID, "datasetid", dataset.ID, "projectid", dataset.ProjectID)
+	return dataset, nil
+}
+
+// getDataset returns a dataset the user has access to.
+func (r *datasetsResource) getDataset(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	return r.datasetForUser(request, user)
+}
+
+// getDatasetMetadata writes the metadata for a dataset the user has access to.
+// Unlike the public route, it works for unpublished datasets.
+func (r *datasetsResource) getDatasetMetadata(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	dataset, err := r.datasetForUser(request, user)
+	if err != nil {
+		return nil, err
+	}
+	return nil, writeDatasetMetadata(request, response, dataset)
+}
+
+// publishDataset publishes a dataset.
+func (r *datasetsResource) publishDataset(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	if _, err := r.datasetForUser(request, user); err != nil {
+		return nil, err
+	}
+	return r.datasets(request).Publish(user, request.PathParameter("dataset"))
+}
+
+// unpublishDataset removes a dataset from public access.
+func (r *datasetsResource) unpublishDataset(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	if _, err := r.datasetForUser(request, user); err != nil {
+		return nil, err
+	}
+	return r.datasets(request).Unpublish(user, request.PathParameter("dataset"))
+}
+
+// datasetForUser looks up the dataset in the request. The user must have access to
+// the dataset's project, and an api token must allow the project.
+func (r *datasetsResource) datasetForUser(request *restful.Request, user schema.User) (*schema.Dataset, error) {
+	store := request.Attribute("store").(dai.Store)
+	dataset, err := store.Datasets().ByID(request.PathParameter("dataset"))
+	if err != nil {
+		return nil, app.ErrNotFound
+	}
+
+	if !filters.TokenAllowsProject(request, dataset.ProjectID) {
+		return nil, app.ErrNoAccess
+	}
+
+	access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
+	if dataset.Owner != user.ID && !access.AllowedByOwner(dataset.ProjectID, user.ID) {
+		return nil, app.ErrNoAccess
+	}
+
+	return dataset, nil
+}
+
+// datasets creates a domain.Datasets for the request's store.
+func (r *datasetsResource) datasets(request *restful.Request) domain.Datasets {
+	store := request.Attribute("store").(dai.Store)
+	files := store.Files()
+	access := domain.NewAccess(store.Projects(), files, store.Users())
+	return domain.NewDatasets(store.Datasets(), files, store.Dirs(), access)
+}
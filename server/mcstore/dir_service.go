@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"fmt"
-	r "github.com/dancannon/gorethink"
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
@@ -24,13 +23,13 @@ type dirService struct {
 	access   domain.Access
 }
 
-// newDirService creates a new idService that connects to the database using
-// the given session.
-func newDirService(session *r.Session) *dirService {
-	access := domain.NewAccess(dai.NewRProjects(session), dai.NewRFiles(session), dai.NewRUsers(session))
+// newDirService creates a new dirService that accesses the database through
+// the given store.
+func newDirService(store dai.Store) *dirService {
+	access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
 	return &dirService{
-		dirs:     dai.NewRDirs(session),
-		projects: dai.NewRProjects(session),
+		dirs:     store.Dirs(),
+		projects: store.Projects(),
 		access:   access,
 	}
 }
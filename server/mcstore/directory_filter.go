@@ -1,10 +1,8 @@
 package mcstore
 
 import (
-	"net/http"
-
-	r "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/pkg/ws"
@@ -12,7 +10,7 @@ import (
 
 func directoryFilter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
 	project := request.Attribute("project").(schema.Project)
-	session := request.Attribute("session").(*r.Session)
+	store := request.Attribute("store").(dai.Store)
 
 	var d struct {
 		DirectoryID string `json:"directory_id"`
@@ -21,17 +19,15 @@ func directoryFilter(request *restful.Request, response *restful.Response, chain
 	err := request.ReadEntity(&d)
 
 	switch {
-	case err != nil:
-		response.WriteErrorString(http.StatusNotAcceptable, "No directory_id found")
-	case d.DirectoryID == "":
-		response.WriteErrorString(http.StatusNotAcceptable, "No directory_id found")
+	case err != nil, d.DirectoryID == "":
+		ws.WriteError(app.Errorf(app.ErrInvalid, "No directory_id found"), response)
 	default:
-		dirs := dai.NewRDirs(session)
-		projects := dai.NewRProjects(session)
+		dirs := store.Dirs()
+		projects := store.Projects()
 		if dir, err := dirs.ByID(d.DirectoryID); err != nil {
 			ws.WriteError(err, response)
-		} else if !projects.HasDirectory(project.ID, dir.ID) {
-			response.WriteErrorString(http.StatusNotAcceptable, "Uknown directory for project")
+		} else if !projects.HasDirectory(project.ID, dir.ID) || dir.InTrash() {
+			ws.WriteError(app.Errorf(app.ErrInvalid, "Unknown directory for project"), response)
 		} else {
 			request.SetAttribute("directory", *dir)
 			chain.ProcessFilter(request, response)
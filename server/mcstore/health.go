@@ -0,0 +1,105 @@
+package mcstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	r "github.com/dancannon/gorethink"
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db"
+	"github.com/materials-commons/mcstore/pkg/health"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/filters"
+	"github.com/materials-commons/mcstore/server/mcstore/uploads"
+	"github.com/materials-commons/mcstore/server/mcstore/uploads/processor"
+)
+
+const (
+	// healthCheckTimeout is how long a single readiness check can take.
+	healthCheckTimeout = 10 * time.Second
+
+	// defaultMinFreeMB is the free space each MCDIR root needs when
+	// MCSTORED_MIN_FREE_MB isn't set.
+	defaultMinFreeMB = 1024
+)
+
+// NewReadinessChecker creates the checks that decide whether the server can
+// handle requests. The database, libmagic and each MCDIR root are critical.
+// Search and the file conversion programs only limit what the server can do,
+// so their failures are reported as warnings. The database check is left out
+// when database is nil, which is the case when the server runs on an in-memory
+// store.
+func NewReadinessChecker(database func() error) *health.Checker {
+	var checks []health.Check
+	if database != nil {
+		checks = append(checks, health.Check{Name: "database", Critical: true, Run: database})
+	}
+	checks = append(checks, []health.Check{
+		{Name: "search", Critical: false, Run: filters.PingSearch},
+		{Name: "mediatype", Critical: true, Run: uploads.CheckMediaTypes},
+		{Name: "processors", Critical: false, Run: processor.CheckCommands},
+	}...)
+	checks = append(checks, mcdirChecks()...)
+	return health.NewChecker(healthCheckTimeout, checks...)
+}
+
+// RethinkDBCheck returns a database check that verifies that a session can be
+// created and that the configured database exists.
+func RethinkDBCheck(sc db.SessionCreater) func() error {
+	return func() error {
+		return checkDatabase(sc)
+	}
+}
+
+// checkDatabase verifies that a session can be created and that the
+// configured database exists.
+func checkDatabase(sc db.SessionCreater) error {
+	session, err := sc.RSession()
+	if err != nil {
+		return err
+	}
+	defer db.Release(sc, session)
+
+	name := config.GetString("MCDB_NAME")
+	res, err := r.DBList().Contains(name).Run(session)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	if err := res.One(&found); err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("database %s doesn't exist", name)
+	}
+	return nil
+}
+
+// mcdirChecks creates a check for each MCDIR root that it is writable and has
+// enough free space.
+func mcdirChecks() []health.Check {
+	if config.GetString("MCDIR") == "" {
+		return []health.Check{
+			{Name: "mcdir", Critical: true, Run: func() error { return errors.New("MCDIR not set") }},
+		}
+	}
+
+	minFreeMB := config.GetInt("MCSTORED_MIN_FREE_MB")
+	if minFreeMB <= 0 {
+		minFreeMB = defaultMinFreeMB
+	}
+	minFree := uint64(minFreeMB) * 1024 * 1024
+
+	var checks []health.Check
+	for _, path := range app.MCDir.Paths() {
+		checks = append(checks, health.Check{
+			Name:     "mcdir:" + path,
+			Critical: true,
+			Run:      health.DirWritable(path, minFree),
+		})
+	}
+	return checks
+}
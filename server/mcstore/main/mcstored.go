@@ -2,42 +2,88 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/emicklei/go-restful"
 	"github.com/inconshreveable/log15"
 	"github.com/jessevdk/go-flags"
 	"github.com/materials-commons/config"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/audit"
 	"github.com/materials-commons/mcstore/pkg/db"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/migrate"
 	"github.com/materials-commons/mcstore/pkg/domain"
+	"github.com/materials-commons/mcstore/pkg/health"
+	"github.com/materials-commons/mcstore/pkg/metrics"
+	"github.com/materials-commons/mcstore/pkg/scrub"
+	"github.com/materials-commons/mcstore/pkg/trash"
 	"github.com/materials-commons/mcstore/server/mcstore"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/serverconfig"
+	"github.com/materials-commons/mcstore/server/mcstore/uploads"
 )
 
-// Options for server startup
+// Options for server startup. Options that aren't given fall back to the
+// environment, then the config file, then the defaults in serverconfig.Defaults.
 type serverOptions struct {
-	MCDir    string `long:"mcdir" description:"Directory path to materials commons file storage"`
-	PrintPid bool   `long:"print-pid" description:"Prints the server pid to stdout"`
-	HTTPPort uint   `long:"http-port" description:"Port webserver listens on" default:"5010"`
-	LogLevel string `long:"log-level" description:"Logging level for server (debug, info, warn, error, crit)" default:"info"`
+	Config   string   `long:"config" description:"YAML (.yaml, .yml) or TOML (.toml) config file" env:"MCSTORED_CONFIG"`
+	MCDir    string   `long:"mcdir" description:"Directory path to materials commons file storage"`
+	PrintPid bool     `long:"print-pid" description:"Prints the server pid to stdout"`
+	HTTPPort uint     `long:"http-port" description:"Port webserver listens on (default 5010)"`
+	Bind     []string `long:"bind" description:"Address to listen on, host or host:port. Can be repeated (default all addresses)"`
+	LogLevel string   `long:"log-level" description:"Logging level for server (debug, info, warn, error, crit) (default info)"`
+	Shutdown uint     `long:"shutdown-timeout" description:"Seconds to wait for uploads in progress when shutting down (default 30)"`
+}
+
+// Options for the integrity scrubber
+type scrubOptions struct {
+	Interval int `long:"scrub-interval" description:"Hours between checks of each stored file, 0 turns the scrubber off (default 168)"`
+	Rate     int `long:"scrub-rate" description:"MB per second the scrubber reads at, 0 for no limit (default 10)"`
+}
+
+// Options for the project trash
+type trashOptions struct {
+	Retention int `long:"trash-retention" description:"Days deleted files stay in the trash before they are purged, 0 keeps them until purged by hand (default 30)"`
+}
+
+// Options for serving HTTPS
+type tlsOptions struct {
+	CertFile   string `long:"tls-cert" description:"Certificate file. Serves HTTPS when given with --tls-key"`
+	KeyFile    string `long:"tls-key" description:"Private key file for the certificate"`
+	ClientAuth string `long:"tls-client-auth" description:"Client certificates: none, request or require (default none)"`
+	ClientCA   string `long:"tls-client-ca" description:"CA certificates used to verify client certificates"`
 }
 
 // Options for the database
 type databaseOptions struct {
+	Store      string `long:"store" description:"Store to use: rethinkdb, postgres, sqlite or memory (default rethinkdb)"`
+	Fixture    string `long:"fixture" description:"JSON file of users and projects to load into the memory, postgres or sqlite store"`
 	Connection string `long:"db-connect" description:"The database connection string"`
-	Name       string `long:"db" description:"Database to use" default:"materialscommons"`
+	DSN        string `long:"db-dsn" description:"The data source name for the postgres and sqlite stores"`
+	Name       string `long:"db" description:"Database to use (default materialscommons)"`
 }
 
 // Options for elastic search
 type searchServerOptions struct {
-	ESUrl string `long:"es-url" description:"The elastic search server url" default:"http://localhost:9200"`
+	ESUrl string `long:"es-url" description:"The elastic search server url (default http://localhost:9200)"`
 }
 
 // Break the options into option groups.
 type options struct {
 	Server       serverOptions       `group:"Server Options"`
+	TLS          tlsOptions          `group:"TLS Options"`
+	Scrub        scrubOptions        `group:"Scrubber Options"`
+	Trash        trashOptions        `group:"Trash Options"`
 	Database     databaseOptions     `group:"Database Options"`
 	SearchServer searchServerOptions `group:"Search Server Options"`
 }
@@ -65,30 +111,77 @@ func main() {
 		fmt.Println(os.Getpid())
 	}
 
+	conf, err := serverconfig.New(opts.Server.Config)
+	if err != nil {
+		fmt.Println("Unable to load config:", err)
+		os.Exit(1)
+	}
+
+	if err := conf.Init(); err != nil {
+		fmt.Println("Unable to initialize config:", err)
+		os.Exit(1)
+	}
+	config.SetErrorHandler(configErrorHandler)
+
 	setupConfig(opts)
-	server(opts.Server.HTTPPort)
+	server(conf)
 }
 
 // setupConfig sets up configuration overrides that were passed in on the command line.
 func setupConfig(opts options) {
 	configSetNotEmpty("MCDB_CONNECTION", opts.Database.Connection)
 	configSetNotEmpty("MCDB_NAME", opts.Database.Name)
+	configSetNotEmpty("MCSTORED_STORE", opts.Database.Store)
+	configSetNotEmpty("MCSTORED_FIXTURE", opts.Database.Fixture)
+	configSetNotEmpty("MCDB_DSN", opts.Database.DSN)
 	configSetNotEmpty("MCDIR", opts.Server.MCDir)
 	configSetNotEmpty("MC_ES_URL", opts.SearchServer.ESUrl)
+	configSetNotEmpty("MCSTORED_BIND", strings.Join(opts.Server.Bind, ","))
+	configSetNotEmpty("MCSTORED_LOG_LEVEL", opts.Server.LogLevel)
+	configSetNotEmpty("MCSTORED_TLS_CERT", opts.TLS.CertFile)
+	configSetNotEmpty("MCSTORED_TLS_KEY", opts.TLS.KeyFile)
+	configSetNotEmpty("MCSTORED_TLS_CLIENT_AUTH", opts.TLS.ClientAuth)
+	configSetNotEmpty("MCSTORED_TLS_CLIENT_CA", opts.TLS.ClientCA)
 
-	if lvl, err := log15.LvlFromString(opts.Server.LogLevel); err != nil {
-		fmt.Printf("Invalid Log Level: %s, setting to info\n", opts.Server.LogLevel)
-		app.SetLogLvl(log15.LvlInfo)
-	} else {
-		fmt.Println("Log level set to:", opts.Server.LogLevel)
-		app.SetLogLvl(lvl)
+	if opts.Server.HTTPPort != 0 {
+		config.Set("MCSTORED_HTTP_PORT", int(opts.Server.HTTPPort))
 	}
 
+	if opts.Server.Shutdown != 0 {
+		config.Set("MCSTORED_SHUTDOWN_TIMEOUT", int(opts.Server.Shutdown))
+	}
+
+	if opts.Scrub.Interval != 0 {
+		config.Set("MCSTORED_SCRUB_INTERVAL", opts.Scrub.Interval)
+	}
+
+	if opts.Scrub.Rate != 0 {
+		config.Set("MCSTORED_SCRUB_RATE", opts.Scrub.Rate)
+	}
+
+	if opts.Trash.Retention != 0 {
+		config.Set("MCSTORED_TRASH_RETENTION", opts.Trash.Retention)
+	}
+
+	setLogLevel()
+
 	// Server always monitors for changes in the database
 	config.Set("MCSTORED_MONITOR_USERS", true)
 	config.Set("MCSTORED_MONITOR_DB_CHANGES", true)
 }
 
+// setLogLevel sets the log level from MCSTORED_LOG_LEVEL.
+func setLogLevel() {
+	level := config.GetString("MCSTORED_LOG_LEVEL")
+	if lvl, err := log15.LvlFromString(level); err != nil {
+		fmt.Printf("Invalid Log Level: %s, setting to info\n", level)
+		app.SetLogLvl(log15.LvlInfo)
+	} else {
+		fmt.Println("Log level set to:", level)
+		app.SetLogLvl(lvl)
+	}
+}
+
 // configSetNotEmpty sets key if to value only if value isn't equal to the empty string.
 func configSetNotEmpty(key, value string) {
 	if value != "" {
@@ -96,19 +189,402 @@ func configSetNotEmpty(key, value string) {
 	}
 }
 
-// server implements the actual serve for mcstored. It sets up the http routes and handlers. This
-// method never returns.
-func server(port uint) {
-	session := db.RSessionMust()
-	uploads := dai.NewRUploads(session)
-	uploads.DeleteAll()
+// server implements the actual serve for mcstored. It sets up the http routes and handlers, and
+// serves requests until the server fails or is sent SIGINT or SIGTERM. SIGHUP reloads the config
+// file and TLS certificate.
+func server(conf *serverconfig.Config) {
+	ss, err := openStore()
+	if err != nil {
+		app.Log.Crit("Unable to open store", "store", config.GetString("MCSTORED_STORE"), "error", err)
+		return
+	}
+	defer ss.close()
+
+	readiness := mcstore.NewReadinessChecker(ss.databaseCheck())
+	logReadiness(readiness.Run())
 
-	container := mcstore.NewServicesContainer(db.Sessions)
+	store := ss.store
+	uploadsDAI := store.Uploads()
+	if restored, err := uploads.RestoreTracker(uploadsDAI); err != nil {
+		app.Log.Error("Unable to restore uploads", "error", err)
+	} else {
+		app.Log.Info("Restored uploads from last shutdown", "count", restored)
+	}
+
+	container, publicContainer := ss.containers()
 	http.Handle("/", container)
+	http.Handle("/public/", publicContainer)
+
+	access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
+	shares := domain.NewShares(store.ShareLinks(), store.Files(), access, mcstore.ShareLinkKey())
+	recorder := audit.NewRecorder(store.AuditEvents(), store.Files())
+	dataHandler := mcstore.NewDataHandler(access, shares, store.Datasets(), store.Users(), store.APITokens(), recorder)
+	http.Handle("/datafiles/static/", mcstore.InstrumentHandler("/datafiles/static/{file}", dataHandler))
+
+	scrubber := startScrubber(store)
+	purger := startPurger(store)
+
+	http.Handle("/metrics", metrics.Handler())
+	http.Handle("/healthz", health.LiveHandler())
+	http.Handle("/readyz", readiness.Handler())
+
+	tlsConfig, certs, err := serverTLSConfig()
+	if err != nil {
+		app.Log.Crit("Unable to set up TLS", "error", err)
+		return
+	}
+
+	listeners, err := listen(bindAddresses(), tlsConfig)
+	if err != nil {
+		app.Log.Crit("Unable to listen", "error", err)
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	srv := &http.Server{}
+	serveErr := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		app.Log.Info("Listening", "address", listener.Addr().String(), "tls", tlsConfig != nil)
+		go func(listener net.Listener) {
+			serveErr <- srv.Serve(listener)
+		}(listener)
+	}
+
+	for {
+		select {
+		case err := <-serveErr:
+			app.Log.Crit("http Server failed", "error", err)
+			return
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reload(conf, certs)
+				continue
+			}
+
+			app.Log.Info("Shutting down", "signal", sig.String())
+			srv.SetKeepAlivesEnabled(false)
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			shutdown(uploadsDAI, scrubber, purger, time.Duration(config.GetInt("MCSTORED_SHUTDOWN_TIMEOUT"))*time.Second)
+			return
+		}
+	}
+}
+
+// serverStore is the store the server runs on. Pool is set for RethinkDB,
+// sql is set for PostgreSQL and SQLite, and mem is set for the in-memory store.
+type serverStore struct {
+	store dai.Store
+	pool  *db.Pool
+	sql   *dai.SQLStore
+	mem   *dai.MemStore
+}
+
+// openStore opens the store named by MCSTORED_STORE. The rethinkdb, postgres and
+// sqlite stores must have the latest schema, which mcdb migrate creates. The
+// postgres and sqlite stores connect to MCDB_DSN. The memory, postgres and
+// sqlite stores load MCSTORED_FIXTURE when it is set.
+func openStore() (*serverStore, error) {
+	switch storeType := config.GetString("MCSTORED_STORE"); storeType {
+	case "rethinkdb":
+		pool := db.NewPool(db.PoolOptionsFromConfig())
+		if err := pool.WaitConnected(30 * time.Second); err != nil {
+			pool.Close()
+			return nil, err
+		}
+		session, err := pool.RSession()
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		version, err := migrate.New(session, config.GetString("MCDB_NAME")).Version()
+		if err == nil {
+			err = checkSchemaVersion(version, migrate.Latest())
+		}
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		return &serverStore{store: dai.NewRStore(session), pool: pool}, nil
+
+	case "postgres", "sqlite":
+		sqlStore, err := openSQLStore(storeType)
+		if err != nil {
+			return nil, err
+		}
+		if err := loadFixture(sqlStore); err != nil {
+			sqlStore.Close()
+			return nil, err
+		}
+		return &serverStore{store: sqlStore, sql: sqlStore}, nil
+
+	case "memory":
+		mem := dai.NewMemStore()
+		if err := loadFixture(mem); err != nil {
+			return nil, err
+		}
+		app.Log.Warn("Using the memory store, nothing is saved when the server stops")
+		return &serverStore{store: mem, mem: mem}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown store %q", storeType)
+	}
+}
+
+// openSQLStore opens the SQLStore at MCDB_DSN.
+func openSQLStore(storeType string) (*dai.SQLStore, error) {
+	dsn := config.GetString("MCDB_DSN")
+	if dsn == "" {
+		return nil, fmt.Errorf("the %s store needs a data source name (MCDB_DSN)", storeType)
+	}
+
+	driver := storeType
+	if storeType == "sqlite" {
+		driver = "sqlite3"
+	}
+
+	sqlStore, err := dai.OpenSQLStore(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := sqlStore.SchemaVersion()
+	if err == nil {
+		err = checkSchemaVersion(version, dai.LatestSchemaVersion())
+	}
+	if err != nil {
+		sqlStore.Close()
+		return nil, err
+	}
+	return sqlStore, nil
+}
+
+// checkSchemaVersion returns an error if the database schema is behind the
+// version the server needs.
+func checkSchemaVersion(version, latest int) error {
+	if version < latest {
+		return fmt.Errorf("database schema is at version %d, the server needs version %d; run mcdb migrate", version, latest)
+	}
+	return nil
+}
+
+// loadFixture loads MCSTORED_FIXTURE into the store, if it is set.
+func loadFixture(store interface {
+	Load(r io.Reader) error
+}) error {
+	fixture := config.GetString("MCSTORED_FIXTURE")
+	if fixture == "" {
+		return nil
+	}
+
+	f, err := os.Open(fixture)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.Load(f)
+}
+
+// databaseCheck returns the readiness check for the store's database, or nil
+// for the memory store.
+func (s *serverStore) databaseCheck() func() error {
+	switch {
+	case s.pool != nil:
+		return mcstore.RethinkDBCheck(s.pool)
+	case s.sql != nil:
+		return s.sql.Check
+	default:
+		return nil
+	}
+}
+
+// containers creates the services and public services containers for the store.
+func (s *serverStore) containers() (*restful.Container, *restful.Container) {
+	switch {
+	case s.sql != nil:
+		return mcstore.NewStoreServicesContainer(s.sql), mcstore.NewStorePublicServicesContainer(s.sql)
+	case s.mem != nil:
+		return mcstore.NewStoreServicesContainer(s.mem), mcstore.NewStorePublicServicesContainer(s.mem)
+	default:
+		return mcstore.NewServicesContainer(s.pool), mcstore.NewPublicServicesContainer(s.pool)
+	}
+}
+
+// close closes the database pool or SQL database.
+func (s *serverStore) close() {
+	switch {
+	case s.pool != nil:
+		s.pool.Close()
+	case s.sql != nil:
+		s.sql.Close()
+	}
+}
+
+// reload rereads the config file and TLS certificate. Only settings that are safe to change
+// while running take effect, which is the log level and anything looked up per request.
+// Changes to addresses, ports and TLS options need a restart.
+func reload(conf *serverconfig.Config, certs *serverconfig.CertReloader) {
+	if err := conf.Reload(); err != nil {
+		app.Log.Error("Unable to reload config", "file", conf.Path(), "error", err)
+	} else {
+		app.Log.Info("Reloaded config", "file", conf.Path())
+		setLogLevel()
+	}
+
+	if certs != nil {
+		if err := certs.Reload(); err != nil {
+			app.Log.Error("Unable to reload TLS certificate", "error", err)
+		} else {
+			app.Log.Info("Reloaded TLS certificate")
+		}
+	}
+}
+
+// serverTLSConfig creates the TLS configuration from the MCSTORED_TLS settings. It returns a
+// nil config when no certificate is configured, in which case the server uses plain HTTP.
+func serverTLSConfig() (*tls.Config, *serverconfig.CertReloader, error) {
+	certFile := config.GetString("MCSTORED_TLS_CERT")
+	keyFile := config.GetString("MCSTORED_TLS_KEY")
+	switch {
+	case certFile == "" && keyFile == "":
+		return nil, nil, nil
+	case certFile == "" || keyFile == "":
+		return nil, nil, fmt.Errorf("both a certificate and a key are needed for TLS")
+	}
+
+	certs, err := serverconfig.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientAuth, err := serverconfig.ClientAuth(config.GetString("MCSTORED_TLS_CLIENT_AUTH"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig, err := serverconfig.TLSConfig(certs, clientAuth, config.GetString("MCSTORED_TLS_CLIENT_CA"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return tlsConfig, certs, nil
+}
+
+// bindAddresses returns the addresses to listen on. MCSTORED_BIND is a comma separated list of
+// hosts or host:port pairs. Hosts without a port use MCSTORED_HTTP_PORT.
+func bindAddresses() []string {
+	port := strconv.Itoa(config.GetInt("MCSTORED_HTTP_PORT"))
+	bind := config.GetString("MCSTORED_BIND")
+	if bind == "" {
+		return []string{":" + port}
+	}
+
+	var addresses []string
+	for _, address := range strings.Split(bind, ",") {
+		address = strings.TrimSpace(address)
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(strings.Trim(address, "[]"), port)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// listen opens a listener on each address. The listeners serve TLS when tlsConfig isn't nil.
+// If any address fails, the listeners already opened are closed.
+func listen(addresses []string, tlsConfig *tls.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, address := range addresses {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+
+		if tlsConfig != nil {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
 
-	access := domain.NewAccess(dai.NewRProjects(session), dai.NewRFiles(session), dai.NewRUsers(session))
-	dataHandler := mcstore.NewDataHandler(access)
-	http.Handle("/datafiles/static/", dataHandler)
+// startScrubber starts the scrubber that verifies stored files, unless
+// MCSTORED_SCRUB_INTERVAL is 0 or there is no MCDIR. It returns nil when the
+// scrubber isn't started.
+func startScrubber(store dai.Store) *scrub.Scrubber {
+	interval := config.GetInt("MCSTORED_SCRUB_INTERVAL")
+	if interval <= 0 || config.GetString("MCDIR") == "" {
+		app.Log.Info("Scrubber is off")
+		return nil
+	}
+
+	options := scrub.Options{
+		Interval:       time.Duration(interval) * time.Hour,
+		BytesPerSecond: int64(config.GetInt("MCSTORED_SCRUB_RATE")) * 1024 * 1024,
+	}
+	scrubber := scrub.New(store, options)
+	scrubber.Start()
+	app.Log.Info("Started scrubber", "interval", options.Interval.String(), "rate_mb", config.GetInt("MCSTORED_SCRUB_RATE"))
+	return scrubber
+}
 
-	app.Log.Crit("http Server failed", "error", http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+// startPurger starts purging items from the trash once they are older than
+// MCSTORED_TRASH_RETENTION days. It returns nil if purging is turned off.
+func startPurger(store dai.Store) *trash.Purger {
+	days := config.GetInt("MCSTORED_TRASH_RETENTION")
+	if days <= 0 || config.GetString("MCDIR") == "" {
+		app.Log.Info("Trash purging is off")
+		return nil
+	}
+
+	retention := time.Duration(days) * 24 * time.Hour
+	purger := trash.NewPurger(store, retention)
+	purger.Start()
+	app.Log.Info("Started trash purger", "retention", retention.String())
+	return purger
+}
+
+// shutdown waits for uploads in progress to finish, saves the state of unfinished
+// uploads so they can be continued after a restart, and stops the changefeed monitors,
+// the scrubber and the trash purger.
+func shutdown(uploadsDAI dai.Uploads, scrubber *scrub.Scrubber, purger *trash.Purger, timeout time.Duration) {
+	if !uploads.Drain(timeout) {
+		app.Log.Warn("Uploads still in progress at shutdown", "timeout", timeout.String())
+	}
+
+	if err := uploads.FlushTracker(uploadsDAI); err != nil {
+		app.Log.Error("Unable to save upload state", "error", err)
+	}
+
+	if !mcstore.StopChangeMonitors(5 * time.Second) {
+		app.Log.Warn("Changefeed monitors didn't stop")
+	}
+
+	if scrubber != nil && !scrubber.Stop(5*time.Second) {
+		app.Log.Warn("Scrubber didn't stop")
+	}
+
+	if purger != nil && !purger.Stop(5*time.Second) {
+		app.Log.Warn("Trash purger didn't stop")
+	}
+
+	app.Log.Info("Shutdown complete")
+}
+
+// logReadiness logs the checks that failed at startup. The server still starts
+// so that /readyz can report when the problems are fixed.
+func logReadiness(report health.Report) {
+	for name, result := range report.Checks {
+		switch result.Status {
+		case health.StatusFail:
+			app.Log.Error("Readiness check failed", "check", name, "error", result.Error)
+		case health.StatusWarn:
+			app.Log.Warn("Readiness check failed", "check", name, "error", result.Error)
+		}
+	}
 }
@@ -0,0 +1,106 @@
+package mcstoreapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/ws"
+)
+
+// Error is an error response from the server. Its Cause is the application
+// error for the response's error code, so app.Is can be used to check what
+// kind of error it is.
+type Error struct {
+	StatusCode int                    // HTTP status code
+	Code       string                 // Error code, see the ws package for the list
+	Message    string                 // Description of the error
+	RequestID  string                 // ID of the request, to match against server logs
+	Details    map[string]interface{} // Additional information about the error
+}
+
+// Error returns the message and the request ID if there is one.
+func (e *Error) Error() string {
+	if e.RequestID == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (request %s)", e.Message, e.RequestID)
+}
+
+// Cause returns the application error for the error code. When the server
+// didn't send a known error code the HTTP status code is used instead.
+func (e *Error) Cause() error {
+	if err := ws.ErrorForCode(e.Code); err != app.ErrUnclassified {
+		return err
+	}
+	return HTTPStatusToError(e.StatusCode)
+}
+
+// ResponseToError tests the response, its body and the list of errors to
+// determine the error to return. A response with an error status is returned
+// as an *Error, decoded from the body if the server sent an error response.
+// The *Error always has the request ID if one was sent.
+func ResponseToError(resp *http.Response, body string, errs []error) error {
+	if len(errs) != 0 {
+		return app.ErrInvalid
+	}
+
+	err := StatusToError(resp.StatusCode, body)
+	if e, ok := err.(*Error); ok && e.RequestID == "" {
+		e.RequestID = responseRequestID(resp)
+	}
+	return err
+}
+
+// responseRequestID returns the request ID the server returned, or the one
+// that was sent if the server didn't return one.
+func responseRequestID(resp *http.Response) string {
+	if id := resp.Header.Get(ws.RequestIDHeader); id != "" {
+		return id
+	}
+
+	if resp.Request != nil {
+		return resp.Request.Header.Get(ws.RequestIDHeader)
+	}
+
+	return ""
+}
+
+// StatusToError returns an *Error for an error status, decoded from the body
+// if the server sent an error response. It returns nil for other statuses.
+func StatusToError(status int, body string) error {
+	if status < 300 {
+		return nil
+	}
+
+	err := &Error{
+		StatusCode: status,
+		Message:    http.StatusText(status),
+	}
+
+	var response ws.ErrorResponse
+	if json.Unmarshal([]byte(body), &response) == nil && response.Error.Code != "" {
+		err.Code = response.Error.Code
+		err.Message = response.Error.Message
+		err.RequestID = response.Error.RequestID
+		err.Details = response.Error.Details
+	}
+
+	return err
+}
+
+// ezhttpError translates the status and error returned by an ezhttp request.
+// ezhttp returns the body of an error response as the error's message.
+func ezhttpError(status int, err error) error {
+	switch {
+	case status == 0:
+		return err
+	case status > 299 && err != nil:
+		return StatusToError(status, err.Error())
+	case err != nil:
+		return err
+	default:
+		return StatusToError(status, "")
+	}
+}
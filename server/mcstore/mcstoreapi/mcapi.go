@@ -17,13 +17,16 @@ func MCUrl() string {
 	return config.GetString("mcurl")
 }
 
-// MCClient creates a new EzClient.
+// MCClient creates a new EzClient. Each request it makes is sent with a
+// request ID.
 func MCClient() *ezhttp.EzClient {
 	mcurl := MCUrl()
+	client := ezhttp.NewClient()
 	if strings.HasPrefix(mcurl, "https") {
-		return ezhttp.NewSSLClient()
+		client = ezhttp.NewSSLClient()
 	}
-	return ezhttp.NewClient()
+	client.Transport = newRequestIDTransport(client.Transport)
+	return client
 }
 
 // Url create the url for accessing a service. It adds the mcurl to
@@ -43,7 +46,8 @@ func Url(path string) string {
 
 // ToError tests the list of errors and the response to determine
 // the type of error to return. It calls HTTPStatusToError to
-// translate response status codes to an error.
+// translate response status codes to an error. Use ResponseToError
+// when the response body is available.
 func ToError(resp *http.Response, errs []error) error {
 	if len(errs) != 0 {
 		return app.ErrInvalid
@@ -65,6 +69,10 @@ func HTTPStatusToError(status int) error {
 		return app.ErrExists
 	case status == http.StatusUnauthorized:
 		return app.ErrNoAccess
+	case status == 429:
+		return app.ErrRateLimited
+	case status == http.StatusServiceUnavailable:
+		return app.ErrUnavailable
 	case status > 299:
 		app.Log.Errorf("Unclassified error %d", status)
 		return app.ErrUnclassified
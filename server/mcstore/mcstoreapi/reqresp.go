@@ -67,3 +67,84 @@ type GetDirectoryResponse struct {
 	DirectoryID string `json:"directory_id"`
 	Path        string `json:"path"`
 }
+
+// CreateTokenRequest requests a new api token. Projects limits the token to
+// the listed projects, an empty list means the token isn't limited. Scopes
+// must contain at least one of read, upload or write. ExpiresInDays is the
+// number of days the token is valid for.
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Projects      []string `json:"projects"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// CreateTokenResponse returns the newly created token. The Token field is
+// the only time the token is returned, it cannot be retrieved again.
+type CreateTokenResponse struct {
+	ID      string    `json:"id"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// CreateShareLinkRequest requests a signed link for a file. When Original is
+// false the link serves the converted preview if one exists. A MaxDownloads
+// of 0 means the number of downloads isn't limited.
+type CreateShareLinkRequest struct {
+	FileID         string `json:"file_id"`
+	Original       bool   `json:"original"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+	MaxDownloads   int    `json:"max_downloads"`
+}
+
+// CreateShareLinkResponse returns the signed link.
+type CreateShareLinkResponse struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// PublicFile describes a file in a published dataset. URL downloads the
+// original file and doesn't require an apikey.
+type PublicFile struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	Mime     string `json:"mime"`
+	URL      string `json:"url"`
+}
+
+// CreateDatasetRequest requests a new dataset made up of the listed files
+// plus all the files in the listed directories and their subdirectories.
+type CreateDatasetRequest struct {
+	ProjectID    string   `json:"project_id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Authors      []string `json:"authors"`
+	License      string   `json:"license"`
+	Keywords     []string `json:"keywords"`
+	DOI          string   `json:"doi"`
+	FileIDs      []string `json:"file_ids"`
+	DirectoryIDs []string `json:"directory_ids"`
+}
+
+// ArchiveRequest requests an archive of files. Files can be selected by id,
+// by directory (including its subdirectories) and by dataset. Format is
+// either zip (the default) or tar.gz. Bag packages a dataset as a BagIt bag,
+// and can only be used with DatasetID.
+type ArchiveRequest struct {
+	FileIDs     []string `json:"file_ids"`
+	DirectoryID string   `json:"directory_id"`
+	DatasetID   string   `json:"dataset_id"`
+	Format      string   `json:"format"`
+	Bag         bool     `json:"bag"`
+}
+
+// TrashRequest moves a file or directory into its project's trash. When
+// FileID is given every version of the file is moved out of DirectoryID,
+// otherwise DirectoryID and everything under it is moved.
+type TrashRequest struct {
+	DirectoryID string `json:"directory_id"`
+	FileID      string `json:"file_id"`
+}
@@ -0,0 +1,44 @@
+package mcstoreapi
+
+import (
+	"net/http"
+
+	"github.com/materials-commons/mcstore/pkg/ws"
+	"github.com/parnurzeal/gorequest"
+)
+
+// requestIDTransport sends a new request ID with each request that doesn't
+// already have one. The server logs the ID and returns it in error responses,
+// so a failure can be found in the server logs.
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+// newRequestIDTransport wraps base, or http.DefaultTransport if base is nil.
+func newRequestIDTransport(base http.RoundTripper) *requestIDTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &requestIDTransport{base: base}
+}
+
+// RoundTrip adds the request ID header to a copy of the request and sends it.
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(ws.RequestIDHeader) != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	r := *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for key, values := range req.Header {
+		r.Header[key] = values
+	}
+	r.Header.Set(ws.RequestIDHeader, ws.NewRequestID())
+	return t.base.RoundTrip(&r)
+}
+
+// withRequestID adds a new request ID to a gorequest request. It must be
+// called after the method, such as Get, since those clear the headers.
+func withRequestID(agent *gorequest.SuperAgent) *gorequest.SuperAgent {
+	return agent.Set(ws.RequestIDHeader, ws.NewRequestID())
+}
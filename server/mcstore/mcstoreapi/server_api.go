@@ -6,9 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"io"
 	"net/http"
-	"os"
 
 	"fmt"
 
@@ -16,6 +14,7 @@ import (
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/app/flow"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/download"
 	"github.com/parnurzeal/gorequest"
 	"gnd.la/net/urlutil"
 )
@@ -38,11 +37,7 @@ func NewServerAPI() *ServerAPI {
 func (s *ServerAPI) CreateUpload(req CreateUploadRequest) (*CreateUploadResponse, error) {
 	var uploadResponse CreateUploadResponse
 	sc, err := s.client.JSON(&req).JSONPost(Url("/upload"), &uploadResponse)
-	if err != nil {
-		return nil, err
-	}
-	err = HTTPStatusToError(sc)
-	if err != nil {
+	if err = ezhttpError(sc, err); err != nil {
 		return nil, err
 	}
 	return &uploadResponse, nil
@@ -56,6 +51,8 @@ func (s *ServerAPI) SendFlowData(req *flow.Request) (*UploadChunkResponse, error
 	switch {
 	case err != nil:
 		return nil, err
+	case sc > 299:
+		return nil, StatusToError(sc, body)
 	case sc != 200:
 		return nil, app.ErrInternal
 	default:
@@ -69,8 +66,8 @@ func (s *ServerAPI) SendFlowData(req *flow.Request) (*UploadChunkResponse, error
 
 // ListUploadRequests will return all the upload requests for a given project ID.
 func (s *ServerAPI) ListUploadRequests(projectID string) ([]UploadEntry, error) {
-	r, body, errs := s.agent.Get(Url("/upload/" + projectID)).End()
-	if err := ToError(r, errs); err != nil {
+	r, body, errs := withRequestID(s.agent.Get(Url("/upload/" + projectID))).End()
+	if err := ResponseToError(r, body, errs); err != nil {
 		return nil, err
 	}
 	var entries []UploadEntry
@@ -80,8 +77,8 @@ func (s *ServerAPI) ListUploadRequests(projectID string) ([]UploadEntry, error)
 
 // DeleteUploadRequest will delete a given upload request.
 func (s *ServerAPI) DeleteUploadRequest(uploadID string) error {
-	r, _, errs := s.agent.Delete(Url("/upload/" + uploadID)).End()
-	return ToError(r, errs)
+	r, body, errs := withRequestID(s.agent.Delete(Url("/upload/" + uploadID))).End()
+	return ResponseToError(r, body, errs)
 }
 
 // This really doesn't belong here as the server code is in a different server. However
@@ -98,8 +95,8 @@ func (s *ServerAPI) GetUserAPIKey(username, password string) (apikey string, err
 		Password: password,
 	}
 	apiURL := urlutil.MustJoin(MCUrl(), "api/user/"+username+"/apikey")
-	r, body, errs := s.agent.Put(apiURL).Send(l).End()
-	if err := ToError(r, errs); err != nil {
+	r, body, errs := withRequestID(s.agent.Put(apiURL)).Send(l).End()
+	if err := ResponseToError(r, body, errs); err != nil {
 		return apikey, err
 	}
 
@@ -124,8 +121,8 @@ func (s *ServerAPI) GetDirectory(req DirectoryRequest) (directoryID string, err
 		Path:      projectBasedPath,
 		ProjectID: req.ProjectID,
 	}
-	r, body, errs := s.agent.Post(Url("/project2/directory")).Send(getDirReq).End()
-	if err = ToError(r, errs); err != nil {
+	r, body, errs := withRequestID(s.agent.Post(Url("/project2/directory"))).Send(getDirReq).End()
+	if err = ResponseToError(r, body, errs); err != nil {
 		return directoryID, err
 	}
 
@@ -155,9 +152,7 @@ func (s *ServerAPI) GetDirectoryList(projectID, directoryID string) (*ServerDir,
 	var dir ServerDir
 	apiURL := "/v2/projects/" + projectID + "/directories/" + directoryID
 	if sc, err := s.client.JSONGet(Url(apiURL), &dir); err != nil {
-		return nil, err
-	} else if err = HTTPStatusToError(sc); err != nil {
-		return nil, err
+		return nil, ezhttpError(sc, err)
 	}
 
 	return &dir, nil
@@ -174,40 +169,29 @@ func toProjectPath(projectName, path string) (string, error) {
 func (s *ServerAPI) CreateProject(req CreateProjectRequest) (*CreateProjectResponse, error) {
 	var response CreateProjectResponse
 	sc, err := s.client.JSON(&req).JSONPost(Url("/projects"), &response)
-	if err != nil {
-		return nil, err
-	}
-
-	err = HTTPStatusToError(sc)
-	if err != nil {
+	if err = ezhttpError(sc, err); err != nil {
 		return nil, err
 	}
 	return &response, nil
 }
 
-func (s *ServerAPI) DownloadFile(projectID, fileID, fpath string) error {
-	fmt.Println("DownloadFile:", projectID, fileID, fpath)
-	out, err := os.Create(fpath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	fileURL := Url("/datafiles/static/"+fileID) + "&original=true"
+// DownloadFile downloads a file to fpath using parallel ranged requests.
+// The file is written to a temporary file that is renamed to fpath once its
+// checksum has been verified, so fpath is never left truncated. An interrupted
+// download resumes from the chunks already downloaded.
+func (s *ServerAPI) DownloadFile(projectID string, file *ServerFile, fpath string, parallel int) error {
+	fmt.Println("DownloadFile:", projectID, file.ID, fpath)
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(fileURL)
-	if err != nil {
-		return err
+	req := download.Request{
+		URL:      Url("/datafiles/static/"+file.ID) + "&original=true",
+		Path:     fpath,
+		Size:     file.Size,
+		Checksum: file.Checksum,
+		Parallel: parallel,
 	}
-	defer resp.Body.Close()
-
-	var n int64
-	n, err = io.Copy(out, resp.Body)
-	fmt.Println("  wrote bytes:", n)
-	return err
+	return download.New(&http.Client{Transport: newRequestIDTransport(tr)}).Download(req)
 }
 
 type ServerFile struct {
@@ -224,8 +208,8 @@ func (s *ServerAPI) GetFileForPath(projectID, fpath string) (*ServerFile, error)
 	}
 
 	urlPath := "/v2/projects/" + projectID + "/files_by_path"
-	r, body, errs := s.agent.Put(Url(urlPath)).Send(filePathArg).End()
-	if err := ToError(r, errs); err != nil {
+	r, body, errs := withRequestID(s.agent.Put(Url(urlPath))).Send(filePathArg).End()
+	if err := ResponseToError(r, body, errs); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,90 @@
+package mcstore
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/metrics"
+)
+
+var (
+	// requestCount counts requests by method, route and status code.
+	requestCount = metrics.NewCounter("mcstore_http_requests_total",
+		"HTTP requests handled, by method, route and status code.", "method", "route", "code")
+
+	// requestDuration tracks request latencies by method and route.
+	requestDuration = metrics.NewHistogram("mcstore_http_request_duration_seconds",
+		"HTTP request latencies in seconds, by method and route.", metrics.DefaultBuckets, "method", "route")
+
+	// bytesReceived counts the bytes in request bodies, including uploaded data.
+	bytesReceived = metrics.NewCounter("mcstore_bytes_received_total", "Bytes received in request bodies.")
+
+	// bytesServed counts the bytes in response bodies, including downloaded data.
+	bytesServed = metrics.NewCounter("mcstore_bytes_served_total", "Bytes sent in response bodies.")
+
+	// apikeyCacheLookups counts apikey cache hits and misses.
+	apikeyCacheLookups = metrics.NewCounter("mcstore_apikey_cache_lookups_total",
+		"API key cache lookups, by result (hit or miss).", "result")
+
+	// dbSessionErrors counts failures to create a database session for a request.
+	dbSessionErrors = metrics.NewCounter("mcstore_db_session_errors_total",
+		"Requests that failed because a RethinkDB session couldn't be created.")
+)
+
+// observeRequest records the metrics for a handled request.
+func observeRequest(method, route string, status int, received, served int64, elapsed time.Duration) {
+	requestCount.Inc(method, route, strconv.Itoa(status))
+	requestDuration.Observe(elapsed.Seconds(), method, route)
+	if received > 0 {
+		bytesReceived.Add(float64(received))
+	}
+	bytesServed.Add(float64(served))
+}
+
+// metricsFilter records request metrics. It should be the first filter so
+// that requests rejected by other filters are counted.
+type metricsFilter struct{}
+
+// Filter records the metrics for a request once it has been handled. Requests
+// are grouped by the route that handled them rather than their path, so that
+// ids don't create a series per request.
+func (f *metricsFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(request, response)
+	observeRequest(request.Request.Method, request.SelectedRoutePath(), response.StatusCode(),
+		request.Request.ContentLength, int64(response.ContentLength()), time.Since(start))
+}
+
+// InstrumentHandler records request metrics for a handler that isn't part of a
+// restful container. All requests are recorded under route.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		w := &countingResponseWriter{ResponseWriter: writer, status: http.StatusOK}
+		handler.ServeHTTP(w, req)
+		observeRequest(req.Method, route, w.status, req.ContentLength, w.written, time.Since(start))
+	})
+}
+
+// countingResponseWriter remembers the status code and counts the bytes written
+// to a response.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+// WriteHeader remembers the status code.
+func (w *countingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written.
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
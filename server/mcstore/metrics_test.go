@@ -0,0 +1,36 @@
+package mcstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	It("Should count instrumented requests and the bytes they serve", func() {
+		handler := InstrumentHandler("/test/{id}", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("hello"))
+		}))
+
+		count := requestCount.Value("GET", "/test/{id}", "418")
+		served := bytesServed.Value()
+
+		req, _ := http.NewRequest("GET", "http://localhost/test/abc", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		Expect(requestCount.Value("GET", "/test/{id}", "418")).To(Equal(count + 1))
+		Expect(bytesServed.Value()).To(Equal(served + 5))
+	})
+
+	It("Should count apikey cache hits and misses", func() {
+		cache := newAPIKeyCache()
+		hits := apikeyCacheLookups.Value("hit")
+		misses := apikeyCacheLookups.Value("miss")
+
+		cache.getUser("nosuchkey")
+		Expect(apikeyCacheLookups.Value("miss")).To(Equal(misses + 1))
+		Expect(apikeyCacheLookups.Value("hit")).To(Equal(hits))
+	})
+})
@@ -0,0 +1,107 @@
+package mcstore
+
+import (
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/oai"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+)
+
+// An oaiResource is the OAI-PMH endpoint that repositories and indexers
+// harvest published dataset metadata from.
+type oaiResource struct {
+	log *app.Logger
+}
+
+// newOAIResource creates a new OAI-PMH resource.
+func newOAIResource() rest.Service {
+	return &oaiResource{
+		log: app.NewLog("resource", "oai"),
+	}
+}
+
+// WebService creates an instance of the OAI-PMH web service. The protocol
+// allows requests as either GET or form encoded POST.
+func (r *oaiResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/oai").Produces("text/xml")
+
+	ws.Route(ws.GET("").To(rest.PublicRouteHandler(r.handle)).
+		Param(ws.QueryParameter("verb", "OAI-PMH verb").DataType("string")).
+		Doc("Answers an OAI-PMH request"))
+
+	ws.Route(ws.POST("").To(rest.PublicRouteHandler(r.handle)).
+		Consumes("application/x-www-form-urlencoded").
+		Doc("Answers an OAI-PMH request"))
+
+	return ws
+}
+
+// handle answers an OAI-PMH request. Protocol errors are part of the XML
+// response, so they are sent with a 200 status.
+func (r *oaiResource) handle(request *restful.Request, response *restful.Response) (interface{}, error) {
+	if err := request.Request.ParseForm(); err != nil {
+		return nil, app.Errorf(app.ErrInvalid, "bad request arguments: %s", err)
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	provider := oai.NewProvider(store, oaiRepository(request.Request))
+
+	resp, err := provider.Handle(request.Request.Form)
+	if err != nil {
+		return nil, err
+	}
+
+	response.AddHeader("Content-Type", "text/xml; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	if err := writeXML(response, resp); err != nil {
+		r.log.Error("Unable to write OAI-PMH response", "error", err)
+	}
+	return nil, nil
+}
+
+// writeXML writes v as an XML document.
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// oaiRepository describes this server as an OAI-PMH repository. The name,
+// admin email and identifier namespace come from MCSTORED_OAI_NAME,
+// MCSTORED_OAI_ADMIN_EMAIL and MCSTORED_OAI_REPOSITORY_ID. The namespace
+// defaults to the host the request was sent to.
+func oaiRepository(req *http.Request) oai.Repository {
+	name := config.GetString("MCSTORED_OAI_NAME")
+	if name == "" {
+		name = "Materials Commons"
+	}
+
+	id := config.GetString("MCSTORED_OAI_REPOSITORY_ID")
+	if id == "" {
+		id = req.Host
+		if host, _, err := net.SplitHostPort(req.Host); err == nil {
+			id = host
+		}
+	}
+
+	return oai.Repository{
+		Name:       name,
+		BaseURL:    serverURL(req, "/oai"),
+		AdminEmail: config.GetString("MCSTORED_OAI_ADMIN_EMAIL"),
+		Identifier: id,
+		Publisher:  publisher(),
+		DatasetURL: func(datasetID string) string {
+			return serverURL(req, "/public/datasets/"+datasetID)
+		},
+	}
+}
@@ -1,9 +1,6 @@
 package filters
 
 import (
-	"net/http"
-
-	r "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
@@ -17,10 +14,10 @@ type projectAccessFilterDAI struct {
 	access   domain.Access
 }
 
-func newProjectAccessFilterDAI(session *r.Session) *projectAccessFilterDAI {
-	files := dai.NewRFiles(session)
-	users := dai.NewRUsers(session)
-	projects := dai.NewRProjects(session)
+func newProjectAccessFilterDAI(store dai.Store) *projectAccessFilterDAI {
+	files := store.Files()
+	users := store.Users()
+	projects := store.Projects()
 	access := domain.NewAccess(projects, files, users)
 	return &projectAccessFilterDAI{
 		projects: projects,
@@ -30,12 +27,14 @@ func newProjectAccessFilterDAI(session *r.Session) *projectAccessFilterDAI {
 
 func ProjectAccess(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
 	user := request.Attribute("user").(schema.User)
-	session := request.Attribute("session").(*r.Session)
+	store := request.Attribute("store").(dai.Store)
 
 	if projectID := getProjectID(request); projectID == "" {
-		response.WriteErrorString(http.StatusNotAcceptable, "No project id found")
+		ws.WriteError(app.Errorf(app.ErrInvalid, "No project id found"), response)
+	} else if !TokenAllowsProject(request, projectID) {
+		ws.WriteError(app.ErrNoAccess, response)
 	} else {
-		f := newProjectAccessFilterDAI(session)
+		f := newProjectAccessFilterDAI(store)
 		if project, err := f.getProjectValidatingAccess(projectID, user.ID); err != nil {
 			ws.WriteError(err, response)
 		} else {
@@ -45,6 +44,16 @@ func ProjectAccess(request *restful.Request, response *restful.Response, chain *
 	}
 }
 
+// TokenAllowsProject checks if the request was made with an api token, and if so that
+// the token isn't restricted from the project. Requests authenticated by apikey are
+// not restricted.
+func TokenAllowsProject(request *restful.Request, projectID string) bool {
+	if token, ok := request.Attribute("apitoken").(schema.APIToken); ok {
+		return token.AllowsProject(projectID)
+	}
+	return true
+}
+
 // getProjectID retrieves the project ID by first checking the path parameter and if
 // that fails then checking the payload.
 func getProjectID(request *restful.Request) string {
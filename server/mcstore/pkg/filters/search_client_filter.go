@@ -1,43 +1,65 @@
 package filters
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"sync"
 
 	"github.com/emicklei/go-restful"
 	"github.com/materials-commons/config"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/ws"
 	"gopkg.in/olivere/elastic.v2"
 )
 
 var (
-	clientInit sync.Once
-	client     *elastic.Client
+	clientMutex sync.Mutex
+	client      *elastic.Client
 )
 
 func SearchClient(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
 	if client := getSearchClient(); client == nil {
-		response.WriteErrorString(http.StatusInternalServerError, "Unable to connect to search service")
+		ws.WriteError(app.Errorf(app.ErrUnavailable, "Unable to connect to search service"), response)
 	} else {
 		request.SetAttribute("searchclient", client)
 		chain.ProcessFilter(request, response)
 	}
 }
 
+// getSearchClient returns the search client, connecting if needed. A failed
+// connection is retried on the next call.
 func getSearchClient() *elastic.Client {
-	clientInit.Do(func() {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+	if client == nil {
 		url := esURL()
 		app.Log.Infof("Connecting to search url: %s", url)
 		c, err := elastic.NewClient(elastic.SetURL(url))
 		if err != nil {
-			app.Log.Errorf("Couldn't connect to ElasticSearch")
+			app.Log.Errorf("Couldn't connect to ElasticSearch: %s", err)
+			return nil
 		}
 		client = c
-	})
+	}
 	return client
 }
 
+// PingSearch checks that the search service responds.
+func PingSearch() error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(esURL())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("search service returned %s", resp.Status)
+	}
+	return nil
+}
+
 func esURL() string {
 	if esURL := config.GetString("MC_ES_URL"); esURL != "" {
 		return esURL
@@ -0,0 +1,128 @@
+// Package serverconfig sets up configuration and TLS for mcstored. Settings
+// are looked up, in order, from command line overrides, the environment, a
+// YAML or TOML config file, and finally built in defaults. The config file
+// and TLS certificates can be reloaded while the server is running.
+package serverconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/config/cfg"
+	"github.com/materials-commons/config/handler"
+	"github.com/materials-commons/config/loader"
+)
+
+// Defaults are the values used when a setting isn't given anywhere else.
+var Defaults = map[string]interface{}{
+	"MCDB_NAME":                 "materialscommons",
+	"MC_ES_URL":                 "http://localhost:9200",
+	"MCSTORED_HTTP_PORT":        5010,
+	"MCSTORED_LOG_LEVEL":        "info",
+	"MCSTORED_SCRUB_INTERVAL":   168,
+	"MCSTORED_SCRUB_RATE":       10,
+	"MCSTORED_SHUTDOWN_TIMEOUT": 30,
+	"MCSTORED_STORE":            "rethinkdb",
+	"MCSTORED_TLS_CLIENT_AUTH":  "none",
+	"MCSTORED_TRASH_RETENTION":  30,
+}
+
+// A Config is the layered configuration for the server.
+type Config struct {
+	path      string
+	overrides cfg.Handler
+	file      *handler.HotSwapHandler
+}
+
+// New creates a Config that reads settings from the config file at path. An
+// empty path means there is no config file.
+func New(path string) (*Config, error) {
+	fileHandler, err := FileHandler(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		path:      path,
+		overrides: handler.Sync(handler.Map()),
+		file:      handler.HotSwap(fileHandler),
+	}
+	return c, nil
+}
+
+// Handler returns the handler that combines all the configuration sources.
+// Settings made with config.Set go into the overrides, which take priority.
+func (c *Config) Handler() cfg.Handler {
+	return handler.Multi(c.overrides, handler.Env(), c.file, handler.MapUse(copyMap(Defaults)))
+}
+
+// Init makes this Config the configuration used by the config package.
+func (c *Config) Init() error {
+	return config.Init(c.Handler())
+}
+
+// Path returns the path of the config file.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Reload rereads the config file. If the file can't be read the current
+// settings are kept.
+func (c *Config) Reload() error {
+	fileHandler, err := FileHandler(c.path)
+	if err != nil {
+		return err
+	}
+	return c.file.SwapHandlerInit(fileHandler)
+}
+
+// FileHandler loads a config file into a handler. The format is chosen by the
+// extension: .yaml or .yml for YAML, and .toml for TOML. Keys are the names of
+// the environment variables, and are case insensitive. An empty path returns
+// a handler with no settings.
+func FileHandler(path string) (cfg.Handler, error) {
+	values := make(map[string]interface{})
+	if path == "" {
+		return handler.MapUse(values), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var l cfg.Loader
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		l = loader.YAML(f)
+	case ".toml":
+		l = loader.TOML(f)
+	default:
+		return nil, fmt.Errorf("unknown config file format %s, expected .yaml, .yml or .toml", path)
+	}
+
+	var loaded map[string]interface{}
+	if err := l.Load(&loaded); err != nil {
+		return nil, fmt.Errorf("unable to load %s: %s", path, err)
+	}
+
+	for key, value := range loaded {
+		values[strings.ToUpper(key)] = value
+	}
+
+	h := handler.MapUse(values)
+	return h, h.Init()
+}
+
+// copyMap returns a shallow copy of m.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
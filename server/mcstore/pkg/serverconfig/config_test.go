@@ -0,0 +1,101 @@
+package serverconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/materials-commons/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var dir string
+
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(BeNil())
+		return path
+	}
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "serverconfig")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		os.Setenv("MCSTORED_TEST_ENV", "")
+	})
+
+	Describe("FileHandler Tests", func() {
+		It("Should load YAML files with case insensitive keys", func() {
+			h, err := FileHandler(write("mcstored.yaml", "mcdir: /data\nMCSTORED_HTTP_PORT: 6000\n"))
+			Expect(err).To(BeNil())
+			value, err := h.Get("MCDIR")
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("/data"))
+			value, _ = h.Get("MCSTORED_HTTP_PORT")
+			Expect(value).To(Equal(6000))
+		})
+
+		It("Should load TOML files", func() {
+			h, err := FileHandler(write("mcstored.toml", "mcdir = \"/data\"\nMCSTORED_TLS_CLIENT_AUTH = \"require\"\n"))
+			Expect(err).To(BeNil())
+			value, _ := h.Get("MCSTORED_TLS_CLIENT_AUTH")
+			Expect(value).To(Equal("require"))
+		})
+
+		It("Should fail for unknown formats and bad files", func() {
+			_, err := FileHandler(write("mcstored.ini", "mcdir=/data\n"))
+			Expect(err).NotTo(BeNil())
+			_, err = FileHandler(write("bad.yaml", "mcdir: [\n"))
+			Expect(err).NotTo(BeNil())
+			_, err = FileHandler(filepath.Join(dir, "missing.yaml"))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("Should have no settings when there is no file", func() {
+			h, err := FileHandler("")
+			Expect(err).To(BeNil())
+			_, err = h.Get("MCDIR")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Config Tests", func() {
+		It("Should look up overrides, then the environment, then the file, then defaults", func() {
+			path := write("mcstored.yaml", "MCSTORED_TEST_ENV: file\nMCSTORED_TEST_FILE: file\nMCSTORED_TEST_OVERRIDE: file\n")
+			c, err := New(path)
+			Expect(err).To(BeNil())
+			h := c.Handler()
+			Expect(h.Init()).To(BeNil())
+
+			os.Setenv("MCSTORED_TEST_ENV", "env")
+			h.Set("MCSTORED_TEST_OVERRIDE", "override")
+
+			cfg := config.New(h)
+			Expect(cfg.GetString("MCSTORED_TEST_OVERRIDE")).To(Equal("override"))
+			Expect(cfg.GetString("MCSTORED_TEST_ENV")).To(Equal("env"))
+			Expect(cfg.GetString("MCSTORED_TEST_FILE")).To(Equal("file"))
+			Expect(cfg.GetInt("MCSTORED_HTTP_PORT")).To(Equal(5010))
+		})
+
+		It("Should pick up changes to the file on reload and keep settings when reload fails", func() {
+			path := write("mcstored.yaml", "MCSTORED_LOG_LEVEL: debug\n")
+			c, _ := New(path)
+			h := c.Handler()
+			Expect(h.Init()).To(BeNil())
+			cfg := config.New(h)
+			Expect(cfg.GetString("MCSTORED_LOG_LEVEL")).To(Equal("debug"))
+
+			write("mcstored.yaml", "MCSTORED_LOG_LEVEL: warn\n")
+			Expect(c.Reload()).To(BeNil())
+			Expect(cfg.GetString("MCSTORED_LOG_LEVEL")).To(Equal("warn"))
+
+			write("mcstored.yaml", "MCSTORED_LOG_LEVEL: [\n")
+			Expect(c.Reload()).NotTo(BeNil())
+			Expect(cfg.GetString("MCSTORED_LOG_LEVEL")).To(Equal("warn"))
+		})
+	})
+})
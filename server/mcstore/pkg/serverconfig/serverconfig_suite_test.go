@@ -0,0 +1,13 @@
+package serverconfig
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestServerconfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Serverconfig Suite")
+}
@@ -0,0 +1,102 @@
+package serverconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// A CertReloader holds a certificate and key loaded from files. The files can
+// be reloaded, for example after the certificate is renewed, without
+// restarting the server. New connections use the reloaded certificate.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+}
+
+// NewCertReloader loads the certificate and key.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload rereads the certificate and key. If they can't be loaded the current
+// certificate is kept.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.cert = &cert
+	r.mutex.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate. It is used as the
+// GetCertificate function of a tls.Config.
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// ClientAuth converts a client certificate setting to a tls.ClientAuthType. The
+// settings are "none", "request" (verify a certificate if one is given) and
+// "require".
+func ClientAuth(setting string) (tls.ClientAuthType, error) {
+	switch setting {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth setting %s, expected none, request or require", setting)
+	}
+}
+
+// TLSConfig creates the TLS configuration for the server. Client certificates
+// are verified against the certificates in clientCAFile, which is required
+// unless clientAuth is tls.NoClientCert.
+func TLSConfig(certs *CertReloader, clientAuth tls.ClientAuthType, clientCAFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		ClientAuth:     clientAuth,
+		MinVersion:     tls.VersionTLS10,
+		NextProtos:     []string{"http/1.1"},
+	}
+
+	if clientAuth == tls.NoClientCert {
+		return tlsConfig, nil
+	}
+
+	if clientCAFile == "" {
+		return nil, fmt.Errorf("client certificates require a CA file")
+	}
+
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
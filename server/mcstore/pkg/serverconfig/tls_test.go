@@ -0,0 +1,119 @@
+package serverconfig
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeCert creates a self signed certificate for name and writes the
+// certificate and key in PEM format to dir.
+func writeCert(dir, name string) (certFile, keyFile string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Expect(err).To(BeNil())
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	Expect(ioutil.WriteFile(certFile, certPEM, 0600)).To(BeNil())
+	Expect(ioutil.WriteFile(keyFile, keyPEM, 0600)).To(BeNil())
+	return certFile, keyFile
+}
+
+// commonName returns the common name of the reloader's current certificate.
+func commonName(r *CertReloader) string {
+	cert, err := r.GetCertificate(nil)
+	Expect(err).To(BeNil())
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	Expect(err).To(BeNil())
+	return parsed.Subject.CommonName
+}
+
+var _ = Describe("TLS", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "serverconfig")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("CertReloader Tests", func() {
+		It("Should serve the reloaded certificate", func() {
+			certFile, keyFile := writeCert(dir, "first")
+			r, err := NewCertReloader(certFile, keyFile)
+			Expect(err).To(BeNil())
+			Expect(commonName(r)).To(Equal("first"))
+
+			writeCert(dir, "second")
+			Expect(r.Reload()).To(BeNil())
+			Expect(commonName(r)).To(Equal("second"))
+		})
+
+		It("Should keep the current certificate when the reload fails", func() {
+			certFile, keyFile := writeCert(dir, "first")
+			r, _ := NewCertReloader(certFile, keyFile)
+			Expect(ioutil.WriteFile(keyFile, []byte("bad key"), 0600)).To(BeNil())
+			Expect(r.Reload()).NotTo(BeNil())
+			Expect(commonName(r)).To(Equal("first"))
+		})
+
+		It("Should fail when the files can't be loaded", func() {
+			_, err := NewCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("TLSConfig Tests", func() {
+		It("Should translate client auth settings", func() {
+			auth, err := ClientAuth("")
+			Expect(err).To(BeNil())
+			Expect(auth).To(Equal(tls.NoClientCert))
+			auth, _ = ClientAuth("require")
+			Expect(auth).To(Equal(tls.RequireAndVerifyClientCert))
+			auth, _ = ClientAuth("request")
+			Expect(auth).To(Equal(tls.VerifyClientCertIfGiven))
+			_, err = ClientAuth("always")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("Should require a CA file for client certificates", func() {
+			certFile, keyFile := writeCert(dir, "server")
+			r, _ := NewCertReloader(certFile, keyFile)
+
+			_, err := TLSConfig(r, tls.RequireAndVerifyClientCert, "")
+			Expect(err).NotTo(BeNil())
+
+			tlsConfig, err := TLSConfig(r, tls.RequireAndVerifyClientCert, certFile)
+			Expect(err).To(BeNil())
+			Expect(tlsConfig.ClientCAs).NotTo(BeNil())
+			Expect(tlsConfig.ClientAuth).To(Equal(tls.RequireAndVerifyClientCert))
+		})
+	})
+})
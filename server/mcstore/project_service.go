@@ -1,7 +1,6 @@
 package mcstore
 
 import (
-	r "github.com/dancannon/gorethink"
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
@@ -22,12 +21,12 @@ type projectService struct {
 	access   domain.Access
 }
 
-// newProjectService creates a new idService that connects to the database using
-// the given session.
-func newProjectService(session *r.Session) *projectService {
+// newProjectService creates a new projectService that accesses the database
+// through the given store.
+func newProjectService(store dai.Store) *projectService {
 	return &projectService{
-		projects: dai.NewRProjects(session),
-		dirs:     dai.NewRDirs(session),
+		projects: store.Projects(),
+		dirs:     store.Dirs(),
 	}
 }
 
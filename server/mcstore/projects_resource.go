@@ -1,22 +1,16 @@
 package mcstore
 
 import (
-	"archive/zip"
-	"net/http"
-	"os"
-	"path/filepath"
-
-	"io/ioutil"
-
-	rethinkdb "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
-	"github.com/hashicorp/go-uuid"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/archive"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/pkg/domain"
+	"github.com/materials-commons/mcstore/pkg/trash"
 	"github.com/materials-commons/mcstore/pkg/ws/rest"
 	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/filters"
 )
 
 // An projectsResource holds the state and services needed for the
@@ -48,11 +42,15 @@ func (r *projectsResource) WebService() *restful.WebService {
 		Reads(mcstoreapi.GetDirectoryRequest{}).
 		Writes(mcstoreapi.GetDirectoryResponse{}))
 
-	ws.Route(ws.POST("archive").To(rest.RouteHandler(r.createDownloadZipFile)).
-		Doc("Creates a zipfile archive of request files ids"))
+	ws.Route(ws.POST("archive").To(rest.RouteHandler1(r.downloadArchive)).
+		Doc("Streams an archive of the requested files, directory or dataset, or a BagIt bag of a dataset").
+		Reads(mcstoreapi.ArchiveRequest{}).
+		Produces("application/zip", "application/gzip", restful.MIME_JSON))
 
-	ws.Route(ws.GET("/download/archive/{archive}").To(rest.RouteHandler1(r.downloadArchiveZipFile)).
-		Doc("Download a created archive"))
+	ws.Route(ws.GET("{project}/usage").Filter(filters.ProjectAccess).To(rest.RouteHandler(r.getUsage)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Doc("Returns the storage a project uses, including what is in its trash").
+		Writes(trash.Usage{}))
 
 	return ws
 }
@@ -60,14 +58,19 @@ func (r *projectsResource) WebService() *restful.WebService {
 // createProject services the create project request. It will ensure that the user
 // doesn't have a project matching the given project name.
 func (r *projectsResource) createProject(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
-	session := request.Attribute("session").(*rethinkdb.Session)
+	store := request.Attribute("store").(dai.Store)
 	var req mcstoreapi.CreateProjectRequest
 	if err := request.ReadEntity(&req); err != nil {
 		app.Log.Debugf("createProject ReadEntity failed: %s", err)
 		return nil, err
 	}
 
-	projectService := newProjectService(session)
+	if token := requestToken(request); token != nil && token.RestrictedToProjects() {
+		// A token limited to projects cannot be used to create new ones.
+		return nil, app.ErrNoAccess
+	}
+
+	projectService := newProjectService(store)
 	proj, existing, err := projectService.createProject(req.Name, user.ID, req.MustNotExist)
 	switch {
 	case err != nil:
@@ -85,14 +88,18 @@ func (r *projectsResource) createProject(request *restful.Request, response *res
 // by their path relative to the project. The getDirectory service will create a directory
 // that doesn't exist.
 func (r *projectsResource) getDirectory(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
-	session := request.Attribute("session").(*rethinkdb.Session)
+	store := request.Attribute("store").(dai.Store)
 	var req mcstoreapi.GetDirectoryRequest
 	if err := request.ReadEntity(&req); err != nil {
 		app.Log.Debugf("getDirectory ReadEntity failed: %s", err)
 		return nil, err
 	}
 
-	dirService := newDirService(session)
+	if !filters.TokenAllowsProject(request, req.ProjectID) {
+		return nil, app.ErrNoAccess
+	}
+
+	dirService := newDirService(store)
 	dir, err := dirService.createDir(req.ProjectID, req.Path)
 	switch {
 	case err != nil:
@@ -107,58 +114,95 @@ func (r *projectsResource) getDirectory(request *restful.Request, response *rest
 	}
 }
 
-func (r *projectsResource) createDownloadZipFile(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
-	var (
-		zipResponse struct {
-			ArchiveID string `json:"archive_id"`
-		}
+// downloadArchive streams an archive of the requested files to the client. Files
+// the user can't access are left out of the archive and listed in its manifest.
+func (r *projectsResource) downloadArchive(request *restful.Request, response *restful.Response, user schema.User) error {
+	var req mcstoreapi.ArchiveRequest
+	if err := request.ReadEntity(&req); err != nil {
+		app.Log.Debugf("downloadArchive ReadEntity failed: %s", err)
+		return err
+	}
 
-		zipRequest struct {
-			FileIDs []string `json:"file_ids"`
+	format, err := archive.ParseFormat(req.Format)
+	switch {
+	case err != nil:
+		return err
+	case len(req.FileIDs) == 0 && req.DirectoryID == "" && req.DatasetID == "":
+		return app.Errorf(app.ErrInvalid, "no files, directory or dataset selected")
+	case req.Bag && (req.DatasetID == "" || len(req.FileIDs) != 0 || req.DirectoryID != ""):
+		return app.Errorf(app.ErrInvalid, "a bag can only be made for a dataset")
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	files := store.Files()
+	dirs := store.Dirs()
+	datasets := store.Datasets()
+	access := domain.NewAccess(store.Projects(), files, store.Users())
+	builder := newArchiveBuilder(files, dirs, datasets)
+
+	if req.DirectoryID != "" {
+		dir, err := dirs.ByID(req.DirectoryID)
+		switch {
+		case err != nil || dir.InTrash():
+			return app.ErrNotFound
+		case !filters.TokenAllowsProject(request, dir.Project) || !access.AllowedByOwner(dir.Project, user.ID):
+			return app.ErrNoAccess
 		}
-	)
 
-	if err := request.ReadEntity(&zipRequest); err != nil {
-		app.Log.Debugf("createDownloadZipFile ReadEntity failed: %s", err)
-		return nil, err
+		if err := builder.addDirectory(dir); err != nil {
+			return err
+		}
 	}
 
-	session := request.Attribute("session").(*rethinkdb.Session)
+	if req.DatasetID != "" {
+		dataset, err := datasets.ByID(req.DatasetID)
+		switch {
+		case err != nil:
+			return app.ErrNotFound
+		case dataset.Published:
+			// Anyone can access a published dataset.
+		case !filters.TokenAllowsProject(request, dataset.ProjectID) || !access.AllowedByOwner(dataset.ProjectID, user.ID):
+			return app.ErrNoAccess
+		}
 
-	u, err := uuid.GenerateUUID()
-	if err != nil {
-		return nil, err
-	}
-	zipfile, err := os.Create(filepath.Join("/tmp", u+".zip"))
-	defer zipfile.Close()
-
-	archive := zip.NewWriter(zipfile)
-	defer archive.Close()
-
-	rfiles := dai.NewRFiles(session)
-	rprojects := dai.NewRProjects(session)
-	rusers := dai.NewRUsers(session)
-	access := domain.NewAccess(rprojects, rfiles, rusers)
-
-	for _, fileID := range zipRequest.FileIDs {
-		if f, err := access.GetFile(user.APIKey, fileID); err == nil {
-			fpath := app.MCDir.FilePath(fileID)
-			if contents, err := ioutil.ReadFile(fpath); err == nil {
-				if f, err := archive.Create(f.Name); err == nil {
-					f.Write(contents)
-				}
+		if err := builder.addDataset(dataset); err != nil {
+			return err
+		}
+
+		if req.Bag {
+			r.log.Info("Dataset bag download", "user", user.ID, "datasetid", dataset.ID, "files", len(builder.entries), "format", format)
+			if err := builder.writeBag(response, "dataset-"+dataset.ID, format, archive.DatasetBagInfo(dataset)); err != nil {
+				r.log.Error("Dataset bag download failed", "user", user.ID, "datasetid", dataset.ID, "error", err)
 			}
+			return nil
 		}
 	}
 
-	zipResponse.ArchiveID = u
+	builder.addFiles(req.FileIDs, func(fileID string) bool {
+		if _, err := access.GetFile(user.APIKey, fileID); err != nil {
+			return false
+		}
+
+		if token := requestToken(request); token != nil && token.RestrictedToProjects() {
+			project, err := files.GetProject(fileID)
+			return err == nil && token.AllowsProject(project.ID)
+		}
+
+		return true
+	})
 
-	return &zipResponse, nil
-}
+	r.log.Info("Archive download", "user", user.ID, "files", len(builder.entries), "skipped", len(builder.skipped), "format", format)
 
-func (r *projectsResource) downloadArchiveZipFile(request *restful.Request, response *restful.Response, user schema.User) error {
-	archiveZipPath := filepath.Join("/tmp", request.PathParameter("archive"))
-	http.ServeFile(response.ResponseWriter, request.Request, archiveZipPath)
-	defer os.Remove(archiveZipPath)
+	if err := builder.writeArchive(response, "archive", format); err != nil {
+		r.log.Error("Archive download failed", "user", user.ID, "error", err)
+	}
 	return nil
 }
+
+// getUsage returns the storage used by a project. Items in the project's trash
+// count until they are purged.
+func (r *projectsResource) getUsage(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	project := request.Attribute("project").(schema.Project)
+	store := request.Attribute("store").(dai.Store)
+	return trash.New(store).Usage(&project)
+}
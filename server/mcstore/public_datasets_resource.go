@@ -0,0 +1,189 @@
+package mcstore
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/archive"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+)
+
+// A publicDatasetsResource gives anonymous read access to published datasets.
+type publicDatasetsResource struct {
+	log *app.Logger
+}
+
+// newPublicDatasetsResource creates a new public datasets resource.
+func newPublicDatasetsResource() rest.Service {
+	return &publicDatasetsResource{
+		log: app.NewLog("resource", "public-datasets"),
+	}
+}
+
+// WebService creates an instance of the public datasets web service.
+func (r *publicDatasetsResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/public/datasets").Produces(restful.MIME_JSON)
+
+	ws.Route(ws.GET("{dataset}").To(rest.PublicRouteHandler(r.getDataset)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Doc("Gets a published dataset").
+		Writes(schema.Dataset{}))
+
+	ws.Route(ws.GET("{dataset}/files").To(rest.PublicRouteHandler(r.getDatasetFiles)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Doc("Lists the files in a published dataset").
+		Writes([]mcstoreapi.PublicFile{}))
+
+	ws.Route(ws.GET("{dataset}/archive").To(rest.PublicRouteHandler(r.downloadDatasetArchive)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Param(ws.QueryParameter("format", "archive format, zip or tar.gz").DataType("string")).
+		Doc("Downloads all the files in a published dataset as an archive").
+		Produces("application/zip", "application/gzip", restful.MIME_JSON))
+
+	ws.Route(ws.GET("{dataset}/bag").To(rest.PublicRouteHandler(r.downloadDatasetBag)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Param(ws.QueryParameter("format", "archive format, zip or tar.gz").DataType("string")).
+		Doc("Downloads a published dataset as a BagIt bag").
+		Produces("application/zip", "application/gzip", restful.MIME_JSON))
+
+	ws.Route(ws.GET("{dataset}/metadata").To(rest.PublicRouteHandler(r.getDatasetMetadata)).
+		Param(ws.PathParameter("dataset", "dataset id").DataType("string")).
+		Param(ws.QueryParameter("format", "metadata format, schemaorg or datacite").DataType("string")).
+		Doc("Gets a published dataset's metadata as schema.org JSON-LD or DataCite XML").
+		Produces("application/ld+json", "application/xml", restful.MIME_JSON))
+
+	return ws
+}
+
+// getDataset returns a published dataset.
+func (r *publicDatasetsResource) getDataset(request *restful.Request, response *restful.Response) (interface{}, error) {
+	store := request.Attribute("store").(dai.Store)
+	return publishedDataset(store.Datasets(), request.PathParameter("dataset"))
+}
+
+// getDatasetFiles lists the files in a published dataset along with the URL
+// to download each one.
+func (r *publicDatasetsResource) getDatasetFiles(request *restful.Request, response *restful.Response) (interface{}, error) {
+	store := request.Attribute("store").(dai.Store)
+	datasets := store.Datasets()
+
+	dataset, err := publishedDataset(datasets, request.PathParameter("dataset"))
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := datasets.Files(dataset.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicFiles := make([]mcstoreapi.PublicFile, 0, len(files))
+	for _, file := range files {
+		publicFiles = append(publicFiles, mcstoreapi.PublicFile{
+			ID:       file.ID,
+			Name:     file.Name,
+			Size:     file.Size,
+			Checksum: file.Checksum,
+			Mime:     file.MediaType.Mime,
+			URL:      serverURL(request.Request, "/datafiles/static/"+file.ID+"?original=true"),
+		})
+	}
+	return publicFiles, nil
+}
+
+// downloadDatasetArchive streams an archive of all the files in a published
+// dataset. The format query parameter selects zip (the default) or tar.gz.
+// Each archive download counts as a single dataset download.
+func (r *publicDatasetsResource) downloadDatasetArchive(request *restful.Request, response *restful.Response) (interface{}, error) {
+	format, err := archive.ParseFormat(request.QueryParameter("format"))
+	if err != nil {
+		return nil, err
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	datasets := store.Datasets()
+
+	dataset, err := publishedDataset(datasets, request.PathParameter("dataset"))
+	if err != nil {
+		return nil, err
+	}
+
+	builder := newArchiveBuilder(store.Files(), store.Dirs(), datasets)
+	if err := builder.addDataset(dataset); err != nil {
+		return nil, err
+	}
+
+	if err := datasets.IncrementDownloads(dataset.ID); err != nil {
+		r.log.Error("Unable to count dataset download", "datasetid", dataset.ID, "error", err)
+	}
+
+	r.log.Info("Dataset archive download", "datasetid", dataset.ID, "files", len(builder.entries), "remote", request.Request.RemoteAddr)
+
+	if err := builder.writeArchive(response, "dataset-"+dataset.ID, format); err != nil {
+		r.log.Error("Dataset archive download failed", "datasetid", dataset.ID, "error", err)
+	}
+	return nil, nil
+}
+
+// downloadDatasetBag streams a published dataset as a BagIt bag, serialized
+// as a zip (the default) or tar.gz. It counts as a dataset download.
+func (r *publicDatasetsResource) downloadDatasetBag(request *restful.Request, response *restful.Response) (interface{}, error) {
+	format, err := archive.ParseFormat(request.QueryParameter("format"))
+	if err != nil {
+		return nil, err
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	datasets := store.Datasets()
+
+	dataset, err := publishedDataset(datasets, request.PathParameter("dataset"))
+	if err != nil {
+		return nil, err
+	}
+
+	builder := newArchiveBuilder(store.Files(), store.Dirs(), datasets)
+	if err := builder.addDataset(dataset); err != nil {
+		return nil, err
+	}
+
+	if err := datasets.IncrementDownloads(dataset.ID); err != nil {
+		r.log.Error("Unable to count dataset download", "datasetid", dataset.ID, "error", err)
+	}
+
+	r.log.Info("Dataset bag download", "datasetid", dataset.ID, "files", len(builder.entries), "remote", request.Request.RemoteAddr)
+
+	if err := builder.writeBag(response, "dataset-"+dataset.ID, format, archive.DatasetBagInfo(dataset)); err != nil {
+		r.log.Error("Dataset bag download failed", "datasetid", dataset.ID, "error", err)
+	}
+	return nil, nil
+}
+
+// getDatasetMetadata writes a published dataset's metadata. The format query
+// parameter selects schemaorg (the default) or datacite.
+func (r *publicDatasetsResource) getDatasetMetadata(request *restful.Request, response *restful.Response) (interface{}, error) {
+	store := request.Attribute("store").(dai.Store)
+	dataset, err := publishedDataset(store.Datasets(), request.PathParameter("dataset"))
+	if err != nil {
+		return nil, err
+	}
+	return nil, writeDatasetMetadata(request, response, dataset)
+}
+
+// publishedDataset looks up a dataset and returns it only if it has been
+// published. Unpublished datasets are reported as not found so their existence
+// isn't revealed.
+func publishedDataset(datasets dai.Datasets, id string) (*schema.Dataset, error) {
+	dataset, err := datasets.ByID(id)
+	switch {
+	case err != nil:
+		return nil, app.ErrNotFound
+	case !dataset.Published:
+		return nil, app.ErrNotFound
+	default:
+		return dataset, nil
+	}
+}
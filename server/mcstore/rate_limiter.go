@@ -0,0 +1,155 @@
+package mcstore
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/ws"
+)
+
+const (
+	// defaultAnonRequestsPerMinute is the sustained rate allowed for each
+	// anonymous client when MCSTORED_ANON_RATE isn't set.
+	defaultAnonRequestsPerMinute = 120
+
+	// defaultAnonBurst is the number of requests an anonymous client can make
+	// back to back when MCSTORED_ANON_BURST isn't set.
+	defaultAnonBurst = 30
+
+	// maxTrackedClients is the number of clients tracked before idle clients
+	// are dropped.
+	maxTrackedClients = 10000
+)
+
+var (
+	anonLimiterInit sync.Once
+	anonLimiter     *rateLimiter
+)
+
+// anonymousRateLimiter returns the rate limiter shared by all anonymous requests.
+func anonymousRateLimiter() *rateLimiter {
+	anonLimiterInit.Do(func() {
+		perMinute := config.GetInt("MCSTORED_ANON_RATE")
+		if perMinute <= 0 {
+			perMinute = defaultAnonRequestsPerMinute
+		}
+
+		burst := config.GetInt("MCSTORED_ANON_BURST")
+		if burst <= 0 {
+			burst = defaultAnonBurst
+		}
+
+		anonLimiter = newRateLimiter(float64(perMinute)/60, burst)
+	})
+	return anonLimiter
+}
+
+// bucket tracks the tokens available to a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket rate limiter keyed by client. Each client
+// gets burst tokens which refill at rate tokens per second.
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*bucket
+	now     func() time.Time
+}
+
+// newRateLimiter creates a new rateLimiter.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow returns true if the client identified by key can make another request.
+func (l *rateLimiter) allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	b, ok := l.clients[key]
+	if !ok {
+		if len(l.clients) >= maxTrackedClients {
+			l.dropIdleClients(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dropIdleClients removes clients whose buckets have completely refilled. These
+// clients are indistinguishable from clients we have never seen.
+func (l *rateLimiter) dropIdleClients(now time.Time) {
+	for key, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// clientAddress returns the address used to identify an anonymous client. When
+// MCSTORED_TRUST_PROXY is set the first address in X-Forwarded-For is used.
+func clientAddress(req *http.Request) string {
+	if config.GetBool("MCSTORED_TRUST_PROXY") {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitFilter rejects anonymous requests from clients that have exceeded
+// their rate limit.
+type rateLimitFilter struct {
+	limiter *rateLimiter
+}
+
+// newRateLimitFilter creates a new rateLimitFilter.
+func newRateLimitFilter(limiter *rateLimiter) *rateLimitFilter {
+	return &rateLimitFilter{
+		limiter: limiter,
+	}
+}
+
+// Filter checks the rate limit for the client making the request.
+func (f *rateLimitFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	client := clientAddress(request.Request)
+	if !f.limiter.allow(client) {
+		app.Log.Info("Anonymous rate limit exceeded", "client", client, "path", request.Request.URL.Path)
+		ws.WriteError(app.ErrRateLimited, response)
+		return
+	}
+	chain.ProcessFilter(request, response)
+}
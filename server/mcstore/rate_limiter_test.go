@@ -0,0 +1,75 @@
+package mcstore
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/materials-commons/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RateLimiter", func() {
+	var (
+		limiter *rateLimiter
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		limiter = newRateLimiter(1, 2)
+		limiter.now = func() time.Time { return now }
+	})
+
+	Describe("allow Method Tests", func() {
+		It("Should allow a burst and then deny", func() {
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeFalse())
+		})
+
+		It("Should refill tokens over time", func() {
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeFalse())
+			now = now.Add(time.Second)
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeFalse())
+		})
+
+		It("Should track clients separately", func() {
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeTrue())
+			Expect(limiter.allow("1.2.3.4")).To(BeFalse())
+			Expect(limiter.allow("5.6.7.8")).To(BeTrue())
+		})
+
+		It("Should drop idle clients", func() {
+			limiter.allow("1.2.3.4")
+			now = now.Add(time.Minute)
+			limiter.dropIdleClients(now)
+			Expect(limiter.clients).To(BeEmpty())
+		})
+	})
+
+	Describe("clientAddress Method Tests", func() {
+		AfterEach(func() {
+			config.Set("MCSTORED_TRUST_PROXY", "false")
+		})
+
+		It("Should use the remote address without the port", func() {
+			req, _ := http.NewRequest("GET", "http://localhost", nil)
+			req.RemoteAddr = "10.0.0.1:5000"
+			req.Header.Set("X-Forwarded-For", "1.2.3.4")
+			Expect(clientAddress(req)).To(Equal("10.0.0.1"))
+		})
+
+		It("Should use X-Forwarded-For when the proxy is trusted", func() {
+			config.Set("MCSTORED_TRUST_PROXY", "true")
+			req, _ := http.NewRequest("GET", "http://localhost", nil)
+			req.RemoteAddr = "10.0.0.1:5000"
+			req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+			Expect(clientAddress(req)).To(Equal("1.2.3.4"))
+		})
+	})
+})
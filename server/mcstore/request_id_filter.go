@@ -0,0 +1,41 @@
+package mcstore
+
+import (
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/ws"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+)
+
+// requestIDFilter gives each request an ID and a logger, and writes an access
+// log line once the request has been handled. It must be the first filter so
+// that every response, including errors from other filters, has the ID.
+type requestIDFilter struct{}
+
+// Filter uses the request ID sent by the client, or generates one if it didn't
+// send a usable one. The ID is returned in the response's X-Request-ID header.
+// The "requestid" attribute is set to the ID, and the "log" attribute to a
+// logger that includes it. Use rest.RequestLog to get the logger.
+func (f *requestIDFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	id := request.Request.Header.Get(ws.RequestIDHeader)
+	if !ws.ValidRequestID(id) {
+		id = ws.NewRequestID()
+	}
+
+	response.AddHeader(ws.RequestIDHeader, id)
+	request.SetAttribute("requestid", id)
+	request.SetAttribute("log", app.NewLog("requestid", id, "route", request.SelectedRoutePath()))
+
+	start := time.Now()
+	chain.ProcessFilter(request, response)
+
+	rest.RequestLog(request).Info("Request",
+		"method", request.Request.Method,
+		"path", request.Request.URL.Path,
+		"status", response.StatusCode(),
+		"bytes", response.ContentLength(),
+		"duration_ms", time.Since(start).Nanoseconds()/int64(time.Millisecond),
+		"client", clientAddress(request.Request))
+}
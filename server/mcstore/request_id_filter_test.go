@@ -0,0 +1,64 @@
+package mcstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/ws"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestIDFilter", func() {
+	var (
+		container *restful.Container
+		requestID string
+		log       *app.Logger
+	)
+
+	BeforeEach(func() {
+		requestID, log = "", nil
+		container = newContainer()
+		f := &requestIDFilter{}
+		container.Filter(f.Filter)
+		service := new(restful.WebService)
+		service.Path("/things").Route(service.GET("").To(func(request *restful.Request, response *restful.Response) {
+			requestID = request.Attribute("requestid").(string)
+			log, _ = request.Attribute("log").(*app.Logger)
+			ws.WriteError(app.ErrNotFound, response)
+		}))
+		container.Add(service)
+	})
+
+	get := func(id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/things", nil)
+		if id != "" {
+			req.Header.Set(ws.RequestIDHeader, id)
+		}
+		container.ServeHTTP(rr, req)
+		return rr
+	}
+
+	It("Should use the request ID sent by the client", func() {
+		rr := get("client-id-1")
+		Expect(requestID).To(Equal("client-id-1"))
+		Expect(log).NotTo(BeNil())
+		Expect(rr.Header().Get(ws.RequestIDHeader)).To(Equal("client-id-1"))
+		Expect(rr.Body.String()).To(ContainSubstring(`"request_id":"client-id-1"`))
+	})
+
+	It("Should generate a request ID when the client didn't send one", func() {
+		rr := get("")
+		Expect(requestID).To(HaveLen(32))
+		Expect(rr.Header().Get(ws.RequestIDHeader)).To(Equal(requestID))
+	})
+
+	It("Should replace a request ID that isn't valid", func() {
+		rr := get("bad id\n")
+		Expect(requestID).NotTo(Equal("bad id\n"))
+		Expect(rr.Header().Get(ws.RequestIDHeader)).To(Equal(requestID))
+	})
+})
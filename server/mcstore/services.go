@@ -1,28 +1,54 @@
 package mcstore
 
 import (
+	r "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
 	"github.com/materials-commons/config"
 	"github.com/materials-commons/mcstore/pkg/db"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/ws"
 )
 
 // NewServicesContainer creates a new restful.Container made up of all
 // the rest resources handled by the server.
 func NewServicesContainer(sc db.SessionCreater) *restful.Container {
-	container := restful.NewContainer()
+	return newServicesContainer(dai.NewRStoreSource(sc), sc)
+}
 
-	databaseSessionFilter := &databaseSessionFilter{
-		session: sc.RSession,
-	}
-	container.Filter(databaseSessionFilter.Filter)
+// NewStoreServicesContainer creates a services container whose resources use
+// the stores from source, such as a MemStore or SQLStore. No database monitors
+// are started.
+func NewStoreServicesContainer(source dai.StoreSource) *restful.Container {
+	return newServicesContainer(source, nil)
+}
+
+// newServicesContainer creates the services container. Requests get their
+// store from source. The database monitors use sc and are only launched
+// when sc is not nil.
+func newServicesContainer(source dai.StoreSource, sc db.SessionCreater) *restful.Container {
+	container := newContainer()
+
+	requestIDFilter := &requestIDFilter{}
+	container.Filter(requestIDFilter.Filter)
+
+	metricsFilter := &metricsFilter{}
+	container.Filter(metricsFilter.Filter)
+
+	storeFilter := newStoreFilter(source)
+	container.Filter(storeFilter.Filter)
 
 	apikeyFilter := newAPIKeyFilter(apiKeyCache)
 	container.Filter(apikeyFilter.Filter)
 
-	if config.GetBool("MCSTORED_MONITOR_USERS") {
+	auditFilter := &auditFilter{}
+	container.Filter(auditFilter.Filter)
+
+	if sc != nil && config.GetBool("MCSTORED_MONITOR_USERS") {
 		// launch routine to track changes to users and
 		// update the keycache appropriately.
-		go updateKeyCacheOnChange(sc.RSessionMust(), apiKeyCache)
+		monitors.launch(func() (*r.Session, error) { return db.FeedSession(sc) }, func(session *r.Session) {
+			updateKeyCacheOnChange(session, apiKeyCache)
+		})
 	}
 
 	//if config.GetBool("MCSTORED_MONITOR_DB_CHANGES") {
@@ -39,6 +65,70 @@ func NewServicesContainer(sc db.SessionCreater) *restful.Container {
 	searchResource := newSearchResource()
 	container.Add(searchResource.WebService())
 
+	tokensResource := newTokensResource()
+	container.Add(tokensResource.WebService())
+
+	sharesResource := newSharesResource()
+	container.Add(sharesResource.WebService())
+
+	datasetsResource := newDatasetsResource()
+	container.Add(datasetsResource.WebService())
+
+	auditResource := newAuditResource()
+	container.Add(auditResource.WebService())
+
+	trashResource := newTrashResource()
+	container.Add(trashResource.WebService())
+
+	return container
+}
+
+// NewPublicServicesContainer creates a new restful.Container for the rest
+// resources that can be used without credentials. Requests to these resources
+// are rate limited by client address.
+func NewPublicServicesContainer(sc db.SessionCreater) *restful.Container {
+	return newPublicServicesContainer(dai.NewRStoreSource(sc))
+}
+
+// NewStorePublicServicesContainer creates a public services container whose
+// resources use the stores from source.
+func NewStorePublicServicesContainer(source dai.StoreSource) *restful.Container {
+	return newPublicServicesContainer(source)
+}
+
+// newPublicServicesContainer creates the public services container. Requests
+// get their store from source.
+func newPublicServicesContainer(source dai.StoreSource) *restful.Container {
+	container := newContainer()
+
+	requestIDFilter := &requestIDFilter{}
+	container.Filter(requestIDFilter.Filter)
+
+	metricsFilter := &metricsFilter{}
+	container.Filter(metricsFilter.Filter)
+
+	rateLimitFilter := newRateLimitFilter(anonymousRateLimiter())
+	container.Filter(rateLimitFilter.Filter)
+
+	storeFilter := newStoreFilter(source)
+	container.Filter(storeFilter.Filter)
+
+	publicDatasetsResource := newPublicDatasetsResource()
+	container.Add(publicDatasetsResource.WebService())
+
+	oaiResource := newOAIResource()
+	container.Add(oaiResource.WebService())
+
+	return container
+}
+
+// newContainer creates a restful.Container that writes routing errors, such
+// as unknown paths, and panics in routes as JSON error responses.
+func newContainer() *restful.Container {
+	container := restful.NewContainer()
+	container.ServeMux.HandleFunc("/", ws.NotFound)
+	container.ServiceErrorHandler(ws.WriteServiceError)
+	container.RecoverHandler(ws.RecoverHandler)
 	return container
 }
 
@@ -0,0 +1,136 @@
+package mcstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StoreServicesContainer", func() {
+	itServesTheStore := func(newStore func() storeWithFixture) {
+		var (
+			store     storeWithFixture
+			container *restful.Container
+		)
+
+		BeforeEach(func() {
+			store = newStore()
+			fixture := `{"users": [{"id": "mem@mc.org", "apikey": "memkey"}]}`
+			Expect(store.Load(strings.NewReader(fixture))).To(BeNil())
+			container = NewStoreServicesContainer(store)
+		})
+
+		AfterEach(func() {
+			if closer, ok := store.(io.Closer); ok {
+				closer.Close()
+			}
+		})
+
+		createProject := func(apikey, name string) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(mcstoreapi.CreateProjectRequest{Name: name})
+			req, _ := http.NewRequest("POST", "/project2?apikey="+apikey, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			container.ServeHTTP(rr, req)
+			return rr
+		}
+
+		It("Should create a project in the store", func() {
+			rr := createProject("memkey", "memproj")
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			var resp mcstoreapi.CreateProjectResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(BeNil())
+			Expect(resp.Existing).To(BeFalse())
+
+			project, err := store.Projects().ByName("memproj", "mem@mc.org")
+			Expect(err).To(BeNil())
+			Expect(project.ID).To(Equal(resp.ProjectID))
+		})
+
+		It("Should reject an unknown apikey", func() {
+			rr := createProject("badkey", "memproj")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("Should move a directory into the trash and restore it", func() {
+			send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+				b, _ := json.Marshal(body)
+				req, _ := http.NewRequest(method, path+"?apikey=memkey", bytes.NewReader(b))
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				container.ServeHTTP(rr, req)
+				return rr
+			}
+
+			var project mcstoreapi.CreateProjectResponse
+			Expect(json.Unmarshal(createProject("memkey", "memproj").Body.Bytes(), &project)).To(BeNil())
+
+			rr := send("POST", "/project2/directory", mcstoreapi.GetDirectoryRequest{
+				ProjectID: project.ProjectID,
+				Path:      "memproj/sub",
+			})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var dir mcstoreapi.GetDirectoryResponse
+			Expect(json.Unmarshal(rr.Body.Bytes(), &dir)).To(BeNil())
+
+			rr = send("POST", "/trash/project/"+project.ProjectID, mcstoreapi.TrashRequest{DirectoryID: dir.DirectoryID})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var item schema.TrashItem
+			Expect(json.Unmarshal(rr.Body.Bytes(), &item)).To(BeNil())
+			Expect(item.Path).To(Equal("memproj/sub"))
+
+			_, err := store.Dirs().ByPath("memproj/sub", project.ProjectID)
+			Expect(err).To(Equal(app.ErrNotFound))
+
+			rr = send("GET", "/trash/project/"+project.ProjectID, nil)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var items []schema.TrashItem
+			Expect(json.Unmarshal(rr.Body.Bytes(), &items)).To(BeNil())
+			Expect(items).To(HaveLen(1))
+
+			rr = send("GET", "/project2/"+project.ProjectID+"/usage", nil)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+
+			rr = send("POST", "/trash/project/"+project.ProjectID+"/"+item.ID+"/restore", nil)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			d, err := store.Dirs().ByPath("memproj/sub", project.ProjectID)
+			Expect(err).To(BeNil())
+			Expect(d.ID).To(Equal(dir.DirectoryID))
+		})
+	}
+
+	Describe("MemStore", func() {
+		itServesTheStore(func() storeWithFixture {
+			return dai.NewMemStore()
+		})
+	})
+
+	Describe("SQLStore on SQLite", func() {
+		itServesTheStore(func() storeWithFixture {
+			store, err := dai.OpenSQLStore("sqlite3", ":memory:")
+			Expect(err).To(BeNil())
+			_, err = store.Migrate()
+			Expect(err).To(BeNil())
+			return store
+		})
+	})
+})
+
+// storeWithFixture is a store that can be served and loaded with a fixture.
+type storeWithFixture interface {
+	dai.Store
+	dai.StoreSource
+	Load(r io.Reader) error
+}
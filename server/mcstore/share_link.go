@@ -0,0 +1,88 @@
+package mcstore
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
+)
+
+var (
+	shareKeyInit sync.Once
+	shareKey     []byte
+)
+
+// ShareLinkKey returns the key used to sign share links. The key is read from
+// MCSTORED_SHARE_SECRET. If it isn't set a random key is generated, which means
+// that share links will stop working when the server restarts.
+func ShareLinkKey() []byte {
+	shareKeyInit.Do(func() {
+		if secret := config.GetString("MCSTORED_SHARE_SECRET"); secret != "" {
+			shareKey = []byte(secret)
+			return
+		}
+
+		app.Log.Warn("MCSTORED_SHARE_SECRET not set, share links will not survive a server restart")
+		shareKey = make([]byte, 32)
+		if _, err := rand.Read(shareKey); err != nil {
+			app.Log.Panicf("Unable to generate share link key: %s", err)
+		}
+	})
+	return shareKey
+}
+
+// shareLinkPath creates the path, including the signed query arguments,
+// for a share link.
+func shareLinkPath(link *schema.ShareLink, sig string) string {
+	values := url.Values{}
+	values.Set("share", link.ID)
+	values.Set("expires", strconv.FormatInt(link.Expires.Unix(), 10))
+	values.Set("sig", sig)
+	if link.Original {
+		values.Set("original", "true")
+	}
+	return "/datafiles/static/" + link.FileID + "?" + values.Encode()
+}
+
+// serverURL creates the full URL for a path on this server. It uses MCSTORED_URL as
+// the base if set, otherwise it uses the host the request was sent to.
+func serverURL(req *http.Request, path string) string {
+	if base := config.GetString("MCSTORED_URL"); base != "" {
+		return strings.TrimSuffix(base, "/") + path
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + path
+}
+
+// isShareRequest returns true if the request is for a share link.
+func isShareRequest(req *http.Request) bool {
+	return req.FormValue("sig") != ""
+}
+
+// toShareRequest pulls the share link arguments out of a request.
+func toShareRequest(req *http.Request, fileID string) (domain.ShareRequest, error) {
+	expires, err := strconv.ParseInt(req.FormValue("expires"), 10, 64)
+	if err != nil {
+		return domain.ShareRequest{}, app.ErrInvalid
+	}
+
+	return domain.ShareRequest{
+		LinkID:        req.FormValue("share"),
+		FileID:        fileID,
+		Expires:       expires,
+		Original:      getOriginalFormValue(req),
+		Signature:     req.FormValue("sig"),
+		CountDownload: req.Method != "HEAD",
+	}, nil
+}
@@ -0,0 +1,95 @@
+package mcstore
+
+import (
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+)
+
+const (
+	// defaultShareExpiresInHours is used when a request doesn't specify an expiration.
+	defaultShareExpiresInHours = 24 * 7
+
+	// maxShareExpiresInHours is the longest a share link can be valid for.
+	maxShareExpiresInHours = 24 * 90
+)
+
+// A sharesResource handles requests to create signed share links.
+type sharesResource struct {
+	log *app.Logger
+}
+
+// newSharesResource creates a new shares resource.
+func newSharesResource() rest.Service {
+	return &sharesResource{
+		log: app.NewLog("resource", "shares"),
+	}
+}
+
+// WebService creates an instance of the shares web service.
+func (r *sharesResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/shares").Produces(restful.MIME_JSON).Consumes(restful.MIME_JSON)
+
+	ws.Route(ws.POST("").To(rest.RouteHandler(r.createShareLink)).
+		Doc("Creates a signed, expiring link to a file that can be used without an apikey").
+		Reads(mcstoreapi.CreateShareLinkRequest{}).
+		Writes(mcstoreapi.CreateShareLinkResponse{}))
+
+	return ws
+}
+
+// createShareLink creates a new share link for a file the user has access to.
+func (r *sharesResource) createShareLink(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	var req mcstoreapi.CreateShareLinkRequest
+	if err := request.ReadEntity(&req); err != nil {
+		r.log.Debugf("createShareLink ReadEntity failed: %s", err)
+		return nil, err
+	}
+
+	switch {
+	case req.FileID == "":
+		return nil, app.Errorf(app.ErrInvalid, "file_id is required")
+	case req.ExpiresInHours < 0 || req.ExpiresInHours > maxShareExpiresInHours:
+		return nil, app.Errorf(app.ErrInvalid, "expires_in_hours must be between 1 and %d, or 0 for the default of %d",
+			maxShareExpiresInHours, defaultShareExpiresInHours)
+	case req.MaxDownloads < 0:
+		return nil, app.Errorf(app.ErrInvalid, "max_downloads cannot be negative")
+	case req.ExpiresInHours == 0:
+		req.ExpiresInHours = defaultShareExpiresInHours
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	files := store.Files()
+
+	if token := requestToken(request); token != nil && token.RestrictedToProjects() {
+		project, err := files.GetProject(req.FileID)
+		if err != nil || !token.AllowsProject(project.ID) {
+			return nil, app.ErrNoAccess
+		}
+	}
+
+	access := domain.NewAccess(store.Projects(), files, store.Users())
+	shares := domain.NewShares(store.ShareLinks(), files, access, ShareLinkKey())
+	expires := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+	link, sig, err := shares.Create(user, req.FileID, req.Original, expires, req.MaxDownloads)
+	if err != nil {
+		return nil, err
+	}
+
+	r.log.Info("Created share link", "user", user.ID, "fileid", link.FileID, "linkid", link.ID, "expires", link.Expires)
+
+	resp := &mcstoreapi.CreateShareLinkResponse{
+		ID:      link.ID,
+		URL:     serverURL(request.Request, shareLinkPath(link, sig)),
+		Expires: link.Expires,
+	}
+	return resp, nil
+}
@@ -0,0 +1,34 @@
+package mcstore
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/ws"
+)
+
+// storeFilter is a filter that provides the store each request uses to access
+// the database.
+type storeFilter struct {
+	source dai.StoreSource
+}
+
+// newStoreFilter creates a storeFilter that gets its stores from source.
+func newStoreFilter(source dai.StoreSource) *storeFilter {
+	return &storeFilter{
+		source: source,
+	}
+}
+
+// Filter will get a store and place it in the store request attribute. When control
+// returns to the filter it will release the store.
+func (f *storeFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if store, err := f.source.Store(); err != nil {
+		dbSessionErrors.Inc()
+		ws.WriteError(app.Errorf(app.ErrUnavailable, "Unable to connect to database"), response)
+	} else {
+		request.SetAttribute("store", store)
+		chain.ProcessFilter(request, response)
+		f.source.Release(store)
+	}
+}
@@ -0,0 +1,171 @@
+package mcstore
+
+import (
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/domain"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+)
+
+const (
+	// defaultTokenExpiresInDays is used when a request doesn't specify an expiration.
+	defaultTokenExpiresInDays = 90
+
+	// maxTokenExpiresInDays is the longest a token can be valid for.
+	maxTokenExpiresInDays = 365
+)
+
+// A tokensResource handles requests to create, list and revoke api tokens.
+type tokensResource struct {
+	log *app.Logger
+}
+
+// newTokensResource creates a new tokens resource.
+func newTokensResource() rest.Service {
+	return &tokensResource{
+		log: app.NewLog("resource", "tokens"),
+	}
+}
+
+// WebService creates an instance of the tokens web service.
+func (r *tokensResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/tokens").Produces(restful.MIME_JSON).Consumes(restful.MIME_JSON)
+
+	ws.Route(ws.POST("").To(rest.RouteHandler(r.createToken)).
+		Doc("Creates a new api token for the user. The token is only returned in this response.").
+		Reads(mcstoreapi.CreateTokenRequest{}).
+		Writes(mcstoreapi.CreateTokenResponse{}))
+
+	ws.Route(ws.GET("").To(rest.RouteHandler(r.listTokens)).
+		Doc("Lists the users api tokens").
+		Writes([]schema.APIToken{}))
+
+	ws.Route(ws.DELETE("{id}").To(rest.RouteHandler1(r.revokeToken)).
+		Doc("Revokes an api token").
+		Param(ws.PathParameter("id", "token to revoke").DataType("string")))
+
+	return ws
+}
+
+// createToken creates a new api token. Tokens can only be created by requests
+// authenticated with an apikey, a token cannot be used to mint new tokens. The
+// user must have access to each project the token is limited to.
+func (r *tokensResource) createToken(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	if requestToken(request) != nil {
+		return nil, app.Errorf(app.ErrNoAccess, "tokens cannot be used to create tokens")
+	}
+
+	var req mcstoreapi.CreateTokenRequest
+	if err := request.ReadEntity(&req); err != nil {
+		r.log.Debugf("createToken ReadEntity failed: %s", err)
+		return nil, err
+	}
+
+	if err := validateTokenRequest(&req); err != nil {
+		return nil, err
+	}
+
+	store := request.Attribute("store").(dai.Store)
+	access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
+	for _, projectID := range req.Projects {
+		if !access.AllowedByOwner(projectID, user.ID) {
+			return nil, app.Errorf(app.ErrNoAccess, "no access to project %s", projectID)
+		}
+	}
+
+	token, err := generateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+	apitoken := schema.NewAPIToken(req.Name, user.ID, hashAPIToken(token), expires)
+	apitoken.Scopes = req.Scopes
+	if req.Projects != nil {
+		apitoken.Projects = req.Projects
+	}
+
+	tokens := store.APITokens()
+	newToken, err := tokens.Insert(&apitoken)
+	if err != nil {
+		return nil, err
+	}
+
+	r.log.Info("Created api token", "user", user.ID, "tokenid", newToken.ID, "name", newToken.Name)
+
+	resp := &mcstoreapi.CreateTokenResponse{
+		ID:      newToken.ID,
+		Token:   token,
+		Expires: newToken.Expires,
+	}
+	return resp, nil
+}
+
+// validateTokenRequest checks the request and fills in defaults for
+// values that weren't specified.
+func validateTokenRequest(req *mcstoreapi.CreateTokenRequest) error {
+	switch {
+	case req.Name == "":
+		return app.Errorf(app.ErrInvalid, "token name is required")
+	case len(req.Scopes) == 0:
+		return app.Errorf(app.ErrInvalid, "at least one scope is required")
+	case req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenExpiresInDays:
+		return app.Errorf(app.ErrInvalid, "expires_in_days must be between 1 and %d, or 0 for the default of %d",
+			maxTokenExpiresInDays, defaultTokenExpiresInDays)
+	}
+
+	for _, scope := range req.Scopes {
+		switch scope {
+		case schema.TokenScopeRead, schema.TokenScopeUpload, schema.TokenScopeWrite:
+		default:
+			return app.Errorf(app.ErrInvalid, "unknown scope %s", scope)
+		}
+	}
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultTokenExpiresInDays
+	}
+
+	return nil
+}
+
+// listTokens returns all the tokens for the user, including revoked and
+// expired tokens.
+func (r *tokensResource) listTokens(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	store := request.Attribute("store").(dai.Store)
+	tokens := store.APITokens()
+	userTokens, err := tokens.ForUser(user.ID)
+	switch {
+	case err == app.ErrNotFound:
+		return []schema.APIToken{}, nil
+	case err != nil:
+		return nil, err
+	default:
+		return userTokens, nil
+	}
+}
+
+// revokeToken revokes one of the users tokens.
+func (r *tokensResource) revokeToken(request *restful.Request, response *restful.Response, user schema.User) error {
+	store := request.Attribute("store").(dai.Store)
+	tokens := store.APITokens()
+	tokenID := request.PathParameter("id")
+
+	token, err := tokens.ByID(tokenID)
+	switch {
+	case err != nil:
+		return err
+	case token.Owner != user.ID:
+		return app.ErrNoAccess
+	default:
+		r.log.Info("Revoking api token", "user", user.ID, "tokenid", tokenID)
+		return tokens.Revoke(tokenID)
+	}
+}
@@ -0,0 +1,104 @@
+package mcstore
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/trash"
+	"github.com/materials-commons/mcstore/pkg/ws/rest"
+	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
+	"github.com/materials-commons/mcstore/server/mcstore/pkg/filters"
+)
+
+// A trashResource handles deleting files and directories from a project, and
+// listing, restoring and purging what is in the project's trash.
+type trashResource struct {
+	log *app.Logger
+}
+
+// newTrashResource creates a new trash resource.
+func newTrashResource() rest.Service {
+	return &trashResource{
+		log: app.NewLog("resource", "trash"),
+	}
+}
+
+// WebService creates an instance of the trash web service.
+func (r *trashResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+
+	ws.Path("/trash").Produces(restful.MIME_JSON).Consumes(restful.MIME_JSON)
+
+	ws.Route(ws.GET("project/{project}").Filter(filters.ProjectAccess).To(rest.RouteHandler(r.listTrash)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Doc("Lists the items in a project's trash, newest first").
+		Writes([]schema.TrashItem{}))
+
+	ws.Route(ws.POST("project/{project}").Filter(filters.ProjectAccess).To(rest.RouteHandler(r.deleteItem)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Doc("Deletes a file, with all its versions, or a directory by moving it into the project's trash").
+		Reads(mcstoreapi.TrashRequest{}).
+		Writes(schema.TrashItem{}))
+
+	ws.Route(ws.POST("project/{project}/{item}/restore").Filter(filters.ProjectAccess).To(rest.RouteHandler1(r.restoreItem)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Param(ws.PathParameter("item", "trash item id").DataType("string")).
+		Doc("Restores an item from the trash to where it was deleted from"))
+
+	ws.Route(ws.DELETE("project/{project}/{item}").Filter(filters.ProjectAccess).To(rest.RouteHandler1(r.purgeItem)).
+		Param(ws.PathParameter("project", "project id").DataType("string")).
+		Param(ws.PathParameter("item", "trash item id").DataType("string")).
+		Doc("Purges an item from the trash now. It can't be restored afterwards. Requires project admin access"))
+
+	return ws
+}
+
+// listTrash returns the items in a project's trash.
+func (r *trashResource) listTrash(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	project := request.Attribute("project").(schema.Project)
+	return trash.New(request.Attribute("store").(dai.Store)).List(project.ID)
+}
+
+// deleteItem moves a file or directory into the project's trash.
+func (r *trashResource) deleteItem(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
+	var req mcstoreapi.TrashRequest
+	if err := request.ReadEntity(&req); err != nil {
+		r.log.Debugf("deleteItem ReadEntity failed: %s", err)
+		return nil, err
+	}
+
+	if req.DirectoryID == "" {
+		return nil, app.Errorf(app.ErrInvalid, "no directory given")
+	}
+
+	project := request.Attribute("project").(schema.Project)
+	t := trash.New(request.Attribute("store").(dai.Store))
+	if req.FileID != "" {
+		return t.DeleteFile(req.FileID, req.DirectoryID, project.ID, user.ID)
+	}
+	return t.DeleteDir(req.DirectoryID, project.ID, user.ID)
+}
+
+// restoreItem moves an item out of the trash.
+func (r *trashResource) restoreItem(request *restful.Request, response *restful.Response, user schema.User) error {
+	project := request.Attribute("project").(schema.Project)
+	t := trash.New(request.Attribute("store").(dai.Store))
+	return t.Restore(request.PathParameter("item"), project.ID)
+}
+
+// purgeItem removes an item from the trash for good. Since this can't be
+// undone only the project owner and system admins can purge.
+func (r *trashResource) purgeItem(request *restful.Request, response *restful.Response, user schema.User) error {
+	project := request.Attribute("project").(schema.Project)
+	if !user.Admin && project.Owner != user.ID {
+		return app.ErrNoAccess
+	}
+
+	t := trash.New(request.Attribute("store").(dai.Store))
+	if err := t.Purge(request.PathParameter("item"), project.ID); err != nil {
+		return err
+	}
+	r.log.Info("Purged trash item", "user", user.ID, "projectid", project.ID, "itemid", request.PathParameter("item"))
+	return nil
+}
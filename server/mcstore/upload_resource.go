@@ -5,9 +5,9 @@ import (
 
 	"fmt"
 
-	rethinkdb "github.com/dancannon/gorethink"
 	"github.com/emicklei/go-restful"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/pkg/ws/rest"
 	"github.com/materials-commons/mcstore/server/mcstore/mcstoreapi"
@@ -66,10 +66,10 @@ func (r *uploadResource) createUploadRequest(request *restful.Request, response
 		app.Log.Debugf("request2IDRequst failed", err)
 		return nil, err
 	} else {
-		session := request.Attribute("session").(*rethinkdb.Session)
+		store := request.Attribute("store").(dai.Store)
 		project := request.Attribute("project").(schema.Project)
 		directory := request.Attribute("directory").(schema.Directory)
-		idService := uploads.NewIDService(session)
+		idService := uploads.NewIDService(store)
 
 		if upload, err := idService.ID(cr, &project, &directory); err != nil {
 			app.Log.Debugf("idService.ID failed", err)
@@ -147,18 +147,22 @@ func findStartingBlock(blocks *bitset.BitSet) uint {
 
 // uploadFileChunk uploads a new file chunk.
 func (r *uploadResource) uploadFileChunk(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
-	session := request.Attribute("session").(*rethinkdb.Session)
+	store := request.Attribute("store").(dai.Store)
 	flowRequest, err := form2FlowRequest(request)
 	if err != nil {
 		r.log.Errorf("Error converting form to flow.Request: %s", err)
 		return nil, err
 	}
 
+	if err := checkUploadProject(request, store, flowRequest.FlowIdentifier); err != nil {
+		return nil, err
+	}
+
 	req := uploads.UploadRequest{
 		Request: flowRequest,
 	}
 
-	uploadService := uploads.NewUploadService(session)
+	uploadService := uploads.NewUploadService(store)
 	if uploadStatus, err := uploadService.Upload(&req); err != nil {
 		return nil, err
 	} else {
@@ -173,17 +177,35 @@ func (r *uploadResource) uploadFileChunk(request *restful.Request, response *res
 // deleteUploadRequest will delete an existing upload request. It validates that
 // the requesting user has access to delete the request.
 func (r *uploadResource) deleteUploadRequest(request *restful.Request, response *restful.Response, user schema.User) error {
-	session := request.Attribute("session").(*rethinkdb.Session)
-	idService := uploads.NewIDService(session)
+	store := request.Attribute("store").(dai.Store)
 	uploadID := request.PathParameter("id")
+	if err := checkUploadProject(request, store, uploadID); err != nil {
+		return err
+	}
+
+	idService := uploads.NewIDService(store)
 	return idService.Delete(uploadID, user.ID)
 }
 
+// checkUploadProject returns app.ErrNoAccess if the request was made with an api
+// token that isn't allowed in the project of the upload.
+func checkUploadProject(request *restful.Request, store dai.Store, uploadID string) error {
+	upload, err := store.Uploads().ByID(uploadID)
+	switch {
+	case err != nil:
+		return err
+	case !filters.TokenAllowsProject(request, upload.ProjectID):
+		return app.ErrNoAccess
+	default:
+		return nil
+	}
+}
+
 // listProjectUploadRequests returns the upload requests for the project if the requester
 // has access to the project.
 func (r *uploadResource) listProjectUploadRequests(request *restful.Request, response *restful.Response, user schema.User) (interface{}, error) {
-	session := request.Attribute("session").(*rethinkdb.Session)
-	idService := uploads.NewIDService(session)
+	store := request.Attribute("store").(dai.Store)
+	idService := uploads.NewIDService(store)
 	project := request.Attribute("project").(schema.Project)
 	entries, err := idService.UploadsForProject(project.ID)
 	switch {
@@ -44,6 +44,24 @@ func newBlockTracker() *blockTracker {
 	}
 }
 
+// count returns the number of requests being tracked.
+func (bt *blockTracker) count() int {
+	bt.mutex.RLock()
+	defer bt.mutex.RUnlock()
+	return len(bt.reqBlocks)
+}
+
+// ids returns the ids of all the requests being tracked.
+func (bt *blockTracker) ids() []string {
+	bt.mutex.RLock()
+	defer bt.mutex.RUnlock()
+	ids := make([]string, 0, len(bt.reqBlocks))
+	for id := range bt.reqBlocks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (bt *blockTracker) idExists(id string) bool {
 	var doesExist bool
 	bt.withReadLock(id, func(b *blockTrackerEntry) {
@@ -81,6 +99,18 @@ func (bt *blockTracker) load(id string, numBlocks int) {
 	})
 }
 
+// restore loads a previously saved bitset for an id. The hash of the blocks
+// written before the save is lost, so the entry is marked as restored and
+// the checksum must be computed from the assembled file.
+func (bt *blockTracker) restore(id string, bset *bitset.BitSet) {
+	bt.withWriteLockNotExist(id, func() {
+		bt.reqBlocks[id] = &blockTrackerEntry{
+			bset:   bset,
+			hasher: md5.New(),
+		}
+	})
+}
+
 // clearBlock will unmark an block.
 func (bt *blockTracker) clearBlock(id string, block int) {
 	bt.withWriteLock(id, func(b *blockTrackerEntry) {
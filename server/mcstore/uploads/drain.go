@@ -0,0 +1,139 @@
+package uploads
+
+import (
+	"sync"
+	"time"
+
+	"github.com/materials-commons/gohandy/file"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai"
+)
+
+// A drainGate admits uploads until the server starts shutting down. Once
+// draining starts new uploads are refused and the uploads in progress are
+// given a chance to finish.
+type drainGate struct {
+	mutex    sync.Mutex
+	draining bool
+	inflight int
+	idle     chan struct{}
+}
+
+// uploadGate is shared by all the upload and id services.
+var uploadGate = newDrainGate()
+
+// newDrainGate creates a new drainGate.
+func newDrainGate() *drainGate {
+	return &drainGate{
+		idle: make(chan struct{}),
+	}
+}
+
+// enter admits an upload. It returns false if the gate is draining, in which
+// case leave must not be called.
+func (g *drainGate) enter() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.draining {
+		return false
+	}
+	g.inflight++
+	return true
+}
+
+// leave marks an admitted upload as finished.
+func (g *drainGate) leave() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.inflight--
+	if g.draining && g.inflight == 0 {
+		close(g.idle)
+	}
+}
+
+// drain stops admitting uploads and waits up to timeout for the uploads in
+// progress to finish. It returns false if they didn't finish in time.
+func (g *drainGate) drain(timeout time.Duration) bool {
+	g.mutex.Lock()
+	if !g.draining {
+		g.draining = true
+		if g.inflight == 0 {
+			close(g.idle)
+		}
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-g.idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Drain stops accepting uploads and waits up to timeout for chunk writes and
+// file assembly in progress to finish. It returns false if they didn't finish
+// in time.
+func Drain(timeout time.Duration) bool {
+	return uploadGate.drain(timeout)
+}
+
+// FlushTracker saves the block state of each upload in progress to its upload
+// entry so that the upload can be continued after the server restarts. It
+// should be called after Drain.
+func FlushTracker(uploads dai.Uploads) error {
+	return flushTracker(requestBlockTracker, uploads)
+}
+
+// flushTracker saves the block state of each tracked upload.
+func flushTracker(tracker *blockTracker, uploads dai.Uploads) error {
+	var lastErr error
+	for _, id := range tracker.ids() {
+		upload, err := uploads.ByID(id)
+		if err != nil {
+			// Upload was deleted, nothing to save.
+			continue
+		}
+
+		upload.SetFBlocks(tracker.getBlocks(id))
+		upload.ServerRestarted = true
+		if err := uploads.Update(upload); err != nil {
+			app.Log.Errorf("Unable to save state for upload %s: %s", id, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// RestoreTracker loads the block state saved by FlushTracker. Uploads without
+// saved state were interrupted without a clean shutdown, so which blocks were
+// written is unknown. These uploads are deleted along with their chunks, and
+// the client will start them over. It returns the number of uploads restored.
+func RestoreTracker(uploads dai.Uploads) (int, error) {
+	return restoreTracker(requestBlockTracker, uploads, file.OS)
+}
+
+// restoreTracker loads the saved block state into tracker. The chunks of
+// deleted uploads are removed using fops.
+func restoreTracker(tracker *blockTracker, uploads dai.Uploads, fops file.Operations) (int, error) {
+	all, err := uploads.All()
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, upload := range all {
+		if !upload.ServerRestarted || len(upload.File.BitString) == 0 {
+			uploads.Delete(upload.ID)
+			fops.RemoveAll(app.MCDir.UploadDir(upload.ID))
+			continue
+		}
+
+		tracker.restore(upload.ID, upload.File.Blocks)
+		if upload.IsExisting {
+			tracker.setIsExistingFile(upload.ID, true)
+		}
+		restored++
+	}
+	return restored, nil
+}
@@ -0,0 +1,112 @@
+package uploads
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/materials-commons/config"
+	"github.com/materials-commons/gohandy/file"
+	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/db/dai/mocks"
+	"github.com/materials-commons/mcstore/pkg/db/schema"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/willf/bitset"
+)
+
+var _ = Describe("Drain", func() {
+	Describe("drainGate Tests", func() {
+		var gate *drainGate
+
+		BeforeEach(func() {
+			gate = newDrainGate()
+		})
+
+		It("Should drain immediately when nothing is in progress", func() {
+			Expect(gate.drain(time.Second)).To(BeTrue())
+			Expect(gate.enter()).To(BeFalse())
+		})
+
+		It("Should wait for uploads in progress to finish", func() {
+			Expect(gate.enter()).To(BeTrue())
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				gate.leave()
+			}()
+			Expect(gate.drain(time.Second)).To(BeTrue())
+		})
+
+		It("Should time out when an upload doesn't finish", func() {
+			Expect(gate.enter()).To(BeTrue())
+			Expect(gate.drain(10 * time.Millisecond)).To(BeFalse())
+			Expect(gate.enter()).To(BeFalse())
+		})
+	})
+
+	Describe("Tracker flush and restore Tests", func() {
+		var (
+			tracker  *blockTracker
+			muploads *mocks.Uploads
+			saved    string = config.GetString("MCDIR")
+			root     string
+		)
+
+		BeforeEach(func() {
+			tracker = newBlockTracker()
+			muploads = mocks.NewMUploads()
+			var err error
+			root, err = ioutil.TempDir("", "drain")
+			Expect(err).To(BeNil())
+			config.Set("MCDIR", root)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(root)
+			config.Set("MCDIR", saved)
+		})
+
+		It("Should save the blocks of uploads in progress", func() {
+			tracker.load("u1", 3)
+			tracker.setBlock("u1", 2)
+			upload := &schema.Upload{ID: "u1"}
+			muploads.On("ByID", "u1").Return(upload, nil)
+			muploads.On("Update", upload).Return(nil)
+
+			Expect(flushTracker(tracker, muploads)).To(BeNil())
+			Expect(upload.ServerRestarted).To(BeTrue())
+			Expect(upload.File.Blocks.Test(1)).To(BeTrue())
+			Expect(upload.File.Blocks.Count()).To(BeNumerically("==", 1))
+		})
+
+		It("Should restore saved uploads and delete the others", func() {
+			blocks := bitset.New(3).Set(0)
+			saved := schema.Upload{ID: "u1", ServerRestarted: true}
+			saved.SetFBlocks(blocks)
+			muploads.On("All").Return([]schema.Upload{saved, {ID: "u2"}}, nil)
+			muploads.On("Delete", "u2").Return(nil)
+
+			n, err := restoreTracker(tracker, muploads, file.OS)
+			Expect(err).To(BeNil())
+			Expect(n).To(Equal(1))
+			Expect(tracker.idExists("u1")).To(BeTrue())
+			Expect(tracker.isBlockSet("u1", 1)).To(BeTrue())
+			Expect(tracker.isBlockSet("u1", 2)).To(BeFalse())
+			Expect(tracker.count()).To(Equal(1))
+			muploads.AssertCalled(GinkgoT(), "Delete", "u2")
+		})
+
+		It("Should remove the chunks of the uploads it deletes", func() {
+			Expect(os.MkdirAll(app.MCDir.UploadDir("u2"), 0700)).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(app.MCDir.UploadDir("u2"), "1"), []byte("chunk"), 0600)).To(BeNil())
+			muploads.On("All").Return([]schema.Upload{{ID: "u2"}}, nil)
+			muploads.On("Delete", "u2").Return(nil)
+
+			_, err := restoreTracker(tracker, muploads, file.OS)
+			Expect(err).To(BeNil())
+			_, err = os.Stat(app.MCDir.UploadDir("u2"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
@@ -5,6 +5,7 @@ import (
 
 	"github.com/materials-commons/gohandy/file"
 	"github.com/materials-commons/mcstore/pkg/app"
+	"github.com/materials-commons/mcstore/pkg/audit"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 	"github.com/materials-commons/mcstore/server/mcstore/uploads/processor"
@@ -14,24 +15,41 @@ import (
 // when a file has been successfully uploaded and
 // reconstructed.
 type finisher struct {
-	files dai.Files
-	dirs  dai.Dirs
-	fops  file.Operations
+	files    dai.Files
+	dirs     dai.Dirs
+	fops     file.Operations
+	recorder audit.Recorder
 }
 
-// newFinisher creates a new finisher.
-func newFinisher(files dai.Files, dirs dai.Dirs) *finisher {
+// newFinisher creates a new finisher. Finished uploads are recorded in the
+// audit log using recorder.
+func newFinisher(files dai.Files, dirs dai.Dirs, recorder audit.Recorder) *finisher {
 	return &finisher{
-		files: files,
-		dirs:  dirs,
-		fops:  file.OS,
+		files:    files,
+		dirs:     dirs,
+		fops:     file.OS,
+		recorder: recorder,
 	}
 }
 
 // finish takes care of updating the file and directory pointers, determining
 // if a matching file (by checksum) has already been uploaded, and making the
-// file ready for the user to access.
+// file ready for the user to access. The result is recorded in the audit log.
 func (f *finisher) finish(req *UploadRequest, fileID, checksum string, upload *schema.Upload) error {
+	err := f.finishUpload(req, fileID, checksum, upload)
+
+	event := schema.NewAuditEvent(upload.Owner, audit.Upload, upload.Host)
+	event.ProjectID = upload.ProjectID
+	event.FileID = fileID
+	event.Result = audit.ResultOf(err)
+	event.Detail = upload.File.Name
+	f.recorder.Record(event)
+
+	return err
+}
+
+// finishUpload does the work for finish.
+func (f *finisher) finishUpload(req *UploadRequest, fileID, checksum string, upload *schema.Upload) error {
 	filePath := app.MCDir.FilePath(fileID)
 
 	parentID, err := f.parentID(upload.File.Name, upload.DirectoryID)
@@ -123,8 +141,7 @@ func (f *finisher) parentID(fileName, dirID string) (parentID string, err error)
 
 // processFile will process the file on disk.
 func (f *finisher) processFile(fileID string, mediatype schema.MediaType) {
-	fp := processor.New(fileID, mediatype)
-	go fp.Process()
+	go processor.Run(fileID, mediatype)
 }
 
 // fileInDir determines if this exact file has already been uploaded
@@ -12,11 +12,19 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// auditRecorder is an audit.Recorder that keeps the events it records.
+type auditRecorder struct {
+	events []schema.AuditEvent
+}
+
+func (r *auditRecorder) Record(event schema.AuditEvent) {
+	r.events = append(r.events, event)
+}
+
 var _ = Describe("FinishRequest", func() {
 	var (
 		mfiles  *dmocks.Files
 		mdirs   *dmocks.Dirs
-		mfiles2 *dmocks.Files2
 		fops    *file.MockOperations
 		f       *finisher
 		nilFile *schema.File = nil
@@ -27,12 +35,12 @@ var _ = Describe("FinishRequest", func() {
 	BeforeEach(func() {
 		mfiles = dmocks.NewMFiles()
 		mdirs = dmocks.NewMDirs()
-		mfiles2 = dmocks.NewMFiles2()
 		fops = file.MockOps()
 		f = &finisher{
-			files: mfiles,
-			dirs:  mdirs,
-			fops:  fops,
+			files:    mfiles,
+			dirs:     mdirs,
+			fops:     fops,
+			recorder: &auditRecorder{},
 		}
 	})
 
@@ -119,6 +127,18 @@ var _ = Describe("FinishRequest", func() {
 				Expect(err).To(Equal(app.ErrInvalid))
 			})
 
+			It("Should record the failed upload in the audit log", func() {
+				mfiles.On("ByPath", "file.name", "dir").Return(nilFile, app.ErrNotFound)
+				fops.On("Stat").SetError(nil).SetValue(file.MockFileInfo{MSize: 2})
+				freq.FlowTotalSize = 3
+				f.finish(req, "fileID", "checksum", upload)
+				events := f.recorder.(*auditRecorder).events
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Action).To(Equal("upload"))
+				Expect(events[0].FileID).To(Equal("fileID"))
+				Expect(events[0].Result).To(Equal("failure"))
+			})
+
 		})
 
 		Context("Connect to database", func() {
@@ -7,7 +7,6 @@ import (
 
 	"fmt"
 
-	r "github.com/dancannon/gorethink"
 	"github.com/materials-commons/gohandy/file"
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
@@ -53,15 +52,15 @@ type idService struct {
 	requestPath requestPath
 }
 
-// NewIDService creates a new idService that connects to the database using
-// the given session.
-func NewIDService(session *r.Session) *idService {
-	access := domain.NewAccess(dai.NewRProjects(session), dai.NewRFiles(session), dai.NewRUsers(session))
+// NewIDService creates a new idService that accesses the database through
+// the given store.
+func NewIDService(store dai.Store) *idService {
+	access := domain.NewAccess(store.Projects(), store.Files(), store.Users())
 	return &idService{
-		dirs:        dai.NewRDirs(session),
-		projects:    dai.NewRProjects(session),
-		uploads:     dai.NewRUploads(session),
-		files:       dai.NewRFiles(session),
+		dirs:        store.Dirs(),
+		projects:    store.Projects(),
+		uploads:     store.Uploads(),
+		files:       store.Files(),
 		access:      access,
 		fops:        file.OS,
 		tracker:     requestBlockTracker,
@@ -71,6 +70,11 @@ func NewIDService(session *r.Session) *idService {
 
 // ID will create a new Upload request or return an existing one.
 func (s *idService) ID(req IDRequest, proj *schema.Project, dir *schema.Directory) (*schema.Upload, error) {
+	if !uploadGate.enter() {
+		return nil, app.Errorf(app.ErrUnavailable, "server is shutting down")
+	}
+	defer uploadGate.leave()
+
 	upload, err := s.findExisting(req, proj, dir)
 	switch {
 	case err == app.ErrNotFound:
@@ -69,14 +69,14 @@ var _ = Describe("IDService", func() {
 			Context("Access allowed", func() {
 				It("Should allow access to admin user", func() {
 					req.User = "admin@mc.org"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).To(BeNil(), "Unexpected error: %s", err)
 					Expect(upload).NotTo(BeNil(), "upload is nil")
 				})
 
 				It("Should allow access to user in project", func() {
 					req.User = "test1@mc.org"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).To(BeNil(), "Unexpected error: %s", err)
 					Expect(upload).NotTo(BeNil())
 				})
@@ -85,7 +85,7 @@ var _ = Describe("IDService", func() {
 			Context("Access not allowed", func() {
 				It("Should not allow access for users not in project", func() {
 					req.User = "test2@mc.org"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).NotTo(BeNil())
 					Expect(err).To(Equal(app.ErrNoAccess))
 					Expect(upload).To(BeNil())
@@ -96,7 +96,7 @@ var _ = Describe("IDService", func() {
 				It("Should not allow access for non existent directory", func() {
 					req.User = "test@mc.org" // valid user
 					req.DirectoryID = "test@mc.org"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).NotTo(BeNil())
 					Expect(upload).To(BeNil())
 				})
@@ -119,21 +119,21 @@ var _ = Describe("IDService", func() {
 
 				It("Should fail on bad project id", func() {
 					req.ProjectID = "does-not-exist"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).To(HaveOccurred())
 					Expect(upload).To(BeNil())
 				})
 
 				It("Should fail on bad directory id", func() {
 					req.DirectoryID = "does-not-exist"
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).To(HaveOccurred())
 					Expect(upload).To(BeNil())
 				})
 
 				It("Should fail on directory id not in project", func() {
 					req.DirectoryID = "test2" // in different project (test2)
-					upload, err := s.ID(req)
+					upload, err := idFor(s, req)
 					Expect(err).To(HaveOccurred())
 					Expect(upload).To(BeNil())
 				})
@@ -152,13 +152,13 @@ var _ = Describe("IDService", func() {
 					ChunkSize:   10,
 					User:        "test@mc.org",
 				}
-				upload, err = s.ID(req)
+				upload, err = idFor(s, req)
 				Expect(err).To(BeNil())
 				Expect(upload.File.Blocks.Len()).To(BeNumerically("==", 10))
 
 				// Now submit again with exact same parameters. It should
 				// not create a new request
-				upload2, err := s.ID(req)
+				upload2, err := idFor(s, req)
 				Expect(err).To(BeNil())
 				Expect(upload2.ID).To(Equal(upload.ID))
 
@@ -185,7 +185,7 @@ var _ = Describe("IDService", func() {
 					ChunkSize:   10,
 				}
 
-				u, _ = s.ID(req)
+				u, _ = idFor(s, req)
 			})
 
 			AfterEach(func() {
@@ -235,7 +235,7 @@ var _ = Describe("IDService", func() {
 					ChunkSize:   10,
 				}
 
-				upload, err := s.ID(req)
+				upload, err := idFor(s, req)
 				Expect(err).To(BeNil(), "Unexpected error: %s", err)
 				Expect(upload).NotTo(BeNil())
 
@@ -261,7 +261,7 @@ var _ = Describe("IDService", func() {
 				ChunkSize:   10,
 			}
 
-			u, _ = s.ID(req)
+			u, _ = idFor(s, req)
 		})
 
 		AfterEach(func() {
@@ -273,26 +273,26 @@ var _ = Describe("IDService", func() {
 
 		Context("Access Permissions", func() {
 			It("Should fail on user not in project", func() {
-				uploads, err := s.UploadsForProject("test", "test2@mc.org")
+				uploads, err := uploadsFor(s, "test", "test2@mc.org")
 				Expect(err).NotTo(BeNil())
 				Expect(err).To(Equal(app.ErrNoAccess))
 				Expect(uploads).To(BeNil())
 			})
 
 			It("Should succeed on user in project", func() {
-				uploads, err := s.UploadsForProject("test", "test@mc.org")
+				uploads, err := uploadsFor(s, "test", "test@mc.org")
 				Expect(err).To(BeNil(), "Unexpected error: %s", err)
 				Expect(len(uploads)).To(BeNumerically(">", 0))
 			})
 
 			It("Should succeed on admin user", func() {
-				uploads, err := s.UploadsForProject("test", "admin@mc.org")
+				uploads, err := uploadsFor(s, "test", "admin@mc.org")
 				Expect(err).To(BeNil(), "Unexpected error: %s", err)
 				Expect(len(uploads)).To(BeNumerically(">", 0))
 			})
 
 			It("Should fail on non-existent user", func() {
-				uploads, err := s.UploadsForProject("test", "no-such-user@doesnot.exist.com")
+				uploads, err := uploadsFor(s, "test", "no-such-user@doesnot.exist.com")
 				Expect(err).NotTo(BeNil())
 				Expect(err).To(Equal(app.ErrNoAccess))
 				Expect(uploads).To(BeNil())
@@ -301,17 +301,48 @@ var _ = Describe("IDService", func() {
 
 		Context("Project ID Validation", func() {
 			It("Should fail on bad project", func() {
-				uploads, err := s.UploadsForProject("no-such-project", "test@mc.org")
+				uploads, err := uploadsFor(s, "no-such-project", "test@mc.org")
 				Expect(err).NotTo(BeNil())
 				Expect(err).To(Equal(app.ErrInvalid))
 				Expect(uploads).To(BeNil())
 			})
 
 			It("Should succeed on good project", func() {
-				uploads, err := s.UploadsForProject("test", "test@mc.org")
+				uploads, err := uploadsFor(s, "test", "test@mc.org")
 				Expect(err).To(BeNil(), "Unexpected error: %s", err)
 				Expect(len(uploads)).To(BeNumerically(">", 0))
 			})
 		})
 	})
 })
+
+// idFor looks up the project and directory for req, checking access the way
+// the upload route's filters do, and then asks s for an upload.
+func idFor(s *idService, req IDRequest) (*schema.Upload, error) {
+	project, err := s.projects.ByID(req.ProjectID)
+	switch {
+	case err != nil:
+		return nil, err
+	case !s.access.AllowedByOwner(project.ID, req.User):
+		return nil, app.ErrNoAccess
+	}
+
+	dir, err := s.dirs.ByID(req.DirectoryID)
+	switch {
+	case err != nil:
+		return nil, err
+	case !s.projects.HasDirectory(project.ID, dir.ID):
+		return nil, app.Errorf(app.ErrInvalid, "Unknown directory for project")
+	}
+
+	return s.ID(req, project, dir)
+}
+
+// uploadsFor checks that user can access the project, as the route's project
+// access filter does, and then lists the project's uploads.
+func uploadsFor(s *idService, projectID, user string) ([]schema.Upload, error) {
+	if !s.access.AllowedByOwner(projectID, user) {
+		return nil, app.ErrNoAccess
+	}
+	return s.UploadsForProject(projectID)
+}
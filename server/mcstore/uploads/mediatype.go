@@ -1,6 +1,7 @@
 package uploads
 
 import (
+	"fmt"
 	"mime"
 	"path/filepath"
 	"strings"
@@ -146,3 +147,17 @@ func getDescription(mtype string) string {
 	}
 	return description
 }
+
+// CheckMediaTypes verifies that libmagic is loaded and can identify a file
+// by its contents.
+func CheckMediaTypes() error {
+	mtype, err := magic.TypeByBuffer([]byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"))
+	switch {
+	case err != nil:
+		return err
+	case cleanMediaType(mtype) != "application/pdf":
+		return fmt.Errorf("libmagic identified a PDF as %s", mtype)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,11 @@
+package uploads
+
+import (
+	"github.com/materials-commons/mcstore/pkg/metrics"
+)
+
+func init() {
+	metrics.NewGaugeFunc("mcstore_uploads_active", "Uploads with blocks being tracked.", func() float64 {
+		return float64(requestBlockTracker.count())
+	})
+}
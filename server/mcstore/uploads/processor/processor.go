@@ -1,9 +1,18 @@
 package processor
 
 import (
+	"fmt"
+	"os/exec"
+	"strings"
+
 	"github.com/materials-commons/mcstore/pkg/db/schema"
+	"github.com/materials-commons/mcstore/pkg/metrics"
 )
 
+// jobs counts processed files by processor and result.
+var jobs = metrics.NewCounter("mcstore_processor_jobs_total",
+	"Files processed after upload, by processor and result (success or failure).", "processor", "result")
+
 // fileProcess defines an interface for processing different
 // types of files. Processing may include extracting data,
 // conversion of the file to a different type, or whatever
@@ -36,3 +45,49 @@ func isImageTypeNeedingConversion(mime string) bool {
 		return false
 	}
 }
+
+// Run processes a file using the processor for its media type and records
+// the outcome in the processor job metrics.
+func Run(fileID string, mediatype schema.MediaType) error {
+	p := New(fileID, mediatype)
+	err := p.Process()
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	jobs.Inc(kind(p), result)
+
+	return err
+}
+
+// kind returns the name of a processor for metrics.
+func kind(p Processor) string {
+	switch p.(type) {
+	case *imageFileProcessor:
+		return "image"
+	case *officeFileProcessor:
+		return "office"
+	default:
+		return "noop"
+	}
+}
+
+// commands are the external programs the processors run.
+var commands = []string{"convert", "libreoffice"}
+
+// CheckCommands verifies that the external programs used to convert files
+// can be found on the PATH.
+func CheckCommands() error {
+	var missing []string
+	for _, cmd := range commands {
+		if _, err := exec.LookPath(cmd); err != nil {
+			missing = append(missing, cmd)
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("commands not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
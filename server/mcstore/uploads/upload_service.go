@@ -7,10 +7,10 @@ import (
 
 	"crypto/md5"
 
-	r "github.com/dancannon/gorethink"
 	"github.com/materials-commons/gohandy/file"
 	"github.com/materials-commons/mcstore/pkg/app"
 	"github.com/materials-commons/mcstore/pkg/app/flow"
+	"github.com/materials-commons/mcstore/pkg/audit"
 	"github.com/materials-commons/mcstore/pkg/db/dai"
 	"github.com/materials-commons/mcstore/pkg/db/schema"
 )
@@ -43,25 +43,33 @@ type uploadService struct {
 	writer      requestWriter
 	requestPath requestPath
 	fops        file.Operations
+	recorder    audit.Recorder
 }
 
-// NewUploadService creates a new idService that connects to the database using
-// the given session.
-func NewUploadService(session *r.Session) *uploadService {
+// NewUploadService creates a new uploadService that accesses the database
+// through the given store.
+func NewUploadService(store dai.Store) *uploadService {
+	files := store.Files()
 	return &uploadService{
 		tracker:     requestBlockTracker,
-		files:       dai.NewRFiles(session),
-		uploads:     dai.NewRUploads(session),
-		dirs:        dai.NewRDirs(session),
+		files:       files,
+		uploads:     store.Uploads(),
+		dirs:        store.Dirs(),
 		writer:      &blockRequestWriter{},
 		requestPath: &mcdirRequestPath{},
 		fops:        file.OS,
+		recorder:    audit.NewRecorder(store.AuditEvents(), files),
 	}
 }
 
 // Upload performs uploading a block and constructing the file
 // after all blocks have been uploaded.
 func (s *uploadService) Upload(req *UploadRequest) (*UploadStatus, error) {
+	if !uploadGate.enter() {
+		return nil, app.Errorf(app.ErrUnavailable, "server is shutting down")
+	}
+	defer uploadGate.leave()
+
 	dir := s.requestPath.dir(req.Request)
 	id := req.UploadID()
 
@@ -143,7 +151,7 @@ func (s *uploadService) assemble(req *UploadRequest, dir string) (*schema.File,
 	}
 
 	// Finish updating the file state.
-	finisher := newFinisher(s.files, s.dirs)
+	finisher := newFinisher(s.files, s.dirs, s.recorder)
 	checksum := s.determineChecksum(req, upload)
 	if err := finisher.finish(req, file.ID, checksum, upload); err != nil {
 		app.Log.Errorf("Assembly failed for request %s, couldn't finish request: %s", req.FlowIdentifier, err)
@@ -47,6 +47,7 @@ var _ = Describe("UploadService", func() {
 			writer:      &blockRequestWriter{},
 			requestPath: &mcdirRequestPath{},
 			fops:        file.OS,
+			recorder:    &auditRecorder{},
 		}
 
 		f = &flow.Request{